@@ -47,9 +47,43 @@ func (attr *BasicAttr) GetValue() string {
 }
 
 func (attr *BasicAttr) SetValue(v string) {
+	old := attr.value
 	attr.value = v
+	if attr.parent != nil {
+		defer beginMutation(attr.parent)()
+		subtreeChanged(attr.parent)
+		if el, ok := attr.parent.(*BasicElement); ok {
+			attributeValueChanged(el, attr, old)
+		}
+	}
 }
 
+// setName changes the name, prefix, or namespace of the attribute.
+// The owner element must not have another attribute with the new
+// name.
+func (attr *BasicAttr) setName(name Name) {
+	if name == attr.name {
+		return
+	}
+	old := attr.name
+	el, _ := attr.parent.(*BasicElement)
+	if el == nil {
+		attr.name = name
+		return
+	}
+	defer beginMutation(el)()
+	delete(el.attributes.mapAttrs, old.Name)
+	el.attributes.mapAttrs[name.Name] = attr
+	attr.name = name
+	attributeRenamed(el, attr, old)
+}
+
+// Returns the value of the attribute
+func (attr *BasicAttr) GetTextContent() string { return attr.value }
+
+// Sets the value of the attribute
+func (attr *BasicAttr) SetTextContent(text string) { attr.SetValue(text) }
+
 func (attr *BasicAttr) CloneNode(bool) Node {
 	return attr.cloneNode(attr.ownerDocument, false)
 }
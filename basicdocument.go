@@ -3,6 +3,8 @@ package dom
 import (
 	"encoding/xml"
 	"fmt"
+	"strings"
+	"unicode"
 )
 
 // BasicDocument implements DOM document
@@ -10,6 +12,29 @@ import (
 // Implementation is guided by https://dom.spec.whatwg.org/
 type BasicDocument struct {
 	basicNode
+
+	// ID attribute options. If nil, DefaultIDOptions are used
+	idOptions *IDOptions
+	// ID index, built on the first GetElementById call
+	ids *idIndex
+	// The XML declaration, which is not a child of the document
+	xmlDecl xmlDeclaration
+	// Node iterators that are not detached
+	iterators iteratorSet
+	// Mutation observers that observe at least one node
+	observers []*BasicMutationObserver
+	// The number of active DOM methods that change the document
+	mutating int
+	// Set while mutation records are being delivered
+	delivering bool
+	// The innermost active transaction
+	tx *BasicTx
+	// Changes of the committed transactions that can be undone, and
+	// the undone transactions that can be redone
+	undoStack [][]change
+	redoStack [][]change
+	// Set while changes are undone or redone
+	replaying bool
 }
 
 var _ Document = &BasicDocument{}
@@ -104,6 +129,47 @@ func (doc *BasicDocument) CreateTextNode(text string) Text {
 	}
 }
 
+// Creates a CDATA section node.
+func (doc *BasicDocument) CreateCDATASection(text string) CDATASection {
+	return &BasicCDATASection{
+		basicChardata: basicChardata{
+			basicNode: basicNode{
+				ownerDocument: doc,
+			},
+			text: text,
+		},
+	}
+}
+
+// Creates a document type node. The node must be inserted into the
+// document before the document element.
+func (doc *BasicDocument) CreateDocumentType(name, publicID, systemID string) DocumentType {
+	if !isValidName(name) {
+		panic(ErrDOM{
+			Typ: INVALID_CHARACTER_ERR,
+			Msg: "Invalid document type name",
+			Op:  "CreateDocumentType",
+		})
+	}
+	return &BasicDocumentType{
+		basicNode: basicNode{
+			ownerDocument: doc,
+		},
+		name:     name,
+		publicID: publicID,
+		systemID: systemID,
+	}
+}
+
+// Creates a new empty DocumentFragment.
+func (doc *BasicDocument) CreateDocumentFragment() DocumentFragment {
+	return &BasicDocumentFragment{
+		basicNode: basicNode{
+			ownerDocument: doc,
+		},
+	}
+}
+
 // Creates a comment node.
 func (doc *BasicDocument) CreateComment(text string) Comment {
 	return &BasicComment{
@@ -141,6 +207,7 @@ func (doc *BasicDocument) CloneNode(deep bool) Node {
 
 func (doc *BasicDocument) cloneNode(_ Document, deep bool) Node {
 	ret := NewDocument().(*BasicDocument)
+	ret.xmlDecl = doc.xmlDecl
 	if deep {
 		for child := doc.GetFirstChild(); child != nil; child = child.GetNextSibling() {
 			newNode := child.cloneNode(ret, deep)
@@ -213,6 +280,102 @@ func (doc *BasicDocument) GetDocumentElement() Element {
 	return nil
 }
 
+// xmlDeclaration is the version, encoding, and standalone
+// declaration of the XML declaration. The document has no XML
+// declaration if version is empty.
+type xmlDeclaration struct {
+	version, encoding, standalone string
+	// The parsed contents of the declaration, written as is until
+	// the declaration is changed
+	text string
+}
+
+// parseXMLDeclaration returns the XML declaration given by the
+// contents of a <?xml ...?> processing instruction
+func parseXMLDeclaration(inst string) xmlDeclaration {
+	return xmlDeclaration{
+		version:    procInstParam(inst, "version"),
+		encoding:   procInstParam(inst, "encoding"),
+		standalone: procInstParam(inst, "standalone"),
+		text:       inst,
+	}
+}
+
+// String returns the contents of the XML declaration
+func (decl xmlDeclaration) String() string {
+	if len(decl.text) > 0 {
+		return decl.text
+	}
+	text := `version="` + decl.version + `"`
+	if len(decl.encoding) > 0 {
+		text += ` encoding="` + decl.encoding + `"`
+	}
+	if len(decl.standalone) > 0 {
+		text += ` standalone="` + decl.standalone + `"`
+	}
+	return text
+}
+
+// setXMLDeclaration sets the XML declaration. If version is empty,
+// the declaration is removed.
+func (doc *BasicDocument) setXMLDeclaration(decl xmlDeclaration) {
+	decl.text = ""
+	if len(decl.version) == 0 {
+		decl = xmlDeclaration{}
+	}
+	current := doc.xmlDecl
+	current.text = ""
+	if decl == current {
+		return
+	}
+	recordChange(doc, &xmlDeclChange{doc: doc, oldDecl: doc.xmlDecl, newDecl: decl})
+	doc.xmlDecl = decl
+}
+
+// Returns the version given in the XML declaration, or "" if the
+// document has no XML declaration.
+func (doc *BasicDocument) GetXMLVersion() string { return doc.xmlDecl.version }
+
+// Sets the version of the XML declaration. If the version is "", the
+// XML declaration is removed.
+func (doc *BasicDocument) SetXMLVersion(version string) {
+	decl := doc.xmlDecl
+	decl.version = version
+	doc.setXMLDeclaration(decl)
+}
+
+// Returns the encoding given in the XML declaration.
+func (doc *BasicDocument) GetXMLEncoding() string { return doc.xmlDecl.encoding }
+
+// Sets the encoding of the XML declaration. If the document does not
+// have an XML declaration, a version 1.0 declaration is added.
+func (doc *BasicDocument) SetXMLEncoding(encoding string) {
+	decl := doc.xmlDeclOrDefault()
+	decl.encoding = encoding
+	doc.setXMLDeclaration(decl)
+}
+
+// Returns the standalone declaration of the XML declaration, "yes",
+// "no", or "" if not declared.
+func (doc *BasicDocument) GetXMLStandalone() string { return doc.xmlDecl.standalone }
+
+// Sets the standalone declaration of the XML declaration, "yes",
+// "no", or "" to omit it. If the document does not have an XML
+// declaration, a version 1.0 declaration is added.
+func (doc *BasicDocument) SetXMLStandalone(standalone string) {
+	decl := doc.xmlDeclOrDefault()
+	decl.standalone = standalone
+	doc.setXMLDeclaration(decl)
+}
+
+func (doc *BasicDocument) xmlDeclOrDefault() xmlDeclaration {
+	decl := doc.xmlDecl
+	if len(decl.version) == 0 {
+		decl.version = "1.0"
+	}
+	return decl
+}
+
 // GetDocumentType returns the document type node
 func (doc *BasicDocument) GetDocumentType() DocumentType {
 	for ch := doc.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
@@ -223,42 +386,262 @@ func (doc *BasicDocument) GetDocumentType() DocumentType {
 	return nil
 }
 
+// Returns the first element in the document that matches the CSS
+// selector, or nil if there is none.
+func (doc *BasicDocument) QuerySelector(selector string) (Element, error) {
+	return querySelector(doc, selector)
+}
+
+// Returns a static NodeList containing all elements in the document
+// matching the CSS selector in document order.
+func (doc *BasicDocument) QuerySelectorAll(selector string) (NodeList, error) {
+	return querySelectorList(doc, selector)
+}
+
+// Returns the first element in document order whose ID is id, or
+// nil if there is none
+func (doc *BasicDocument) GetElementById(id string) Element {
+	if doc.ids == nil {
+		doc.buildIDIndex()
+	}
+	list := doc.ids.ids[id]
+	if len(list) == 0 {
+		return nil
+	}
+	first := list[0]
+	for _, el := range list[1:] {
+		if precedes(el, first) {
+			first = el
+		}
+	}
+	return first
+}
+
+// Resolves a whitespace separated list of IDREFS. Returns the
+// elements in the order of the references, or a NOT_FOUND error for
+// the first reference that cannot be resolved.
+func (doc *BasicDocument) ResolveIDRefs(refs string) ([]Element, error) {
+	ret := make([]Element, 0)
+	for _, ref := range strings.Fields(refs) {
+		el := doc.GetElementById(ref)
+		if el == nil {
+			return nil, ErrDOM{
+				Typ: NOT_FOUND_ERR,
+				Msg: "ID not found: " + ref,
+				Op:  "ResolveIDRefs",
+			}
+		}
+		ret = append(ret, el)
+	}
+	return ret, nil
+}
+
+// Returns the options that determine the ID attributes
+func (doc *BasicDocument) GetIDOptions() IDOptions {
+	if doc.idOptions == nil {
+		return DefaultIDOptions()
+	}
+	return *doc.idOptions
+}
+
+// Sets the options that determine the ID attributes
+func (doc *BasicDocument) SetIDOptions(options IDOptions) {
+	doc.idOptions = &options
+	doc.ids = nil
+}
+
+// Creates a NodeIterator over the subtree of root. Only the node
+// types selected by whatToShow are passed to the filter. The filter
+// may be nil. The iterator is updated when nodes are removed from the
+// document until it is detached. Before Go 1.24, the document keeps
+// the iterator until Detach is called, so Detach must be called when
+// the iterator is no longer used.
+func (doc *BasicDocument) CreateNodeIterator(root Node, whatToShow uint32, filter NodeFilter) NodeIterator {
+	return newNodeIterator(root, whatToShow, filter)
+}
+
+// Creates a TreeWalker over the subtree of root. Only the node types
+// selected by whatToShow are passed to the filter. The filter may be
+// nil.
+func (doc *BasicDocument) CreateTreeWalker(root Node, whatToShow uint32, filter NodeFilter) TreeWalker {
+	return newTreeWalker(root, whatToShow, filter)
+}
+
+// Creates a MutationObserver that reports the changes in the nodes
+// of this document. The callback is called when the DOM method that
+// changed the document returns. If callback is nil, the records are
+// collected until TakeRecords is called.
+func (doc *BasicDocument) CreateMutationObserver(callback MutationCallback) MutationObserver {
+	return newMutationObserver(doc, callback)
+}
+
+// Starts a transaction that records the changes to the document
+// until it is committed or rolled back. If there is an active
+// transaction, the new transaction is nested in it.
+func (doc *BasicDocument) Begin() Tx {
+	doc.tx = &BasicTx{doc: doc, parent: doc.tx}
+	return doc.tx
+}
+
+// Calls f so that its changes are applied all together or not at
+// all. If f returns an error or panics, the changes made by f are
+// rolled back, and the error is returned or the panic continues.
+// Otherwise, the changes become part of the active transaction, or if
+// there is none, they are handled like changes made outside a
+// transaction: they are not pushed to the undo stack, and they clear
+// the undo and redo stacks.
+func (doc *BasicDocument) Atomically(f func() error) (err error) {
+	tx := &BasicTx{doc: doc, parent: doc.tx, private: true}
+	doc.tx = tx
+	defer func() {
+		r := recover()
+		if r == nil && err == nil {
+			tx.Commit()
+			return
+		}
+		// Roll back the transactions f left open
+		for doc.tx != tx {
+			doc.tx.Rollback()
+		}
+		tx.Rollback()
+		if r != nil {
+			panic(r)
+		}
+	}()
+	return f()
+}
+
+// Undoes the changes of the last committed transaction. Returns false
+// if there is nothing to undo. Panics if there is an active
+// transaction.
+func (doc *BasicDocument) Undo() bool {
+	doc.checkNoTx("Undo")
+	if len(doc.undoStack) == 0 {
+		return false
+	}
+	changes := doc.undoStack[len(doc.undoStack)-1]
+	doc.undoStack = doc.undoStack[:len(doc.undoStack)-1]
+	doc.replay(func() { undoChanges(changes) })
+	doc.redoStack = append(doc.redoStack, changes)
+	return true
+}
+
+// Redoes the changes of the last undone transaction. Returns false if
+// there is nothing to redo. Panics if there is an active transaction.
+func (doc *BasicDocument) Redo() bool {
+	doc.checkNoTx("Redo")
+	if len(doc.redoStack) == 0 {
+		return false
+	}
+	changes := doc.redoStack[len(doc.redoStack)-1]
+	doc.redoStack = doc.redoStack[:len(doc.redoStack)-1]
+	doc.replay(func() { redoChanges(changes) })
+	doc.undoStack = append(doc.undoStack, changes)
+	return true
+}
+
+// Returns true if there is a transaction to undo
+func (doc *BasicDocument) CanUndo() bool { return len(doc.undoStack) > 0 }
+
+// Returns true if there is a transaction to redo
+func (doc *BasicDocument) CanRedo() bool { return len(doc.redoStack) > 0 }
+
+func (doc *BasicDocument) buildIDIndex() {
+	index := &idIndex{
+		options: doc.GetIDOptions(),
+		ids:     make(map[string][]*BasicElement),
+	}
+	if dt, ok := doc.GetDocumentType().(*BasicDocumentType); ok && dt != nil && index.options.DTD {
+		index.dtdIDs = dtdIDAttributes(dt.defn)
+	}
+	index.update(doc, true)
+	doc.ids = index
+}
+
+// Returns a live HTMLCollection of the descendant elements with the
+// given qualified name. The name "*" matches all elements.
+func (doc *BasicDocument) GetElementsByTagName(name string) HTMLCollection {
+	return getElementsByTagName(doc, name)
+}
+
+// Returns a live HTMLCollection of the descendant elements with the
+// given namespace URI and local name.
+func (doc *BasicDocument) GetElementsByTagNameNS(uri, local string) HTMLCollection {
+	return getElementsByTagNameNS(doc, uri, local)
+}
+
+// Returns a live HTMLCollection of the descendant elements that have
+// all the given class names.
+func (doc *BasicDocument) GetElementsByClassName(names string) HTMLCollection {
+	return getElementsByClassName(doc, names)
+}
+
+// Inserts newNode before referenceNode, and returns the added
+// child. Panics if the insertion is not valid.
 func (doc *BasicDocument) InsertBefore(newNode, referenceNode Node) Node {
-	panic(ErrHierarchyRequest("InsertBefore", "Cannot insert before a document"))
+	return mustNode(doc.InsertBeforeE(newNode, referenceNode))
+}
+
+// Inserts newNode before referenceNode, and returns the added
+// child. Returns an error if the insertion is not valid.
+func (doc *BasicDocument) InsertBeforeE(newNode, referenceNode Node) (Node, error) {
+	return insertBeforeE(doc, newNode, referenceNode, "InsertBefore")
 }
 
 // Append newNode as a child of node
 func (doc *BasicDocument) AppendChild(newNode Node) Node {
-	if err := validatePreInsertion(newNode, doc, nil, "AppendChild"); err != nil {
-		panic(err)
-	}
-	return insertBefore(doc, newNode, nil)
+	return mustNode(doc.AppendChildE(newNode))
+}
+
+// Append newNode as a child of node. Returns an error if the
+// insertion is not valid.
+func (doc *BasicDocument) AppendChildE(newNode Node) (Node, error) {
+	return insertBeforeE(doc, newNode, nil, "AppendChild")
 }
 
 // Remove child from node
 func (doc *BasicDocument) RemoveChild(child Node) {
-	if child.GetParentNode() != doc {
-		panic(ErrDOM{
-			Typ: NOT_FOUND_ERR,
-			Msg: "Wrong parent",
-			Op:  "RemoveChild",
-		})
+	if err := doc.RemoveChildE(child); err != nil {
+		panic(err)
 	}
-	detachChild(doc, child)
+}
+
+// Remove child from node. Returns an error if child is not a child
+// of node.
+func (doc *BasicDocument) RemoveChildE(child Node) error {
+	return removeChildE(doc, child)
+}
+
+// Replaces oldChild with newChild, and returns oldChild.
+func (doc *BasicDocument) ReplaceChild(newChild, oldChild Node) Node {
+	return mustNode(doc.ReplaceChildE(newChild, oldChild))
+}
+
+// Replaces oldChild with newChild, and returns oldChild. Returns an
+// error if the replacement is not valid.
+func (doc *BasicDocument) ReplaceChildE(newChild, oldChild Node) (Node, error) {
+	return replaceChildE(doc, newChild, oldChild)
 }
 
 // Adopt node from an external document.
 func (doc *BasicDocument) AdoptNode(node Node) Node {
+	return mustNode(doc.AdoptNodeE(node))
+}
+
+// Adopt node from an external document. Returns an error if node is
+// a document.
+func (doc *BasicDocument) AdoptNodeE(node Node) (Node, error) {
 	if _, ok := node.(Document); ok {
-		panic(ErrDOM{
+		return nil, ErrDOM{
 			Typ: NOT_SUPPORTED_ERR,
 			Msg: "Cannot adopt a document",
 			Op:  "AdoptNode",
-		})
+		}
 	}
 	if node.GetOwnerDocument() == doc {
-		return node
+		return node, nil
 	}
+	defer beginMutation(node)()
 	if node.GetParentNode() != nil {
 		detachChild(node.GetParentNode(), node)
 	}
@@ -273,11 +656,50 @@ func (doc *BasicDocument) AdoptNode(node Node) Node {
 		}
 	}
 	setOwner(node)
-	return node
+	return node, nil
+}
+
+// Changes the prefix, namespace, and local name of an element or
+// attribute of this document, and returns the node. The node is
+// renamed in place. Returns an error if the node cannot be renamed,
+// or if the owner element of an attribute already has an attribute
+// with the new name.
+func (doc *BasicDocument) RenameNode(node Node, prefix, ns, local string) (Node, error) {
+	if node.GetOwnerDocument() != doc {
+		return nil, ErrDOM{
+			Typ: WRONG_DOCUMENT_ERR,
+			Msg: "Node does not belong to the document",
+			Op:  "RenameNode",
+		}
+	}
+	name := Name{Name: xml.Name{Space: ns, Local: local}, Prefix: prefix}
+	switch n := node.(type) {
+	case *BasicElement:
+		n.setName(name)
+	case *BasicAttr:
+		if owner := n.GetOwnerElement(); owner != nil && n.name.Name != name.Name {
+			if owner.GetAttributeNodeNS(ns, local) != nil {
+				return nil, ErrDOM{
+					Typ: INUSE_ATTRIBUTE_ERR,
+					Msg: "Element already has an attribute with the same name",
+					Op:  "RenameNode",
+				}
+			}
+		}
+		n.setName(name)
+	default:
+		return nil, ErrDOM{
+			Typ: NOT_SUPPORTED_ERR,
+			Msg: "Only elements and attributes can be renamed",
+			Op:  "RenameNode",
+		}
+	}
+	return node, nil
 }
 
 // NormalizeNamespaces assigns missing namespace prefixes
 func (doc *BasicDocument) NormalizeNamespaces() error {
+	defer beginMutation(doc)()
 
 	type dictionary struct {
 		parent        *dictionary
@@ -336,7 +758,9 @@ func (doc *BasicDocument) NormalizeNamespaces() error {
 				}
 			} else {
 				// There is namespace but no prefix
-				bel.name.Prefix = uniquePrefix(newDict, bel, bel.name.Space)
+				name := bel.name
+				name.Prefix = uniquePrefix(newDict, bel, bel.name.Space)
+				bel.setName(name)
 				bel.SetAttributeNS(xmlnsPrefix, xmlnsURL, bel.name.Prefix, bel.name.Space)
 			}
 		} else if len(bel.name.Prefix) > 0 {
@@ -348,7 +772,9 @@ func (doc *BasicDocument) NormalizeNamespaces() error {
 					Msg: fmt.Sprintf("No namespace for prefix %s", bel.name.Prefix),
 				}
 			}
-			bel.name.Space = ns
+			name := bel.name
+			name.Space = ns
+			bel.setName(name)
 		}
 
 		for child := el.GetFirstElementChild(); child != nil; child = child.GetNextElementSibling() {
@@ -360,3 +786,29 @@ func (doc *BasicDocument) NormalizeNamespaces() error {
 
 	return normalize(root, nil)
 }
+
+// procInstParam returns the value of a pseudo-attribute of a
+// processing instruction, such as the version of an XML declaration
+func procInstParam(inst, name string) string {
+	s := inst
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		eq := strings.IndexByte(s, '=')
+		if eq == -1 {
+			return ""
+		}
+		param := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeftFunc(s[eq+1:], unicode.IsSpace)
+		if len(s) == 0 || (s[0] != '"' && s[0] != '\'') {
+			return ""
+		}
+		end := strings.IndexByte(s[1:], s[0])
+		if end == -1 {
+			return ""
+		}
+		if param == name {
+			return s[1 : end+1]
+		}
+		s = s[end+2:]
+	}
+}
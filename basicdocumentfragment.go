@@ -0,0 +1,131 @@
+package dom
+
+// BasicDocumentFragment is a lightweight document object with no
+// parent. When a fragment is inserted into a node, its children are
+// moved to the node, leaving the fragment empty.
+type BasicDocumentFragment struct {
+	basicNode
+}
+
+var _ DocumentFragment = &BasicDocumentFragment{}
+
+// Returns "#document-fragment"
+func (frag *BasicDocumentFragment) GetNodeName() string { return "#document-fragment" }
+
+// Returns DOCUMENT_FRAGMENT_NODE
+func (frag *BasicDocumentFragment) GetNodeType() NodeType { return DOCUMENT_FRAGMENT_NODE }
+
+// Returns a boolean value indicating whether or not the two nodes are
+// the same (that is, they reference the same object).
+func (frag *BasicDocumentFragment) IsSameNode(node Node) bool { return node == frag }
+
+// Returns a boolean value which indicates whether or not two nodes
+// are of the same type and all their defining data points match.
+func (frag *BasicDocumentFragment) IsEqualNode(node Node) bool {
+	if _, ok := node.(*BasicDocumentFragment); !ok {
+		return false
+	}
+	return isEqualNode(frag, node)
+}
+
+// A fragment has no parent, so there are no namespaces in scope
+func (frag *BasicDocumentFragment) IsDefaultNamespace(uri string) bool { return false }
+
+// A fragment has no parent, so there are no namespaces in scope
+func (frag *BasicDocumentFragment) LookupPrefix(uri string) string { return "" }
+
+// A fragment has no parent, so there are no namespaces in scope
+func (frag *BasicDocumentFragment) LookupNamespaceURI(prefix string) string { return "" }
+
+// Returns the concatenation of the text of all descendant text and
+// CDATA section nodes
+func (frag *BasicDocumentFragment) GetTextContent() string {
+	return getTextContent(frag)
+}
+
+// Replaces all children with a single text node, or removes them if
+// text is ""
+func (frag *BasicDocumentFragment) SetTextContent(text string) {
+	setTextContent(frag, text)
+}
+
+func (frag *BasicDocumentFragment) GetFirstElementChild() Element {
+	return nextElementSibling(frag.GetFirstChild())
+}
+
+func (frag *BasicDocumentFragment) GetLastElementChild() Element {
+	return prevElementSibling(frag.GetLastChild())
+}
+
+// Returns the first descendant element that matches the CSS
+// selector, or nil if there is none.
+func (frag *BasicDocumentFragment) QuerySelector(selector string) (Element, error) {
+	return querySelector(frag, selector)
+}
+
+// Returns a static NodeList containing all descendant elements
+// matching the CSS selector in document order.
+func (frag *BasicDocumentFragment) QuerySelectorAll(selector string) (NodeList, error) {
+	return querySelectorList(frag, selector)
+}
+
+// Inserts newNode before referenceNode, and returns the added
+// child. Panics if the insertion is not valid.
+func (frag *BasicDocumentFragment) InsertBefore(newNode, referenceNode Node) Node {
+	return mustNode(frag.InsertBeforeE(newNode, referenceNode))
+}
+
+// Inserts newNode before referenceNode, and returns the added
+// child. Returns an error if the insertion is not valid.
+func (frag *BasicDocumentFragment) InsertBeforeE(newNode, referenceNode Node) (Node, error) {
+	return insertBeforeE(frag, newNode, referenceNode, "InsertBefore")
+}
+
+// Append newNode as a child of node
+func (frag *BasicDocumentFragment) AppendChild(newNode Node) Node {
+	return mustNode(frag.AppendChildE(newNode))
+}
+
+// Append newNode as a child of node. Returns an error if the
+// insertion is not valid.
+func (frag *BasicDocumentFragment) AppendChildE(newNode Node) (Node, error) {
+	return insertBeforeE(frag, newNode, nil, "AppendChild")
+}
+
+// Remove child from node
+func (frag *BasicDocumentFragment) RemoveChild(child Node) {
+	if err := frag.RemoveChildE(child); err != nil {
+		panic(err)
+	}
+}
+
+// Remove child from node. Returns an error if child is not a child
+// of node.
+func (frag *BasicDocumentFragment) RemoveChildE(child Node) error {
+	return removeChildE(frag, child)
+}
+
+// Replaces oldChild with newChild, and returns oldChild.
+func (frag *BasicDocumentFragment) ReplaceChild(newChild, oldChild Node) Node {
+	return mustNode(frag.ReplaceChildE(newChild, oldChild))
+}
+
+// Replaces oldChild with newChild, and returns oldChild. Returns an
+// error if the replacement is not valid.
+func (frag *BasicDocumentFragment) ReplaceChildE(newChild, oldChild Node) (Node, error) {
+	return replaceChildE(frag, newChild, oldChild)
+}
+
+func (frag *BasicDocumentFragment) CloneNode(deep bool) Node {
+	return frag.cloneNode(frag.ownerDocument, deep)
+}
+
+func (frag *BasicDocumentFragment) cloneNode(owner Document, deep bool) Node {
+	ret := owner.CreateDocumentFragment().(*BasicDocumentFragment)
+	if deep {
+		for child := frag.GetFirstChild(); child != nil; child = child.GetNextSibling() {
+			ret.AppendChild(child.cloneNode(owner, deep))
+		}
+	}
+	return ret
+}
@@ -0,0 +1,95 @@
+package dom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestDocumentFragment(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<root><a/><d/></root>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	frag := doc.CreateDocumentFragment()
+	frag.AppendChild(doc.CreateElement("b"))
+	frag.AppendChild(doc.CreateTextNode("text"))
+	frag.AppendChild(doc.CreateElement("c"))
+
+	clone := frag.CloneNode(true)
+	if !clone.IsEqualNode(frag) {
+		t.Errorf("Clone is not equal")
+	}
+
+	buf := bytes.Buffer{}
+	if err := Encode(frag, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "<b></b>text<c></c>" {
+		t.Errorf("Wrong encoding: %s", buf.String())
+	}
+
+	d := root.GetLastChild()
+	ret := root.InsertBefore(frag, d)
+	if ret != frag {
+		t.Errorf("Wrong return value")
+	}
+	if frag.HasChildNodes() {
+		t.Errorf("Fragment is not empty")
+	}
+	buf.Reset()
+	if err := Encode(doc, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "<root><a></a><b></b>text<c></c><d></d></root>" {
+		t.Errorf("Wrong result: %s", buf.String())
+	}
+	for ch := root.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+		if ch.GetParentNode() != root {
+			t.Errorf("Wrong parent for %s", ch.GetNodeName())
+		}
+	}
+	if clone.IsEqualNode(frag) {
+		t.Errorf("Empty fragment equals clone")
+	}
+	if root.GetChildNodes().GetLength() != 5 {
+		t.Errorf("Wrong child count")
+	}
+}
+
+func TestDocumentFragmentUnderDocument(t *testing.T) {
+	expectPanic := func(name string, f func()) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("%s: expected panic", name)
+			} else if e, ok := r.(ErrDOM); !ok || e.Typ != HIERARCHY_REQUEST_ERR {
+				t.Errorf("%s: unexpected panic %v", name, r)
+			}
+		}()
+		f()
+	}
+
+	doc := NewDocument()
+	frag := doc.CreateDocumentFragment()
+	frag.AppendChild(doc.CreateElement("a"))
+	frag.AppendChild(doc.CreateElement("b"))
+	expectPanic("two elements", func() { doc.AppendChild(frag) })
+
+	frag = doc.CreateDocumentFragment()
+	frag.AppendChild(doc.CreateTextNode("x"))
+	expectPanic("text", func() { doc.AppendChild(frag) })
+
+	frag = doc.CreateDocumentFragment()
+	frag.AppendChild(doc.CreateComment("c"))
+	frag.AppendChild(doc.CreateElement("root"))
+	doc.AppendChild(frag)
+	if doc.GetDocumentElement() == nil || doc.GetDocumentElement().GetTagName() != "root" {
+		t.Errorf("No document element")
+	}
+
+	frag = doc.CreateDocumentFragment()
+	frag.AppendChild(doc.CreateElement("second"))
+	expectPanic("second root", func() { doc.AppendChild(frag) })
+}
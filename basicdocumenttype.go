@@ -1,6 +1,7 @@
 package dom
 
 import (
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -15,67 +16,136 @@ type BasicDocumentType struct {
 	defn string
 }
 
+var _ DocumentType = &BasicDocumentType{}
+
+// Returns the name of the document type
+func (dt *BasicDocumentType) GetNodeName() string { return dt.name }
+
 func (dt *BasicDocumentType) GetNodeType() NodeType { return DOCUMENT_TYPE_NODE }
 func (dt *BasicDocumentType) GetName() string       { return dt.name }
 func (dt *BasicDocumentType) GetPublicID() string   { return dt.publicID }
 func (dt *BasicDocumentType) GetSystemID() string   { return dt.systemID }
 func (dt *BasicDocumentType) GetDefinition() string { return dt.defn }
 
+// Returns a boolean value indicating whether or not the two nodes are
+// the same (that is, they reference the same object).
+func (dt *BasicDocumentType) IsSameNode(node Node) bool { return node == dt }
+
+// Returns a boolean value which indicates whether or not two nodes
+// are of the same type and all their defining data points match.
+func (dt *BasicDocumentType) IsEqualNode(node Node) bool {
+	n, ok := node.(*BasicDocumentType)
+	if !ok {
+		return false
+	}
+	return n.name == dt.name && n.publicID == dt.publicID && n.systemID == dt.systemID && n.defn == dt.defn
+}
+
+func (dt *BasicDocumentType) CloneNode(deep bool) Node {
+	return dt.cloneNode(dt.ownerDocument, deep)
+}
+
+func (dt *BasicDocumentType) cloneNode(owner Document, deep bool) Node {
+	ret := *dt
+	ret.basicNode = basicNode{}
+	if owner != nil {
+		ret.ownerDocument = owner.(*BasicDocument)
+	}
+	return &ret
+}
+
 // ParseDocumentType parses a document type starting with <!DOCTYPE ...
+// The leading '<' and the trailing '>' are not included in content,
+// and the '!' is optional.
 // If the input is not a doctype, returns nil,false,nil
 func ParseDocumentType(content []byte) (DocumentType, bool, error) {
-	nextToken := func(in []byte) (string, []byte) {
-		// Skip leading spaces.
-		start := 0
-		for width := 0; start < len(in); start += width {
-			var r rune
-			r, width = utf8.DecodeRune(in[start:])
-			if !unicode.IsSpace(r) {
-				break
+	in := string(content)
+	skipSpace := func() {
+		in = strings.TrimLeftFunc(in, unicode.IsSpace)
+	}
+	nextToken := func() string {
+		skipSpace()
+		for i, r := range in {
+			if unicode.IsSpace(r) || r == '[' || r == '>' || r == '"' || r == '\'' {
+				tok := in[:i]
+				in = in[i:]
+				return tok
 			}
 		}
-		// Scan the token
-		for width, i := 0, start; i < len(in); i += width {
-			var r rune
-			r, width = utf8.DecodeRune(in[i:])
-			if r == '[' || r == ']' || r == '(' || r == ')' || r == '<' || r == '>' || r == ',' || r == '|' {
-				if i == start {
-					return string(in[start : i+width]), in[i+width:]
-				}
-				return string(in[start:i]), in[i:]
-			}
-			if unicode.IsSpace(r) {
-				return string(in[start:i]), in[i:]
-			}
+		tok := in
+		in = ""
+		return tok
+	}
+	literal := func() (string, bool) {
+		skipSpace()
+		if len(in) == 0 || (in[0] != '"' && in[0] != '\'') {
+			return "", false
 		}
-		// We are at the end
-		return string(in), in[len(in):]
+		end := strings.IndexByte(in[1:], in[0])
+		if end == -1 {
+			return "", false
+		}
+		ret := in[1 : end+1]
+		in = in[end+2:]
+		return ret, true
+	}
+	syntaxError := ErrDOM{
+		Typ: SYNTAX_ERR,
+		Msg: "Document type syntax error",
 	}
 
-	tok, rem := nextToken(content)
-	if tok != "!DOCTYPE" {
+	tok := nextToken()
+	if tok != "!DOCTYPE" && tok != "DOCTYPE" {
 		return nil, false, nil
 	}
 	ret := &BasicDocumentType{}
-	tok, rem = nextToken(rem)
-	ret.name = tok
-
-	tok, rem = nextToken(rem)
-	if tok == "PUBLIC" {
-		tok, rem = nextToken(rem)
-		ret.publicID = tok
-		tok, rem = nextToken(rem)
-		ret.systemID = tok
-	} else if tok == "SYSTEM" {
-		tok, rem = nextToken(rem)
-		ret.systemID = tok
-	} else if tok == "[" {
-		ret.defn = "[" + string(rem)
-	} else {
-		return nil, true, ErrDOM{
-			Typ: SYNTAX_ERR,
-			Msg: "Document type syntax error",
+	ret.name = nextToken()
+	if len(ret.name) == 0 {
+		return nil, true, syntaxError
+	}
+	switch nextToken() {
+	case "PUBLIC":
+		var ok bool
+		if ret.publicID, ok = literal(); !ok {
+			return nil, true, syntaxError
+		}
+		if ret.systemID, ok = literal(); !ok {
+			return nil, true, syntaxError
+		}
+	case "SYSTEM":
+		var ok bool
+		if ret.systemID, ok = literal(); !ok {
+			return nil, true, syntaxError
+		}
+	case "":
+	default:
+		return nil, true, syntaxError
+	}
+	skipSpace()
+	if strings.HasPrefix(in, "[") {
+		ret.defn = strings.TrimRightFunc(in, unicode.IsSpace)
+		if !strings.HasSuffix(ret.defn, "]") {
+			return nil, true, syntaxError
 		}
+	} else if len(in) > 0 {
+		return nil, true, syntaxError
 	}
 	return ret, true, nil
 }
+
+// isValidName returns true if the name can be written without
+// escaping
+func isValidName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i, r := range name {
+		if r == utf8.RuneError || unicode.IsSpace(r) || strings.ContainsRune("<>&\"'[]", r) {
+			return false
+		}
+		if i == 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -50,6 +50,16 @@ func (el *BasicElement) GetQName() Name {
 	return el.name
 }
 
+// setName changes the name, prefix, or namespace of the element
+func (el *BasicElement) setName(name Name) {
+	if name == el.name {
+		return
+	}
+	old := el.name
+	el.name = name
+	elementRenamed(el, old)
+}
+
 // Returns a String with the name of the tag for the given element.
 func (el *BasicElement) GetTagName() string {
 	return el.name.QName()
@@ -121,7 +131,10 @@ func (el *BasicElement) LookupPrefix(uri string) string {
 
 // Accepts a prefix and returns the namespace URI associated with it
 // on the given node if found (and "" if not). Supplying "" for
-// the prefix will return the default namespace.
+// the prefix will return the default namespace. The default namespace
+// is declared by an xmlns attribute with or without the xmlns
+// namespace, so declarations parsed or created with SetAttribute are
+// found even when the element itself has a prefix.
 func (el *BasicElement) LookupNamespaceURI(prefix string) string {
 	switch prefix {
 	case xmlPrefix:
@@ -129,18 +142,8 @@ func (el *BasicElement) LookupNamespaceURI(prefix string) string {
 	case xmlnsPrefix:
 		return xmlnsURL
 	}
-	if len(el.name.Space) > 0 && el.name.Prefix == prefix {
-		return el.name.Space
-	}
-	for _, attr := range el.attributes.attrs {
-		if attr.name.Space == xmlnsURL {
-			if attr.name.Prefix == xmlnsPrefix && attr.name.Local == prefix {
-				return attr.value
-			}
-			if len(prefix) == 0 && len(attr.name.Prefix) == 0 && attr.name.Local == xmlnsPrefix {
-				return attr.value
-			}
-		}
+	if uri, ok := el.lookupLocalNamespaceURI(prefix); ok {
+		return uri
 	}
 	if el.parent == nil {
 		return ""
@@ -151,6 +154,24 @@ func (el *BasicElement) LookupNamespaceURI(prefix string) string {
 	return el.parent.LookupNamespaceURI(prefix)
 }
 
+// lookupLocalNamespaceURI returns the namespace URI associated with
+// prefix by the element itself, without looking at its ancestors
+func (el *BasicElement) lookupLocalNamespaceURI(prefix string) (string, bool) {
+	if len(el.name.Space) > 0 && el.name.Prefix == prefix {
+		return el.name.Space, true
+	}
+	for _, attr := range el.attributes.attrs {
+		if attr.name.Space == xmlnsURL && attr.name.Prefix == xmlnsPrefix && attr.name.Local == prefix {
+			return attr.value, true
+		}
+		// The default namespace declaration may not have a namespace
+		if len(prefix) == 0 && len(attr.name.Prefix) == 0 && attr.name.Local == xmlnsPrefix && (len(attr.name.Space) == 0 || attr.name.Space == xmlnsURL) {
+			return attr.value, true
+		}
+	}
+	return "", false
+}
+
 func nextElementSibling(start Node) Element {
 	if start == nil {
 		return nil
@@ -175,11 +196,6 @@ func prevElementSibling(start Node) Element {
 	return nil
 }
 
-// Removes the element from the children list of its parent.
-func (el *BasicElement) Remove() {
-	detachChild(el.GetParentNode(), el)
-}
-
 func (el *BasicElement) GetAttributes() NamedNodeMap {
 	return &BasicNamedNodeMap{
 		owner: el,
@@ -299,34 +315,114 @@ func (el *BasicElement) SetAttributeNodeNS(attr Attr) {
 	el.attributes.setNamedItemNS(el, attr)
 }
 
+// Returns the first descendant element that matches the CSS
+// selector, or nil if there is none.
+func (el *BasicElement) QuerySelector(selector string) (Element, error) {
+	return querySelector(el, selector)
+}
+
+// Returns a static NodeList containing all descendant elements
+// matching the CSS selector in document order.
+func (el *BasicElement) QuerySelectorAll(selector string) (NodeList, error) {
+	return querySelectorList(el, selector)
+}
+
+// Returns a live HTMLCollection of the descendant elements with the
+// given qualified name. The name "*" matches all elements.
+func (el *BasicElement) GetElementsByTagName(name string) HTMLCollection {
+	return getElementsByTagName(el, name)
+}
+
+// Returns a live HTMLCollection of the descendant elements with the
+// given namespace URI and local name.
+func (el *BasicElement) GetElementsByTagNameNS(uri, local string) HTMLCollection {
+	return getElementsByTagNameNS(el, uri, local)
+}
+
+// Returns a live HTMLCollection of the descendant elements that have
+// all the given class names.
+func (el *BasicElement) GetElementsByClassName(names string) HTMLCollection {
+	return getElementsByClassName(el, names)
+}
+
+// Returns the concatenation of the text of all descendant text and
+// CDATA section nodes
+func (el *BasicElement) GetTextContent() string {
+	return getTextContent(el)
+}
+
+// Replaces all children with a single text node, or removes them if
+// text is ""
+func (el *BasicElement) SetTextContent(text string) {
+	setTextContent(el, text)
+}
+
+// Returns the serialization of the children of the element.
+// Namespaces in scope for the element are not declared again.
+func (el *BasicElement) GetInnerXML() (string, error) {
+	return getInnerXML(el)
+}
+
+// Parses the XML content and replaces the children of the element
+// with the parsed nodes
+func (el *BasicElement) SetInnerXML(content string) error {
+	return setInnerXML(el, content)
+}
+
+// Returns the serialization of the element and its descendants
+func (el *BasicElement) GetOuterXML() (string, error) {
+	return getOuterXML(el)
+}
+
+// Inserts newNode before referenceNode, and returns the added
+// child. Panics if the insertion is not valid.
 func (el *BasicElement) InsertBefore(newNode, referenceNode Node) Node {
-	if err := validatePreInsertion(newNode, el, referenceNode, "InsertBefore"); err != nil {
-		panic(err)
-	}
-	return insertBefore(el, newNode, referenceNode)
+	return mustNode(el.InsertBeforeE(newNode, referenceNode))
+}
+
+// Inserts newNode before referenceNode, and returns the added
+// child. Returns an error if the insertion is not valid.
+func (el *BasicElement) InsertBeforeE(newNode, referenceNode Node) (Node, error) {
+	return insertBeforeE(el, newNode, referenceNode, "InsertBefore")
 }
 
 // Append newNode as a child of node
 func (el *BasicElement) AppendChild(newNode Node) Node {
-	if err := validatePreInsertion(newNode, el, nil, "AppendChild"); err != nil {
-		panic(err)
-	}
-	return insertBefore(el, newNode, nil)
+	return mustNode(el.AppendChildE(newNode))
+}
+
+// Append newNode as a child of node. Returns an error if the
+// insertion is not valid.
+func (el *BasicElement) AppendChildE(newNode Node) (Node, error) {
+	return insertBeforeE(el, newNode, nil, "AppendChild")
 }
 
 // Remove child from node
 func (el *BasicElement) RemoveChild(child Node) {
-	if child.GetParentNode() != el {
-		panic(ErrDOM{
-			Typ: NOT_FOUND_ERR,
-			Msg: "Wrong parent",
-			Op:  "RemoveChild",
-		})
+	if err := el.RemoveChildE(child); err != nil {
+		panic(err)
 	}
-	detachChild(el, child)
+}
+
+// Remove child from node. Returns an error if child is not a child
+// of node.
+func (el *BasicElement) RemoveChildE(child Node) error {
+	return removeChildE(el, child)
+}
+
+// Replaces oldChild with newChild, and returns oldChild.
+func (el *BasicElement) ReplaceChild(newChild, oldChild Node) Node {
+	return mustNode(el.ReplaceChildE(newChild, oldChild))
+}
+
+// Replaces oldChild with newChild, and returns oldChild. Returns an
+// error if the replacement is not valid.
+func (el *BasicElement) ReplaceChildE(newChild, oldChild Node) (Node, error) {
+	return replaceChildE(el, newChild, oldChild)
 }
 
 func (el *BasicElement) Normalize() {
+	defer beginMutation(el)()
 	// Combine all text nodes
 	for childNode := el.GetFirstChild(); childNode != nil; {
 		childNode.Normalize()
@@ -347,7 +443,7 @@ func (el *BasicElement) Normalize() {
 				childNode = nextNode
 				break
 			}
-			text.text += nextText.text
+			text.SetValue(text.text + nextText.text)
 			nextNode = nextText.GetNextSibling()
 			detachChild(el, nextText)
 		}
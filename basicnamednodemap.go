@@ -46,16 +46,29 @@ func (m *basicNamedNodeMap) RemoveNamedItemNS(uri string, name string) {
 }
 
 func (m *basicNamedNodeMap) removeAttr(attr Attr) {
-	qname := attr.(*BasicAttr).name.Name
+	ba := attr.(*BasicAttr)
+	owner := ba.parent
+	qname := ba.name.Name
 	delete(m.mapAttrs, qname)
 	w := 0
+	index := -1
 	for k := range m.attrs {
 		if m.attrs[k] != attr {
 			m.attrs[w] = m.attrs[k]
 			w++
+		} else {
+			index = k
 		}
 	}
 	m.attrs = m.attrs[:w]
+	ba.parent = nil
+	if owner != nil {
+		defer beginMutation(owner)()
+		subtreeChanged(owner)
+		if el, ok := owner.(*BasicElement); ok {
+			attributeRemoved(el, ba, index)
+		}
+	}
 }
 
 // Replaces, or adds, the Attr identified in the map by the given namespace and related local name.
@@ -67,28 +80,46 @@ func (m *basicNamedNodeMap) setNamedItemNS(owner Node, attr Attr) {
 			Op:  "SetNamedItem",
 		})
 	}
-	if m.mapAttrs == nil {
-		m.mapAttrs = make(map[xml.Name]*BasicAttr)
-	}
 	ba := attr.(*BasicAttr)
-	qname := ba.name.Name
-	existing := m.mapAttrs[qname]
-	if existing != nil {
-		if existing == attr {
-			return
-		}
-		m.mapAttrs[qname] = ba
-		for k := range m.attrs {
-			if m.attrs[k] == existing {
-				m.attrs[k] = ba
-				ba.parent = owner
-				return
+	existing := m.mapAttrs[ba.name.Name]
+	if existing == nil {
+		m.insertAttr(owner, ba, len(m.attrs))
+		return
+	}
+	if existing == ba {
+		return
+	}
+	for k := range m.attrs {
+		if m.attrs[k] == existing {
+			m.mapAttrs[ba.name.Name] = ba
+			m.attrs[k] = ba
+			existing.parent = nil
+			ba.parent = owner
+			defer beginMutation(owner)()
+			subtreeChanged(owner)
+			if el, ok := owner.(*BasicElement); ok {
+				attributeSet(el, ba, existing, k)
 			}
+			return
 		}
 	}
-	m.mapAttrs[qname] = ba
-	m.attrs = append(m.attrs, ba)
+}
+
+// insertAttr adds attr to the attribute list at index
+func (m *basicNamedNodeMap) insertAttr(owner Node, ba *BasicAttr, index int) {
+	if m.mapAttrs == nil {
+		m.mapAttrs = make(map[xml.Name]*BasicAttr)
+	}
+	m.mapAttrs[ba.name.Name] = ba
+	m.attrs = append(m.attrs, nil)
+	copy(m.attrs[index+1:], m.attrs[index:])
+	m.attrs[index] = ba
 	ba.parent = owner
+	defer beginMutation(owner)()
+	subtreeChanged(owner)
+	if el, ok := owner.(*BasicElement); ok {
+		attributeSet(el, ba, nil, index)
+	}
 }
 
 type BasicNamedNodeMap struct {
@@ -5,6 +5,9 @@ type basicNode struct {
 	tnode
 
 	ownerDocument *BasicDocument
+
+	// Position in the parsed input, if tracked
+	position *Position
 }
 
 func (node *basicNode) setOwner(doc *BasicDocument) {
@@ -25,6 +28,18 @@ func getRootNode(node Node) Node {
 
 func (node *basicNode) treeNode() *tnode { return &node.tnode }
 
+// Returns the position of the node in the parsed input
+func (node *basicNode) GetPosition() (Position, bool) {
+	if node.position == nil {
+		return Position{}, false
+	}
+	return *node.position, true
+}
+
+func (node *basicNode) setPosition(pos *Position) {
+	node.position = pos
+}
+
 func (node *basicNode) GetRootNode() Node {
 	return getRootNode(node)
 }
@@ -124,6 +139,12 @@ func (node *basicNode) GetChildNodes() NodeList {
 
 func (node *basicNode) IsSameNode(Node) bool { return false }
 
+// Returns "" for documents and document types
+func (node *basicNode) GetTextContent() string { return "" }
+
+// Does nothing for documents and document types
+func (node *basicNode) SetTextContent(string) {}
+
 func (node *basicNode) InsertBefore(newNode, referenceNode Node) Node { return nil }
 
 // Append newNode as a child of node
@@ -132,6 +153,33 @@ func (node *basicNode) AppendChild(newNode Node) Node { return nil }
 // Remove child from node
 func (node *basicNode) RemoveChild(child Node) {}
 
+// Replace oldChild with newChild
+func (node *basicNode) ReplaceChild(newChild, oldChild Node) Node { return nil }
+
+// Returns an error, because the node cannot have children
+func (node *basicNode) InsertBeforeE(newNode, referenceNode Node) (Node, error) {
+	return nil, ErrHierarchyRequest("InsertBefore", "Node cannot have children")
+}
+
+// Returns an error, because the node cannot have children
+func (node *basicNode) AppendChildE(newNode Node) (Node, error) {
+	return nil, ErrHierarchyRequest("AppendChild", "Node cannot have children")
+}
+
+// Returns an error, because the node cannot have children
+func (node *basicNode) RemoveChildE(child Node) error {
+	return ErrDOM{
+		Typ: NOT_FOUND_ERR,
+		Msg: "Wrong parent",
+		Op:  "RemoveChild",
+	}
+}
+
+// Returns an error, because the node cannot have children
+func (node *basicNode) ReplaceChildE(newChild, oldChild Node) (Node, error) {
+	return nil, ErrHierarchyRequest("ReplaceChild", "Node cannot have children")
+}
+
 // Returns a string  containing the prefix for a given namespace
 // URI, if present, and "" if not. When multiple prefixes are
 // possible, the result is implementation-dependent.
@@ -166,10 +214,24 @@ func (node *basicNode) Contains(nd Node) bool {
 }
 
 // Inserts a Node before the reference node as a child of a
-// specified parent node. Returns the added child
+// specified parent node. Returns the added child. If newNode is a
+// DocumentFragment, all its children are moved, and the empty
+// fragment is returned.
 func insertBefore(parent, newNode, referenceNode Node) Node {
-	detachChild(parent, newNode)
-	insertChildBefore(parent, newNode, nil)
+	if referenceNode == newNode {
+		referenceNode = newNode.GetNextSibling()
+	}
+	if frag, ok := newNode.(*BasicDocumentFragment); ok {
+		for child := frag.GetFirstChild(); child != nil; child = frag.GetFirstChild() {
+			detachChild(frag, child)
+			insertChildBefore(parent, child, referenceNode)
+		}
+		return frag
+	}
+	if oldParent := newNode.GetParentNode(); oldParent != nil {
+		detachChild(oldParent, newNode)
+	}
+	insertChildBefore(parent, newNode, referenceNode)
 	return newNode
 }
 
@@ -208,17 +270,50 @@ func hasChildOfType(parent Node, typ NodeType) bool {
 }
 
 func validatePreInsertion(node, parent, beforeChild Node, op string) error {
+	return validateInsertion(node, parent, beforeChild, false, op)
+}
+
+// validateReplacement ensures replace validity of node in place of
+// child in parent
+func validateReplacement(node, parent, child Node, op string) error {
+	return validateInsertion(node, parent, child, true, op)
+}
+
+// validateInsertion checks if node can be inserted into parent
+// before child. If replacing is true, child is going to be replaced
+// by node.
+func validateInsertion(node, parent, child Node, replacing bool, op string) error {
 	if node.GetOwnerDocument() != parent.GetOwnerDocument() {
-		panic("Child node does not belong to the target document. Use AdoptNode to adopt it.")
+		return ErrDOM{
+			Typ: WRONG_DOCUMENT_ERR,
+			Msg: "Child node does not belong to the target document. Use AdoptNode to adopt it.",
+			Op:  op,
+		}
 	}
-	if beforeChild != nil && beforeChild.GetOwnerDocument() != parent.GetOwnerDocument() {
-		panic("ReferenceNode node does not belong to the target document.")
+	if child != nil && child.GetOwnerDocument() != parent.GetOwnerDocument() {
+		return ErrDOM{
+			Typ: WRONG_DOCUMENT_ERR,
+			Msg: "Reference node does not belong to the target document.",
+			Op:  op,
+		}
 	}
 	parentType := parent.GetNodeType()
 	nodeType := node.GetNodeType()
 	if parentType != DOCUMENT_NODE && parentType != DOCUMENT_FRAGMENT_NODE && parentType != ELEMENT_NODE {
 		return ErrHierarchyRequest(op, "Parent is not a DOCUMENT, DOCUMENT_FRAGMENT, or ELEMENT")
 	}
+	for trc := parent; trc != nil; trc = trc.GetParentNode() {
+		if trc == node {
+			return ErrHierarchyRequest(op, "Node is an ancestor of the parent")
+		}
+	}
+	if (child != nil || replacing) && (child == nil || child.GetParentNode() != parent) {
+		return ErrDOM{
+			Typ: NOT_FOUND_ERR,
+			Msg: "Reference node not found in parent",
+			Op:  op,
+		}
+	}
 	if nodeType != DOCUMENT_FRAGMENT_NODE &&
 		nodeType != DOCUMENT_TYPE_NODE &&
 		nodeType != ELEMENT_NODE &&
@@ -228,75 +323,139 @@ func validatePreInsertion(node, parent, beforeChild Node, op string) error {
 		nodeType != COMMENT_NODE {
 		return ErrHierarchyRequest(op, "Invalid node type")
 	}
-	if beforeChild != nil && beforeChild.GetParentNode() != node.GetParentNode() {
-		return ErrDOM{
-			Typ: NOT_FOUND_ERR,
-			Msg: "Reference node not found in parent",
-			Op:  op,
-		}
-	}
 	if nodeType == DOCUMENT_TYPE_NODE && parentType != DOCUMENT_NODE {
 		return ErrHierarchyRequest(op, "Document type node must be under document node")
 	}
-	if parentType == DOCUMENT_NODE {
-		if beforeChild != nil && beforeChild.GetNodeType() == TEXT_NODE {
-			return ErrHierarchyRequest(op, "Text reference node under document node is not allowed")
-		}
-		switch nodeType {
-		case TEXT_NODE:
-			return ErrHierarchyRequest(op, "Text under document node is not allowed")
-		case DOCUMENT_FRAGMENT_NODE:
-			nElementChild := 0
-			for child := node.GetFirstChild(); child != nil; child = child.GetNextSibling() {
-				switch child.GetNodeType() {
-				case ELEMENT_NODE:
-					nElementChild++
-					if nElementChild > 1 {
-						return ErrHierarchyRequest(op, "Attempting to add multiple elements to a document node")
-					}
-				case TEXT_NODE:
-					return ErrHierarchyRequest(op, "Attempting to add text to a document node")
-				}
-			}
-			if nElementChild == 1 {
-				if hasChildOfType(parent, ELEMENT_NODE) && beforeChild != nil && beforeChild.GetNodeType() == DOCUMENT_TYPE_NODE {
-					return ErrHierarchyRequest(op, "Invalid fragment")
-				}
-				if beforeChild != nil {
-					for x := beforeChild; x != nil; x = x.GetNextSibling() {
-						if x.GetNodeType() == DOCUMENT_TYPE_NODE {
-							return ErrHierarchyRequest(op, "Invalid fragment")
-						}
-					}
-				}
+	if parentType != DOCUMENT_NODE {
+		return nil
+	}
+
+	// hasOther returns true if parent has a child of the given type
+	// other than the child being replaced
+	hasOther := func(typ NodeType) bool {
+		for ch := parent.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			if ch.GetNodeType() == typ && !(replacing && ch == child) {
+				return true
 			}
-		case ELEMENT_NODE:
-			if hasChildOfType(parent, ELEMENT_NODE) && beforeChild != nil && beforeChild.GetNodeType() == DOCUMENT_TYPE_NODE {
-				return ErrHierarchyRequest(op, "Invalid element")
+		}
+		return false
+	}
+	doctypeFollowing := func() bool {
+		if child == nil {
+			return false
+		}
+		for x := child.GetNextSibling(); x != nil; x = x.GetNextSibling() {
+			if x.GetNodeType() == DOCUMENT_TYPE_NODE {
+				return true
 			}
-			if beforeChild != nil {
-				for x := beforeChild; x != nil; x = x.GetNextSibling() {
-					if x.GetNodeType() == DOCUMENT_TYPE_NODE {
-						return ErrHierarchyRequest(op, "Invalid fragment")
-					}
-				}
+		}
+		return false
+	}
+	elementPreceding := func() bool {
+		if child == nil {
+			return false
+		}
+		for x := child.GetPreviousSibling(); x != nil; x = x.GetPreviousSibling() {
+			if x.GetNodeType() == ELEMENT_NODE {
+				return true
 			}
-		case DOCUMENT_TYPE_NODE:
-			if hasChildOfType(parent, DOCUMENT_TYPE_NODE) && beforeChild != nil {
-				for x := beforeChild; x != nil; x.GetPreviousSibling() {
-					if x.GetNodeType() == ELEMENT_NODE {
-						return ErrHierarchyRequest(op, "Invalid document type node")
-					}
-				}
-			} else {
-				// beforeChild is nil
-				for child := parent.GetFirstChild(); child != nil; child = child.GetNextSibling() {
-					if child.GetNodeType() == ELEMENT_NODE {
-						return ErrHierarchyRequest(op, "Invalid document type node")
-					}
+		}
+		return false
+	}
+	// validElement checks if an element can be placed before or in
+	// place of child
+	validElement := func() bool {
+		if hasOther(ELEMENT_NODE) || doctypeFollowing() {
+			return false
+		}
+		return replacing || child == nil || child.GetNodeType() != DOCUMENT_TYPE_NODE
+	}
+
+	switch nodeType {
+	case TEXT_NODE, CDATA_SECTION_NODE:
+		return ErrHierarchyRequest(op, "Text under document node is not allowed")
+	case DOCUMENT_FRAGMENT_NODE:
+		nElementChild := 0
+		for ch := node.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			switch ch.GetNodeType() {
+			case ELEMENT_NODE:
+				nElementChild++
+				if nElementChild > 1 {
+					return ErrHierarchyRequest(op, "Attempting to add multiple elements to a document node")
 				}
+			case TEXT_NODE, CDATA_SECTION_NODE:
+				return ErrHierarchyRequest(op, "Attempting to add text to a document node")
 			}
 		}
+		if nElementChild == 1 && !validElement() {
+			return ErrHierarchyRequest(op, "Invalid fragment")
+		}
+	case ELEMENT_NODE:
+		if !validElement() {
+			return ErrHierarchyRequest(op, "Invalid element")
+		}
+	case DOCUMENT_TYPE_NODE:
+		if hasOther(DOCUMENT_TYPE_NODE) || elementPreceding() {
+			return ErrHierarchyRequest(op, "Invalid document type node")
+		}
+		if !replacing && child == nil && hasChildOfType(parent, ELEMENT_NODE) {
+			return ErrHierarchyRequest(op, "Invalid document type node")
+		}
 	}
 	return nil
 }
+
+// insertBeforeE validates and inserts newNode into parent before
+// referenceNode
+func insertBeforeE(parent, newNode, referenceNode Node, op string) (Node, error) {
+	defer beginMutation(parent)()
+	if err := validatePreInsertion(newNode, parent, referenceNode, op); err != nil {
+		return nil, err
+	}
+	return insertBefore(parent, newNode, referenceNode), nil
+}
+
+// removeChildE removes child from parent if it is a child of parent
+func removeChildE(parent, child Node) error {
+	defer beginMutation(parent)()
+	if child.GetParentNode() != parent {
+		return ErrDOM{
+			Typ: NOT_FOUND_ERR,
+			Msg: "Wrong parent",
+			Op:  "RemoveChild",
+		}
+	}
+	detachChild(parent, child)
+	return nil
+}
+
+// replaceChildE validates and replaces oldChild of parent with
+// newChild
+func replaceChildE(parent, newChild, oldChild Node) (Node, error) {
+	defer beginMutation(parent)()
+	if err := validateReplacement(newChild, parent, oldChild, "ReplaceChild"); err != nil {
+		return nil, err
+	}
+	return replaceChild(parent, newChild, oldChild), nil
+}
+
+// mustNode returns node, or panics if err is not nil
+func mustNode(node Node, err error) Node {
+	if err != nil {
+		panic(err)
+	}
+	return node
+}
+
+// replaceChild replaces oldChild of parent with newChild, and
+// returns oldChild. If newChild is a DocumentFragment, all its
+// children are moved into the place of oldChild.
+func replaceChild(parent, newChild, oldChild Node) Node {
+	if newChild == oldChild {
+		return oldChild
+	}
+	reference := oldChild.GetNextSibling()
+	detachChild(parent, oldChild)
+	insertBefore(parent, newChild, reference)
+	return oldChild
+}
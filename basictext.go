@@ -5,21 +5,51 @@ type basicChardata struct {
 	text string
 }
 
-func (cd *basicChardata) GetValue() string     { return cd.text }
-func (cd *basicChardata) SetValue(text string) { cd.text = text }
+func (cd *basicChardata) GetValue() string { return cd.text }
 
-func (cd *basicChardata) AppendChild(Node) Node {
-	panic(ErrHierarchyRequest("AppendChild", "Invalid node type: character data node"))
+// setValue sets the value of node, whose character data is cd
+func (cd *basicChardata) setValue(node Node, text string) {
+	defer beginMutation(node)()
+	old := cd.text
+	cd.text = text
+	characterDataChanged(node, old)
+}
+
+// Returns the value of the node
+func (cd *basicChardata) GetTextContent() string { return cd.text }
+
+func (cd *basicChardata) AppendChild(newNode Node) Node {
+	return mustNode(cd.AppendChildE(newNode))
+}
+
+func (cd *basicChardata) AppendChildE(Node) (Node, error) {
+	return nil, ErrHierarchyRequest("AppendChild", "Invalid node type: character data node")
 }
 
 func (cd *basicChardata) HasChildNodes() bool { return false }
 
 func (cd *basicChardata) InsertBefore(newNode, referenceNode Node) Node {
-	panic(ErrHierarchyRequest("InsertBefore", "Invalid node type: character data node"))
+	return mustNode(cd.InsertBeforeE(newNode, referenceNode))
+}
+
+func (cd *basicChardata) InsertBeforeE(newNode, referenceNode Node) (Node, error) {
+	return nil, ErrHierarchyRequest("InsertBefore", "Invalid node type: character data node")
+}
+
+func (cs *basicChardata) RemoveChild(child Node) {
+	panic(cs.RemoveChildE(child))
+}
+
+func (cs *basicChardata) RemoveChildE(Node) error {
+	return ErrHierarchyRequest("RemoveChild", "Invalid node type: character data node")
 }
 
-func (cs *basicChardata) RemoveChild(Node) {
-	panic(ErrHierarchyRequest("RemoveChild", "Invalid node type: character data node"))
+func (cs *basicChardata) ReplaceChild(newChild, oldChild Node) Node {
+	return mustNode(cs.ReplaceChildE(newChild, oldChild))
+}
+
+func (cs *basicChardata) ReplaceChildE(newChild, oldChild Node) (Node, error) {
+	return nil, ErrHierarchyRequest("ReplaceChild", "Invalid node type: character data node")
 }
 
 func (cs *basicChardata) Normalize() {}
@@ -48,6 +78,12 @@ func (cd *BasicText) IsEqualNode(node Node) bool {
 // the same (that is, they reference the same object).
 func (cd *BasicText) IsSameNode(node Node) bool { return node == cd }
 
+// Sets the value of the node
+func (cd *BasicText) SetValue(text string) { cd.setValue(cd, text) }
+
+// Sets the value of the node
+func (cd *BasicText) SetTextContent(text string) { cd.setValue(cd, text) }
+
 func (cd *BasicText) CloneNode(deep bool) Node {
 	return cd.cloneNode(cd.ownerDocument, deep)
 }
@@ -56,6 +92,44 @@ func (cd *BasicText) cloneNode(owner Document, deep bool) Node {
 	return owner.CreateTextNode(cd.text)
 }
 
+type BasicCDATASection struct {
+	basicChardata
+}
+
+var _ CDATASection = &BasicCDATASection{}
+
+// Returns "#cdata-section"
+func (cd *BasicCDATASection) GetNodeName() string { return "#cdata-section" }
+
+// Returns CDATA_SECTION_NODE
+func (cd *BasicCDATASection) GetNodeType() NodeType { return CDATA_SECTION_NODE }
+
+func (cd *BasicCDATASection) IsEqualNode(node Node) bool {
+	n, ok := node.(*BasicCDATASection)
+	if !ok {
+		return false
+	}
+	return n.text == cd.text
+}
+
+// Returns a boolean value indicating whether or not the two nodes are
+// the same (that is, they reference the same object).
+func (cd *BasicCDATASection) IsSameNode(node Node) bool { return node == cd }
+
+// Sets the value of the node
+func (cd *BasicCDATASection) SetValue(text string) { cd.setValue(cd, text) }
+
+// Sets the value of the node
+func (cd *BasicCDATASection) SetTextContent(text string) { cd.setValue(cd, text) }
+
+func (cd *BasicCDATASection) CloneNode(deep bool) Node {
+	return cd.cloneNode(cd.ownerDocument, deep)
+}
+
+func (cd *BasicCDATASection) cloneNode(owner Document, deep bool) Node {
+	return owner.CreateCDATASection(cd.text)
+}
+
 type BasicComment struct {
 	basicChardata
 }
@@ -80,6 +154,12 @@ func (cd *BasicComment) IsEqualNode(node Node) bool {
 // the same (that is, they reference the same object).
 func (cd *BasicComment) IsSameNode(node Node) bool { return node == cd }
 
+// Sets the value of the node
+func (cd *BasicComment) SetValue(text string) { cd.setValue(cd, text) }
+
+// Sets the value of the node
+func (cd *BasicComment) SetTextContent(text string) { cd.setValue(cd, text) }
+
 func (cd *BasicComment) CloneNode(deep bool) Node {
 	return cd.cloneNode(cd.ownerDocument, deep)
 }
@@ -113,9 +193,22 @@ func (p *BasicProcessingInstruction) IsEqualNode(node Node) bool {
 // the same (that is, they reference the same object).
 func (p *BasicProcessingInstruction) IsSameNode(node Node) bool { return node == p }
 
+// Sets the value of the node
+func (p *BasicProcessingInstruction) SetValue(text string) { p.setValue(p, text) }
+
+// Sets the value of the node
+func (p *BasicProcessingInstruction) SetTextContent(text string) { p.setValue(p, text) }
+
 func (p *BasicProcessingInstruction) GetTarget() string { return p.target }
 
-func (p *BasicProcessingInstruction) SetTarget(t string) { p.target = t }
+func (p *BasicProcessingInstruction) SetTarget(t string) {
+	if t == p.target {
+		return
+	}
+	old := p.target
+	p.target = t
+	targetChanged(p, old)
+}
 
 func (p *BasicProcessingInstruction) CloneNode(deep bool) Node {
 	return p.cloneNode(p.ownerDocument, deep)
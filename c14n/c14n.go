@@ -0,0 +1,541 @@
+// Package c14n implements W3C Canonical XML 1.0, Canonical XML 1.1,
+// and Exclusive XML Canonicalization 1.0 over dom.Node trees.
+//
+// The DOM does not have namespace nodes. When a document subset is
+// canonicalized, the namespace nodes of an element are in the
+// subset if the element is in the subset.
+package c14n
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/bserdar/go-dom"
+)
+
+const (
+	xmlURL   = "http://www.w3.org/XML/1998/namespace"
+	xmlnsURL = "http://www.w3.org/2000/xmlns"
+)
+
+// Algorithm identifiers
+const (
+	C14N10                    = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	C14N10WithComments        = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315#WithComments"
+	C14N11                    = "http://www.w3.org/2006/12/xml-c14n11"
+	C14N11WithComments        = "http://www.w3.org/2006/12/xml-c14n11#WithComments"
+	ExclusiveC14N             = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	ExclusiveC14NWithComments = "http://www.w3.org/2001/10/xml-exc-c14n#WithComments"
+)
+
+// Method is the canonicalization method
+type Method int
+
+const (
+	// Canonical XML 1.0
+	Inclusive10 Method = iota
+	// Canonical XML 1.1
+	Inclusive11
+	// Exclusive XML Canonicalization 1.0
+	Exclusive
+)
+
+// Options determine the canonicalization algorithm and the node
+// subset to canonicalize
+type Options struct {
+	Method Method
+
+	// If set, comments are included in the output
+	WithComments bool
+
+	// InclusivePrefixes is the InclusiveNamespaces PrefixList of
+	// exclusive canonicalization. These prefixes are treated as in
+	// inclusive canonicalization. Use "#default" for the default
+	// namespace.
+	InclusivePrefixes []string
+
+	// If non-nil, Filter selects the document subset to
+	// canonicalize. A node is in the subset if it is under the
+	// canonicalized node and Filter returns true for it. Filter is
+	// called for elements, attributes, text, comment, and processing
+	// instruction nodes.
+	Filter func(dom.Node) bool
+}
+
+// OptionsForAlgorithm returns the options for the given algorithm
+// identifier
+func OptionsForAlgorithm(uri string) (Options, error) {
+	switch uri {
+	case C14N10:
+		return Options{Method: Inclusive10}, nil
+	case C14N10WithComments:
+		return Options{Method: Inclusive10, WithComments: true}, nil
+	case C14N11:
+		return Options{Method: Inclusive11}, nil
+	case C14N11WithComments:
+		return Options{Method: Inclusive11, WithComments: true}, nil
+	case ExclusiveC14N:
+		return Options{Method: Exclusive}, nil
+	case ExclusiveC14NWithComments:
+		return Options{Method: Exclusive, WithComments: true}, nil
+	}
+	return Options{}, dom.ErrDOM{
+		Typ: dom.NOT_SUPPORTED_ERR,
+		Msg: fmt.Sprintf("Unsupported canonicalization algorithm %s", uri),
+		Op:  "Canonicalize",
+	}
+}
+
+// Algorithm returns the algorithm identifier for the options
+func (o Options) Algorithm() string {
+	switch o.Method {
+	case Inclusive11:
+		if o.WithComments {
+			return C14N11WithComments
+		}
+		return C14N11
+	case Exclusive:
+		if o.WithComments {
+			return ExclusiveC14NWithComments
+		}
+		return ExclusiveC14N
+	}
+	if o.WithComments {
+		return C14N10WithComments
+	}
+	return C14N10
+}
+
+// Canonicalize returns the canonical form of the node
+func Canonicalize(node dom.Node, options Options) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := Encode(node, &buf, options); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Encode writes the canonical form of the node. If node is a
+// document, the whole document is canonicalized. If node is an
+// element, the subtree rooted at the element is canonicalized,
+// including the namespaces inherited from its ancestors.
+func Encode(node dom.Node, writer io.Writer, options Options) error {
+	out := bufio.NewWriter(writer)
+	c := canonicalizer{
+		out:     out,
+		options: options,
+		apex:    node,
+	}
+	if options.Method == Exclusive {
+		c.inclusive = make(map[string]struct{})
+		for _, p := range options.InclusivePrefixes {
+			if p == "#default" {
+				p = ""
+			}
+			c.inclusive[p] = struct{}{}
+		}
+	}
+	inScope := map[string]string{}
+	if parent := node.GetParentNode(); parent != nil {
+		inScope = inScopeNamespaces(parent)
+	}
+	if err := c.process(node, inScope, map[string]string{}, false); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+type canonicalizer struct {
+	out       *bufio.Writer
+	options   Options
+	apex      dom.Node
+	inclusive map[string]struct{}
+}
+
+func (c *canonicalizer) inSet(node dom.Node) bool {
+	return c.options.Filter == nil || c.options.Filter(node)
+}
+
+// isNamespaceDecl returns true if attr is an xmlns or xmlns:prefix
+// attribute
+func isNamespaceDecl(attr dom.Attr) bool {
+	name := attr.GetQName()
+	if name.Prefix == "xmlns" || name.Space == xmlnsURL {
+		return true
+	}
+	return len(name.Prefix) == 0 && name.Local == "xmlns"
+}
+
+// attrNamespace returns the namespace URI of an attribute
+func attrNamespace(attr dom.Attr) string {
+	name := attr.GetQName()
+	if name.Prefix == "xml" {
+		return xmlURL
+	}
+	return name.Space
+}
+
+// addNamespaces adds the namespaces declared or used by el to
+// inScope
+func addNamespaces(el dom.Element, inScope map[string]string) {
+	attrs := el.GetAttributes()
+	for i := 0; i < attrs.GetLength(); i++ {
+		attr := attrs.Item(i)
+		if !isNamespaceDecl(attr) {
+			continue
+		}
+		name := attr.GetQName()
+		if len(name.Prefix) == 0 {
+			inScope[""] = attr.GetValue()
+		} else {
+			inScope[name.Local] = attr.GetValue()
+		}
+	}
+	// Namespaces used by element and attribute names that are not
+	// declared
+	name := el.GetQName()
+	if inScope[name.Prefix] != name.Space && (len(name.Prefix) == 0 || len(name.Space) > 0) {
+		inScope[name.Prefix] = name.Space
+	}
+	for i := 0; i < attrs.GetLength(); i++ {
+		attr := attrs.Item(i)
+		name := attr.GetQName()
+		if isNamespaceDecl(attr) || len(name.Prefix) == 0 || name.Prefix == "xml" || len(name.Space) == 0 {
+			continue
+		}
+		if inScope[name.Prefix] != name.Space {
+			inScope[name.Prefix] = name.Space
+		}
+	}
+}
+
+// inScopeNamespaces returns the namespaces in scope for a node
+func inScopeNamespaces(node dom.Node) map[string]string {
+	ancestors := make([]dom.Element, 0)
+	for trc := node; trc != nil; trc = trc.GetParentNode() {
+		if el, ok := trc.(dom.Element); ok {
+			ancestors = append(ancestors, el)
+		}
+	}
+	ret := map[string]string{}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		addNamespaces(ancestors[i], ret)
+	}
+	return ret
+}
+
+func copyMap(m map[string]string) map[string]string {
+	ret := make(map[string]string, len(m))
+	for k, v := range m {
+		ret[k] = v
+	}
+	return ret
+}
+
+// isDocumentLevel returns true if the node is a child of a document
+func isDocumentLevel(node dom.Node) bool {
+	return node.GetParentNode() != nil && node.GetParentNode().GetNodeType() == dom.DOCUMENT_NODE
+}
+
+// writeDocumentLevel writes a comment or processing instruction
+// separated from the document element by a newline if it is a child
+// of the document
+func (c *canonicalizer) writeDocumentLevel(node dom.Node, write func()) {
+	if !isDocumentLevel(node) {
+		write()
+		return
+	}
+	afterRoot := false
+	for s := node.GetPreviousSibling(); s != nil; s = s.GetPreviousSibling() {
+		if s.GetNodeType() == dom.ELEMENT_NODE {
+			afterRoot = true
+			break
+		}
+	}
+	if afterRoot {
+		c.out.WriteByte('\n')
+	}
+	write()
+	if !afterRoot {
+		c.out.WriteByte('\n')
+	}
+}
+
+// process writes the canonical form of node. inScope contains the
+// namespaces in scope for the parent of node, and rendered contains
+// the namespace declarations in effect in the output for the
+// nearest output ancestor. parentInSet is true if the parent of the
+// node is in the output.
+func (c *canonicalizer) process(node dom.Node, inScope, rendered map[string]string, parentInSet bool) error {
+	switch node.GetNodeType() {
+	case dom.DOCUMENT_NODE, dom.DOCUMENT_FRAGMENT_NODE:
+		for ch := node.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			if err := c.process(ch, inScope, rendered, false); err != nil {
+				return err
+			}
+		}
+	case dom.ELEMENT_NODE:
+		return c.processElement(node.(dom.Element), inScope, rendered, parentInSet)
+	case dom.PROCESSING_INSTRUCTION_NODE:
+		n := node.(dom.ProcessingInstruction)
+		if n.GetTarget() == "xml" || !c.inSet(n) {
+			return nil
+		}
+		c.writeDocumentLevel(n, func() {
+			c.out.WriteString("<?")
+			c.out.WriteString(n.GetTarget())
+			if v := n.GetValue(); len(v) > 0 {
+				c.out.WriteByte(' ')
+				c.out.WriteString(v)
+			}
+			c.out.WriteString("?>")
+		})
+	case dom.COMMENT_NODE:
+		n := node.(dom.Comment)
+		if !c.options.WithComments || !c.inSet(n) {
+			return nil
+		}
+		c.writeDocumentLevel(n, func() {
+			c.out.WriteString("<!--")
+			c.out.WriteString(n.GetValue())
+			c.out.WriteString("-->")
+		})
+	case dom.TEXT_NODE, dom.CDATA_SECTION_NODE:
+		n := node.(dom.CharacterData)
+		if c.inSet(n) {
+			writeEscaped(c.out, n.GetValue(), false)
+		}
+	}
+	return nil
+}
+
+type outputAttr struct {
+	ns    string
+	local string
+	qname string
+	value string
+}
+
+func (c *canonicalizer) processElement(el dom.Element, parentScope, rendered map[string]string, parentInSet bool) error {
+	inScope := copyMap(parentScope)
+	addNamespaces(el, inScope)
+	if !c.inSet(el) {
+		for ch := el.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			if err := c.process(ch, inScope, rendered, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Attributes in the node set
+	attrs := make([]outputAttr, 0)
+	usedPrefixes := map[string]struct{}{el.GetPrefix(): {}}
+	elAttrs := el.GetAttributes()
+	for i := 0; i < elAttrs.GetLength(); i++ {
+		attr := elAttrs.Item(i)
+		if isNamespaceDecl(attr) || !c.inSet(attr) {
+			continue
+		}
+		name := attr.GetQName()
+		if len(name.Prefix) > 0 {
+			usedPrefixes[name.Prefix] = struct{}{}
+		}
+		attrs = append(attrs, outputAttr{ns: attrNamespace(attr), local: name.Local, qname: name.QName(), value: attr.GetValue()})
+	}
+	if !parentInSet && c.options.Method != Exclusive {
+		attrs = c.inheritXMLAttributes(el, attrs)
+	}
+
+	// Namespace declarations to render
+	newRendered := copyMap(rendered)
+	decls := make([]string, 0)
+	render := func(prefix string) {
+		if prefix == "xml" {
+			return
+		}
+		uri, ok := inScope[prefix]
+		if !ok && len(prefix) > 0 {
+			return
+		}
+		// An absent default namespace is the same as an empty one
+		if newRendered[prefix] == uri {
+			return
+		}
+		newRendered[prefix] = uri
+		decls = append(decls, prefix)
+	}
+	if c.options.Method == Exclusive {
+		for prefix := range usedPrefixes {
+			render(prefix)
+		}
+		for prefix := range c.inclusive {
+			render(prefix)
+		}
+	} else {
+		for prefix := range inScope {
+			render(prefix)
+		}
+		render("")
+	}
+	sort.Strings(decls)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].ns != attrs[j].ns {
+			return attrs[i].ns < attrs[j].ns
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	out := c.out
+	out.WriteByte('<')
+	out.WriteString(el.GetTagName())
+	for _, prefix := range decls {
+		if len(prefix) == 0 {
+			out.WriteString(` xmlns="`)
+		} else {
+			out.WriteString(` xmlns:`)
+			out.WriteString(prefix)
+			out.WriteString(`="`)
+		}
+		writeEscaped(out, newRendered[prefix], true)
+		out.WriteByte('"')
+	}
+	for _, attr := range attrs {
+		out.WriteByte(' ')
+		out.WriteString(attr.qname)
+		out.WriteString(`="`)
+		writeEscaped(out, attr.value, true)
+		out.WriteByte('"')
+	}
+	out.WriteByte('>')
+	for ch := el.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+		if err := c.process(ch, inScope, newRendered, true); err != nil {
+			return err
+		}
+	}
+	out.WriteString("</")
+	out.WriteString(el.GetTagName())
+	out.WriteByte('>')
+	return nil
+}
+
+// inheritXMLAttributes adds the xml:* attributes of the ancestors
+// that are not in the output to the attributes of el. Canonical XML
+// 1.1 does not inherit xml:id, and joins xml:base values.
+func (c *canonicalizer) inheritXMLAttributes(el dom.Element, attrs []outputAttr) []outputAttr {
+	has := map[string]int{}
+	for i, a := range attrs {
+		if a.ns == xmlURL {
+			has[a.local] = i
+		}
+	}
+	// xml:base values of omitted ancestors, innermost first
+	bases := make([]string, 0)
+	inherited := map[string]outputAttr{}
+	for trc := el.GetParentNode(); trc != nil; trc = trc.GetParentNode() {
+		anc, ok := trc.(dom.Element)
+		if !ok {
+			break
+		}
+		if c.isInTraversal(anc) && c.inSet(anc) {
+			break
+		}
+		ancAttrs := anc.GetAttributes()
+		for i := 0; i < ancAttrs.GetLength(); i++ {
+			attr := ancAttrs.Item(i)
+			if attrNamespace(attr) != xmlURL {
+				continue
+			}
+			local := attr.GetLocalName()
+			if c.options.Method == Inclusive11 {
+				if local == "id" {
+					continue
+				}
+				if local == "base" {
+					bases = append(bases, attr.GetValue())
+					continue
+				}
+			}
+			if _, ok := has[local]; ok {
+				continue
+			}
+			if _, ok := inherited[local]; ok {
+				continue
+			}
+			inherited[local] = outputAttr{ns: xmlURL, local: local, qname: "xml:" + local, value: attr.GetValue()}
+		}
+	}
+	for _, a := range inherited {
+		attrs = append(attrs, a)
+	}
+	if c.options.Method == Inclusive11 && len(bases) > 0 {
+		base := ""
+		for i := len(bases) - 1; i >= 0; i-- {
+			base = joinURI(base, bases[i])
+		}
+		if ix, ok := has["base"]; ok {
+			attrs[ix].value = joinURI(base, attrs[ix].value)
+		} else if len(base) > 0 {
+			attrs = append(attrs, outputAttr{ns: xmlURL, local: "base", qname: "xml:base", value: base})
+		}
+	}
+	return attrs
+}
+
+// isInTraversal returns true if node is under the canonicalized node
+func (c *canonicalizer) isInTraversal(node dom.Node) bool {
+	for trc := node; trc != nil; trc = trc.GetParentNode() {
+		if trc == c.apex {
+			return true
+		}
+	}
+	return false
+}
+
+// joinURI resolves ref against base
+func joinURI(base, ref string) string {
+	if len(base) == 0 {
+		return ref
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+// writeEscaped writes text or attribute values using the escaping
+// rules of canonical XML
+func writeEscaped(out *bufio.Writer, s string, attr bool) {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '&':
+			sb.WriteString("&amp;")
+		case r == '<':
+			sb.WriteString("&lt;")
+		case r == '>' && !attr:
+			sb.WriteString("&gt;")
+		case r == '"' && attr:
+			sb.WriteString("&quot;")
+		case r == '\t' && attr:
+			sb.WriteString("&#x9;")
+		case r == '\n' && attr:
+			sb.WriteString("&#xA;")
+		case r == '\r':
+			sb.WriteString("&#xD;")
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	out.WriteString(sb.String())
+}
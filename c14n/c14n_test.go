@@ -0,0 +1,201 @@
+package c14n
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/bserdar/go-dom"
+)
+
+func parse(t *testing.T, input string) dom.Document {
+	doc, err := dom.Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func canonical(t *testing.T, node dom.Node, options Options) string {
+	out, err := Canonicalize(node, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestPIsCommentsOutsideDocument(t *testing.T) {
+	input := `<?xml version="1.0"?>
+
+<?xml-stylesheet   href="doc.xsl"
+   type="text/xsl"   ?>
+
+<doc>Hello, world!<!-- Comment 1 --></doc>
+
+<?pi-without-data     ?>
+
+<!-- Comment 2 -->
+
+<!-- Comment 3 -->`
+	doc := parse(t, input)
+	expected := `<?xml-stylesheet href="doc.xsl"
+   type="text/xsl"   ?>
+<doc>Hello, world!</doc>
+<?pi-without-data?>`
+	if s := canonical(t, doc, Options{}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	expected = `<?xml-stylesheet href="doc.xsl"
+   type="text/xsl"   ?>
+<doc>Hello, world!<!-- Comment 1 --></doc>
+<?pi-without-data?>
+<!-- Comment 2 -->
+<!-- Comment 3 -->`
+	if s := canonical(t, doc, Options{WithComments: true}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+}
+
+func TestStartEndTags(t *testing.T) {
+	input := `<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+	expected := `<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   <e4 id="elem4" name="elem4"></e4>
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6 xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9 xmlns:a="http://www.ietf.org"></e9>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+	if s := canonical(t, parse(t, input), Options{}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+}
+
+func TestCharacterModifications(t *testing.T) {
+	input := `<doc>
+   <text>First line&#x0d;&#10;Second line</text>
+   <value>&#x32;</value>
+   <compute><![CDATA[value>"0" && value<"10" ?"valid":"error"]]></compute>
+   <compute expr='value>"0" &amp;&amp; value&lt;"10" ?"valid":"error"'>valid</compute>
+   <norm attr=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+</doc>`
+	expected := `<doc>
+   <text>First line&#xD;
+Second line</text>
+   <value>2</value>
+   <compute>value&gt;"0" &amp;&amp; value&lt;"10" ?"valid":"error"</compute>
+   <compute expr="value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;">valid</compute>
+   <norm attr=" '    &#xD;&#xA;&#x9;   ' "></norm>
+</doc>`
+	if s := canonical(t, parse(t, input), Options{}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+}
+
+const subsetInput = `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org" xml:lang="en"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en-ca"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2></n0:local>`
+
+func TestSubtree(t *testing.T) {
+	doc := parse(t, subsetInput)
+	elem2 := doc.GetDocumentElement().GetFirstElementChild()
+
+	expected := `<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net" xmlns:n3="ftp://example.org" xml:lang="en-ca"><n3:stuff></n3:stuff></n1:elem2>`
+	if s := canonical(t, elem2, Options{}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	expected = `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en-ca"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`
+	if s := canonical(t, elem2, Options{Method: Exclusive}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	expected = `<n1:elem2 xmlns:n1="http://example.net" xmlns:n3="ftp://example.org" xml:lang="en-ca"><n3:stuff></n3:stuff></n1:elem2>`
+	if s := canonical(t, elem2, Options{Method: Exclusive, InclusivePrefixes: []string{"n3"}}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+
+	// xml:lang is inherited from omitted ancestors
+	stuff := elem2.GetFirstElementChild()
+	expected = `<n3:stuff xmlns:n0="foo:bar" xmlns:n1="http://example.net" xmlns:n3="ftp://example.org" xml:lang="en-ca"></n3:stuff>`
+	if s := canonical(t, stuff, Options{Method: Inclusive11}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+}
+
+func TestDocumentSubset(t *testing.T) {
+	doc := parse(t, `<doc xmlns="http://a" xml:base="http://www.example.com/a/"><e1 xml:base="b/" attr="x"><e2 attr="y">text<!--c--></e2></e1></doc>`)
+	// Omit e1 and the attr attributes
+	filter := func(node dom.Node) bool {
+		switch n := node.(type) {
+		case dom.Element:
+			return n.GetLocalName() != "e1"
+		case dom.Attr:
+			return n.GetLocalName() != "attr"
+		}
+		return true
+	}
+	e2 := doc.GetDocumentElement().GetFirstElementChild().GetFirstElementChild()
+	expected := `<doc xmlns="http://a" xml:base="http://www.example.com/a/"><e2 xml:base="b/">text</e2></doc>`
+	if s := canonical(t, doc, Options{Filter: filter}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	// The xml:base of the omitted e1 is joined with the output ancestor
+	expected = `<doc xmlns="http://a" xml:base="http://www.example.com/a/"><e2 xml:base="b/">text<!--c--></e2></doc>`
+	if s := canonical(t, doc, Options{Method: Inclusive11, Filter: filter, WithComments: true}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	expected = `<e2 xmlns="http://a" xml:base="http://www.example.com/a/b/">text</e2>`
+	if s := canonical(t, e2, Options{Method: Inclusive11, Filter: filter}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+}
+
+func TestDefaultNamespaceUndeclaration(t *testing.T) {
+	doc := parse(t, `<a xmlns="http://a"><b xmlns=""><c/></b></a>`)
+	filter := func(node dom.Node) bool {
+		el, ok := node.(dom.Element)
+		return !ok || el.GetLocalName() != "a"
+	}
+	expected := `<b><c></c></b>`
+	if s := canonical(t, doc, Options{Filter: filter}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	expected = `<a xmlns="http://a"><b xmlns=""><c></c></b></a>`
+	if s := canonical(t, doc, Options{Method: Exclusive}); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+}
+
+func TestOptionsForAlgorithm(t *testing.T) {
+	for _, uri := range []string{C14N10, C14N10WithComments, C14N11, C14N11WithComments, ExclusiveC14N, ExclusiveC14NWithComments} {
+		opt, err := OptionsForAlgorithm(uri)
+		if err != nil {
+			t.Error(err)
+		}
+		if opt.Algorithm() != uri {
+			t.Errorf("Expected %s, got %s", uri, opt.Algorithm())
+		}
+	}
+	if _, err := OptionsForAlgorithm("x"); err == nil {
+		t.Errorf("Error expected")
+	}
+}
@@ -0,0 +1,5 @@
+package dom
+
+type CDATASection interface {
+	Text
+}
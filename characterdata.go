@@ -5,4 +5,26 @@ type CharacterData interface {
 
 	GetValue() string
 	SetValue(string)
+
+	// Inserts nodes or strings before this node. Strings are
+	// inserted as Text nodes.
+	Before(nodes ...interface{})
+
+	// Inserts nodes or strings after this node. Strings are
+	// inserted as Text nodes.
+	After(nodes ...interface{})
+
+	// Replaces this node with nodes or strings. Strings are
+	// inserted as Text nodes.
+	ReplaceWith(nodes ...interface{})
+
+	// Removes this node from the children list of its parent.
+	Remove()
+
+	// The methods ending with E are the same as the corresponding
+	// methods, but they return the ErrDOM errors instead of
+	// panicking.
+	BeforeE(nodes ...interface{}) error
+	AfterE(nodes ...interface{}) error
+	ReplaceWithE(nodes ...interface{}) error
 }
@@ -0,0 +1,130 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// getTextContent returns the concatenation of the text and CDATA
+// section descendants of node
+func getTextContent(node Node) string {
+	var sb strings.Builder
+	for trc := nextInSubtree(node, node); trc != nil; trc = nextInSubtree(node, trc) {
+		switch t := trc.(type) {
+		case *BasicText:
+			sb.WriteString(t.text)
+		case *BasicCDATASection:
+			sb.WriteString(t.text)
+		}
+	}
+	return sb.String()
+}
+
+// setTextContent replaces the children of node with a text node. If
+// text is empty, all children are removed.
+func setTextContent(node Node, text string) {
+	defer beginMutation(node)()
+	for child := node.GetFirstChild(); child != nil; child = node.GetFirstChild() {
+		detachChild(node, child)
+	}
+	if len(text) > 0 {
+		doc := node.GetOwnerDocument()
+		insertChildBefore(node, doc.CreateTextNode(text), nil)
+	}
+}
+
+// newContextEncoder returns an encoder that writes content in the
+// context of el. Namespaces in scope for el are not declared again.
+func newContextEncoder(sb *strings.Builder, el Element) *Encoder {
+	e := NewEncoder(sb)
+	scope := &nsScope{name: el.GetQName()}
+	define := func(prefix, ns string) {
+		if len(prefix) == 0 {
+			if !scope.hasDefault {
+				scope.defaultNS, scope.hasDefault = ns, true
+			}
+			return
+		}
+		if _, exists := scope.definedPrefix[prefix]; !exists {
+			scope.define(prefix, ns)
+		}
+	}
+	// Inner declarations hide the outer ones
+	for trc := Node(el); trc != nil; trc = trc.GetParentNode() {
+		ancestor, ok := trc.(Element)
+		if !ok {
+			continue
+		}
+		attrs := ancestor.GetAttributes()
+		for i := 0; i < attrs.GetLength(); i++ {
+			attr := attrs.Item(i)
+			name := attr.GetQName()
+			if !isNamespaceDeclaration(name) {
+				continue
+			}
+			if name.Prefix == xmlnsPrefix {
+				define(name.Local, attr.GetValue())
+			} else {
+				define("", attr.GetValue())
+			}
+		}
+		if name := ancestor.GetQName(); len(name.Space) > 0 {
+			define(name.Prefix, name.Space)
+		}
+	}
+	e.top = scope
+	e.depth = 1
+	e.rootSeen = true
+	e.lenient = true
+	return e
+}
+
+// getInnerXML serializes the children of el. Names using namespaces
+// in scope for el are written without declarations. Undeclared
+// prefixes are written as they are, as Encode does.
+func getInnerXML(el Element) (string, error) {
+	var sb strings.Builder
+	e := newContextEncoder(&sb, el)
+	for child := el.GetFirstChild(); child != nil; child = child.GetNextSibling() {
+		if err := e.WriteNode(child); err != nil {
+			return "", err
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// getOuterXML serializes el and its descendants. Namespace
+// declarations are added so the output is namespace well-formed on
+// its own. Undeclared prefixes are written as they are, as Encode
+// does.
+func getOuterXML(el Element) (string, error) {
+	var sb strings.Builder
+	e := NewEncoder(&sb)
+	e.lenient = true
+	if err := e.WriteNode(el); err != nil {
+		return "", err
+	}
+	if err := e.Close(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// setInnerXML parses the XML content in the context of el, and
+// replaces the children of el with the parsed nodes. If the content
+// cannot be parsed, el is not changed.
+func setInnerXML(el *BasicElement, content string) error {
+	frag, err := ParseFragment(el, xml.NewDecoder(strings.NewReader(content)))
+	if err != nil {
+		return err
+	}
+	defer beginMutation(el)()
+	for child := el.GetFirstChild(); child != nil; child = el.GetFirstChild() {
+		detachChild(el, child)
+	}
+	insertBefore(el, frag, nil)
+	return nil
+}
@@ -0,0 +1,103 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestTextContent(t *testing.T) {
+	input := `<root a="v">one<b>two<!--x--><![CDATA[three]]></b><?pi four?></root>`
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	if s := root.GetTextContent(); s != "onetwothree" {
+		t.Errorf("Wrong text: %s", s)
+	}
+	if s := doc.GetTextContent(); s != "" {
+		t.Errorf("Wrong document text: %s", s)
+	}
+	attr := root.GetAttributeNode("a")
+	attr.SetTextContent("w")
+	if s, _ := root.GetAttribute("a"); s != "w" || attr.GetTextContent() != "w" {
+		t.Errorf("Wrong attribute: %s", s)
+	}
+	pi := root.GetLastChild()
+	if pi.GetTextContent() != "four" {
+		t.Errorf("Wrong PI text: %s", pi.GetTextContent())
+	}
+	b := root.GetFirstChild().GetNextSibling().(Element)
+	b.SetTextContent("new")
+	if b.GetFirstChild() != b.GetLastChild() || b.GetTextContent() != "new" {
+		t.Errorf("Wrong text after set")
+	}
+	b.SetTextContent("")
+	if b.HasChildNodes() {
+		t.Errorf("Expected no children")
+	}
+}
+
+func TestInnerOuterXML(t *testing.T) {
+	input := `<root xmlns="urn:d" xmlns:p="urn:p"><p:a x="1">t<b p:y="2"/></p:a></root>`
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := doc.GetDocumentElement().GetFirstChild().(Element)
+	inner, err := a.GetInnerXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner != `t<b p:y="2"></b>` {
+		t.Errorf("Wrong inner XML: %s", inner)
+	}
+	outer, err := a.GetOuterXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outer != `<p:a xmlns:p="urn:p" x="1">t<b xmlns="urn:d" p:y="2"></b></p:a>` {
+		t.Errorf("Wrong outer XML: %s", outer)
+	}
+
+	if err := a.SetInnerXML(`<c p:z="3"/>text<p:d/>`); err != nil {
+		t.Fatal(err)
+	}
+	c := a.GetFirstChild().(Element)
+	if c.GetQName().Space != "urn:d" || c.GetOwnerDocument() != doc {
+		t.Errorf("Wrong element: %v", c.GetQName())
+	}
+	if v, ok := c.GetAttributeNS("urn:p", "z"); !ok || v != "3" {
+		t.Errorf("Wrong attribute")
+	}
+	if d := a.GetLastChild().(Element); d.GetQName().Space != "urn:p" {
+		t.Errorf("Wrong element: %v", d.GetQName())
+	}
+	if err := a.SetInnerXML(`<c>`); err == nil {
+		t.Errorf("Expected error")
+	}
+	if a.GetFirstChild() != c {
+		t.Errorf("Element changed after error")
+	}
+
+	// Undeclared prefixes accepted by Parse and SetInnerXML are
+	// written as they are
+	doc, err = Parse(xml.NewDecoder(strings.NewReader(`<r><bad:z/></r>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := doc.GetDocumentElement()
+	if outer, err := r.GetOuterXML(); err != nil || outer != `<r><bad:z></bad:z></r>` {
+		t.Errorf("Wrong outer XML: %s %v", outer, err)
+	}
+	if err := r.SetInnerXML(`<bad:y/>`); err != nil {
+		t.Fatal(err)
+	}
+	if inner, err := r.GetInnerXML(); err != nil || inner != `<bad:y></bad:y>` {
+		t.Errorf("Wrong inner XML: %s %v", inner, err)
+	}
+	if outer, err := r.GetOuterXML(); err != nil || outer != encodeString(t, r) {
+		t.Errorf("Wrong outer XML: %s %v", outer, err)
+	}
+}
@@ -0,0 +1,588 @@
+// Package diff compares two DOM trees and produces an edit script
+// that describes the differences between them.
+//
+// Children are matched using the longest common subsequence of
+// equal subtrees. Unmatched children of the same kind (elements with
+// the same name, text, comments, or processing instructions with the
+// same target) are compared recursively. Subtrees that are deleted
+// in one place and inserted unchanged in another are reported as
+// moves.
+package diff
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bserdar/go-dom"
+)
+
+// Options control which differences are reported
+type Options struct {
+	// Ignore text nodes that contain only whitespace
+	IgnoreWhitespace bool
+
+	// Ignore comments
+	IgnoreComments bool
+
+	// Ignore the order of attributes
+	IgnoreAttributeOrder bool
+}
+
+// EditType is the type of an Edit
+type EditType int
+
+const (
+	// A node is inserted into the new tree
+	Insert EditType = iota
+	// A node of the old tree is deleted
+	Delete
+	// A node is moved to another position
+	Move
+	// An attribute is added to an element
+	AddAttribute
+	// An attribute is removed from an element
+	RemoveAttribute
+	// The value of an attribute is changed
+	ChangeAttribute
+	// The attributes of an element are reordered
+	ReorderAttributes
+	// The value of a text, CDATA section, comment, or processing
+	// instruction node is changed
+	ChangeText
+)
+
+func (t EditType) String() string {
+	switch t {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	case Move:
+		return "move"
+	case AddAttribute:
+		return "add attribute"
+	case RemoveAttribute:
+		return "remove attribute"
+	case ChangeAttribute:
+		return "change attribute"
+	case ReorderAttributes:
+		return "reorder attributes"
+	case ChangeText:
+		return "change text"
+	}
+	return fmt.Sprintf("EditType(%d)", int(t))
+}
+
+// Edit is a single difference between two trees
+type Edit struct {
+	Type EditType
+
+	// The node in the old tree. It is nil for Insert. For attribute
+	// edits, this is the element.
+	Old dom.Node
+
+	// The node in the new tree. It is nil for Delete. For attribute
+	// edits, this is the element.
+	New dom.Node
+
+	// The paths of Old and New in their trees
+	OldPath string
+	NewPath string
+
+	// The qualified name of the attribute for attribute edits
+	Attribute string
+
+	// The old and new values of the attribute or node. For
+	// ReorderAttributes, these are the space separated attribute
+	// names.
+	OldValue string
+	NewValue string
+
+	// The position of New for Insert and Move. Parent is the node of
+	// the old tree that corresponds to the parent of New. After is
+	// the node New follows among the compared children: either a
+	// node of the old tree, or the New node of an earlier Insert or
+	// Move. After is nil if New is the first child.
+	Parent dom.Node
+	After  dom.Node
+}
+
+// Script is the list of edits that transform one tree to another
+type Script []Edit
+
+// Compare returns the edits that transform the old tree to the new
+// tree. The result is empty if the trees are equal.
+func Compare(old, new dom.Node, options Options) Script {
+	d := differ{
+		options: options,
+		sigs:    make(map[dom.Node][sha256.Size]byte),
+	}
+	if d.equal(old, new) {
+		return nil
+	}
+	if d.key(old) != d.key(new) {
+		d.edits = append(d.edits,
+			Edit{Type: Delete, Old: old, OldPath: Path(old)},
+			Edit{Type: Insert, New: new, NewPath: Path(new), Parent: old.GetParentNode(), After: old.GetPreviousSibling()})
+	} else {
+		d.diffNode(old, new)
+	}
+	d.findMoves()
+	return d.edits
+}
+
+type differ struct {
+	options Options
+	sigs    map[dom.Node][sha256.Size]byte
+	edits   Script
+}
+
+// children returns the children of node that are compared
+func (d *differ) children(node dom.Node) []dom.Node {
+	ret := make([]dom.Node, 0)
+	for child := node.GetFirstChild(); child != nil; child = child.GetNextSibling() {
+		if !d.ignored(child) {
+			ret = append(ret, child)
+		}
+	}
+	return ret
+}
+
+func (d *differ) ignored(node dom.Node) bool {
+	switch node.GetNodeType() {
+	case dom.COMMENT_NODE:
+		return d.options.IgnoreComments
+	case dom.TEXT_NODE, dom.CDATA_SECTION_NODE:
+		return d.options.IgnoreWhitespace && len(strings.TrimSpace(node.GetTextContent())) == 0
+	}
+	return false
+}
+
+// attributes returns the attributes of el in document order
+func attributes(el dom.Element) []dom.Attr {
+	attrs := el.GetAttributes()
+	ret := make([]dom.Attr, 0, attrs.GetLength())
+	for i := 0; i < attrs.GetLength(); i++ {
+		ret = append(ret, attrs.Item(i))
+	}
+	return ret
+}
+
+// attrKey returns the expanded name of the attribute
+func attrKey(attr dom.Attr) string {
+	name := attr.GetQName()
+	return name.Space + " " + name.Local
+}
+
+// key identifies the nodes that can be compared with each other
+func (d *differ) key(node dom.Node) string {
+	switch node.GetNodeType() {
+	case dom.ELEMENT_NODE:
+		name := node.(dom.Element).GetQName()
+		return "e " + name.Space + " " + name.Local
+	case dom.TEXT_NODE, dom.CDATA_SECTION_NODE:
+		return "t"
+	case dom.PROCESSING_INSTRUCTION_NODE:
+		return "p " + node.(dom.ProcessingInstruction).GetTarget()
+	}
+	return fmt.Sprint(node.GetNodeType())
+}
+
+// value returns the value compared for character data nodes
+func value(node dom.Node) string {
+	switch node.GetNodeType() {
+	case dom.TEXT_NODE, dom.CDATA_SECTION_NODE, dom.COMMENT_NODE, dom.PROCESSING_INSTRUCTION_NODE:
+		return node.GetTextContent()
+	case dom.DOCUMENT_TYPE_NODE:
+		dt := node.(dom.DocumentType)
+		return dt.GetName() + " " + dt.GetPublicID() + " " + dt.GetSystemID()
+	}
+	return ""
+}
+
+// signature returns the hash of the subtree rooted at node. Subtrees
+// that are equal under the options have the same signature.
+func (d *differ) signature(node dom.Node) [sha256.Size]byte {
+	if sig, ok := d.sigs[node]; ok {
+		return sig
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %q\n", d.key(node), value(node))
+	if el, ok := node.(dom.Element); ok {
+		attrs := make([]string, 0)
+		for _, attr := range attributes(el) {
+			attrs = append(attrs, fmt.Sprintf("%q=%q", attrKey(attr), attr.GetValue()))
+		}
+		if d.options.IgnoreAttributeOrder {
+			sort.Strings(attrs)
+		}
+		fmt.Fprintln(h, strings.Join(attrs, " "))
+	}
+	for _, child := range d.children(node) {
+		sig := d.signature(child)
+		h.Write(sig[:])
+	}
+	var sig [sha256.Size]byte
+	copy(sig[:], h.Sum(nil))
+	d.sigs[node] = sig
+	return sig
+}
+
+// equal returns true if the subtrees are equal under the options
+func (d *differ) equal(a, b dom.Node) bool {
+	// IsEqualNode does not depend on attribute order, and is
+	// stricter than the other options
+	if d.options.IgnoreAttributeOrder && a.IsEqualNode(b) {
+		return true
+	}
+	return d.signature(a) == d.signature(b)
+}
+
+// diffNode records the differences between two nodes with the same
+// key
+func (d *differ) diffNode(old, new dom.Node) {
+	if d.equal(old, new) {
+		return
+	}
+	switch old.GetNodeType() {
+	case dom.ELEMENT_NODE:
+		d.diffAttributes(old.(dom.Element), new.(dom.Element))
+		d.diffChildren(old, new)
+	case dom.DOCUMENT_NODE, dom.DOCUMENT_FRAGMENT_NODE:
+		d.diffChildren(old, new)
+	default:
+		if value(old) != value(new) {
+			d.edits = append(d.edits, Edit{
+				Type:     ChangeText,
+				Old:      old,
+				New:      new,
+				OldPath:  Path(old),
+				NewPath:  Path(new),
+				OldValue: value(old),
+				NewValue: value(new),
+			})
+		}
+	}
+}
+
+func (d *differ) diffAttributes(old, new dom.Element) {
+	oldAttrs := attributes(old)
+	newAttrs := attributes(new)
+	oldMap := make(map[string]dom.Attr)
+	for _, attr := range oldAttrs {
+		oldMap[attrKey(attr)] = attr
+	}
+	newMap := make(map[string]dom.Attr)
+	for _, attr := range newAttrs {
+		newMap[attrKey(attr)] = attr
+	}
+	edit := func(typ EditType, name, oldValue, newValue string) {
+		d.edits = append(d.edits, Edit{
+			Type:      typ,
+			Old:       old,
+			New:       new,
+			OldPath:   Path(old),
+			NewPath:   Path(new),
+			Attribute: name,
+			OldValue:  oldValue,
+			NewValue:  newValue,
+		})
+	}
+	for _, attr := range oldAttrs {
+		if _, ok := newMap[attrKey(attr)]; !ok {
+			edit(RemoveAttribute, attr.GetName(), attr.GetValue(), "")
+		}
+	}
+	for _, attr := range newAttrs {
+		oldAttr, ok := oldMap[attrKey(attr)]
+		if !ok {
+			edit(AddAttribute, attr.GetName(), "", attr.GetValue())
+			continue
+		}
+		if oldAttr.GetValue() != attr.GetValue() {
+			edit(ChangeAttribute, attr.GetName(), oldAttr.GetValue(), attr.GetValue())
+		}
+	}
+	if d.options.IgnoreAttributeOrder {
+		return
+	}
+	// Compare the order of the common attributes
+	oldOrder := make([]string, 0)
+	for _, attr := range oldAttrs {
+		if _, ok := newMap[attrKey(attr)]; ok {
+			oldOrder = append(oldOrder, attr.GetName())
+		}
+	}
+	newOrder := make([]string, 0)
+	for _, attr := range newAttrs {
+		if _, ok := oldMap[attrKey(attr)]; ok {
+			newOrder = append(newOrder, attr.GetName())
+		}
+	}
+	if o, n := strings.Join(oldOrder, " "), strings.Join(newOrder, " "); o != n {
+		edit(ReorderAttributes, "", o, n)
+	}
+}
+
+// diffChildren records the differences between the children of two
+// nodes
+func (d *differ) diffChildren(oldParent, newParent dom.Node) {
+	olds := d.children(oldParent)
+	news := d.children(newParent)
+
+	// Match the equal children, and then pair the remaining children
+	// of the same kind. match[j] is the index of the old child
+	// matched to the new child j, or -1.
+	match := make([]int, len(news))
+	for j := range match {
+		match[j] = -1
+	}
+	matchedOld := make([]bool, len(olds))
+	equal := lcs(len(olds), len(news), func(i, j int) bool { return d.equal(olds[i], news[j]) })
+	for _, p := range equal {
+		match[p[1]] = p[0]
+		matchedOld[p[0]] = true
+	}
+	// The children of the same kind are paired between the same equal
+	// children, so the pairs keep their order
+	startOld, startNew := 0, 0
+	for _, p := range append(equal, [2]int{len(olds), len(news)}) {
+		restOld := make([]int, 0)
+		for i := startOld; i < p[0]; i++ {
+			restOld = append(restOld, i)
+		}
+		restNew := make([]int, 0)
+		for j := startNew; j < p[1]; j++ {
+			restNew = append(restNew, j)
+		}
+		for _, q := range lcs(len(restOld), len(restNew), func(i, j int) bool {
+			return d.key(olds[restOld[i]]) == d.key(news[restNew[j]])
+		}) {
+			match[restNew[q[1]]] = restOld[q[0]]
+			matchedOld[restOld[q[0]]] = true
+		}
+		startOld, startNew = p[0]+1, p[1]+1
+	}
+
+	// Record the edits in the order of the new children. The
+	// unmatched old children are deleted before the first new child
+	// that is matched to a following old child.
+	nextOld := 0
+	deleteUntil := func(end int) {
+		for ; nextOld < end; nextOld++ {
+			if !matchedOld[nextOld] {
+				o := olds[nextOld]
+				d.edits = append(d.edits, Edit{Type: Delete, Old: o, OldPath: Path(o)})
+			}
+		}
+	}
+	var after dom.Node
+	for j, n := range news {
+		i := match[j]
+		if i == -1 {
+			d.edits = append(d.edits, Edit{Type: Insert, New: n, NewPath: Path(n), Parent: oldParent, After: after})
+			after = n
+			continue
+		}
+		deleteUntil(i)
+		d.diffNode(olds[i], n)
+		after = olds[i]
+	}
+	deleteUntil(len(olds))
+}
+
+// lcs returns the index pairs of a longest common subsequence of two
+// sequences of lengths m and n. The common prefix and suffix are
+// matched directly, and the rest is computed using Hirschberg's
+// algorithm, which needs memory linear in n.
+func lcs(m, n int, equal func(i, j int) bool) [][2]int {
+	ret := make([][2]int, 0)
+	start := 0
+	for start < m && start < n && equal(start, start) {
+		ret = append(ret, [2]int{start, start})
+		start++
+	}
+	endOld, endNew := m, n
+	for endOld > start && endNew > start && equal(endOld-1, endNew-1) {
+		endOld--
+		endNew--
+	}
+	ret = hirschberg(start, endOld, start, endNew, equal, ret)
+	for i := 0; endOld+i < m; i++ {
+		ret = append(ret, [2]int{endOld + i, endNew + i})
+	}
+	return ret
+}
+
+// hirschberg appends the index pairs of a longest common subsequence
+// of the ranges [i0,i1) and [j0,j1) to ret
+func hirschberg(i0, i1, j0, j1 int, equal func(i, j int) bool, ret [][2]int) [][2]int {
+	if i0 == i1 || j0 == j1 {
+		return ret
+	}
+	if i1-i0 == 1 {
+		for j := j0; j < j1; j++ {
+			if equal(i0, j) {
+				return append(ret, [2]int{i0, j})
+			}
+		}
+		return ret
+	}
+	// Split the first range in half, and find the split of the
+	// second range that maximizes the length of the subsequence
+	mid := (i0 + i1) / 2
+	forward := lcsLengths(i0, mid, j0, j1, equal, false)
+	backward := lcsLengths(mid, i1, j0, j1, equal, true)
+	best, split := -1, j0
+	for k := range forward {
+		if l := forward[k] + backward[k]; l > best {
+			best, split = l, j0+k
+		}
+	}
+	ret = hirschberg(i0, mid, j0, split, equal, ret)
+	return hirschberg(mid, i1, split, j1, equal, ret)
+}
+
+// lcsLengths returns the lengths of the longest common subsequences
+// of the range [i0,i1) with the prefixes [j0,j0+k) of the second
+// range, or with the suffixes [j0+k,j1) if reverse is set, for k from
+// 0 to j1-j0
+func lcsLengths(i0, i1, j0, j1 int, equal func(i, j int) bool, reverse bool) []int {
+	n := j1 - j0
+	prev := make([]int, n+1)
+	cur := make([]int, n+1)
+	if !reverse {
+		for i := i0; i < i1; i++ {
+			for k := 1; k <= n; k++ {
+				switch {
+				case equal(i, j0+k-1):
+					cur[k] = prev[k-1] + 1
+				case prev[k] >= cur[k-1]:
+					cur[k] = prev[k]
+				default:
+					cur[k] = cur[k-1]
+				}
+			}
+			prev, cur = cur, prev
+		}
+		return prev
+	}
+	for i := i1 - 1; i >= i0; i-- {
+		for k := n - 1; k >= 0; k-- {
+			switch {
+			case equal(i, j0+k):
+				cur[k] = prev[k+1] + 1
+			case prev[k] >= cur[k+1]:
+				cur[k] = prev[k]
+			default:
+				cur[k] = cur[k+1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// findMoves replaces the deletions and insertions of equal subtrees
+// with moves
+func (d *differ) findMoves() {
+	deleted := make(map[[sha256.Size]byte][]int)
+	for i, edit := range d.edits {
+		if edit.Type == Delete {
+			sig := d.signature(edit.Old)
+			deleted[sig] = append(deleted[sig], i)
+		}
+	}
+	if len(deleted) == 0 {
+		return
+	}
+	remove := make(map[int]bool)
+	for i, edit := range d.edits {
+		if edit.Type != Insert {
+			continue
+		}
+		sig := d.signature(edit.New)
+		candidates := deleted[sig]
+		if len(candidates) == 0 {
+			continue
+		}
+		del := d.edits[candidates[0]]
+		deleted[sig] = candidates[1:]
+		remove[candidates[0]] = true
+		d.edits[i] = Edit{
+			Type:    Move,
+			Old:     del.Old,
+			New:     edit.New,
+			OldPath: del.OldPath,
+			NewPath: edit.NewPath,
+			Parent:  edit.Parent,
+			After:   edit.After,
+		}
+	}
+	w := 0
+	for i := range d.edits {
+		if !remove[i] {
+			d.edits[w] = d.edits[i]
+			w++
+		}
+	}
+	d.edits = d.edits[:w]
+}
+
+// Path returns an XPath expression that selects node in its tree,
+// such as /config/server[2]/text()[1]. Names are written as
+// qualified names using the prefixes of the document. The path of a
+// document type node is /!DOCTYPE.
+func Path(node dom.Node) string {
+	if node == nil {
+		return ""
+	}
+	parent := node.GetParentNode()
+	var step string
+	switch node.GetNodeType() {
+	case dom.DOCUMENT_NODE, dom.DOCUMENT_FRAGMENT_NODE:
+		return ""
+	case dom.ELEMENT_NODE:
+		name := node.(dom.Element).GetTagName()
+		step = fmt.Sprintf("%s[%d]", name, position(node, func(n dom.Node) bool {
+			el, ok := n.(dom.Element)
+			return ok && el.GetTagName() == name
+		}))
+	case dom.TEXT_NODE, dom.CDATA_SECTION_NODE:
+		step = fmt.Sprintf("text()[%d]", position(node, func(n dom.Node) bool {
+			return n.GetNodeType() == dom.TEXT_NODE || n.GetNodeType() == dom.CDATA_SECTION_NODE
+		}))
+	case dom.COMMENT_NODE:
+		step = fmt.Sprintf("comment()[%d]", position(node, func(n dom.Node) bool {
+			return n.GetNodeType() == dom.COMMENT_NODE
+		}))
+	case dom.PROCESSING_INSTRUCTION_NODE:
+		target := node.(dom.ProcessingInstruction).GetTarget()
+		step = fmt.Sprintf("processing-instruction('%s')[%d]", target, position(node, func(n dom.Node) bool {
+			pi, ok := n.(dom.ProcessingInstruction)
+			return ok && pi.GetTarget() == target
+		}))
+	case dom.DOCUMENT_TYPE_NODE:
+		return "/!DOCTYPE"
+	default:
+		return ""
+	}
+	if parent == nil {
+		return "/" + step
+	}
+	return Path(parent) + "/" + step
+}
+
+// position returns the 1-based position of node among the siblings
+// that match
+func position(node dom.Node, match func(dom.Node) bool) int {
+	n := 1
+	for trc := node.GetPreviousSibling(); trc != nil; trc = trc.GetPreviousSibling() {
+		if match(trc) {
+			n++
+		}
+	}
+	return n
+}
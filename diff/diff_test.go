@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bserdar/go-dom"
+)
+
+func parse(t *testing.T, input string) dom.Document {
+	doc, err := dom.Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestCompare(t *testing.T) {
+	old := parse(t, `<config>
+  <server port="80" debug="true">a</server>
+  <name>x</name>
+  <!-- c -->
+  <old/>
+  <group><item id="1"/></group>
+</config>`)
+	new := parse(t, `<config>
+  <server port="8080" tls="on">b</server>
+  <name>x</name>
+  <!-- c -->
+  <group/>
+  <item id="1"/>
+  <new/>
+</config>`)
+	script := Compare(old, new, Options{IgnoreWhitespace: true})
+	report := script.String()
+	expected := `- /config[1]/server[1]/@debug: "true"
+~ /config[1]/server[1]/@port: "80" -> "8080"
++ /config[1]/server[1]/@tls: "on"
+~ /config[1]/server[1]/text()[1]: "a" -> "b"
+- /config[1]/old[1]: "<old></old>"
+> /config[1]/group[1]/item[1] -> /config[1]/item[1]
++ /config[1]/new[1]: "<new></new>"
+`
+	if report != expected {
+		t.Errorf("Wrong report:\n%s", report)
+	}
+	if len(Compare(old, old, Options{})) != 0 {
+		t.Errorf("Differences in the same document")
+	}
+}
+
+func TestCompareOptions(t *testing.T) {
+	old := parse(t, `<a x="1" y="2"><!--c--><b/></a>`)
+	new := parse(t, `<a y="2" x="1">
+  <b/>
+</a>`)
+	script := Compare(old, new, Options{})
+	types := make([]EditType, 0)
+	for _, edit := range script {
+		types = append(types, edit.Type)
+	}
+	if len(script) != 4 || script[0].Type != ReorderAttributes {
+		t.Errorf("Wrong edits: %v\n%s", types, script)
+	}
+	script = Compare(old, new, Options{IgnoreWhitespace: true, IgnoreComments: true, IgnoreAttributeOrder: true})
+	if len(script) != 0 {
+		t.Errorf("Unexpected edits:\n%s", script)
+	}
+}
+
+func TestCompareOrder(t *testing.T) {
+	// d is paired with the d of the old tree, but it moves before b
+	old := parse(t, `<a><b/><c/><d x="1"/></a>`)
+	new := parse(t, `<a><d x="2"/><b/><e/></a>`)
+	report := Compare(old, new, Options{}).String()
+	expected := `+ /a[1]/d[1]: "<d x=\"2\"></d>"
++ /a[1]/e[1]: "<e></e>"
+- /a[1]/c[1]: "<c></c>"
+- /a[1]/d[1]: "<d x=\"1\"></d>"
+`
+	if report != expected {
+		t.Errorf("Wrong report:\n%s", report)
+	}
+}
+
+func TestLCS(t *testing.T) {
+	// The length of the subsequence is compared to the quadratic
+	// dynamic programming solution
+	length := func(a, b []byte) int {
+		l := make([][]int, len(a)+1)
+		for i := range l {
+			l[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				switch {
+				case a[i] == b[j]:
+					l[i][j] = l[i+1][j+1] + 1
+				case l[i+1][j] > l[i][j+1]:
+					l[i][j] = l[i+1][j]
+				default:
+					l[i][j] = l[i][j+1]
+				}
+			}
+		}
+		return l[0][0]
+	}
+	seed := uint32(1)
+	random := func(n int) []byte {
+		ret := make([]byte, n)
+		for i := range ret {
+			seed = seed*1664525 + 1013904223
+			ret[i] = 'a' + byte(seed>>24)%4
+		}
+		return ret
+	}
+	for k := 0; k < 200; k++ {
+		a, b := random(k%17), random(k%13)
+		pairs := lcs(len(a), len(b), func(i, j int) bool { return a[i] == b[j] })
+		if len(pairs) != length(a, b) {
+			t.Fatalf("%s %s: wrong length %d", a, b, len(pairs))
+		}
+		for i, p := range pairs {
+			if a[p[0]] != b[p[1]] || (i > 0 && (p[0] <= pairs[i-1][0] || p[1] <= pairs[i-1][1])) {
+				t.Fatalf("%s %s: wrong pairs %v", a, b, pairs)
+			}
+		}
+	}
+}
+
+func TestCompareManySiblings(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("<a>")
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&sb, `<item id="%d"/>`, i)
+	}
+	sb.WriteString("</a>")
+	old := parse(t, sb.String())
+	new := parse(t, strings.Replace(sb.String(), `<item id="2500"/>`, `<item id="x"/>`, 1))
+	script := Compare(old, new, Options{})
+	if len(script) != 1 || script[0].Type != ChangeAttribute || script[0].NewValue != "x" {
+		t.Errorf("Wrong script: %s", script)
+	}
+}
@@ -0,0 +1,63 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/bserdar/go-dom"
+)
+
+// Report writes the edits in a readable form, one edit per line.
+// Inserted nodes are prefixed with "+", deleted nodes with "-",
+// changes with "~", and moves with ">". Nodes are identified by
+// their paths; attribute paths end with /@name.
+func (s Script) Report(w io.Writer) error {
+	for _, edit := range s {
+		var line string
+		switch edit.Type {
+		case Insert:
+			line = fmt.Sprintf("+ %s: %s", edit.NewPath, nodeString(edit.New))
+		case Delete:
+			line = fmt.Sprintf("- %s: %s", edit.OldPath, nodeString(edit.Old))
+		case Move:
+			line = fmt.Sprintf("> %s -> %s", edit.OldPath, edit.NewPath)
+		case AddAttribute:
+			line = fmt.Sprintf("+ %s/@%s: %q", edit.NewPath, edit.Attribute, edit.NewValue)
+		case RemoveAttribute:
+			line = fmt.Sprintf("- %s/@%s: %q", edit.NewPath, edit.Attribute, edit.OldValue)
+		case ChangeAttribute:
+			line = fmt.Sprintf("~ %s/@%s: %q -> %q", edit.NewPath, edit.Attribute, edit.OldValue, edit.NewValue)
+		case ReorderAttributes:
+			line = fmt.Sprintf("~ %s: attribute order %q -> %q", edit.NewPath, edit.OldValue, edit.NewValue)
+		case ChangeText:
+			line = fmt.Sprintf("~ %s: %q -> %q", edit.NewPath, edit.OldValue, edit.NewValue)
+		default:
+			line = fmt.Sprintf("? %s %s -> %s", edit.Type, edit.OldPath, edit.NewPath)
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the report of the edits
+func (s Script) String() string {
+	buf := bytes.Buffer{}
+	s.Report(&buf)
+	return buf.String()
+}
+
+// nodeString returns the serialization of node as a quoted string
+func nodeString(node dom.Node) string {
+	switch node.GetNodeType() {
+	case dom.TEXT_NODE, dom.CDATA_SECTION_NODE:
+		return fmt.Sprintf("%q", node.GetTextContent())
+	}
+	buf := bytes.Buffer{}
+	if err := dom.Encode(node, &buf); err != nil {
+		return node.GetNodeName()
+	}
+	return fmt.Sprintf("%q", buf.String())
+}
@@ -24,6 +24,12 @@ type Document interface {
 	// Creates a text node.
 	CreateTextNode(string) Text
 
+	// Creates a CDATA section node.
+	CreateCDATASection(string) CDATASection
+
+	// Creates a new empty DocumentFragment.
+	CreateDocumentFragment() DocumentFragment
+
 	//Creates a new ProcessingInstruction object.
 	CreateProcessingInstruction(target, data string) ProcessingInstruction
 
@@ -33,6 +39,149 @@ type Document interface {
 	//	Adopt node from an external document.
 	AdoptNode(Node) Node
 
+	// Same as AdoptNode, but returns an error instead of panicking
+	AdoptNodeE(Node) (Node, error)
+
+	// Changes the prefix, namespace, and local name of an element or
+	// attribute of this document, and returns the node. The node is
+	// renamed in place. Returns an error if the node cannot be
+	// renamed, or if the owner element of an attribute already has
+	// an attribute with the new name.
+	RenameNode(node Node, prefix, ns, local string) (Node, error)
+
 	// Return the document type node
 	GetDocumentType() DocumentType
+
+	// Creates a document type node.
+	CreateDocumentType(name, publicID, systemID string) DocumentType
+
+	// Returns the version given in the XML declaration, or "" if
+	// the document has no XML declaration.
+	GetXMLVersion() string
+
+	// Sets the version of the XML declaration. If the version is
+	// "", the XML declaration is removed. The XML declaration is a
+	// property of the document, not a child node.
+	SetXMLVersion(string)
+
+	// Returns the encoding given in the XML declaration.
+	GetXMLEncoding() string
+
+	// Sets the encoding of the XML declaration.
+	SetXMLEncoding(string)
+
+	// Returns the standalone declaration of the XML declaration,
+	// "yes", "no", or "" if not declared.
+	GetXMLStandalone() string
+
+	// Sets the standalone declaration of the XML declaration.
+	SetXMLStandalone(string)
+
+	// Returns the first element in the document that matches the
+	// CSS selector, or nil if there is none.
+	QuerySelector(selector string) (Element, error)
+
+	// Returns a static NodeList containing all elements in the
+	// document matching the CSS selector in document order.
+	QuerySelectorAll(selector string) (NodeList, error)
+
+	// Returns a live HTMLCollection of the descendant elements with
+	// the given qualified name in document order. The name "*"
+	// matches all elements.
+	GetElementsByTagName(name string) HTMLCollection
+
+	// Returns a live HTMLCollection of the descendant elements with
+	// the given namespace URI and local name in document order. "*"
+	// matches any namespace or local name.
+	GetElementsByTagNameNS(uri, local string) HTMLCollection
+
+	// Returns a live HTMLCollection of the descendant elements that
+	// have all the given whitespace separated class names.
+	GetElementsByClassName(names string) HTMLCollection
+
+	// Returns the first element in document order whose ID is id, or
+	// nil if there is none. The ID attributes are determined by the
+	// IDOptions of the document.
+	GetElementById(id string) Element
+
+	// Resolves a whitespace separated list of IDREFS. Returns the
+	// elements in the order of the references, or a NOT_FOUND error
+	// for the first reference that cannot be resolved.
+	ResolveIDRefs(refs string) ([]Element, error)
+
+	// Returns the options that determine the ID attributes
+	GetIDOptions() IDOptions
+
+	// Sets the options that determine the ID attributes
+	SetIDOptions(IDOptions)
+
+	// Creates a NodeIterator over the subtree of root. Only the node
+	// types selected by whatToShow are passed to the filter. The
+	// filter may be nil. The iterator is updated when nodes are
+	// removed from the document until it is detached. Before Go
+	// 1.24, the document keeps the iterator until Detach is called,
+	// so Detach must be called when the iterator is no longer used.
+	CreateNodeIterator(root Node, whatToShow uint32, filter NodeFilter) NodeIterator
+
+	// Creates a TreeWalker over the subtree of root. Only the node
+	// types selected by whatToShow are passed to the filter. The
+	// filter may be nil.
+	CreateTreeWalker(root Node, whatToShow uint32, filter NodeFilter) TreeWalker
+
+	// Creates a MutationObserver that reports the changes in the
+	// nodes of this document. The callback is called when the DOM
+	// method that changed the document returns. If callback is nil,
+	// the records are collected until TakeRecords is called.
+	CreateMutationObserver(callback MutationCallback) MutationObserver
+
+	// Starts a transaction that records the changes to the document
+	// until it is committed or rolled back. If there is an active
+	// transaction, the new transaction is nested in it. Changes made
+	// to the document outside a transaction clear the undo and redo
+	// stacks.
+	Begin() Tx
+
+	// Calls f so that its changes are applied all together or not at
+	// all. If f returns an error or panics, the changes made by f are
+	// rolled back, and the error is returned or the panic continues.
+	// Otherwise, the changes become part of the active transaction,
+	// or if there is none, they are handled like changes made outside
+	// a transaction: they are not pushed to the undo stack, and they
+	// clear the undo and redo stacks.
+	Atomically(f func() error) error
+
+	// Undoes the changes of the last committed transaction. Returns
+	// false if there is nothing to undo. Panics if there is an active
+	// transaction.
+	Undo() bool
+
+	// Redoes the changes of the last undone transaction. Returns
+	// false if there is nothing to redo. Panics if there is an active
+	// transaction.
+	Redo() bool
+
+	// Returns true if there is a transaction to undo
+	CanUndo() bool
+
+	// Returns true if there is a transaction to redo
+	CanRedo() bool
+
+	// Inserts nodes or strings before the first child. Strings are
+	// inserted as Text nodes.
+	Prepend(nodes ...interface{})
+
+	// Inserts nodes or strings after the last child. Strings are
+	// inserted as Text nodes.
+	Append(nodes ...interface{})
+
+	// Replaces the children with nodes or strings. Strings are
+	// inserted as Text nodes.
+	ReplaceChildren(nodes ...interface{})
+
+	// The methods ending with E are the same as the corresponding
+	// methods, but they return the ErrDOM errors instead of
+	// panicking.
+	PrependE(nodes ...interface{}) error
+	AppendE(nodes ...interface{}) error
+	ReplaceChildrenE(nodes ...interface{}) error
 }
@@ -0,0 +1,35 @@
+package dom
+
+type DocumentFragment interface {
+	Node
+
+	GetFirstElementChild() Element
+	GetLastElementChild() Element
+
+	// Returns the first descendant element that matches the CSS
+	// selector, or nil if there is none.
+	QuerySelector(selector string) (Element, error)
+
+	// Returns a static NodeList containing all descendant elements
+	// matching the CSS selector in document order.
+	QuerySelectorAll(selector string) (NodeList, error)
+
+	// Inserts nodes or strings before the first child. Strings are
+	// inserted as Text nodes.
+	Prepend(nodes ...interface{})
+
+	// Inserts nodes or strings after the last child. Strings are
+	// inserted as Text nodes.
+	Append(nodes ...interface{})
+
+	// Replaces the children with nodes or strings. Strings are
+	// inserted as Text nodes.
+	ReplaceChildren(nodes ...interface{})
+
+	// The methods ending with E are the same as the corresponding
+	// methods, but they return the ErrDOM errors instead of
+	// panicking.
+	PrependE(nodes ...interface{}) error
+	AppendE(nodes ...interface{}) error
+	ReplaceChildrenE(nodes ...interface{}) error
+}
@@ -55,6 +55,40 @@ type Element interface {
 	// Removes the element from the children list of its parent.
 	Remove()
 
+	// Inserts nodes or strings before this element. Strings are
+	// inserted as Text nodes.
+	Before(nodes ...interface{})
+
+	// Inserts nodes or strings after this element. Strings are
+	// inserted as Text nodes.
+	After(nodes ...interface{})
+
+	// Replaces this element with nodes or strings. Strings are
+	// inserted as Text nodes.
+	ReplaceWith(nodes ...interface{})
+
+	// Inserts nodes or strings before the first child of this
+	// element. Strings are inserted as Text nodes.
+	Prepend(nodes ...interface{})
+
+	// Inserts nodes or strings after the last child of this
+	// element. Strings are inserted as Text nodes.
+	Append(nodes ...interface{})
+
+	// Replaces the children of this element with nodes or
+	// strings. Strings are inserted as Text nodes.
+	ReplaceChildren(nodes ...interface{})
+
+	// The methods ending with E are the same as the corresponding
+	// methods, but they return the ErrDOM errors instead of
+	// panicking.
+	BeforeE(nodes ...interface{}) error
+	AfterE(nodes ...interface{}) error
+	ReplaceWithE(nodes ...interface{}) error
+	PrependE(nodes ...interface{}) error
+	AppendE(nodes ...interface{}) error
+	ReplaceChildrenE(nodes ...interface{}) error
+
 	// Removes the named attribute from the current node.
 	RemoveAttribute(string)
 
@@ -76,6 +110,45 @@ type Element interface {
 	// Sets the value of the attribute with the specified name and
 	// namespace, from the current node.
 	SetAttributeNS(prefix, uri, name string, value string)
+
+	// Returns the first descendant element that matches the CSS
+	// selector, or nil if there is none. Namespace prefixes in the
+	// selector (ns|tag) are resolved using LookupNamespaceURI of this
+	// element.
+	QuerySelector(selector string) (Element, error)
+
+	// Returns a static NodeList containing all descendant elements
+	// matching the CSS selector in document order.
+	QuerySelectorAll(selector string) (NodeList, error)
+
+	// Returns a live HTMLCollection of the descendant elements with
+	// the given qualified name in document order. The name "*"
+	// matches all elements.
+	GetElementsByTagName(name string) HTMLCollection
+
+	// Returns a live HTMLCollection of the descendant elements with
+	// the given namespace URI and local name in document order. "*"
+	// matches any namespace or local name.
+	GetElementsByTagNameNS(uri, local string) HTMLCollection
+
+	// Returns a live HTMLCollection of the descendant elements that
+	// have all the given whitespace separated class names.
+	GetElementsByClassName(names string) HTMLCollection
+
+	// Returns the serialization of the children of the element.
+	// Namespaces in scope for the element are not declared again.
+	GetInnerXML() (string, error)
+
+	// Parses the XML content and replaces the children of the element
+	// with the parsed nodes. Prefixes are resolved using the
+	// namespaces in scope for the element. If the content cannot be
+	// parsed, the element is not changed.
+	SetInnerXML(content string) error
+
+	// Returns the serialization of the element and its
+	// descendants. Namespace declarations are added as necessary so
+	// the output is namespace well-formed on its own.
+	GetOuterXML() (string, error)
 }
 
 type NamedNodeMap interface {
@@ -323,3 +323,91 @@ func TestNamedNodeMap(t *testing.T) {
 		t.Errorf("a2 wrong")
 	}
 }
+
+func TestGetElementsBy(t *testing.T) {
+	input := `<root xmlns:x="urn:x"><a class="one two"><b/><x:a id="n" class="two"/></a><c><a class="one"/></c></root>`
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	names := func(c HTMLCollection) string {
+		s := make([]string, 0)
+		for i := 0; i < c.GetLength(); i++ {
+			s = append(s, c.Item(i).GetTagName())
+		}
+		return strings.Join(s, ",")
+	}
+	byTag := root.GetElementsByTagName("a")
+	if s := names(byTag); s != "a,a" {
+		t.Errorf("Wrong elements: %s", s)
+	}
+	if s := names(doc.GetElementsByTagName("*")); s != "root,a,b,x:a,c,a" {
+		t.Errorf("Wrong elements: %s", s)
+	}
+	byNS := root.GetElementsByTagNameNS("urn:x", "*")
+	if s := names(byNS); s != "x:a" {
+		t.Errorf("Wrong elements: %s", s)
+	}
+	if byNS.NamedItem("n") == nil || byNS.NamedItem("m") != nil {
+		t.Errorf("Wrong named item")
+	}
+	byClass := doc.GetElementsByClassName(" two  one ")
+	if byClass.GetLength() != 1 {
+		t.Errorf("Wrong class elements: %d", byClass.GetLength())
+	}
+
+	// Collections are live
+	c := root.GetLastChild().(Element)
+	c.AppendChild(doc.CreateElement("a"))
+	if s := names(byTag); s != "a,a,a" {
+		t.Errorf("Wrong elements after insert: %s", s)
+	}
+	c.GetFirstChild().(Element).SetAttribute("class", "two one")
+	if byClass.GetLength() != 2 {
+		t.Errorf("Wrong class elements after set: %d", byClass.GetLength())
+	}
+	root.RemoveChild(c)
+	if s := names(byTag); s != "a" {
+		t.Errorf("Wrong elements after remove: %s", s)
+	}
+	if byClass.GetLength() != 1 {
+		t.Errorf("Wrong class elements after remove: %d", byClass.GetLength())
+	}
+	root.GetFirstChild().(Element).RemoveAttribute("class")
+	if byClass.GetLength() != 0 {
+		t.Errorf("Wrong class elements after remove attribute: %d", byClass.GetLength())
+	}
+}
+
+func TestRenameNode(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<r xmlns:p="u"><p:c p:a="1" b="2"/></r>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := doc.GetDocumentElement().GetFirstElementChild()
+	a := c.GetAttributeNodeNS("u", "a")
+	tx := doc.Begin()
+	if _, err := doc.RenameNode(c, "q", "v", "d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.RenameNode(a, "q", "v", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetQName().Space != "v" || c.GetTagName() != "q:d" {
+		t.Errorf("Element not renamed: %v", c.GetQName())
+	}
+	if c.GetAttributeNodeNS("u", "a") != nil || c.GetAttributeNodeNS("v", "a") != a || c.GetAttributes().Item(0) != a {
+		t.Errorf("Attribute not renamed")
+	}
+	if _, err := doc.RenameNode(a, "", "", "b"); err == nil {
+		t.Errorf("Expecting error for duplicate attribute")
+	}
+	if _, err := doc.RenameNode(doc.CreateTextNode("x"), "", "", "x"); err == nil {
+		t.Errorf("Expecting error for text node")
+	}
+	tx.Rollback()
+	if c.GetTagName() != "p:c" || c.GetAttributeNodeNS("u", "a") != a {
+		t.Errorf("Rename not rolled back")
+	}
+}
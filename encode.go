@@ -3,13 +3,83 @@ package dom
 import (
 	"bufio"
 	"io"
+	"strings"
 	"unicode/utf8"
 )
 
+// WhitespaceMode determines how whitespace-only text nodes are
+// written
+type WhitespaceMode int
+
+const (
+	// Whitespace-only text nodes are written as they are, unless the
+	// output is indented. When indenting, whitespace-only text nodes
+	// in element-only content are replaced by indentation.
+	WhitespaceAuto WhitespaceMode = iota
+
+	// Whitespace-only text nodes are always written. Elements
+	// containing them are not indented.
+	WhitespacePreserve
+
+	// Whitespace-only text nodes in element-only content are
+	// removed.
+	WhitespaceStrip
+)
+
+// EncodeOptions control the formatting of the encoded output.
+// Indentation and whitespace removal are only applied to
+// element-only content, so the meaning of mixed content does not
+// change. Elements with xml:space="preserve" and their descendants
+// are written as they are.
+type EncodeOptions struct {
+	// Indent is the string used for each level of indentation. If
+	// empty, the output is not indented.
+	Indent string
+
+	// Newline is the line terminator used when indenting or wrapping
+	// attributes. The default is "\n".
+	Newline string
+
+	// If WrapAttributes is greater than zero, elements with more
+	// than WrapAttributes attributes are written with every
+	// attribute on a separate line.
+	WrapAttributes int
+
+	// If set, elements without children are written as self-closing
+	// tags.
+	SelfClosing bool
+
+	// Whitespace determines how whitespace-only text nodes are
+	// written.
+	Whitespace WhitespaceMode
+
+	// If set, the output is written using the HTML syntax: void
+	// elements have no end tags, the contents of raw text elements
+	// such as script and style are not escaped, boolean attributes
+	// are written using only their names, and text is escaped using
+	// the HTML rules. Elements without a namespace are treated as
+	// HTML elements.
+	HTML bool
+}
+
+// Encode writes the node as XML
 func Encode(node Node, writer io.Writer) error {
+	return EncodeWithOptions(node, writer, EncodeOptions{})
+}
+
+// EncodeWithOptions writes the node as XML formatted using the given
+// options
+func EncodeWithOptions(node Node, writer io.Writer, options EncodeOptions) error {
 	out := bufio.NewWriter(writer)
-	defer out.Flush()
-	return encodeNode(node, out)
+	if len(options.Newline) == 0 {
+		options.Newline = "\n"
+	}
+	enc := encoder{out: out, options: options}
+	if err := enc.encode(node, 0, true, inheritsPreserve(node)); err != nil {
+		out.Flush()
+		return err
+	}
+	return out.Flush()
 }
 
 var (
@@ -84,78 +154,346 @@ func escapeText(w io.Writer, s []byte, escapeNewline bool) error {
 	return err
 }
 
-func encodeNode(node Node, out *bufio.Writer) error {
-	space := func() error {
-		_, err := out.WriteRune(' ')
-		return err
+type encoder struct {
+	out     *bufio.Writer
+	options EncodeOptions
+}
+
+func isWhitespace(s string) bool {
+	for _, r := range s {
+		if r != ' ' && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
 	}
-	writeName := func(n Name) error {
-		if len(n.Prefix) > 0 {
-			if _, err := out.WriteString(n.Prefix); err != nil {
-				return err
-			}
-			if _, err := out.WriteRune(':'); err != nil {
-				return err
+	return true
+}
+
+// isElementOnly returns true if the node has element children, and
+// no text children other than whitespace-only text. If whitespace is
+// preserved, whitespace text makes the content mixed as well.
+func (enc *encoder) isElementOnly(node Node, preserve bool) bool {
+	if preserve {
+		return false
+	}
+	hasElement := false
+	for c := node.GetFirstChild(); c != nil; c = c.GetNextSibling() {
+		switch c.GetNodeType() {
+		case ELEMENT_NODE:
+			hasElement = true
+		case CDATA_SECTION_NODE:
+			return false
+		case TEXT_NODE:
+			if enc.options.Whitespace == WhitespacePreserve || !isWhitespace(c.(Text).GetValue()) {
+				return false
 			}
 		}
-		_, err := out.WriteString(n.Local)
+	}
+	return hasElement
+}
+
+// skipText returns true if the text node should not be written in
+// element-only content
+func (enc *encoder) skipText(node Node, indent bool) bool {
+	if node.GetNodeType() != TEXT_NODE || !isWhitespace(node.(Text).GetValue()) {
+		return false
+	}
+	switch enc.options.Whitespace {
+	case WhitespaceStrip:
+		return true
+	case WhitespaceAuto:
+		return indent && len(enc.options.Indent) > 0
+	}
+	return false
+}
+
+func (enc *encoder) newline(depth int) error {
+	if _, err := enc.out.WriteString(enc.options.Newline); err != nil {
 		return err
 	}
-	writeAttrValue := func(value string) error {
-		if _, err := out.WriteRune('"'); err != nil {
+	for i := 0; i < depth; i++ {
+		if _, err := enc.out.WriteString(enc.options.Indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (enc *encoder) writeName(n Name) error {
+	if len(n.Prefix) > 0 {
+		if _, err := enc.out.WriteString(n.Prefix); err != nil {
 			return err
 		}
-		if err := escapeText(out, []byte(value), true); err != nil {
+		if _, err := enc.out.WriteRune(':'); err != nil {
 			return err
 		}
-		_, err := out.WriteRune('"')
+	}
+	_, err := enc.out.WriteString(n.Local)
+	return err
+}
+
+// writeElementName writes the element name. In HTML mode, elements
+// in the HTML, SVG, and MathML namespaces are written using their
+// local names.
+func (enc *encoder) writeElementName(el *BasicElement) error {
+	name := el.GetQName()
+	if enc.options.HTML {
+		switch name.Space {
+		case XHTMLNamespace, SVGNamespace, MathMLNamespace:
+			name.Prefix = ""
+		}
+	}
+	return enc.writeName(name)
+}
+
+func (enc *encoder) writeAttrValue(value string) error {
+	if _, err := enc.out.WriteRune('"'); err != nil {
+		return err
+	}
+	if enc.options.HTML {
+		if err := escapeHTML(enc.out, value, true); err != nil {
+			return err
+		}
+	} else if err := escapeText(enc.out, []byte(value), true); err != nil {
+		return err
+	}
+	_, err := enc.out.WriteRune('"')
+	return err
+}
+
+// writeLiteral writes a system or public ID literal. The literal is
+// quoted using single quotes if it contains a double quote.
+func (enc *encoder) writeLiteral(value string) error {
+	quote := '"'
+	if strings.ContainsRune(value, '"') {
+		quote = '\''
+	}
+	if _, err := enc.out.WriteRune(quote); err != nil {
+		return err
+	}
+	if _, err := enc.out.WriteString(value); err != nil {
 		return err
 	}
-	writeCharData := func(value string) error {
-		return escapeText(out, []byte(value), false)
+	_, err := enc.out.WriteRune(quote)
+	return err
+}
+
+// hasXMLDeclaration returns true if the XML declaration of doc is
+// written. HTML documents are written without an XML declaration.
+func (enc *encoder) hasXMLDeclaration(doc *BasicDocument) bool {
+	return len(doc.xmlDecl.version) > 0 && !enc.options.HTML
+}
+
+// writeXMLDeclaration writes the XML declaration of doc, if it has
+// one
+func (enc *encoder) writeXMLDeclaration(doc *BasicDocument) error {
+	if !enc.hasXMLDeclaration(doc) {
+		return nil
+	}
+	if _, err := enc.out.WriteString("<?xml " + doc.xmlDecl.String() + "?>"); err != nil {
+		return err
 	}
+	return nil
+}
+
+func (enc *encoder) writeCharData(value string) error {
+	if enc.options.HTML {
+		return escapeHTML(enc.out, value, false)
+	}
+	return escapeText(enc.out, []byte(value), false)
+}
+
+// encodeChildren writes the children of node. If indent is set, and
+// the node has element-only content, each child is written on a
+// separate line. Returns true if children are written on separate
+// lines. If preserve is set, the children are in the scope of
+// xml:space="preserve", and they are written as they are.
+func (enc *encoder) encodeChildren(node Node, depth int, indent, preserve bool) (bool, error) {
+	elementOnly := enc.isElementOnly(node, preserve)
+	indent = indent && elementOnly && len(enc.options.Indent) > 0
+	// The first child of a document starts a new line only if it
+	// follows the XML declaration
+	newlineFirst := true
+	if doc, isDoc := node.(*BasicDocument); isDoc {
+		newlineFirst = enc.hasXMLDeclaration(doc)
+	}
+	wrote := false
+	for c := node.GetFirstChild(); c != nil; c = c.GetNextSibling() {
+		if elementOnly && enc.skipText(c, indent) {
+			continue
+		}
+		if indent {
+			if newlineFirst || wrote {
+				if err := enc.newline(depth + 1); err != nil {
+					return false, err
+				}
+			}
+		}
+		if err := enc.encode(c, depth+1, indent, preserve); err != nil {
+			return false, err
+		}
+		wrote = true
+	}
+	return indent && wrote, nil
+}
+
+// preservesSpace returns true if the element has xml:space="preserve".
+// If the element does not have xml:space, inherited is returned.
+func preservesSpace(el *BasicElement, inherited bool) bool {
+	for _, attr := range el.attributes.attrs {
+		if attr.name.Local == "space" && (attr.name.Space == xmlURL || attr.name.Prefix == xmlPrefix) {
+			return attr.value == "preserve"
+		}
+	}
+	return inherited
+}
+
+// inheritsPreserve returns true if node is in the scope of an
+// xml:space="preserve" declared on an ancestor
+func inheritsPreserve(node Node) bool {
+	var ancestors []*BasicElement
+	for trc := node.GetParentNode(); trc != nil; trc = trc.GetParentNode() {
+		if el, ok := trc.(*BasicElement); ok {
+			ancestors = append(ancestors, el)
+		}
+	}
+	preserve := false
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		preserve = preservesSpace(ancestors[i], preserve)
+	}
+	return preserve
+}
+
+func (enc *encoder) encode(node Node, depth int, indent, preserve bool) error {
+	out := enc.out
 	switch ch := node.(type) {
 	case *BasicDocument:
-		for c := ch.GetFirstChild(); c != nil; c = c.GetNextSibling() {
-			if err := encodeNode(c, out); err != nil {
+		if err := enc.writeXMLDeclaration(ch); err != nil {
+			return err
+		}
+		// Children of the document are at the same level as the
+		// document
+		if _, err := enc.encodeChildren(ch, depth-1, indent, preserve); err != nil {
+			return err
+		}
+
+	case *BasicDocumentType:
+		if _, err := out.WriteString("<!DOCTYPE "); err != nil {
+			return err
+		}
+		if _, err := out.WriteString(ch.name); err != nil {
+			return err
+		}
+		if enc.options.HTML {
+			// HTML doctypes are written without identifiers
+			_, err := out.WriteRune('>')
+			return err
+		}
+		if len(ch.publicID) > 0 {
+			if _, err := out.WriteString(" PUBLIC "); err != nil {
+				return err
+			}
+			if err := enc.writeLiteral(ch.publicID); err != nil {
+				return err
+			}
+			if _, err := out.WriteRune(' '); err != nil {
+				return err
+			}
+			if err := enc.writeLiteral(ch.systemID); err != nil {
+				return err
+			}
+		} else if len(ch.systemID) > 0 {
+			if _, err := out.WriteString(" SYSTEM "); err != nil {
+				return err
+			}
+			if err := enc.writeLiteral(ch.systemID); err != nil {
 				return err
 			}
 		}
+		if len(ch.defn) > 0 {
+			if _, err := out.WriteRune(' '); err != nil {
+				return err
+			}
+			if _, err := out.WriteString(ch.defn); err != nil {
+				return err
+			}
+		}
+		if _, err := out.WriteRune('>'); err != nil {
+			return err
+		}
+
+	case *BasicDocumentFragment:
+		if _, err := enc.encodeChildren(ch, depth-1, false, preserve); err != nil {
+			return err
+		}
 
 	case *BasicElement:
 		if _, err := out.WriteRune('<'); err != nil {
 			return err
 		}
-		if err := writeName(ch.GetQName()); err != nil {
+		if err := enc.writeElementName(ch); err != nil {
 			return err
 		}
 		attrs := ch.GetAttributes()
+		wrap := enc.options.WrapAttributes > 0 && attrs.GetLength() > enc.options.WrapAttributes
 		for i := 0; i < attrs.GetLength(); i++ {
-			if err := space(); err != nil {
+			if wrap {
+				if err := enc.newline(depth + 1); err != nil {
+					return err
+				}
+			} else if _, err := out.WriteRune(' '); err != nil {
 				return err
 			}
 			attr := attrs.Item(i)
-			if err := writeName(attr.GetQName()); err != nil {
+			if err := enc.writeName(attr.GetQName()); err != nil {
 				return err
 			}
+			if enc.options.HTML && isHTMLBooleanAttribute(ch, attr) {
+				continue
+			}
 			if _, err := out.WriteRune('='); err != nil {
 				return err
 			}
-			writeAttrValue(attr.GetValue())
+			if err := enc.writeAttrValue(attr.GetValue()); err != nil {
+				return err
+			}
+		}
+		_, isHTMLElement := htmlElementName(ch)
+		if enc.options.SelfClosing && !ch.HasChildNodes() && !(enc.options.HTML && isHTMLElement) {
+			_, err := out.WriteString("/>")
+			return err
 		}
 		if _, err := out.WriteRune('>'); err != nil {
 			return err
 		}
-
-		for c := ch.GetFirstChild(); c != nil; c = c.GetNextSibling() {
-			if err := encodeNode(c, out); err != nil {
+		if enc.options.HTML && isHTMLVoidElement(ch) {
+			return nil
+		}
+		preserve = preservesSpace(ch, preserve)
+		if preserve {
+			indent = false
+		}
+		if enc.options.HTML && isHTMLPreformatted(ch) {
+			indent = false
+			// The parser drops a newline following the start tag
+			if t, ok := ch.GetFirstChild().(*BasicText); ok && strings.HasPrefix(t.GetValue(), "\n") && !isHTMLRawTextParent(ch) {
+				if _, err := out.WriteRune('\n'); err != nil {
+					return err
+				}
+			}
+		}
+		multiline, err := enc.encodeChildren(ch, depth, indent, preserve)
+		if err != nil {
+			return err
+		}
+		if multiline {
+			if err := enc.newline(depth); err != nil {
 				return err
 			}
 		}
 		if _, err := out.WriteString("</"); err != nil {
 			return err
 		}
-		if err := writeName(ch.GetQName()); err != nil {
+		if err := enc.writeElementName(ch); err != nil {
 			return err
 		}
 		if _, err := out.WriteRune('>'); err != nil {
@@ -166,7 +504,11 @@ func encodeNode(node Node, out *bufio.Writer) error {
 		if _, err := out.WriteString("<!--"); err != nil {
 			return err
 		}
-		if err := writeCharData(ch.GetValue()); err != nil {
+		if enc.options.HTML {
+			if _, err := out.WriteString(ch.GetValue()); err != nil {
+				return err
+			}
+		} else if err := enc.writeCharData(ch.GetValue()); err != nil {
 			return err
 		}
 		if _, err := out.WriteString("-->"); err != nil {
@@ -174,10 +516,36 @@ func encodeNode(node Node, out *bufio.Writer) error {
 		}
 
 	case *BasicText:
-		if err := writeCharData(ch.GetValue()); err != nil {
+		if enc.options.HTML && isHTMLRawTextParent(ch.GetParentNode()) {
+			if _, err := out.WriteString(ch.GetValue()); err != nil {
+				return err
+			}
+		} else if err := enc.writeCharData(ch.GetValue()); err != nil {
 			return err
 		}
 
+	case *BasicCDATASection:
+		// A CDATA section cannot contain "]]>", so split it into
+		// multiple sections
+		parts := strings.Split(ch.GetValue(), "]]>")
+		for i, part := range parts {
+			if i > 0 {
+				part = ">" + part
+			}
+			if i < len(parts)-1 {
+				part += "]]"
+			}
+			if _, err := out.WriteString("<![CDATA["); err != nil {
+				return err
+			}
+			if _, err := out.WriteString(part); err != nil {
+				return err
+			}
+			if _, err := out.WriteString("]]>"); err != nil {
+				return err
+			}
+		}
+
 	case *BasicProcessingInstruction:
 		if _, err := out.WriteString("<?"); err != nil {
 			return err
@@ -185,13 +553,17 @@ func encodeNode(node Node, out *bufio.Writer) error {
 		if _, err := out.WriteString(ch.GetTarget()); err != nil {
 			return err
 		}
-		if err := space(); err != nil {
+		if _, err := out.WriteRune(' '); err != nil {
 			return err
 		}
 		if _, err := out.WriteString(ch.GetValue()); err != nil {
 			return err
 		}
-		if _, err := out.WriteString("?>"); err != nil {
+		end := "?>"
+		if enc.options.HTML {
+			end = ">"
+		}
+		if _, err := out.WriteString(end); err != nil {
 			return err
 		}
 
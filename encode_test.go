@@ -0,0 +1,96 @@
+package dom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestEncodeIndent(t *testing.T) {
+	input := `<config>
+  <server name="a" port="80" host="localhost"><enabled></enabled></server>
+     <desc>Some <b>mixed</b> <i><u>content</u></i></desc>
+  <!--comment-->
+  <pre xml:space="preserve"><x><y></y></x></pre>
+</config>`
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(options EncodeOptions) string {
+		buf := bytes.Buffer{}
+		if err := EncodeWithOptions(doc, &buf, options); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	expected := `<config>
+  <server name="a" port="80" host="localhost">
+    <enabled/>
+  </server>
+  <desc>Some <b>mixed</b> <i><u>content</u></i></desc>
+  <!--comment-->
+  <pre xml:space="preserve"><x><y/></x></pre>
+</config>`
+	if s := encode(EncodeOptions{Indent: "  ", SelfClosing: true}); s != expected {
+		t.Errorf("Got %s", s)
+	}
+
+	expected = "<config>\r\n\t<server\r\n\t\tname=\"a\"\r\n\t\tport=\"80\"\r\n\t\thost=\"localhost\">\r\n\t\t<enabled></enabled>\r\n\t</server>"
+	if s := encode(EncodeOptions{Indent: "\t", Newline: "\r\n", WrapAttributes: 2}); !strings.HasPrefix(s, expected) {
+		t.Errorf("Got %s", s)
+	}
+
+	expected = `<config><server name="a" port="80" host="localhost"><enabled></enabled></server><desc>Some <b>mixed</b> <i><u>content</u></i></desc><!--comment--><pre xml:space="preserve"><x><y></y></x></pre></config>`
+	if s := encode(EncodeOptions{Whitespace: WhitespaceStrip}); s != expected {
+		t.Errorf("Got %s", s)
+	}
+
+	// Whitespace is preserved, so nothing is indented
+	if s := encode(EncodeOptions{Indent: "  ", Whitespace: WhitespacePreserve}); s != input {
+		t.Errorf("Got %s", s)
+	}
+	if s := encode(EncodeOptions{}); s != input {
+		t.Errorf("Got %s", s)
+	}
+}
+
+func TestEncodeWhitespace(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  EncodeOptions
+		expected string
+	}{
+		// Whitespace in leaf elements is content
+		{`<r><a> </a></r>`, EncodeOptions{Indent: "  "}, "<r>\n  <a> </a>\n</r>"},
+		{`<r><a> </a> </r>`, EncodeOptions{Whitespace: WhitespaceStrip}, `<r><a> </a></r>`},
+		// xml:space="preserve" applies to the descendants
+		{`<r xml:space="preserve"><a> <b/> </a></r>`, EncodeOptions{Whitespace: WhitespaceStrip}, `<r xml:space="preserve"><a> <b></b> </a></r>`},
+		{`<r xml:space="preserve"><a> <b/> </a></r>`, EncodeOptions{Indent: "  "}, `<r xml:space="preserve"><a> <b></b> </a></r>`},
+		{`<r xml:space="preserve"><a xml:space="default"> <b/> </a></r>`, EncodeOptions{Whitespace: WhitespaceStrip}, `<r xml:space="preserve"><a xml:space="default"><b></b></a></r>`},
+	}
+	for _, test := range tests {
+		doc, err := Parse(xml.NewDecoder(strings.NewReader(test.input)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.Buffer{}
+		if err := EncodeWithOptions(doc, &buf, test.options); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, buf.String())
+		}
+	}
+
+	// The scope of xml:space is inherited when a subtree is written
+	doc, _ := Parse(xml.NewDecoder(strings.NewReader(`<r xml:space="preserve"><a> <b/> </a></r>`)))
+	buf := bytes.Buffer{}
+	if err := EncodeWithOptions(doc.GetDocumentElement().GetFirstChild(), &buf, EncodeOptions{Whitespace: WhitespaceStrip}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != `<a> <b></b> </a>` {
+		t.Errorf("Got %s", buf.String())
+	}
+}
@@ -0,0 +1,112 @@
+package dom
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// EncodeHTML writes the node using the HTML syntax
+func EncodeHTML(node Node, writer io.Writer) error {
+	return EncodeWithOptions(node, writer, EncodeOptions{HTML: true})
+}
+
+var htmlVoidElements = map[string]bool{}
+var htmlRawTextElements = map[string]bool{}
+var htmlBooleanAttributes = map[string]bool{}
+
+func init() {
+	for _, name := range strings.Fields(`area base basefont bgsound br col embed frame hr img input keygen
+		link meta param source track wbr`) {
+		htmlVoidElements[name] = true
+	}
+	for _, name := range strings.Fields(`iframe noembed noframes noscript plaintext script style xmp`) {
+		htmlRawTextElements[name] = true
+	}
+	for _, name := range strings.Fields(`allowfullscreen async autofocus autoplay checked controls default
+		defer disabled formnovalidate hidden inert ismap itemscope loop multiple muted nomodule novalidate
+		open playsinline readonly required reversed selected`) {
+		htmlBooleanAttributes[name] = true
+	}
+}
+
+// htmlElementName returns the local name of el if it is an HTML
+// element. Elements without a namespace are treated as HTML
+// elements.
+func htmlElementName(el *BasicElement) (string, bool) {
+	if el.name.Space != XHTMLNamespace && el.name.Space != "" {
+		return "", false
+	}
+	return strings.ToLower(el.name.Local), true
+}
+
+func isHTMLVoidElement(el *BasicElement) bool {
+	name, ok := htmlElementName(el)
+	return ok && htmlVoidElements[name]
+}
+
+// isHTMLRawTextParent returns true if node is an element whose text
+// content is written without escaping
+func isHTMLRawTextParent(node Node) bool {
+	el, ok := node.(*BasicElement)
+	if !ok {
+		return false
+	}
+	name, ok := htmlElementName(el)
+	return ok && htmlRawTextElements[name]
+}
+
+// isHTMLPreformatted returns true if whitespace is significant in
+// the element contents
+func isHTMLPreformatted(el *BasicElement) bool {
+	name, ok := htmlElementName(el)
+	return ok && (name == "pre" || name == "textarea" || name == "listing" || htmlRawTextElements[name])
+}
+
+// isHTMLBooleanAttribute returns true if the attribute can be
+// written using only its name
+func isHTMLBooleanAttribute(el *BasicElement, attr Attr) bool {
+	if _, ok := htmlElementName(el); !ok {
+		return false
+	}
+	name := attr.GetQName()
+	if len(name.Space) > 0 || !htmlBooleanAttributes[strings.ToLower(name.Local)] {
+		return false
+	}
+	value := attr.GetValue()
+	return len(value) == 0 || strings.EqualFold(value, name.Local)
+}
+
+// escapeHTML writes s using the HTML escaping rules. In attribute
+// values, quotes are escaped. In text, < and > are escaped.
+func escapeHTML(w io.Writer, s string, inAttr bool) error {
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		i += width
+		var esc string
+		switch {
+		case r == '&':
+			esc = "&amp;"
+		case r == '\u00a0':
+			esc = "&nbsp;"
+		case r == '"' && inAttr:
+			esc = "&quot;"
+		case r == '<' && !inAttr:
+			esc = "&lt;"
+		case r == '>' && !inAttr:
+			esc = "&gt;"
+		default:
+			continue
+		}
+		if _, err := io.WriteString(w, s[last:i-width]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, esc); err != nil {
+			return err
+		}
+		last = i
+	}
+	_, err := io.WriteString(w, s[last:])
+	return err
+}
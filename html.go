@@ -0,0 +1,1902 @@
+package dom
+
+import (
+	"io"
+	"strings"
+)
+
+// Namespaces of HTML documents
+const (
+	XHTMLNamespace  = "http://www.w3.org/1999/xhtml"
+	SVGNamespace    = "http://www.w3.org/2000/svg"
+	MathMLNamespace = "http://www.w3.org/1998/Math/MathML"
+	xlinkNamespace  = "http://www.w3.org/1999/xlink"
+)
+
+// ParseHTML parses an HTML document using the tree construction
+// algorithm of the WHATWG HTML specification. Elements are created
+// in the XHTML namespace, or in the SVG and MathML namespaces for
+// foreign content.
+//
+// The input is expected to be UTF-8. Scripting is assumed to be
+// enabled, so noscript contents are parsed as text. Template
+// contents are parsed as ordinary children, and quirks mode is not
+// implemented.
+func ParseHTML(r io.Reader) (Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &htmlParser{
+		tokenizer:  newHTMLTokenizer(string(data)),
+		doc:        NewDocument().(*BasicDocument),
+		mode:       htmlInitialMode,
+		framesetOK: true,
+	}
+	p.parse()
+	return p.doc, nil
+}
+
+type htmlInsertionMode int
+
+const (
+	htmlInitialMode htmlInsertionMode = iota
+	htmlBeforeHTMLMode
+	htmlBeforeHeadMode
+	htmlInHeadMode
+	htmlAfterHeadMode
+	htmlInBodyMode
+	htmlTextMode
+	htmlInTableMode
+	htmlInCaptionMode
+	htmlInColumnGroupMode
+	htmlInTableBodyMode
+	htmlInRowMode
+	htmlInCellMode
+	htmlInSelectMode
+	htmlInSelectInTableMode
+	htmlInTemplateMode
+	htmlAfterBodyMode
+	htmlInFramesetMode
+	htmlAfterFramesetMode
+	htmlAfterAfterBodyMode
+)
+
+type htmlParser struct {
+	tokenizer *htmlTokenizer
+	doc       *BasicDocument
+
+	mode         htmlInsertionMode
+	originalMode htmlInsertionMode
+
+	// Stack of open elements
+	oe []*BasicElement
+	// List of active formatting elements. nil entries are markers.
+	afe []*BasicElement
+	// Stack of template insertion modes
+	templateModes []htmlInsertionMode
+
+	head *BasicElement
+	form *BasicElement
+
+	framesetOK      bool
+	fosterParenting bool
+	// Skip a newline at the start of pre, listing, and textarea
+	skipNewline bool
+}
+
+func (p *htmlParser) parse() {
+	for {
+		tok := p.tokenizer.next()
+		if tok.typ == htmlTextToken && p.skipNewline {
+			tok.data = strings.TrimPrefix(tok.data, "\n")
+			if len(tok.data) == 0 {
+				continue
+			}
+		}
+		p.skipNewline = false
+		p.dispatch(&tok)
+		if tok.typ == htmlEOFToken {
+			return
+		}
+		current := p.currentNode()
+		p.tokenizer.allowCDATA = current != nil && current.name.Space != XHTMLNamespace
+	}
+}
+
+// Element helpers
+
+func isHTML(el *BasicElement, names ...string) bool {
+	if el == nil || el.name.Space != XHTMLNamespace {
+		return false
+	}
+	if len(names) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if el.name.Local == n {
+			return true
+		}
+	}
+	return false
+}
+
+func isIn(name string, names ...string) bool {
+	for _, n := range names {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
+
+var htmlSpecialElements = map[string]bool{}
+
+func init() {
+	for _, name := range strings.Fields(`address applet area article aside base basefont bgsound blockquote body br
+		button caption center col colgroup dd details dir div dl dt embed fieldset figcaption figure footer form
+		frame frameset h1 h2 h3 h4 h5 h6 head header hgroup hr html iframe img input keygen li link listing main
+		marquee menu meta nav noembed noframes noscript object ol p param plaintext pre script search section
+		select source style summary table tbody td template textarea tfoot th thead title tr track ul wbr xmp`) {
+		htmlSpecialElements[name] = true
+	}
+}
+
+func isSpecial(el *BasicElement) bool {
+	switch el.name.Space {
+	case XHTMLNamespace:
+		return htmlSpecialElements[el.name.Local]
+	case MathMLNamespace:
+		return isIn(el.name.Local, "mi", "mo", "mn", "ms", "mtext", "annotation-xml")
+	case SVGNamespace:
+		return isIn(el.name.Local, "foreignObject", "desc", "title")
+	}
+	return false
+}
+
+func isMathMLTextIntegrationPoint(el *BasicElement) bool {
+	return el.name.Space == MathMLNamespace && isIn(el.name.Local, "mi", "mo", "mn", "ms", "mtext")
+}
+
+func isHTMLIntegrationPoint(el *BasicElement) bool {
+	if el.name.Space == SVGNamespace {
+		return isIn(el.name.Local, "foreignObject", "desc", "title")
+	}
+	if el.name.Space == MathMLNamespace && el.name.Local == "annotation-xml" {
+		enc, _ := el.GetAttribute("encoding")
+		enc = strings.ToLower(enc)
+		return enc == "text/html" || enc == "application/xhtml+xml"
+	}
+	return false
+}
+
+func (p *htmlParser) currentNode() *BasicElement {
+	if len(p.oe) == 0 {
+		return nil
+	}
+	return p.oe[len(p.oe)-1]
+}
+
+func (p *htmlParser) pop() *BasicElement {
+	el := p.oe[len(p.oe)-1]
+	p.oe = p.oe[:len(p.oe)-1]
+	return el
+}
+
+// popUntil pops elements until an HTML element with one of the
+// names is popped
+func (p *htmlParser) popUntil(names ...string) {
+	for len(p.oe) > 0 {
+		if isHTML(p.pop(), names...) {
+			return
+		}
+	}
+}
+
+func indexOf(list []*BasicElement, el *BasicElement) int {
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i] == el {
+			return i
+		}
+	}
+	return -1
+}
+
+func removeAt(list []*BasicElement, i int) []*BasicElement {
+	return append(list[:i], list[i+1:]...)
+}
+
+func insertAt(list []*BasicElement, i int, el *BasicElement) []*BasicElement {
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = el
+	return list
+}
+
+// Element scopes
+type htmlScope int
+
+const (
+	htmlDefaultScope htmlScope = iota
+	htmlListItemScope
+	htmlButtonScope
+	htmlTableScope
+	htmlSelectScope
+)
+
+// isScopeBoundary returns true if el ends the scope
+func isScopeBoundary(scope htmlScope, el *BasicElement) bool {
+	switch scope {
+	case htmlTableScope:
+		return isHTML(el, "html", "table", "template")
+	case htmlSelectScope:
+		return !isHTML(el, "optgroup", "option")
+	case htmlListItemScope:
+		if isHTML(el, "ol", "ul") {
+			return true
+		}
+	case htmlButtonScope:
+		if isHTML(el, "button") {
+			return true
+		}
+	}
+	if isHTML(el, "applet", "caption", "html", "table", "td", "th", "marquee", "object", "template") {
+		return true
+	}
+	switch el.name.Space {
+	case MathMLNamespace:
+		return isMathMLTextIntegrationPoint(el) || el.name.Local == "annotation-xml"
+	case SVGNamespace:
+		return isIn(el.name.Local, "foreignObject", "desc", "title")
+	}
+	return false
+}
+
+// inScope returns true if there is an HTML element with one of the
+// names in the given scope
+func (p *htmlParser) inScope(scope htmlScope, names ...string) bool {
+	for i := len(p.oe) - 1; i >= 0; i-- {
+		el := p.oe[i]
+		if isHTML(el, names...) {
+			return true
+		}
+		if isScopeBoundary(scope, el) {
+			return false
+		}
+	}
+	return false
+}
+
+// generateImpliedEndTags pops elements with implied end tags other
+// than except
+func (p *htmlParser) generateImpliedEndTags(except ...string) {
+	for {
+		current := p.currentNode()
+		if !isHTML(current, "dd", "dt", "li", "optgroup", "option", "p", "rb", "rp", "rt", "rtc") || isHTML(current, except...) {
+			return
+		}
+		p.pop()
+	}
+}
+
+func (p *htmlParser) generateAllImpliedEndTags() {
+	for isHTML(p.currentNode(), "caption", "colgroup", "dd", "dt", "li", "optgroup", "option", "p", "rb", "rp", "rt", "rtc", "tbody", "td", "tfoot", "th", "thead", "tr") {
+		p.pop()
+	}
+}
+
+func (p *htmlParser) closePElement() {
+	p.generateImpliedEndTags("p")
+	p.popUntil("p")
+}
+
+func (p *htmlParser) closePInButtonScope() {
+	if p.inScope(htmlButtonScope, "p") {
+		p.closePElement()
+	}
+}
+
+// Node creation and insertion
+
+var svgTagNames = map[string]string{}
+var svgAttrNames = map[string]string{}
+
+func init() {
+	for _, name := range strings.Fields(`altGlyph altGlyphDef altGlyphItem animateColor animateMotion
+		animateTransform clipPath feBlend feColorMatrix feComponentTransfer feComposite feConvolveMatrix
+		feDiffuseLighting feDisplacementMap feDistantLight feDropShadow feFlood feFuncA feFuncB feFuncG feFuncR
+		feGaussianBlur feImage feMerge feMergeNode feMorphology feOffset fePointLight feSpecularLighting
+		feSpotLight feTile feTurbulence foreignObject glyphRef linearGradient radialGradient textPath`) {
+		svgTagNames[strings.ToLower(name)] = name
+	}
+	for _, name := range strings.Fields(`attributeName attributeType baseFrequency baseProfile calcMode
+		clipPathUnits diffuseConstant edgeMode filterUnits glyphRef gradientTransform gradientUnits
+		kernelMatrix kernelUnitLength keyPoints keySplines keyTimes lengthAdjust limitingConeAngle markerHeight
+		markerUnits markerWidth maskContentUnits maskUnits numOctaves pathLength patternContentUnits
+		patternTransform patternUnits pointsAtX pointsAtY pointsAtZ preserveAlpha preserveAspectRatio
+		primitiveUnits refX refY repeatCount repeatDur requiredExtensions requiredFeatures specularConstant
+		specularExponent spreadMethod startOffset stdDeviation stitchTiles surfaceScale systemLanguage
+		tableValues targetX targetY textLength viewBox viewTarget xChannelSelector yChannelSelector zoomAndPan`) {
+		svgAttrNames[strings.ToLower(name)] = name
+	}
+}
+
+// createElement creates an element for the token in the namespace
+func (p *htmlParser) createElement(tok *htmlToken, ns string) *BasicElement {
+	name := tok.data
+	if ns == SVGNamespace {
+		if adjusted, ok := svgTagNames[name]; ok {
+			name = adjusted
+		}
+	}
+	el := p.doc.CreateElementNS("", ns, name).(*BasicElement)
+	for _, attr := range tok.attrs {
+		attrName := attr.name
+		switch ns {
+		case SVGNamespace:
+			if adjusted, ok := svgAttrNames[attrName]; ok {
+				attrName = adjusted
+			}
+		case MathMLNamespace:
+			if attrName == "definitionurl" {
+				attrName = "definitionURL"
+			}
+		}
+		if ns != XHTMLNamespace {
+			// Foreign attributes
+			if prefix, local, ok := strings.Cut(attrName, ":"); ok {
+				switch prefix {
+				case "xlink":
+					el.SetAttributeNS("xlink", xlinkNamespace, local, attr.value)
+					continue
+				case xmlPrefix:
+					el.SetAttributeNS(xmlPrefix, xmlURL, local, attr.value)
+					continue
+				case xmlnsPrefix:
+					el.SetAttributeNS(xmlnsPrefix, xmlnsURL, local, attr.value)
+					continue
+				}
+			}
+		}
+		el.SetAttribute(attrName, attr.value)
+	}
+	return el
+}
+
+// insertionLocation returns the parent and the reference node for
+// inserting a node
+func (p *htmlParser) insertionLocation(override *BasicElement) (Node, Node) {
+	target := override
+	if target == nil {
+		target = p.currentNode()
+	}
+	if p.fosterParenting && isHTML(target, "table", "tbody", "tfoot", "thead", "tr") {
+		i := len(p.oe) - 1
+		for ; i >= 0; i-- {
+			if isHTML(p.oe[i], "table") {
+				break
+			}
+		}
+		// Inside a template that is newer than the table, nodes are
+		// appended to the template
+		for j := len(p.oe) - 1; j > i; j-- {
+			if isHTML(p.oe[j], "template") {
+				return p.oe[j], nil
+			}
+		}
+		if i < 0 {
+			return p.oe[0], nil
+		}
+		table := p.oe[i]
+		if parent := table.GetParentNode(); parent != nil {
+			return parent, table
+		}
+		return p.oe[i-1], nil
+	}
+	return target, nil
+}
+
+func (p *htmlParser) insertNode(node Node, override *BasicElement) {
+	parent, ref := p.insertionLocation(override)
+	parent.InsertBefore(node, ref)
+}
+
+func (p *htmlParser) insertElement(tok *htmlToken, ns string) *BasicElement {
+	el := p.createElement(tok, ns)
+	p.insertNode(el, nil)
+	p.oe = append(p.oe, el)
+	return el
+}
+
+func (p *htmlParser) insertHTMLElement(tok *htmlToken) *BasicElement {
+	return p.insertElement(tok, XHTMLNamespace)
+}
+
+func (p *htmlParser) insertText(text string) {
+	if len(text) == 0 {
+		return
+	}
+	parent, ref := p.insertionLocation(nil)
+	if parent.GetNodeType() == DOCUMENT_NODE {
+		return
+	}
+	var prev Node
+	if ref == nil {
+		prev = parent.GetLastChild()
+	} else {
+		prev = ref.GetPreviousSibling()
+	}
+	if t, ok := prev.(*BasicText); ok {
+		t.SetValue(t.GetValue() + text)
+		return
+	}
+	parent.InsertBefore(p.doc.CreateTextNode(text), ref)
+}
+
+func (p *htmlParser) insertComment(tok *htmlToken, parent Node) {
+	comment := p.doc.CreateComment(tok.data)
+	if parent != nil {
+		parent.AppendChild(comment)
+		return
+	}
+	p.insertNode(comment, nil)
+}
+
+// Active formatting elements
+
+func (p *htmlParser) pushFormatting(el *BasicElement) {
+	// Noah's Ark clause: at most three elements with the same name
+	// and attributes after the last marker
+	count := 0
+	for i := len(p.afe) - 1; i >= 0 && p.afe[i] != nil; i-- {
+		e := p.afe[i]
+		if e.name.Local == el.name.Local && e.name.Space == el.name.Space && sameAttributes(e, el) {
+			count++
+			if count == 3 {
+				p.afe = removeAt(p.afe, i)
+				break
+			}
+		}
+	}
+	p.afe = append(p.afe, el)
+}
+
+func sameAttributes(a, b *BasicElement) bool {
+	if len(a.attributes.attrs) != len(b.attributes.attrs) {
+		return false
+	}
+	for _, attr := range a.attributes.attrs {
+		v, ok := b.GetAttributeNS(attr.name.Space, attr.name.Local)
+		if !ok || v != attr.value {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *htmlParser) pushMarker() {
+	p.afe = append(p.afe, nil)
+}
+
+func (p *htmlParser) clearFormattingToMarker() {
+	for len(p.afe) > 0 {
+		el := p.afe[len(p.afe)-1]
+		p.afe = p.afe[:len(p.afe)-1]
+		if el == nil {
+			return
+		}
+	}
+}
+
+func (p *htmlParser) reconstructFormatting() {
+	if len(p.afe) == 0 {
+		return
+	}
+	last := p.afe[len(p.afe)-1]
+	if last == nil || indexOf(p.oe, last) != -1 {
+		return
+	}
+	i := len(p.afe) - 1
+	for i > 0 {
+		entry := p.afe[i-1]
+		if entry == nil || indexOf(p.oe, entry) != -1 {
+			break
+		}
+		i--
+	}
+	for ; i < len(p.afe); i++ {
+		el := p.afe[i].CloneNode(false).(*BasicElement)
+		p.insertNode(el, nil)
+		p.oe = append(p.oe, el)
+		p.afe[i] = el
+	}
+}
+
+// formattingElement returns the last element in the list of active
+// formatting elements after the last marker with the given name
+func (p *htmlParser) formattingElement(name string) *BasicElement {
+	for i := len(p.afe) - 1; i >= 0 && p.afe[i] != nil; i-- {
+		if p.afe[i].name.Local == name {
+			return p.afe[i]
+		}
+	}
+	return nil
+}
+
+// adoptionAgency runs the adoption agency algorithm. Returns false
+// if the token should be handled as any other end tag.
+func (p *htmlParser) adoptionAgency(name string) bool {
+	current := p.currentNode()
+	if isHTML(current, name) && indexOf(p.afe, current) == -1 {
+		p.pop()
+		return true
+	}
+	for outer := 0; outer < 8; outer++ {
+		fe := p.formattingElement(name)
+		if fe == nil {
+			return false
+		}
+		feIndex := indexOf(p.oe, fe)
+		if feIndex == -1 {
+			p.afe = removeAt(p.afe, indexOf(p.afe, fe))
+			return true
+		}
+		if !p.inScope(htmlDefaultScope, name) {
+			return true
+		}
+		var furthestBlock *BasicElement
+		for i := feIndex + 1; i < len(p.oe); i++ {
+			if isSpecial(p.oe[i]) {
+				furthestBlock = p.oe[i]
+				break
+			}
+		}
+		if furthestBlock == nil {
+			p.oe = p.oe[:feIndex]
+			p.afe = removeAt(p.afe, indexOf(p.afe, fe))
+			return true
+		}
+		commonAncestor := p.oe[feIndex-1]
+		bookmark := indexOf(p.afe, fe)
+		node, lastNode := furthestBlock, furthestBlock
+		nodeIndex := indexOf(p.oe, node)
+		for inner := 1; ; inner++ {
+			nodeIndex--
+			node = p.oe[nodeIndex]
+			if node == fe {
+				break
+			}
+			afeIndex := indexOf(p.afe, node)
+			if inner > 3 && afeIndex != -1 {
+				p.afe = removeAt(p.afe, afeIndex)
+				if afeIndex < bookmark {
+					bookmark--
+				}
+				afeIndex = -1
+			}
+			if afeIndex == -1 {
+				p.oe = removeAt(p.oe, nodeIndex)
+				continue
+			}
+			clone := node.CloneNode(false).(*BasicElement)
+			p.afe[afeIndex] = clone
+			p.oe[nodeIndex] = clone
+			node = clone
+			if lastNode == furthestBlock {
+				bookmark = afeIndex + 1
+			}
+			node.AppendChild(lastNode)
+			lastNode = node
+		}
+		p.insertNode(lastNode, commonAncestor)
+		clone := fe.CloneNode(false).(*BasicElement)
+		for ch := furthestBlock.GetFirstChild(); ch != nil; ch = furthestBlock.GetFirstChild() {
+			clone.AppendChild(ch)
+		}
+		furthestBlock.AppendChild(clone)
+		feAFEIndex := indexOf(p.afe, fe)
+		p.afe = removeAt(p.afe, feAFEIndex)
+		if feAFEIndex < bookmark {
+			bookmark--
+		}
+		p.afe = insertAt(p.afe, bookmark, clone)
+		p.oe = removeAt(p.oe, indexOf(p.oe, fe))
+		p.oe = insertAt(p.oe, indexOf(p.oe, furthestBlock)+1, clone)
+	}
+	return true
+}
+
+// templateElement returns the innermost open template element
+func (p *htmlParser) templateElement() *BasicElement {
+	for i := len(p.oe) - 1; i >= 0; i-- {
+		if isHTML(p.oe[i], "template") {
+			return p.oe[i]
+		}
+	}
+	return nil
+}
+
+func (p *htmlParser) resetInsertionMode() {
+	for i := len(p.oe) - 1; i >= 0; i-- {
+		node := p.oe[i]
+		last := i == 0
+		switch {
+		case isHTML(node, "template"):
+			p.mode = p.templateModes[len(p.templateModes)-1]
+		case isHTML(node, "select"):
+			for j := i - 1; j > 0; j-- {
+				if isHTML(p.oe[j], "table") {
+					p.mode = htmlInSelectInTableMode
+					return
+				}
+			}
+			p.mode = htmlInSelectMode
+		case isHTML(node, "td", "th") && !last:
+			p.mode = htmlInCellMode
+		case isHTML(node, "tr"):
+			p.mode = htmlInRowMode
+		case isHTML(node, "tbody", "thead", "tfoot"):
+			p.mode = htmlInTableBodyMode
+		case isHTML(node, "caption"):
+			p.mode = htmlInCaptionMode
+		case isHTML(node, "colgroup"):
+			p.mode = htmlInColumnGroupMode
+		case isHTML(node, "table"):
+			p.mode = htmlInTableMode
+		case isHTML(node, "head") && !last:
+			p.mode = htmlInHeadMode
+		case isHTML(node, "body"):
+			p.mode = htmlInBodyMode
+		case isHTML(node, "frameset"):
+			p.mode = htmlInFramesetMode
+		case isHTML(node, "html"):
+			if p.head == nil {
+				p.mode = htmlBeforeHeadMode
+			} else {
+				p.mode = htmlAfterHeadMode
+			}
+		default:
+			if !last {
+				continue
+			}
+			p.mode = htmlInBodyMode
+		}
+		return
+	}
+	p.mode = htmlInBodyMode
+}
+
+// parseRawText inserts the element and switches the tokenizer to
+// raw text
+func (p *htmlParser) parseRawText(tok *htmlToken, kind htmlRawKind) {
+	p.insertHTMLElement(tok)
+	p.tokenizer.setRaw(kind, tok.data)
+	p.originalMode = p.mode
+	p.mode = htmlTextMode
+}
+
+// Token dispatch
+
+func isAllSpace(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isHTMLSpace(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLeadingSpace splits the leading whitespace of s
+func splitLeadingSpace(s string) (string, string) {
+	i := 0
+	for i < len(s) && isHTMLSpace(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func (p *htmlParser) dispatch(tok *htmlToken) {
+	current := p.currentNode()
+	if current == nil || current.name.Space == XHTMLNamespace || tok.typ == htmlEOFToken {
+		p.process(tok)
+		return
+	}
+	if isMathMLTextIntegrationPoint(current) &&
+		((tok.typ == htmlStartTagToken && tok.data != "mglyph" && tok.data != "malignmark") || tok.typ == htmlTextToken) {
+		p.process(tok)
+		return
+	}
+	if current.name.Space == MathMLNamespace && current.name.Local == "annotation-xml" && tok.typ == htmlStartTagToken && tok.data == "svg" {
+		p.process(tok)
+		return
+	}
+	if isHTMLIntegrationPoint(current) && (tok.typ == htmlStartTagToken || tok.typ == htmlTextToken) {
+		p.process(tok)
+		return
+	}
+	p.foreignContent(tok)
+}
+
+// process handles the token using the rules of the current
+// insertion mode
+func (p *htmlParser) process(tok *htmlToken) {
+	switch p.mode {
+	case htmlInitialMode:
+		p.initialMode(tok)
+	case htmlBeforeHTMLMode:
+		p.beforeHTMLMode(tok)
+	case htmlBeforeHeadMode:
+		p.beforeHeadMode(tok)
+	case htmlInHeadMode:
+		p.inHeadMode(tok)
+	case htmlAfterHeadMode:
+		p.afterHeadMode(tok)
+	case htmlInBodyMode:
+		p.inBodyMode(tok)
+	case htmlTextMode:
+		p.textMode(tok)
+	case htmlInTableMode:
+		p.inTableMode(tok)
+	case htmlInCaptionMode:
+		p.inCaptionMode(tok)
+	case htmlInColumnGroupMode:
+		p.inColumnGroupMode(tok)
+	case htmlInTableBodyMode:
+		p.inTableBodyMode(tok)
+	case htmlInRowMode:
+		p.inRowMode(tok)
+	case htmlInCellMode:
+		p.inCellMode(tok)
+	case htmlInSelectMode:
+		p.inSelectMode(tok)
+	case htmlInSelectInTableMode:
+		p.inSelectInTableMode(tok)
+	case htmlInTemplateMode:
+		p.inTemplateMode(tok)
+	case htmlAfterBodyMode:
+		p.afterBodyMode(tok)
+	case htmlInFramesetMode:
+		p.inFramesetMode(tok)
+	case htmlAfterFramesetMode:
+		p.afterFramesetMode(tok)
+	case htmlAfterAfterBodyMode:
+		p.afterAfterBodyMode(tok)
+	}
+}
+
+// reprocess switches to mode and processes the token again
+func (p *htmlParser) reprocess(mode htmlInsertionMode, tok *htmlToken) {
+	p.mode = mode
+	p.process(tok)
+}
+
+func (p *htmlParser) initialMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		space, rest := splitLeadingSpace(tok.data)
+		_ = space
+		if len(rest) == 0 {
+			return
+		}
+		tok.data = rest
+	case htmlCommentToken:
+		p.insertComment(tok, p.doc)
+		return
+	case htmlDoctypeToken:
+		dt := &BasicDocumentType{name: tok.data, publicID: tok.publicID, systemID: tok.systemID}
+		dt.setOwner(p.doc)
+		p.doc.AppendChild(dt)
+		p.mode = htmlBeforeHTMLMode
+		return
+	}
+	p.reprocess(htmlBeforeHTMLMode, tok)
+}
+
+func (p *htmlParser) beforeHTMLMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlDoctypeToken:
+		return
+	case htmlCommentToken:
+		p.insertComment(tok, p.doc)
+		return
+	case htmlTextToken:
+		_, rest := splitLeadingSpace(tok.data)
+		if len(rest) == 0 {
+			return
+		}
+		tok.data = rest
+	case htmlStartTagToken:
+		if tok.data == "html" {
+			el := p.createElement(tok, XHTMLNamespace)
+			p.doc.AppendChild(el)
+			p.oe = append(p.oe, el)
+			p.mode = htmlBeforeHeadMode
+			return
+		}
+	case htmlEndTagToken:
+		if !isIn(tok.data, "head", "body", "html", "br") {
+			return
+		}
+	}
+	el := p.doc.CreateElementNS("", XHTMLNamespace, "html").(*BasicElement)
+	p.doc.AppendChild(el)
+	p.oe = append(p.oe, el)
+	p.reprocess(htmlBeforeHeadMode, tok)
+}
+
+func (p *htmlParser) beforeHeadMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		_, rest := splitLeadingSpace(tok.data)
+		if len(rest) == 0 {
+			return
+		}
+		tok.data = rest
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+		return
+	case htmlDoctypeToken:
+		return
+	case htmlStartTagToken:
+		switch tok.data {
+		case "html":
+			p.inBodyMode(tok)
+			return
+		case "head":
+			p.head = p.insertHTMLElement(tok)
+			p.mode = htmlInHeadMode
+			return
+		}
+	case htmlEndTagToken:
+		if !isIn(tok.data, "head", "body", "html", "br") {
+			return
+		}
+	}
+	p.head = p.insertHTMLElement(&htmlToken{typ: htmlStartTagToken, data: "head"})
+	p.reprocess(htmlInHeadMode, tok)
+}
+
+func (p *htmlParser) inHeadMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		space, rest := splitLeadingSpace(tok.data)
+		p.insertText(space)
+		if len(rest) == 0 {
+			return
+		}
+		tok.data = rest
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+		return
+	case htmlDoctypeToken:
+		return
+	case htmlStartTagToken:
+		switch tok.data {
+		case "html":
+			p.inBodyMode(tok)
+			return
+		case "base", "basefont", "bgsound", "link", "meta":
+			p.insertHTMLElement(tok)
+			p.pop()
+			return
+		case "title":
+			p.parseRawText(tok, htmlRCDATA)
+			return
+		case "noscript", "noframes", "style", "script":
+			p.parseRawText(tok, htmlRawText)
+			return
+		case "template":
+			// Template contents are parsed as children of the
+			// template element
+			p.insertHTMLElement(tok)
+			p.pushMarker()
+			p.framesetOK = false
+			p.mode = htmlInTemplateMode
+			p.templateModes = append(p.templateModes, htmlInTemplateMode)
+			return
+		case "head":
+			return
+		}
+	case htmlEndTagToken:
+		switch tok.data {
+		case "template":
+			if indexOf(p.oe, p.templateElement()) == -1 {
+				return
+			}
+			p.generateAllImpliedEndTags()
+			p.popUntil("template")
+			p.clearFormattingToMarker()
+			p.templateModes = p.templateModes[:len(p.templateModes)-1]
+			p.resetInsertionMode()
+			return
+		case "head":
+			p.pop()
+			p.mode = htmlAfterHeadMode
+			return
+		case "body", "html", "br":
+		default:
+			return
+		}
+	}
+	p.pop()
+	p.reprocess(htmlAfterHeadMode, tok)
+}
+
+func (p *htmlParser) afterHeadMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		space, rest := splitLeadingSpace(tok.data)
+		p.insertText(space)
+		if len(rest) == 0 {
+			return
+		}
+		tok.data = rest
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+		return
+	case htmlDoctypeToken:
+		return
+	case htmlStartTagToken:
+		switch tok.data {
+		case "html":
+			p.inBodyMode(tok)
+			return
+		case "body":
+			p.insertHTMLElement(tok)
+			p.framesetOK = false
+			p.mode = htmlInBodyMode
+			return
+		case "frameset":
+			p.insertHTMLElement(tok)
+			p.mode = htmlInFramesetMode
+			return
+		case "base", "basefont", "bgsound", "link", "meta", "noframes", "script", "style", "template", "title":
+			p.oe = append(p.oe, p.head)
+			p.inHeadMode(tok)
+			if i := indexOf(p.oe, p.head); i != -1 {
+				p.oe = removeAt(p.oe, i)
+			}
+			return
+		case "head":
+			return
+		}
+	case htmlEndTagToken:
+		if !isIn(tok.data, "body", "html", "br") {
+			return
+		}
+	}
+	p.insertHTMLElement(&htmlToken{typ: htmlStartTagToken, data: "body"})
+	p.reprocess(htmlInBodyMode, tok)
+}
+
+func (p *htmlParser) inBodyMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		text := strings.ReplaceAll(tok.data, "\x00", "")
+		if len(text) == 0 {
+			return
+		}
+		p.reconstructFormatting()
+		p.insertText(text)
+		if !isAllSpace(text) {
+			p.framesetOK = false
+		}
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+	case htmlDoctypeToken:
+	case htmlStartTagToken:
+		p.inBodyStartTag(tok)
+	case htmlEndTagToken:
+		p.inBodyEndTag(tok)
+	case htmlEOFToken:
+		if len(p.templateModes) > 0 {
+			p.inTemplateMode(tok)
+		}
+	}
+}
+
+func (p *htmlParser) addMissingAttributes(el *BasicElement, tok *htmlToken) {
+	for _, attr := range tok.attrs {
+		if !el.HasAttribute(attr.name) {
+			el.SetAttribute(attr.name, attr.value)
+		}
+	}
+}
+
+func (p *htmlParser) inBodyStartTag(tok *htmlToken) {
+	switch tok.data {
+	case "html":
+		p.addMissingAttributes(p.oe[0], tok)
+	case "base", "basefont", "bgsound", "link", "meta", "noframes", "script", "style", "template", "title":
+		p.inHeadMode(tok)
+	case "body":
+		if len(p.oe) > 1 && isHTML(p.oe[1], "body") {
+			p.framesetOK = false
+			p.addMissingAttributes(p.oe[1], tok)
+		}
+	case "frameset":
+	case "address", "article", "aside", "blockquote", "center", "details", "dialog", "dir", "div", "dl",
+		"fieldset", "figcaption", "figure", "footer", "header", "hgroup", "main", "menu", "nav", "ol", "p",
+		"search", "section", "summary", "ul":
+		p.closePInButtonScope()
+		p.insertHTMLElement(tok)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		p.closePInButtonScope()
+		if isHTML(p.currentNode(), "h1", "h2", "h3", "h4", "h5", "h6") {
+			p.pop()
+		}
+		p.insertHTMLElement(tok)
+	case "pre", "listing":
+		p.closePInButtonScope()
+		p.insertHTMLElement(tok)
+		p.skipNewline = true
+		p.framesetOK = false
+	case "form":
+		if p.form != nil {
+			return
+		}
+		p.closePInButtonScope()
+		p.form = p.insertHTMLElement(tok)
+	case "li", "dd", "dt":
+		p.framesetOK = false
+		names := []string{"li"}
+		if tok.data != "li" {
+			names = []string{"dd", "dt"}
+		}
+		for i := len(p.oe) - 1; i >= 0; i-- {
+			node := p.oe[i]
+			if isHTML(node, names...) {
+				p.generateImpliedEndTags(node.name.Local)
+				p.popUntil(node.name.Local)
+				break
+			}
+			if isSpecial(node) && !isHTML(node, "address", "div", "p") {
+				break
+			}
+		}
+		p.closePInButtonScope()
+		p.insertHTMLElement(tok)
+	case "plaintext":
+		p.closePInButtonScope()
+		p.insertHTMLElement(tok)
+		p.tokenizer.setRaw(htmlPlaintext, "")
+	case "button":
+		if p.inScope(htmlDefaultScope, "button") {
+			p.generateImpliedEndTags()
+			p.popUntil("button")
+		}
+		p.reconstructFormatting()
+		p.insertHTMLElement(tok)
+		p.framesetOK = false
+	case "a":
+		if p.formattingElement("a") != nil {
+			fe := p.formattingElement("a")
+			p.adoptionAgency("a")
+			if i := indexOf(p.afe, fe); i != -1 {
+				p.afe = removeAt(p.afe, i)
+			}
+			if i := indexOf(p.oe, fe); i != -1 {
+				p.oe = removeAt(p.oe, i)
+			}
+		}
+		p.reconstructFormatting()
+		p.pushFormatting(p.insertHTMLElement(tok))
+	case "b", "big", "code", "em", "font", "i", "s", "small", "strike", "strong", "tt", "u":
+		p.reconstructFormatting()
+		p.pushFormatting(p.insertHTMLElement(tok))
+	case "nobr":
+		p.reconstructFormatting()
+		if p.inScope(htmlDefaultScope, "nobr") {
+			p.adoptionAgency("nobr")
+			p.reconstructFormatting()
+		}
+		p.pushFormatting(p.insertHTMLElement(tok))
+	case "applet", "marquee", "object":
+		p.reconstructFormatting()
+		p.insertHTMLElement(tok)
+		p.pushMarker()
+		p.framesetOK = false
+	case "table":
+		p.closePInButtonScope()
+		p.insertHTMLElement(tok)
+		p.framesetOK = false
+		p.mode = htmlInTableMode
+	case "area", "br", "embed", "img", "keygen", "wbr":
+		p.reconstructFormatting()
+		p.insertHTMLElement(tok)
+		p.pop()
+		p.framesetOK = false
+	case "input":
+		p.reconstructFormatting()
+		p.insertHTMLElement(tok)
+		p.pop()
+		if t, _ := tok.getAttr("type"); !strings.EqualFold(t, "hidden") {
+			p.framesetOK = false
+		}
+	case "param", "source", "track":
+		p.insertHTMLElement(tok)
+		p.pop()
+	case "hr":
+		p.closePInButtonScope()
+		p.insertHTMLElement(tok)
+		p.pop()
+		p.framesetOK = false
+	case "image":
+		tok.data = "img"
+		p.inBodyStartTag(tok)
+	case "textarea":
+		p.parseRawText(tok, htmlRCDATA)
+		p.skipNewline = true
+		p.framesetOK = false
+	case "xmp":
+		p.closePInButtonScope()
+		p.reconstructFormatting()
+		p.framesetOK = false
+		p.parseRawText(tok, htmlRawText)
+	case "iframe":
+		p.framesetOK = false
+		p.parseRawText(tok, htmlRawText)
+	case "noembed", "noscript":
+		p.parseRawText(tok, htmlRawText)
+	case "select":
+		p.reconstructFormatting()
+		p.insertHTMLElement(tok)
+		p.framesetOK = false
+		switch p.mode {
+		case htmlInTableMode, htmlInCaptionMode, htmlInTableBodyMode, htmlInRowMode, htmlInCellMode:
+			p.mode = htmlInSelectInTableMode
+		default:
+			p.mode = htmlInSelectMode
+		}
+	case "optgroup", "option":
+		if isHTML(p.currentNode(), "option") {
+			p.pop()
+		}
+		p.reconstructFormatting()
+		p.insertHTMLElement(tok)
+	case "rb", "rtc":
+		if p.inScope(htmlDefaultScope, "ruby") {
+			p.generateImpliedEndTags()
+		}
+		p.insertHTMLElement(tok)
+	case "rp", "rt":
+		if p.inScope(htmlDefaultScope, "ruby") {
+			p.generateImpliedEndTags("rtc")
+		}
+		p.insertHTMLElement(tok)
+	case "math", "svg":
+		p.reconstructFormatting()
+		ns := MathMLNamespace
+		if tok.data == "svg" {
+			ns = SVGNamespace
+		}
+		p.insertElement(tok, ns)
+		if tok.selfClosing {
+			p.pop()
+		}
+	case "caption", "col", "colgroup", "frame", "head", "tbody", "td", "tfoot", "th", "thead", "tr":
+	default:
+		p.reconstructFormatting()
+		p.insertHTMLElement(tok)
+	}
+}
+
+func (p *htmlParser) inBodyEndTag(tok *htmlToken) {
+	switch tok.data {
+	case "template":
+		p.inHeadMode(tok)
+	case "body":
+		if p.inScope(htmlDefaultScope, "body") {
+			p.mode = htmlAfterBodyMode
+		}
+	case "html":
+		if p.inScope(htmlDefaultScope, "body") {
+			p.reprocess(htmlAfterBodyMode, tok)
+		}
+	case "address", "article", "aside", "blockquote", "button", "center", "details", "dialog", "dir", "div",
+		"dl", "fieldset", "figcaption", "figure", "footer", "header", "hgroup", "listing", "main", "menu", "nav",
+		"ol", "pre", "search", "section", "summary", "ul":
+		if !p.inScope(htmlDefaultScope, tok.data) {
+			return
+		}
+		p.generateImpliedEndTags()
+		p.popUntil(tok.data)
+	case "form":
+		node := p.form
+		p.form = nil
+		if node == nil || !p.inScope(htmlDefaultScope, "form") {
+			return
+		}
+		p.generateImpliedEndTags()
+		if i := indexOf(p.oe, node); i != -1 {
+			p.oe = removeAt(p.oe, i)
+		}
+	case "p":
+		if !p.inScope(htmlButtonScope, "p") {
+			p.insertHTMLElement(&htmlToken{typ: htmlStartTagToken, data: "p"})
+		}
+		p.closePElement()
+	case "li":
+		if !p.inScope(htmlListItemScope, "li") {
+			return
+		}
+		p.generateImpliedEndTags("li")
+		p.popUntil("li")
+	case "dd", "dt":
+		if !p.inScope(htmlDefaultScope, tok.data) {
+			return
+		}
+		p.generateImpliedEndTags(tok.data)
+		p.popUntil(tok.data)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if !p.inScope(htmlDefaultScope, "h1", "h2", "h3", "h4", "h5", "h6") {
+			return
+		}
+		p.generateImpliedEndTags()
+		p.popUntil("h1", "h2", "h3", "h4", "h5", "h6")
+	case "a", "b", "big", "code", "em", "font", "i", "nobr", "s", "small", "strike", "strong", "tt", "u":
+		if !p.adoptionAgency(tok.data) {
+			p.anyOtherEndTag(tok.data)
+		}
+	case "applet", "marquee", "object":
+		if !p.inScope(htmlDefaultScope, tok.data) {
+			return
+		}
+		p.generateImpliedEndTags()
+		p.popUntil(tok.data)
+		p.clearFormattingToMarker()
+	case "br":
+		p.inBodyStartTag(&htmlToken{typ: htmlStartTagToken, data: "br"})
+	default:
+		p.anyOtherEndTag(tok.data)
+	}
+}
+
+func (p *htmlParser) anyOtherEndTag(name string) {
+	for i := len(p.oe) - 1; i >= 0; i-- {
+		node := p.oe[i]
+		if isHTML(node, name) {
+			p.generateImpliedEndTags(name)
+			p.oe = p.oe[:i]
+			return
+		}
+		if isSpecial(node) {
+			return
+		}
+	}
+}
+
+func (p *htmlParser) textMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		p.insertText(tok.data)
+	case htmlEOFToken:
+		p.pop()
+		p.reprocess(p.originalMode, tok)
+	case htmlEndTagToken:
+		p.pop()
+		p.mode = p.originalMode
+	}
+}
+
+// clearStackBackTo pops elements until the current node is one of
+// the names or html
+func (p *htmlParser) clearStackBackTo(names ...string) {
+	for !isHTML(p.currentNode(), names...) && !isHTML(p.currentNode(), "html", "template") {
+		p.pop()
+	}
+}
+
+func (p *htmlParser) inTableMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		if isHTML(p.currentNode(), "table", "tbody", "template", "tfoot", "thead", "tr") {
+			// NUL characters are ignored in table text
+			if text := strings.ReplaceAll(tok.data, "\x00", ""); isAllSpace(text) {
+				p.insertText(text)
+				return
+			}
+			// Non-whitespace text is foster parented
+			p.fosterParenting = true
+			p.inBodyMode(tok)
+			p.fosterParenting = false
+			return
+		}
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+		return
+	case htmlDoctypeToken:
+		return
+	case htmlStartTagToken:
+		switch tok.data {
+		case "caption":
+			p.clearStackBackTo("table")
+			p.pushMarker()
+			p.insertHTMLElement(tok)
+			p.mode = htmlInCaptionMode
+			return
+		case "colgroup":
+			p.clearStackBackTo("table")
+			p.insertHTMLElement(tok)
+			p.mode = htmlInColumnGroupMode
+			return
+		case "col":
+			p.clearStackBackTo("table")
+			p.insertHTMLElement(&htmlToken{typ: htmlStartTagToken, data: "colgroup"})
+			p.reprocess(htmlInColumnGroupMode, tok)
+			return
+		case "tbody", "tfoot", "thead":
+			p.clearStackBackTo("table")
+			p.insertHTMLElement(tok)
+			p.mode = htmlInTableBodyMode
+			return
+		case "td", "th", "tr":
+			p.clearStackBackTo("table")
+			p.insertHTMLElement(&htmlToken{typ: htmlStartTagToken, data: "tbody"})
+			p.reprocess(htmlInTableBodyMode, tok)
+			return
+		case "table":
+			if !p.inScope(htmlTableScope, "table") {
+				return
+			}
+			p.popUntil("table")
+			p.resetInsertionMode()
+			p.process(tok)
+			return
+		case "style", "script", "template":
+			p.inHeadMode(tok)
+			return
+		case "input":
+			if t, _ := tok.getAttr("type"); strings.EqualFold(t, "hidden") {
+				p.insertHTMLElement(tok)
+				p.pop()
+				return
+			}
+		case "form":
+			if p.form != nil {
+				return
+			}
+			p.form = p.insertHTMLElement(tok)
+			p.pop()
+			return
+		}
+	case htmlEndTagToken:
+		switch tok.data {
+		case "table":
+			if !p.inScope(htmlTableScope, "table") {
+				return
+			}
+			p.popUntil("table")
+			p.resetInsertionMode()
+			return
+		case "body", "caption", "col", "colgroup", "html", "tbody", "td", "tfoot", "th", "thead", "tr":
+			return
+		case "template":
+			p.inHeadMode(tok)
+			return
+		}
+	case htmlEOFToken:
+		p.inBodyMode(tok)
+		return
+	}
+	p.fosterParenting = true
+	p.inBodyMode(tok)
+	p.fosterParenting = false
+}
+
+func (p *htmlParser) inCaptionMode(tok *htmlToken) {
+	closeCaption := func() bool {
+		if !p.inScope(htmlTableScope, "caption") {
+			return false
+		}
+		p.generateImpliedEndTags()
+		p.popUntil("caption")
+		p.clearFormattingToMarker()
+		p.mode = htmlInTableMode
+		return true
+	}
+	switch {
+	case tok.typ == htmlEndTagToken && tok.data == "caption":
+		closeCaption()
+		return
+	case (tok.typ == htmlStartTagToken && isIn(tok.data, "caption", "col", "colgroup", "tbody", "td", "tfoot", "th", "thead", "tr")) ||
+		(tok.typ == htmlEndTagToken && tok.data == "table"):
+		if closeCaption() {
+			p.process(tok)
+		}
+		return
+	case tok.typ == htmlEndTagToken && isIn(tok.data, "body", "col", "colgroup", "html", "tbody", "td", "tfoot", "th", "thead", "tr"):
+		return
+	}
+	p.inBodyMode(tok)
+}
+
+func (p *htmlParser) inColumnGroupMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		space, rest := splitLeadingSpace(tok.data)
+		p.insertText(space)
+		if len(rest) == 0 {
+			return
+		}
+		tok.data = rest
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+		return
+	case htmlDoctypeToken:
+		return
+	case htmlStartTagToken:
+		switch tok.data {
+		case "html":
+			p.inBodyMode(tok)
+			return
+		case "col":
+			p.insertHTMLElement(tok)
+			p.pop()
+			return
+		case "template":
+			p.inHeadMode(tok)
+			return
+		}
+	case htmlEndTagToken:
+		switch tok.data {
+		case "colgroup":
+			if isHTML(p.currentNode(), "colgroup") {
+				p.pop()
+				p.mode = htmlInTableMode
+			}
+			return
+		case "col":
+			return
+		case "template":
+			p.inHeadMode(tok)
+			return
+		}
+	case htmlEOFToken:
+		p.inBodyMode(tok)
+		return
+	}
+	if !isHTML(p.currentNode(), "colgroup") {
+		return
+	}
+	p.pop()
+	p.reprocess(htmlInTableMode, tok)
+}
+
+func (p *htmlParser) inTableBodyMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlStartTagToken:
+		switch tok.data {
+		case "tr":
+			p.clearStackBackTo("tbody", "tfoot", "thead")
+			p.insertHTMLElement(tok)
+			p.mode = htmlInRowMode
+			return
+		case "th", "td":
+			p.clearStackBackTo("tbody", "tfoot", "thead")
+			p.insertHTMLElement(&htmlToken{typ: htmlStartTagToken, data: "tr"})
+			p.reprocess(htmlInRowMode, tok)
+			return
+		case "caption", "col", "colgroup", "tbody", "tfoot", "thead":
+			if !p.inScope(htmlTableScope, "tbody", "thead", "tfoot") {
+				return
+			}
+			p.clearStackBackTo("tbody", "tfoot", "thead")
+			p.pop()
+			p.reprocess(htmlInTableMode, tok)
+			return
+		}
+	case htmlEndTagToken:
+		switch tok.data {
+		case "tbody", "tfoot", "thead":
+			if !p.inScope(htmlTableScope, tok.data) {
+				return
+			}
+			p.clearStackBackTo("tbody", "tfoot", "thead")
+			p.pop()
+			p.mode = htmlInTableMode
+			return
+		case "table":
+			if !p.inScope(htmlTableScope, "tbody", "thead", "tfoot") {
+				return
+			}
+			p.clearStackBackTo("tbody", "tfoot", "thead")
+			p.pop()
+			p.reprocess(htmlInTableMode, tok)
+			return
+		case "body", "caption", "col", "colgroup", "html", "td", "th", "tr":
+			return
+		}
+	}
+	p.inTableMode(tok)
+}
+
+func (p *htmlParser) inRowMode(tok *htmlToken) {
+	closeRow := func() bool {
+		if !p.inScope(htmlTableScope, "tr") {
+			return false
+		}
+		p.clearStackBackTo("tr")
+		p.pop()
+		p.mode = htmlInTableBodyMode
+		return true
+	}
+	switch tok.typ {
+	case htmlStartTagToken:
+		switch tok.data {
+		case "th", "td":
+			p.clearStackBackTo("tr")
+			p.insertHTMLElement(tok)
+			p.mode = htmlInCellMode
+			p.pushMarker()
+			return
+		case "caption", "col", "colgroup", "tbody", "tfoot", "thead", "tr":
+			if closeRow() {
+				p.process(tok)
+			}
+			return
+		}
+	case htmlEndTagToken:
+		switch tok.data {
+		case "tr":
+			closeRow()
+			return
+		case "table":
+			if closeRow() {
+				p.process(tok)
+			}
+			return
+		case "tbody", "tfoot", "thead":
+			if !p.inScope(htmlTableScope, tok.data) {
+				return
+			}
+			if closeRow() {
+				p.process(tok)
+			}
+			return
+		case "body", "caption", "col", "colgroup", "html", "td", "th":
+			return
+		}
+	}
+	p.inTableMode(tok)
+}
+
+func (p *htmlParser) closeCell() {
+	p.generateImpliedEndTags()
+	p.popUntil("td", "th")
+	p.clearFormattingToMarker()
+	p.mode = htmlInRowMode
+}
+
+func (p *htmlParser) inCellMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlStartTagToken:
+		if isIn(tok.data, "caption", "col", "colgroup", "tbody", "td", "tfoot", "th", "thead", "tr") {
+			if p.inScope(htmlTableScope, "td", "th") {
+				p.closeCell()
+				p.process(tok)
+			}
+			return
+		}
+	case htmlEndTagToken:
+		switch tok.data {
+		case "td", "th":
+			if !p.inScope(htmlTableScope, tok.data) {
+				return
+			}
+			p.generateImpliedEndTags()
+			p.popUntil(tok.data)
+			p.clearFormattingToMarker()
+			p.mode = htmlInRowMode
+			return
+		case "body", "caption", "col", "colgroup", "html":
+			return
+		case "table", "tbody", "tfoot", "thead", "tr":
+			if !p.inScope(htmlTableScope, tok.data) {
+				return
+			}
+			p.closeCell()
+			p.process(tok)
+			return
+		}
+	}
+	p.inBodyMode(tok)
+}
+
+func (p *htmlParser) inSelectMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		p.insertText(strings.ReplaceAll(tok.data, "\x00", ""))
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+	case htmlStartTagToken:
+		switch tok.data {
+		case "html":
+			p.inBodyMode(tok)
+		case "option":
+			if isHTML(p.currentNode(), "option") {
+				p.pop()
+			}
+			p.insertHTMLElement(tok)
+		case "optgroup":
+			if isHTML(p.currentNode(), "option") {
+				p.pop()
+			}
+			if isHTML(p.currentNode(), "optgroup") {
+				p.pop()
+			}
+			p.insertHTMLElement(tok)
+		case "hr":
+			if isHTML(p.currentNode(), "option") {
+				p.pop()
+			}
+			if isHTML(p.currentNode(), "optgroup") {
+				p.pop()
+			}
+			p.insertHTMLElement(tok)
+			p.pop()
+		case "select":
+			if p.inScope(htmlSelectScope, "select") {
+				p.popUntil("select")
+				p.resetInsertionMode()
+			}
+		case "input", "keygen", "textarea":
+			if p.inScope(htmlSelectScope, "select") {
+				p.popUntil("select")
+				p.resetInsertionMode()
+				p.process(tok)
+			}
+		case "script", "template":
+			p.inHeadMode(tok)
+		}
+	case htmlEndTagToken:
+		switch tok.data {
+		case "optgroup":
+			if isHTML(p.currentNode(), "option") && len(p.oe) > 1 && isHTML(p.oe[len(p.oe)-2], "optgroup") {
+				p.pop()
+			}
+			if isHTML(p.currentNode(), "optgroup") {
+				p.pop()
+			}
+		case "option":
+			if isHTML(p.currentNode(), "option") {
+				p.pop()
+			}
+		case "select":
+			if p.inScope(htmlSelectScope, "select") {
+				p.popUntil("select")
+				p.resetInsertionMode()
+			}
+		case "template":
+			p.inHeadMode(tok)
+		}
+	case htmlEOFToken:
+		p.inBodyMode(tok)
+	}
+}
+
+func (p *htmlParser) inSelectInTableMode(tok *htmlToken) {
+	if isIn(tok.data, "caption", "table", "tbody", "tfoot", "thead", "tr", "td", "th") {
+		switch tok.typ {
+		case htmlStartTagToken:
+			p.popUntil("select")
+			p.resetInsertionMode()
+			p.process(tok)
+			return
+		case htmlEndTagToken:
+			if !p.inScope(htmlTableScope, tok.data) {
+				return
+			}
+			p.popUntil("select")
+			p.resetInsertionMode()
+			p.process(tok)
+			return
+		}
+	}
+	p.inSelectMode(tok)
+}
+
+func (p *htmlParser) inTemplateMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken, htmlCommentToken, htmlDoctypeToken:
+		p.inBodyMode(tok)
+	case htmlStartTagToken:
+		// The first start tag of the template contents decides how
+		// the contents are parsed
+		mode := htmlInBodyMode
+		switch tok.data {
+		case "base", "basefont", "bgsound", "link", "meta", "noframes", "script", "style", "template", "title":
+			p.inHeadMode(tok)
+			return
+		case "caption", "colgroup", "tbody", "tfoot", "thead":
+			mode = htmlInTableMode
+		case "col":
+			mode = htmlInColumnGroupMode
+		case "tr":
+			mode = htmlInTableBodyMode
+		case "td", "th":
+			mode = htmlInRowMode
+		}
+		p.templateModes[len(p.templateModes)-1] = mode
+		p.reprocess(mode, tok)
+	case htmlEndTagToken:
+		if tok.data == "template" {
+			p.inHeadMode(tok)
+		}
+	case htmlEOFToken:
+		if p.templateElement() == nil {
+			return
+		}
+		p.popUntil("template")
+		p.clearFormattingToMarker()
+		p.templateModes = p.templateModes[:len(p.templateModes)-1]
+		p.resetInsertionMode()
+		p.process(tok)
+	}
+}
+
+func (p *htmlParser) afterBodyMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		if isAllSpace(tok.data) {
+			p.inBodyMode(tok)
+			return
+		}
+	case htmlCommentToken:
+		p.insertComment(tok, p.oe[0])
+		return
+	case htmlDoctypeToken:
+		return
+	case htmlStartTagToken:
+		if tok.data == "html" {
+			p.inBodyMode(tok)
+			return
+		}
+	case htmlEndTagToken:
+		if tok.data == "html" {
+			p.mode = htmlAfterAfterBodyMode
+			return
+		}
+	case htmlEOFToken:
+		return
+	}
+	p.reprocess(htmlInBodyMode, tok)
+}
+
+func (p *htmlParser) inFramesetMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		var sb strings.Builder
+		for i := 0; i < len(tok.data); i++ {
+			if isHTMLSpace(tok.data[i]) {
+				sb.WriteByte(tok.data[i])
+			}
+		}
+		p.insertText(sb.String())
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+	case htmlStartTagToken:
+		switch tok.data {
+		case "html":
+			p.inBodyMode(tok)
+		case "frameset":
+			p.insertHTMLElement(tok)
+		case "frame":
+			p.insertHTMLElement(tok)
+			p.pop()
+		case "noframes":
+			p.inHeadMode(tok)
+		}
+	case htmlEndTagToken:
+		if tok.data == "frameset" && len(p.oe) > 1 {
+			p.pop()
+			if !isHTML(p.currentNode(), "frameset") {
+				p.mode = htmlAfterFramesetMode
+			}
+		}
+	}
+}
+
+func (p *htmlParser) afterFramesetMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		var sb strings.Builder
+		for i := 0; i < len(tok.data); i++ {
+			if isHTMLSpace(tok.data[i]) {
+				sb.WriteByte(tok.data[i])
+			}
+		}
+		p.insertText(sb.String())
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+	case htmlStartTagToken:
+		switch tok.data {
+		case "html":
+			p.inBodyMode(tok)
+		case "noframes":
+			p.inHeadMode(tok)
+		}
+	case htmlEndTagToken:
+		if tok.data == "html" {
+			p.mode = htmlAfterAfterBodyMode
+		}
+	}
+}
+
+func (p *htmlParser) afterAfterBodyMode(tok *htmlToken) {
+	switch tok.typ {
+	case htmlCommentToken:
+		p.insertComment(tok, p.doc)
+		return
+	case htmlDoctypeToken:
+		p.inBodyMode(tok)
+		return
+	case htmlTextToken:
+		if isAllSpace(tok.data) {
+			p.inBodyMode(tok)
+			return
+		}
+	case htmlStartTagToken:
+		if tok.data == "html" {
+			p.inBodyMode(tok)
+			return
+		}
+	case htmlEOFToken:
+		return
+	}
+	p.reprocess(htmlInBodyMode, tok)
+}
+
+// foreignContent handles tokens in SVG and MathML content
+func (p *htmlParser) foreignContent(tok *htmlToken) {
+	switch tok.typ {
+	case htmlTextToken:
+		text := strings.ReplaceAll(tok.data, "\x00", "�")
+		p.insertText(text)
+		if !isAllSpace(text) {
+			p.framesetOK = false
+		}
+	case htmlCommentToken:
+		p.insertComment(tok, nil)
+	case htmlDoctypeToken:
+	case htmlStartTagToken:
+		breakout := isIn(tok.data, "b", "big", "blockquote", "body", "br", "center", "code", "dd", "div", "dl",
+			"dt", "em", "embed", "h1", "h2", "h3", "h4", "h5", "h6", "head", "hr", "i", "img", "li", "listing",
+			"menu", "meta", "nobr", "ol", "p", "pre", "ruby", "s", "small", "span", "strong", "strike", "sub",
+			"sup", "table", "tt", "u", "ul", "var")
+		if tok.data == "font" {
+			for _, a := range []string{"color", "face", "size"} {
+				if _, ok := tok.getAttr(a); ok {
+					breakout = true
+				}
+			}
+		}
+		if breakout {
+			for {
+				current := p.currentNode()
+				if current.name.Space == XHTMLNamespace || isMathMLTextIntegrationPoint(current) || isHTMLIntegrationPoint(current) {
+					break
+				}
+				p.pop()
+			}
+			p.process(tok)
+			return
+		}
+		p.insertElement(tok, p.currentNode().name.Space)
+		if tok.selfClosing {
+			p.pop()
+		}
+	case htmlEndTagToken:
+		for i := len(p.oe) - 1; i > 0; i-- {
+			node := p.oe[i]
+			if node.name.Space == XHTMLNamespace {
+				p.process(tok)
+				return
+			}
+			if strings.ToLower(node.name.Local) == tok.data {
+				p.oe = p.oe[:i]
+				return
+			}
+		}
+	}
+}
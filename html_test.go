@@ -0,0 +1,195 @@
+package dom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func parseHTMLBody(t *testing.T, input string) (Document, string) {
+	doc, err := ParseHTML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := doc.GetDocumentElement()
+	var body Node
+	for ch := html.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+		if el, ok := ch.(Element); ok && el.GetLocalName() == "body" {
+			body = el
+		}
+	}
+	if body == nil {
+		t.Fatalf("No body: %s", input)
+	}
+	var buf bytes.Buffer
+	for ch := body.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+		if err := Encode(ch, &buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return doc, buf.String()
+}
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		// Implied end tags
+		{`<p>One<p>Two<ul><li>a<li>b</ul>`, `<p>One</p><p>Two</p><ul><li>a</li><li>b</li></ul>`},
+		{`<dl><dt>a<dd>b<dt>c</dl>`, `<dl><dt>a</dt><dd>b</dd><dt>c</dt></dl>`},
+		// Misnested formatting elements
+		{`<p><b>1<i>2</b>3</i>4`, `<p><b>1<i>2</i></b><i>3</i>4</p>`},
+		{`<b><p>text</b>more</p>`, `<b></b><p><b>text</b>more</p>`},
+		{`<a><p>x<a>y`, `<a></a><p><a>x</a><a>y</a></p>`},
+		// Unquoted and valueless attributes
+		{`<a href=foo class='x y' checked>link</a>`, `<a href="foo" class="x y" checked="">link</a>`},
+		// Entities
+		{`a&nbsp;b&copy c&#x41;&#128;&amp;`, "a b© cA€&amp;"},
+		// Tables and foster parenting
+		{`<table><tr><td>1<td>2</table>`, `<table><tbody><tr><td>1</td><td>2</td></tr></tbody></table>`},
+		{`<table>foo<tr><td>x</table>`, `foo<table><tbody><tr><td>x</td></tr></tbody></table>`},
+		// Leading newline in pre and textarea
+		{"<pre>\nx</pre><textarea>\n<b>y</textarea>", `<pre>x</pre><textarea>&lt;b&gt;y</textarea>`},
+		{`<select><option>a<option>b</select>`, `<select><option>a</option><option>b</option></select>`},
+	}
+	for _, test := range tests {
+		_, out := parseHTMLBody(t, test.input)
+		if out != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, out)
+		}
+	}
+}
+
+func TestParseHTMLDocument(t *testing.T) {
+	doc, err := ParseHTML(strings.NewReader(`<!DOCTYPE html><!--c--><title>A &amp; B</title><script>if (a < b) { x = "</p>" }</script><p>x`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dt, ok := doc.GetFirstChild().(DocumentType)
+	if !ok || dt.GetName() != "html" {
+		t.Errorf("Expected doctype, got %v", doc.GetFirstChild())
+	}
+	if doc.GetFirstChild().GetNextSibling().GetNodeType() != COMMENT_NODE {
+		t.Errorf("Expected comment")
+	}
+	html := doc.GetDocumentElement()
+	if html.GetLocalName() != "html" || html.GetQName().Space != XHTMLNamespace {
+		t.Errorf("Wrong root: %v", html.GetQName())
+	}
+	head := html.GetFirstChild().(Element)
+	if head.GetLocalName() != "head" {
+		t.Fatalf("Expected head, got %s", head.GetLocalName())
+	}
+	title := head.GetFirstChild().(Element)
+	if title.GetLocalName() != "title" || title.GetFirstChild().(Text).GetValue() != "A & B" {
+		t.Errorf("Wrong title")
+	}
+	script := title.GetNextSibling().(Element)
+	if v := script.GetFirstChild().(Text).GetValue(); v != `if (a < b) { x = "</p>" }` {
+		t.Errorf("Wrong script: %s", v)
+	}
+}
+
+func TestParseHTMLScriptData(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		// Escaped and double escaped script data
+		{`<body><script><!--<script>x</script>y--></script>z`, `<script>&lt;!--&lt;script&gt;x&lt;/script&gt;y--&gt;</script>z`},
+		{`<body><script><!--x</script>y`, `<script>&lt;!--x</script>y`},
+		{`<body><script><!--<script></script></script>y`, `<script>&lt;!--&lt;script&gt;&lt;/script&gt;</script>y`},
+		{`<body><script><!--<scripts></script>y`, `<script>&lt;!--&lt;scripts&gt;</script>y`},
+		{`<body><script><!-->x</script>y`, `<script>&lt;!--&gt;x</script>y`},
+		{`<body><script><!--<script>--></script>y`, `<script>&lt;!--&lt;script&gt;--&gt;</script>y`},
+		// NUL characters
+		{"a\x00b&#0;", "ab\uFFFD"},
+		{"<body><script>a\x00b</script><style>c\x00</style>", "<script>a\uFFFDb</script><style>c\uFFFD</style>"},
+		{"<textarea>a\x00b</textarea>", "<textarea>a\uFFFDb</textarea>"},
+		{"<p title=\"a\x00b\">c\x00</p>", "<p title=\"a\uFFFDb\">c</p>"},
+		{"<table>\x00 <tr></tr></table>", "<table> <tbody><tr></tr></tbody></table>"},
+		{"<select>a\x00</select>", "<select>a</select>"},
+		{"<svg>a\x00</svg>", "<svg>a\uFFFD</svg>"},
+	}
+	for _, test := range tests {
+		_, out := parseHTMLBody(t, test.input)
+		if out != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.input, test.expected, out)
+		}
+	}
+}
+
+func TestParseHTMLForeignContent(t *testing.T) {
+	doc, out := parseHTMLBody(t, `<svg viewbox="0 0 1 1"><foreignobject><p>x</p></foreignobject><circle r=1 /></svg><p>after`)
+	expected := `<svg viewBox="0 0 1 1"><foreignObject><p>x</p></foreignObject><circle r="1"></circle></svg><p>after</p>`
+	if out != expected {
+		t.Errorf("Expected %s, got %s", expected, out)
+	}
+	svg := doc.GetDocumentElement().GetLastChild().GetFirstChild().(Element)
+	if svg.GetQName().Space != SVGNamespace {
+		t.Errorf("Wrong svg namespace: %s", svg.GetQName().Space)
+	}
+	p := svg.GetFirstChild().GetFirstChild().(Element)
+	if p.GetQName().Space != XHTMLNamespace {
+		t.Errorf("Wrong p namespace: %s", p.GetQName().Space)
+	}
+	circle := svg.GetLastChild().(Element)
+	if circle.GetQName().Space != SVGNamespace || circle.HasChildNodes() {
+		t.Errorf("Wrong circle")
+	}
+}
+
+func TestParseHTMLTemplate(t *testing.T) {
+	// Table content in templates, from the html5lib template tests
+	tests := []struct {
+		input, expected string
+	}{
+		{`<body><template><td>x</td></template>`, `<template><td>x</td></template>`},
+		{`<body><template><th></th></template>`, `<template><th></th></template>`},
+		{`<body><template><tr></tr></template>`, `<template><tr></tr></template>`},
+		{`<body><template><thead></thead></template>`, `<template><thead></thead></template>`},
+		{`<body><template><caption></caption></template>`, `<template><caption></caption></template>`},
+		{`<body><template><colgroup></colgroup></template>`, `<template><colgroup></colgroup></template>`},
+		{`<body><template><col></template>`, `<template><col></col></template>`},
+		{`<body><template><col><div></template>`, `<template><col></col></template>`},
+		{`<body><template><td></td><div></div></template>`, `<template><td></td><div></div></template>`},
+		{`<body><template><tr></tr><td></td></template>`, `<template><tr></tr><tr><td></td></tr></template>`},
+		{`<body><template><div><tr></tr></div></template>`, `<template><div></div></template>`},
+		{`<body><template><template><tr></tr></template><td></td></template>`, `<template><template><tr></tr></template><td></td></template>`},
+		{`<table><tbody><template><tr></tr></template></tbody></table>`, `<table><tbody><template><tr></tr></template></tbody></table>`},
+		{`<body><template><td>x`, `<template><td>x</td></template>`},
+	}
+	for _, test := range tests {
+		_, out := parseHTMLBody(t, test.input)
+		if out != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, out)
+		}
+	}
+}
+
+func TestEncodeHTML(t *testing.T) {
+	input := `<!DOCTYPE html><html><head><title>A &amp; B</title><script>if (a < b && c) { x = "<p>" }</script></head>` +
+		`<body><p class="a&quot;b">x&nbsp;y<br><img src="i.png"><input type=checkbox checked disabled value=""></p>` +
+		"<pre>\n\nz</pre><textarea>&lt;b&gt;</textarea><!-- c --><svg><circle r=\"1\"/></svg></body></html>"
+	doc, err := ParseHTML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := EncodeHTML(doc, &buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<!DOCTYPE html><html><head><title>A &amp; B</title><script>if (a < b && c) { x = "<p>" }</script></head>` +
+		`<body><p class="a&quot;b">x&nbsp;y<br><img src="i.png"><input type="checkbox" checked disabled value=""></p>` +
+		"<pre>\n\nz</pre><textarea>&lt;b&gt;</textarea><!-- c --><svg><circle r=\"1\"></circle></svg></body></html>"
+	if buf.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, buf.String())
+	}
+	// The output parses into the same tree
+	doc2, err := ParseHTML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.GetDocumentElement().IsEqualNode(doc2.GetDocumentElement()) {
+		t.Errorf("Round trip changed the document")
+	}
+}
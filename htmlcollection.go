@@ -0,0 +1,148 @@
+package dom
+
+import (
+	"strings"
+)
+
+// HTMLCollection is a live list of elements. The list is updated
+// when the subtree it is built from changes.
+type HTMLCollection interface {
+	GetLength() int
+
+	// Returns the element at the given index, or nil if the index is
+	// out of range
+	Item(int) Element
+
+	// Returns the first element whose id or name attribute is the
+	// given name, or nil if there is none
+	NamedItem(name string) Element
+}
+
+// BasicHTMLCollection is a live collection of the descendants of a
+// root node that satisfy a predicate. The collection is rebuilt
+// lazily when the subtree of the root changes.
+type BasicHTMLCollection struct {
+	root  Node
+	match func(Element) bool
+
+	list []Element
+	ver  int
+}
+
+var _ HTMLCollection = &BasicHTMLCollection{}
+
+func newBasicHTMLCollection(root Node, match func(Element) bool) *BasicHTMLCollection {
+	return &BasicHTMLCollection{
+		root:  root,
+		match: match,
+	}
+}
+
+// nextInSubtree returns the node following node in document order
+// that is under root, or nil
+func nextInSubtree(root, node Node) Node {
+	if child := node.GetFirstChild(); child != nil {
+		return child
+	}
+	for trc := node; trc != nil && trc != root; trc = trc.GetParentNode() {
+		if next := trc.GetNextSibling(); next != nil {
+			return next
+		}
+	}
+	return nil
+}
+
+func (c *BasicHTMLCollection) buildList() {
+	ver := c.root.treeNode().subtreeVer
+	if c.list != nil && c.ver == ver {
+		return
+	}
+	c.list = make([]Element, 0)
+	for node := nextInSubtree(c.root, c.root); node != nil; node = nextInSubtree(c.root, node) {
+		if el, ok := node.(Element); ok && c.match(el) {
+			c.list = append(c.list, el)
+		}
+	}
+	c.ver = ver
+}
+
+func (c *BasicHTMLCollection) GetLength() int {
+	c.buildList()
+	return len(c.list)
+}
+
+func (c *BasicHTMLCollection) Item(i int) Element {
+	c.buildList()
+	if i < 0 || i >= len(c.list) {
+		return nil
+	}
+	return c.list[i]
+}
+
+func (c *BasicHTMLCollection) NamedItem(name string) Element {
+	if len(name) == 0 {
+		return nil
+	}
+	c.buildList()
+	for _, el := range c.list {
+		if id, _ := el.GetAttribute("id"); id == name {
+			return el
+		}
+		if el.GetQName().Space == XHTMLNamespace {
+			if n, _ := el.GetAttribute("name"); n == name {
+				return el
+			}
+		}
+	}
+	return nil
+}
+
+// getElementsByTagName returns the descendants of root with the
+// qualified name. The name "*" matches all elements.
+func getElementsByTagName(root Node, name string) HTMLCollection {
+	return newBasicHTMLCollection(root, func(el Element) bool {
+		if name == "*" {
+			return true
+		}
+		qname := el.GetQName()
+		return qname.QName() == name
+	})
+}
+
+// getElementsByTagNameNS returns the descendants of root with the
+// namespace and local name. "*" matches any namespace or local name.
+func getElementsByTagNameNS(root Node, uri, local string) HTMLCollection {
+	return newBasicHTMLCollection(root, func(el Element) bool {
+		qname := el.GetQName()
+		return (uri == "*" || qname.Space == uri) && (local == "*" || qname.Local == local)
+	})
+}
+
+// getElementsByClassName returns the descendants of root that have
+// all the whitespace separated class names
+func getElementsByClassName(root Node, names string) HTMLCollection {
+	classes := strings.Fields(names)
+	return newBasicHTMLCollection(root, func(el Element) bool {
+		if len(classes) == 0 {
+			return false
+		}
+		value, ok := el.GetAttribute("class")
+		if !ok {
+			return false
+		}
+		have := strings.Fields(value)
+		for _, class := range classes {
+			found := false
+			for _, h := range have {
+				if h == class {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	})
+}
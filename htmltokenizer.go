@@ -0,0 +1,567 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type htmlTokenType int
+
+const (
+	htmlEOFToken htmlTokenType = iota
+	htmlTextToken
+	htmlStartTagToken
+	htmlEndTagToken
+	htmlCommentToken
+	htmlDoctypeToken
+)
+
+type htmlAttr struct {
+	name  string
+	value string
+}
+
+type htmlToken struct {
+	typ         htmlTokenType
+	data        string
+	attrs       []htmlAttr
+	selfClosing bool
+
+	// Document type identifiers
+	publicID string
+	systemID string
+}
+
+func (t *htmlToken) getAttr(name string) (string, bool) {
+	for _, a := range t.attrs {
+		if a.name == name {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// Text content models of raw text elements
+type htmlRawKind int
+
+const (
+	htmlNoRaw htmlRawKind = iota
+	// Text until the end tag, without character references
+	htmlRawText
+	// Text until the end tag, with character references
+	htmlRCDATA
+	// Text until the end of input
+	htmlPlaintext
+)
+
+// htmlTokenizer implements the tokenization stage of the WHATWG HTML
+// parser
+type htmlTokenizer struct {
+	input string
+	pos   int
+
+	// Raw text mode, set by the tree builder after a start tag
+	raw    htmlRawKind
+	rawTag string
+
+	// If set, CDATA sections are recognized. The tree builder sets
+	// this in foreign content.
+	allowCDATA bool
+}
+
+func newHTMLTokenizer(input string) *htmlTokenizer {
+	// Normalize newlines
+	input = strings.ReplaceAll(input, "\r\n", "\n")
+	input = strings.ReplaceAll(input, "\r", "\n")
+	return &htmlTokenizer{input: input}
+}
+
+func (t *htmlTokenizer) setRaw(kind htmlRawKind, tag string) {
+	t.raw = kind
+	t.rawTag = tag
+}
+
+func isASCIIAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isASCIIAlnum(c byte) bool {
+	return isASCIIAlpha(c) || (c >= '0' && c <= '9')
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+func (t *htmlTokenizer) hasPrefixFold(prefix string) bool {
+	return len(t.input)-t.pos >= len(prefix) && strings.EqualFold(t.input[t.pos:t.pos+len(prefix)], prefix)
+}
+
+// next returns the next token
+func (t *htmlTokenizer) next() htmlToken {
+	if t.pos >= len(t.input) {
+		return htmlToken{typ: htmlEOFToken}
+	}
+	if t.raw != htmlNoRaw {
+		return t.rawText()
+	}
+	if t.input[t.pos] == '<' {
+		if tok, ok := t.markup(); ok {
+			return tok
+		}
+		// A '<' that does not start markup is text
+		start := t.pos
+		t.pos++
+		return t.text(start)
+	}
+	return t.text(t.pos)
+}
+
+// text returns the text starting at start until the next '<'. NUL
+// characters are kept, so the tree builder can drop them.
+func (t *htmlTokenizer) text(start int) htmlToken {
+	end := strings.IndexByte(t.input[t.pos:], '<')
+	if end == -1 {
+		t.pos = len(t.input)
+	} else {
+		t.pos += end
+	}
+	return htmlToken{typ: htmlTextToken, data: decodeCharRefs(t.input[start:t.pos], false)}
+}
+
+// rawText returns the content of a raw text element
+func (t *htmlTokenizer) rawText() htmlToken {
+	start := t.pos
+	kind := t.raw
+	if kind == htmlPlaintext {
+		t.pos = len(t.input)
+	} else {
+		if t.rawTag == "script" {
+			t.pos = t.scriptDataEnd()
+		} else {
+			for {
+				i := strings.Index(t.input[t.pos:], "</")
+				if i == -1 {
+					t.pos = len(t.input)
+					break
+				}
+				t.pos += i
+				if t.isTagName(t.pos+2, t.rawTag) {
+					break
+				}
+				t.pos += 2
+			}
+		}
+		t.raw = htmlNoRaw
+	}
+	data := strings.ReplaceAll(t.input[start:t.pos], "\x00", "\uFFFD")
+	if kind == htmlRCDATA {
+		data = decodeCharRefs(data, false)
+	}
+	if len(data) == 0 {
+		return t.next()
+	}
+	return htmlToken{typ: htmlTextToken, data: data}
+}
+
+// isTagName returns true if the input at pos starts with the tag
+// name, followed by a character that ends a tag name
+func (t *htmlTokenizer) isTagName(pos int, name string) bool {
+	end := pos + len(name)
+	return end <= len(t.input) && strings.EqualFold(t.input[pos:end], name) &&
+		(end == len(t.input) || isHTMLSpace(t.input[end]) || t.input[end] == '/' || t.input[end] == '>')
+}
+
+// scriptDataEnd returns the position of the end tag of the script
+// starting at t.pos, or the end of input. A "<!--" in script data
+// starts an escaped section that ends with "-->". In an escaped
+// section, a <script> tag starts a double escaped section in which
+// </script> does not end the script, but returns to the escaped
+// section.
+func (t *htmlTokenizer) scriptDataEnd() int {
+	const (
+		data = iota
+		escaped
+		doubleEscaped
+	)
+	state := data
+	// The dashes before '>' must be after the start of the escaped
+	// section
+	dashStart := 0
+	for i := t.pos; i < len(t.input); i++ {
+		switch t.input[i] {
+		case '<':
+			switch {
+			case state == data && strings.HasPrefix(t.input[i:], "<!--"):
+				state = escaped
+				i += 1
+				dashStart = i + 1
+			case state != doubleEscaped && strings.HasPrefix(t.input[i:], "</") && t.isTagName(i+2, "script"):
+				return i
+			case state == escaped && t.isTagName(i+1, "script"):
+				state = doubleEscaped
+				i += len("script")
+			case state == doubleEscaped && strings.HasPrefix(t.input[i:], "</") && t.isTagName(i+2, "script"):
+				state = escaped
+				i += 1 + len("script")
+			}
+		case '>':
+			if state != data && i-2 >= dashStart && t.input[i-2:i] == "--" {
+				state = data
+			}
+		}
+	}
+	return len(t.input)
+}
+
+// markup reads a tag, comment, or doctype starting at '<'
+func (t *htmlTokenizer) markup() (htmlToken, bool) {
+	rest := t.input[t.pos+1:]
+	switch {
+	case len(rest) > 0 && isASCIIAlpha(rest[0]):
+		t.pos++
+		return t.tag(htmlStartTagToken), true
+
+	case len(rest) > 1 && rest[0] == '/' && isASCIIAlpha(rest[1]):
+		t.pos += 2
+		return t.tag(htmlEndTagToken), true
+
+	case strings.HasPrefix(rest, "/>"):
+		// "</>" is ignored
+		t.pos += 3
+		return t.next(), true
+
+	case len(rest) > 0 && rest[0] == '/':
+		t.pos += 2
+		return t.bogusComment(), true
+
+	case strings.HasPrefix(rest, "!--"):
+		t.pos += 4
+		return t.comment(), true
+
+	case len(rest) >= 8 && strings.EqualFold(rest[1:8], "DOCTYPE") && rest[0] == '!':
+		t.pos += 9
+		return t.doctype(), true
+
+	case strings.HasPrefix(rest, "![CDATA[") && t.allowCDATA:
+		t.pos += 9
+		end := strings.Index(t.input[t.pos:], "]]>")
+		var data string
+		if end == -1 {
+			data = t.input[t.pos:]
+			t.pos = len(t.input)
+		} else {
+			data = t.input[t.pos : t.pos+end]
+			t.pos += end + 3
+		}
+		return htmlToken{typ: htmlTextToken, data: data}, true
+
+	case len(rest) > 0 && rest[0] == '!':
+		t.pos += 2
+		return t.bogusComment(), true
+
+	case len(rest) > 0 && rest[0] == '?':
+		t.pos++
+		return t.bogusComment(), true
+	}
+	return htmlToken{}, false
+}
+
+func (t *htmlTokenizer) bogusComment() htmlToken {
+	end := strings.IndexByte(t.input[t.pos:], '>')
+	var data string
+	if end == -1 {
+		data = t.input[t.pos:]
+		t.pos = len(t.input)
+	} else {
+		data = t.input[t.pos : t.pos+end]
+		t.pos += end + 1
+	}
+	return htmlToken{typ: htmlCommentToken, data: data}
+}
+
+func (t *htmlTokenizer) comment() htmlToken {
+	rest := t.input[t.pos:]
+	// Abrupt closing of empty comment
+	if strings.HasPrefix(rest, ">") {
+		t.pos++
+		return htmlToken{typ: htmlCommentToken}
+	}
+	if strings.HasPrefix(rest, "->") {
+		t.pos += 2
+		return htmlToken{typ: htmlCommentToken}
+	}
+	end := strings.Index(rest, "-->")
+	endBang := strings.Index(rest, "--!>")
+	switch {
+	case end == -1 && endBang == -1:
+		t.pos = len(t.input)
+		return htmlToken{typ: htmlCommentToken, data: rest}
+	case end == -1 || (endBang != -1 && endBang < end):
+		t.pos += endBang + 4
+		return htmlToken{typ: htmlCommentToken, data: rest[:endBang]}
+	}
+	t.pos += end + 3
+	return htmlToken{typ: htmlCommentToken, data: rest[:end]}
+}
+
+func (t *htmlTokenizer) skipSpace() {
+	for t.pos < len(t.input) && isHTMLSpace(t.input[t.pos]) {
+		t.pos++
+	}
+}
+
+// doctype reads a DOCTYPE after the keyword
+func (t *htmlTokenizer) doctype() htmlToken {
+	tok := htmlToken{typ: htmlDoctypeToken}
+	end := strings.IndexByte(t.input[t.pos:], '>')
+	var content string
+	if end == -1 {
+		content = t.input[t.pos:]
+		t.pos = len(t.input)
+	} else {
+		content = t.input[t.pos : t.pos+end]
+		t.pos += end + 1
+	}
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return tok
+	}
+	tok.data = strings.ToLower(fields[0])
+	rest := strings.TrimSpace(content[strings.Index(content, fields[0])+len(fields[0]):])
+	literal := func() string {
+		rest = strings.TrimSpace(rest)
+		if len(rest) == 0 || (rest[0] != '"' && rest[0] != '\'') {
+			return ""
+		}
+		q := rest[0]
+		end := strings.IndexByte(rest[1:], q)
+		if end == -1 {
+			ret := rest[1:]
+			rest = ""
+			return ret
+		}
+		ret := rest[1 : end+1]
+		rest = rest[end+2:]
+		return ret
+	}
+	switch {
+	case len(rest) >= 6 && strings.EqualFold(rest[:6], "PUBLIC"):
+		rest = rest[6:]
+		tok.publicID = literal()
+		tok.systemID = literal()
+	case len(rest) >= 6 && strings.EqualFold(rest[:6], "SYSTEM"):
+		rest = rest[6:]
+		tok.systemID = literal()
+	}
+	return tok
+}
+
+// tag reads a start or end tag after '<' or '</'
+func (t *htmlTokenizer) tag(typ htmlTokenType) htmlToken {
+	tok := htmlToken{typ: typ}
+	start := t.pos
+	for t.pos < len(t.input) && !isHTMLSpace(t.input[t.pos]) && t.input[t.pos] != '/' && t.input[t.pos] != '>' {
+		t.pos++
+	}
+	tok.data = strings.ToLower(t.input[start:t.pos])
+	for {
+		t.skipSpace()
+		if t.pos >= len(t.input) {
+			// EOF in tag: the tag is dropped
+			return htmlToken{typ: htmlEOFToken}
+		}
+		c := t.input[t.pos]
+		if c == '>' {
+			t.pos++
+			break
+		}
+		if c == '/' {
+			t.pos++
+			if t.pos < len(t.input) && t.input[t.pos] == '>' {
+				tok.selfClosing = true
+				t.pos++
+				break
+			}
+			continue
+		}
+		// Attribute name. The first character can be '='
+		nameStart := t.pos
+		t.pos++
+		for t.pos < len(t.input) {
+			c := t.input[t.pos]
+			if isHTMLSpace(c) || c == '/' || c == '>' || c == '=' {
+				break
+			}
+			t.pos++
+		}
+		name := strings.ToLower(t.input[nameStart:t.pos])
+		value := ""
+		t.skipSpace()
+		if t.pos < len(t.input) && t.input[t.pos] == '=' {
+			t.pos++
+			t.skipSpace()
+			value = t.attrValue()
+		}
+		if _, exists := tok.getAttr(name); !exists && typ == htmlStartTagToken {
+			tok.attrs = append(tok.attrs, htmlAttr{name: name, value: value})
+		}
+	}
+	return tok
+}
+
+func (t *htmlTokenizer) attrValue() string {
+	if t.pos >= len(t.input) {
+		return ""
+	}
+	if q := t.input[t.pos]; q == '"' || q == '\'' {
+		t.pos++
+		end := strings.IndexByte(t.input[t.pos:], q)
+		var value string
+		if end == -1 {
+			value = t.input[t.pos:]
+			t.pos = len(t.input)
+		} else {
+			value = t.input[t.pos : t.pos+end]
+			t.pos += end + 1
+		}
+		return decodeCharRefs(value, true)
+	}
+	start := t.pos
+	for t.pos < len(t.input) && !isHTMLSpace(t.input[t.pos]) && t.input[t.pos] != '>' {
+		t.pos++
+	}
+	return decodeCharRefs(t.input[start:t.pos], true)
+}
+
+// Replacements for numeric character references in the C1 range
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', '\u008D', 'Ž', '\u008F',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', '\u009D', 'ž', 'Ÿ',
+}
+
+// Named character references that are recognized without a
+// semicolon
+var legacyEntities = []string{"amp", "lt", "gt", "quot", "nbsp", "copy", "reg", "AMP", "LT", "GT", "QUOT", "COPY", "REG"}
+
+// lookupEntity returns the value of a named character reference
+func lookupEntity(name string) (string, bool) {
+	switch name {
+	case "amp", "AMP":
+		return "&", true
+	case "lt", "LT":
+		return "<", true
+	case "gt", "GT":
+		return ">", true
+	case "quot", "QUOT":
+		return "\"", true
+	case "apos":
+		return "'", true
+	case "COPY":
+		return "©", true
+	case "REG":
+		return "®", true
+	}
+	v, ok := xml.HTMLEntity[name]
+	return v, ok
+}
+
+// decodeCharRefs replaces the character references in s. In
+// attribute values, a legacy reference without a semicolon followed
+// by an alphanumeric character or '=' is not replaced, and NUL
+// characters are replaced with U+FFFD.
+func decodeCharRefs(s string, inAttr bool) string {
+	if inAttr {
+		s = strings.ReplaceAll(s, "\x00", "\uFFFD")
+	}
+	if strings.IndexByte(s, '&') == -1 {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c != '&' {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		if value, n := charRef(s[i+1:], inAttr); n > 0 {
+			sb.WriteString(value)
+			i += n + 1
+			continue
+		}
+		sb.WriteByte(c)
+		i++
+	}
+	return sb.String()
+}
+
+// charRef decodes the character reference after '&'. Returns the
+// value and the number of bytes used, or 0 if there is no reference.
+func charRef(s string, inAttr bool) (string, int) {
+	if len(s) == 0 {
+		return "", 0
+	}
+	if s[0] == '#' {
+		i := 1
+		base := 10
+		if i < len(s) && (s[i] == 'x' || s[i] == 'X') {
+			base = 16
+			i++
+		}
+		start := i
+		for i < len(s) && ((base == 10 && s[i] >= '0' && s[i] <= '9') || (base == 16 && isHexDigit(s[i]))) {
+			i++
+		}
+		if i == start {
+			return "", 0
+		}
+		code, err := strconv.ParseInt(s[start:i], base, 64)
+		if i < len(s) && s[i] == ';' {
+			i++
+		}
+		r := rune(code)
+		switch {
+		case err != nil || code > utf8.MaxRune || code == 0 || (code >= 0xD800 && code <= 0xDFFF):
+			r = '�'
+		case code >= 0x80 && code <= 0x9F:
+			r = windows1252[code-0x80]
+		}
+		return string(r), i
+	}
+	i := 0
+	for i < len(s) && isASCIIAlnum(s[i]) {
+		i++
+	}
+	if i == 0 {
+		return "", 0
+	}
+	if i < len(s) && s[i] == ';' {
+		if v, ok := lookupEntity(s[:i]); ok {
+			return v, i + 1
+		}
+		return "", 0
+	}
+	// Legacy references without semicolons match the longest
+	// prefix
+	for _, name := range legacyEntities {
+		if !strings.HasPrefix(s, name) {
+			continue
+		}
+		if inAttr && len(s) > len(name) && (isASCIIAlnum(s[len(name)]) || s[len(name)] == '=') {
+			return "", 0
+		}
+		if v, ok := lookupEntity(name); ok {
+			return v, len(name)
+		}
+	}
+	return "", 0
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
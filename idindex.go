@@ -0,0 +1,251 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// IDOptions determine which attributes of a document are ID
+// attributes
+type IDOptions struct {
+	// Attributes are the names of ID attributes. Attributes without
+	// a namespace have an empty Space.
+	Attributes []xml.Name
+
+	// If DTD is set, attributes declared with type ID in the internal
+	// subset of the document type are also ID attributes.
+	DTD bool
+}
+
+// DefaultIDOptions returns the ID options of new documents: xml:id
+// and id attributes, and attributes declared as ID in the DTD
+func DefaultIDOptions() IDOptions {
+	return IDOptions{
+		Attributes: []xml.Name{{Space: xmlURL, Local: "id"}, {Local: "id"}},
+		DTD:        true,
+	}
+}
+
+// idIndex maps ID values to elements. The index is built on the
+// first lookup, and then maintained as nodes are inserted and
+// detached, and attributes are changed.
+type idIndex struct {
+	options IDOptions
+	// ID attributes declared in the DTD, keyed by element and
+	// attribute qualified names
+	dtdIDs map[[2]string]bool
+	// Elements with an ID value. An element may appear more than
+	// once if it has multiple ID attributes with the same value.
+	ids map[string][]*BasicElement
+}
+
+func (index *idIndex) isID(el *BasicElement, attr *BasicAttr) bool {
+	for _, name := range index.options.Attributes {
+		if attr.name.Name == name {
+			return true
+		}
+	}
+	return index.dtdIDs[[2]string{el.name.QName(), attr.name.QName()}]
+}
+
+func (index *idIndex) add(id string, el *BasicElement) {
+	index.ids[id] = append(index.ids[id], el)
+}
+
+func (index *idIndex) remove(id string, el *BasicElement) {
+	list := index.ids[id]
+	for i := range list {
+		if list[i] == el {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(index.ids, id)
+		return
+	}
+	index.ids[id] = list
+}
+
+// update adds or removes the ID attributes of the elements under
+// node
+func (index *idIndex) update(node Node, add bool) {
+	for trc := node; trc != nil; trc = nextInSubtree(node, trc) {
+		el, ok := trc.(*BasicElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range el.attributes.attrs {
+			if !index.isID(el, attr) {
+				continue
+			}
+			if add {
+				index.add(attr.value, el)
+			} else {
+				index.remove(attr.value, el)
+			}
+		}
+	}
+}
+
+// getIDIndex returns the ID index of the document that contains
+// node, or nil if node is not connected to its document, or if the
+// document has no index yet
+func getIDIndex(node Node) *idIndex {
+	doc, _ := node.GetOwnerDocument().(*BasicDocument)
+	if doc == nil || doc.ids == nil || getRootNode(node) != doc {
+		return nil
+	}
+	return doc.ids
+}
+
+// idIndexInserted updates the ID index after node is inserted
+func idIndexInserted(node Node) {
+	if node.GetNodeType() == DOCUMENT_TYPE_NODE {
+		if doc, ok := node.GetParentNode().(*BasicDocument); ok {
+			doc.ids = nil
+		}
+		return
+	}
+	if index := getIDIndex(node); index != nil {
+		index.update(node, true)
+	}
+}
+
+// idIndexDetaching updates the ID index before node is detached
+func idIndexDetaching(node Node) {
+	if node.GetNodeType() == DOCUMENT_TYPE_NODE {
+		if doc, ok := node.GetParentNode().(*BasicDocument); ok {
+			doc.ids = nil
+		}
+		return
+	}
+	if index := getIDIndex(node); index != nil {
+		index.update(node, false)
+	}
+}
+
+// idIndexAttrChanged updates the ID index when the value of attr of
+// el changes from oldValue to newValue. hadOld and hasNew are false
+// if the attribute is added or removed.
+func idIndexAttrChanged(el *BasicElement, attr *BasicAttr, oldValue string, hadOld bool, newValue string, hasNew bool) {
+	index := getIDIndex(el)
+	if index == nil || !index.isID(el, attr) {
+		return
+	}
+	if hadOld {
+		index.remove(oldValue, el)
+	}
+	if hasNew {
+		index.add(newValue, el)
+	}
+}
+
+// dtdIDAttributes returns the attributes declared with type ID in
+// the ATTLIST declarations of the internal subset
+func dtdIDAttributes(subset string) map[[2]string]bool {
+	ret := make(map[[2]string]bool)
+	for {
+		i := strings.Index(subset, "<!")
+		if i == -1 {
+			return ret
+		}
+		subset = subset[i:]
+		if strings.HasPrefix(subset, "<!--") {
+			end := strings.Index(subset, "-->")
+			if end == -1 {
+				return ret
+			}
+			subset = subset[end+3:]
+			continue
+		}
+		isAttlist := strings.HasPrefix(subset, "<!ATTLIST")
+		tokens, rest := dtdDeclTokens(subset[2:])
+		subset = rest
+		if !isAttlist || len(tokens) < 2 {
+			continue
+		}
+		element := tokens[1]
+		defs := tokens[2:]
+		for len(defs) >= 3 {
+			name, typ := defs[0], defs[1]
+			defs = defs[2:]
+			if typ == "NOTATION" && len(defs) > 0 {
+				defs = defs[1:]
+			}
+			if len(defs) > 0 && defs[0] == "#FIXED" {
+				defs = defs[1:]
+			}
+			if len(defs) > 0 {
+				defs = defs[1:]
+			}
+			if typ == "ID" {
+				ret[[2]string{element, name}] = true
+			}
+		}
+	}
+}
+
+// dtdDeclTokens splits a markup declaration into tokens. Quoted
+// literals and parenthesized groups are single tokens. Returns the
+// tokens and the input following the declaration.
+func dtdDeclTokens(in string) ([]string, string) {
+	tokens := make([]string, 0)
+	for len(in) > 0 {
+		switch c := in[0]; {
+		case c == '>':
+			return tokens, in[1:]
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			in = in[1:]
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(in[1:], c)
+			if end == -1 {
+				return tokens, ""
+			}
+			tokens = append(tokens, in[:end+2])
+			in = in[end+2:]
+		case c == '(':
+			end := strings.IndexByte(in, ')')
+			if end == -1 {
+				return tokens, ""
+			}
+			tokens = append(tokens, in[:end+1])
+			in = in[end+1:]
+		default:
+			end := strings.IndexAny(in, " \t\n\r>\"'(")
+			if end == -1 {
+				end = len(in)
+			}
+			tokens = append(tokens, in[:end])
+			in = in[end:]
+		}
+	}
+	return tokens, in
+}
+
+// precedes returns true if node a is before node b in document order
+func precedes(a, b Node) bool {
+	ancestors := func(node Node) []Node {
+		ret := make([]Node, 0)
+		for trc := node; trc != nil; trc = trc.GetParentNode() {
+			ret = append(ret, trc)
+		}
+		return ret
+	}
+	pa, pb := ancestors(a), ancestors(b)
+	i, j := len(pa)-1, len(pb)-1
+	for i >= 0 && j >= 0 && pa[i] == pb[j] {
+		i--
+		j--
+	}
+	if i < 0 || j < 0 {
+		// One is an ancestor of the other
+		return i < 0 && j >= 0
+	}
+	for trc := pa[i].GetNextSibling(); trc != nil; trc = trc.GetNextSibling() {
+		if trc == pb[j] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestGetElementById(t *testing.T) {
+	input := `<root><a id="x"/><b xml:id="y" id="z"/><c ref="x y"/></root>`
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	a := root.GetFirstChild().(Element)
+	b := a.GetNextSibling().(Element)
+	for id, expected := range map[string]Element{"x": a, "y": b, "z": b, "w": nil} {
+		if el := doc.GetElementById(id); el != expected {
+			t.Errorf("Wrong element for %s: %v", id, el)
+		}
+	}
+
+	// Attribute changes
+	a.SetAttribute("id", "w")
+	if doc.GetElementById("x") != nil || doc.GetElementById("w") != a {
+		t.Errorf("Index not updated after set")
+	}
+	b.RemoveAttributeNS(xmlURL, "id")
+	if doc.GetElementById("y") != nil {
+		t.Errorf("Index not updated after remove")
+	}
+
+	// Inserted and detached nodes
+	el := doc.CreateElement("d")
+	el.SetAttribute("id", "w")
+	if doc.GetElementById("w") != a {
+		t.Errorf("Detached element indexed")
+	}
+	root.InsertBefore(el, a)
+	if doc.GetElementById("w") != el {
+		t.Errorf("Expected first element in document order")
+	}
+	root.RemoveChild(el)
+	if doc.GetElementById("w") != a {
+		t.Errorf("Index not updated after detach")
+	}
+
+	refs, err := doc.ResolveIDRefs(" w  z ")
+	if err != nil || len(refs) != 2 || refs[0] != a || refs[1] != b {
+		t.Errorf("Wrong refs: %v %v", refs, err)
+	}
+	if _, err := doc.ResolveIDRefs("w q"); err == nil {
+		t.Errorf("Expected error")
+	}
+
+	doc.SetIDOptions(IDOptions{Attributes: []xml.Name{{Local: "ref"}}})
+	if doc.GetElementById("w") != nil || doc.GetElementById("x y") == nil {
+		t.Errorf("ID options not applied")
+	}
+}
+
+func TestDTDIDAttributes(t *testing.T) {
+	input := `<!DOCTYPE root [
+<!-- <!ATTLIST item key ID #IMPLIED> -->
+<!ENTITY e "<!ATTLIST item name ID #IMPLIED>">
+<!ATTLIST item
+    kind (a|b) "a"
+    key ID #REQUIRED
+    fixed CDATA #FIXED "x">
+]>
+<root><item key="k1" name="n1"/></root>`
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.GetElementById("k1") == nil {
+		t.Errorf("DTD ID not found")
+	}
+	if doc.GetElementById("n1") != nil {
+		t.Errorf("Attribute declared in entity used as ID")
+	}
+	options := doc.GetIDOptions()
+	options.DTD = false
+	doc.SetIDOptions(options)
+	if doc.GetElementById("k1") != nil {
+		t.Errorf("DTD ID used when disabled")
+	}
+}
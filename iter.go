@@ -0,0 +1,102 @@
+//go:build go1.23
+
+package dom
+
+import "iter"
+
+// The iterators walk the sibling lists of the tree directly. They can
+// be stopped early by breaking out of the loop. Modifying the tree
+// during iteration may end the iteration early or skip nodes.
+
+// Children returns an iterator over the children of node
+func Children(node Node) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for child := node.treeNode().firstChild(); child != nil; child = child.treeNode().nextSibling() {
+			if !yield(child) {
+				return
+			}
+		}
+	}
+}
+
+// ElementChildren returns an iterator over the children of node that
+// are elements
+func ElementChildren(node Node) iter.Seq[Element] {
+	return func(yield func(Element) bool) {
+		for child := node.treeNode().firstChild(); child != nil; child = child.treeNode().nextSibling() {
+			if el, ok := child.(Element); ok {
+				if !yield(el) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Descendants returns an iterator over the descendants of node in
+// document order. The node itself is not included.
+func Descendants(node Node) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for trc := nextInSubtree(node, node); trc != nil; trc = nextInSubtree(node, trc) {
+			if !yield(trc) {
+				return
+			}
+		}
+	}
+}
+
+// Ancestors returns an iterator over the ancestors of node, starting
+// with its parent
+func Ancestors(node Node) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for trc := node.treeNode().parent; trc != nil; trc = trc.treeNode().parent {
+			if !yield(trc) {
+				return
+			}
+		}
+	}
+}
+
+// FollowingSiblings returns an iterator over the siblings after
+// node, starting with the next sibling
+func FollowingSiblings(node Node) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for trc := node.treeNode().nextSibling(); trc != nil; trc = trc.treeNode().nextSibling() {
+			if !yield(trc) {
+				return
+			}
+		}
+	}
+}
+
+// PrecedingSiblings returns an iterator over the siblings before
+// node, starting with the previous sibling
+func PrecedingSiblings(node Node) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for trc := node.treeNode().prevSibling(); trc != nil; trc = trc.treeNode().prevSibling() {
+			if !yield(trc) {
+				return
+			}
+		}
+	}
+}
+
+// Attributes returns an iterator over the attributes of el
+func Attributes(el Element) iter.Seq[Attr] {
+	return func(yield func(Attr) bool) {
+		if basic, ok := el.(*BasicElement); ok {
+			for _, attr := range basic.attributes.attrs {
+				if !yield(attr) {
+					return
+				}
+			}
+			return
+		}
+		attrs := el.GetAttributes()
+		for i := 0; i < attrs.GetLength(); i++ {
+			if !yield(attrs.Item(i)) {
+				return
+			}
+		}
+	}
+}
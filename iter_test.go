@@ -0,0 +1,67 @@
+//go:build go1.23
+
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestIterators(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<a x="1" y="2"><b><c/>t</b><!--d--><e/><f/></a>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := doc.GetDocumentElement()
+	collect := func(seq func(func(Node) bool)) string {
+		nodes := make([]Node, 0)
+		for n := range seq {
+			nodes = append(nodes, n)
+		}
+		return nodeNames(nodes)
+	}
+	if s := collect(Children(a)); s != "b,d,e,f" {
+		t.Errorf("Wrong children: %s", s)
+	}
+	if s := collect(Descendants(a)); s != "b,c,t,d,e,f" {
+		t.Errorf("Wrong descendants: %s", s)
+	}
+	c := a.GetFirstChild().GetFirstChild()
+	if s := collect(Ancestors(c)); s != "b,a,#document" {
+		t.Errorf("Wrong ancestors: %s", s)
+	}
+	e := a.GetLastChild().GetPreviousSibling()
+	if s := collect(FollowingSiblings(e)); s != "f" {
+		t.Errorf("Wrong following siblings: %s", s)
+	}
+	if s := collect(PrecedingSiblings(e)); s != "d,b" {
+		t.Errorf("Wrong preceding siblings: %s", s)
+	}
+	names := make([]string, 0)
+	for el := range ElementChildren(a) {
+		names = append(names, el.GetTagName())
+	}
+	if strings.Join(names, ",") != "b,e,f" {
+		t.Errorf("Wrong element children: %v", names)
+	}
+	names = names[:0]
+	for attr := range Attributes(a) {
+		names = append(names, attr.GetName()+"="+attr.GetValue())
+	}
+	if strings.Join(names, ",") != "x=1,y=2" {
+		t.Errorf("Wrong attributes: %v", names)
+	}
+
+	// Breaking out early
+	n := 0
+	for range Descendants(a) {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("Wrong count: %d", n)
+	}
+}
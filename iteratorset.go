@@ -0,0 +1,44 @@
+//go:build go1.24
+
+package dom
+
+import "weak"
+
+// iteratorSet keeps track of the node iterators of a document that
+// are not detached. The iterators are referenced weakly, so the
+// iterators that are dropped without calling Detach are garbage
+// collected and removed from the set.
+type iteratorSet struct {
+	iterators map[weak.Pointer[BasicNodeIterator]]struct{}
+	// The set is pruned when it grows beyond limit
+	limit int
+}
+
+func (s *iteratorSet) add(it *BasicNodeIterator) {
+	if s.iterators == nil {
+		s.iterators = make(map[weak.Pointer[BasicNodeIterator]]struct{})
+	}
+	if len(s.iterators) >= s.limit {
+		s.each(func(*BasicNodeIterator) {})
+		s.limit = 2*len(s.iterators) + 16
+	}
+	s.iterators[weak.Make(it)] = struct{}{}
+}
+
+func (s *iteratorSet) remove(it *BasicNodeIterator) {
+	delete(s.iterators, weak.Make(it))
+}
+
+func (s *iteratorSet) len() int { return len(s.iterators) }
+
+// each calls f for the live iterators, and removes the collected
+// ones
+func (s *iteratorSet) each(f func(*BasicNodeIterator)) {
+	for p := range s.iterators {
+		if it := p.Value(); it != nil {
+			f(it)
+		} else {
+			delete(s.iterators, p)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !go1.24
+
+package dom
+
+// iteratorSet keeps track of the node iterators of a document that
+// are not detached. Weak references need Go 1.24, so with older
+// versions the iterators stay in the set until they are detached.
+type iteratorSet struct {
+	iterators map[*BasicNodeIterator]struct{}
+}
+
+func (s *iteratorSet) add(it *BasicNodeIterator) {
+	if s.iterators == nil {
+		s.iterators = make(map[*BasicNodeIterator]struct{})
+	}
+	s.iterators[it] = struct{}{}
+}
+
+func (s *iteratorSet) remove(it *BasicNodeIterator) {
+	delete(s.iterators, it)
+}
+
+func (s *iteratorSet) len() int { return len(s.iterators) }
+
+func (s *iteratorSet) each(f func(*BasicNodeIterator)) {
+	for it := range s.iterators {
+		f(it)
+	}
+}
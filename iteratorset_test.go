@@ -0,0 +1,33 @@
+//go:build go1.24
+
+package dom
+
+import (
+	"runtime"
+	"testing"
+)
+
+//go:noinline
+func createIterators(doc Document, n int) {
+	for i := 0; i < n; i++ {
+		it := doc.CreateNodeIterator(doc, SHOW_ALL, nil)
+		it.NextNode()
+	}
+}
+
+func TestNodeIteratorNotDetached(t *testing.T) {
+	doc := traversalTestDoc(t)
+	createIterators(doc, 100)
+	kept := doc.CreateNodeIterator(doc, SHOW_ELEMENT, nil)
+	kept.NextNode()
+	runtime.GC()
+	el := doc.GetDocumentElement()
+	el.RemoveChild(el.GetFirstChild())
+	if n := doc.(*BasicDocument).iterators.len(); n != 1 {
+		t.Errorf("Iterators are not freed: %d", n)
+	}
+	if n := kept.NextNode(); n == nil || n.GetNodeName() != "e" {
+		t.Errorf("Wrong next node: %v", n)
+	}
+	runtime.KeepAlive(kept)
+}
@@ -0,0 +1,484 @@
+package dom
+
+import (
+	"fmt"
+)
+
+// This file implements the ChildNode and ParentNode mixins of the
+// DOM specification. The methods accept Node and string arguments.
+// Strings are converted to Text nodes.
+
+// convertNodes converts a list of nodes and strings into a single
+// node. If there are multiple nodes, they are collected in a
+// DocumentFragment. The returned function moves the nodes back to
+// where they were, and it is called if the node cannot be inserted.
+func convertNodes(doc Document, nodes []interface{}, op string) (Node, func(), error) {
+	// Check the argument types first, so nodes are not moved on error
+	for _, x := range nodes {
+		switch x.(type) {
+		case Node, string:
+		default:
+			return nil, nil, ErrDOM{
+				Typ: TYPE_MISMATCH_ERR,
+				Msg: fmt.Sprintf("Expecting Node or string, got %T", x),
+				Op:  op,
+			}
+		}
+	}
+	convert := func(x interface{}) Node {
+		if s, ok := x.(string); ok {
+			return doc.CreateTextNode(s)
+		}
+		return x.(Node)
+	}
+	if len(nodes) == 1 {
+		return convert(nodes[0]), func() {}, nil
+	}
+	frag := doc.CreateDocumentFragment()
+	restore := savePositions(frag, nodes)
+	for _, x := range nodes {
+		if _, err := frag.AppendChildE(convert(x)); err != nil {
+			restore()
+			return nil, nil, err
+		}
+	}
+	return frag, restore, nil
+}
+
+// nodePosition is the position of a node in its parent
+type nodePosition struct {
+	node, parent, next Node
+}
+
+// savePositions records the positions of nodes, and the children of
+// fragments in nodes. The returned function removes the nodes from
+// frag, and moves them back to their positions.
+func savePositions(frag Node, nodes []interface{}) func() {
+	positions := make([]nodePosition, 0, len(nodes))
+	seen := make(map[Node]bool)
+	save := func(n Node) {
+		if !seen[n] && n.GetParentNode() != nil {
+			seen[n] = true
+			positions = append(positions, nodePosition{node: n, parent: n.GetParentNode(), next: n.GetNextSibling()})
+		}
+	}
+	for _, x := range nodes {
+		n, ok := x.(Node)
+		if !ok {
+			continue
+		}
+		save(n)
+		if n.GetNodeType() == DOCUMENT_FRAGMENT_NODE {
+			for ch := n.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+				save(ch)
+			}
+		}
+	}
+	return func() {
+		for ch := frag.GetFirstChild(); ch != nil; ch = frag.GetFirstChild() {
+			detachChild(frag, ch)
+		}
+		// A node is inserted after its next sibling is back in place
+		for len(positions) > 0 {
+			rest := positions[:0]
+			for _, p := range positions {
+				if p.node.GetParentNode() != nil {
+					continue
+				}
+				if p.next != nil && p.next.GetParentNode() != p.parent {
+					rest = append(rest, p)
+					continue
+				}
+				insertChildBefore(p.parent, p.node, p.next)
+			}
+			if len(rest) == len(positions) {
+				return
+			}
+			positions = rest
+		}
+	}
+}
+
+func containsNode(nodes []interface{}, node Node) bool {
+	for _, x := range nodes {
+		if n, ok := x.(Node); ok && n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// must panics if err is not nil
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Inserts nodes before node in the children list of its parent
+func childBefore(node Node, nodes []interface{}) error {
+	parent := node.GetParentNode()
+	if parent == nil || len(nodes) == 0 {
+		return nil
+	}
+	defer beginMutation(parent)()
+	viablePrev := node.GetPreviousSibling()
+	for viablePrev != nil && containsNode(nodes, viablePrev) {
+		viablePrev = viablePrev.GetPreviousSibling()
+	}
+	newNode, restore, err := convertNodes(node.GetOwnerDocument(), nodes, "Before")
+	if err != nil {
+		return err
+	}
+	reference := parent.GetFirstChild()
+	if viablePrev != nil {
+		reference = viablePrev.GetNextSibling()
+	}
+	if _, err = insertBeforeE(parent, newNode, reference, "Before"); err != nil {
+		restore()
+	}
+	return err
+}
+
+// Inserts nodes after node in the children list of its parent
+func childAfter(node Node, nodes []interface{}) error {
+	parent := node.GetParentNode()
+	if parent == nil || len(nodes) == 0 {
+		return nil
+	}
+	defer beginMutation(parent)()
+	viableNext := node.GetNextSibling()
+	for viableNext != nil && containsNode(nodes, viableNext) {
+		viableNext = viableNext.GetNextSibling()
+	}
+	newNode, restore, err := convertNodes(node.GetOwnerDocument(), nodes, "After")
+	if err != nil {
+		return err
+	}
+	if _, err = insertBeforeE(parent, newNode, viableNext, "After"); err != nil {
+		restore()
+	}
+	return err
+}
+
+// Replaces node in the children list of its parent with nodes
+func childReplaceWith(node Node, nodes []interface{}) error {
+	parent := node.GetParentNode()
+	if parent == nil {
+		return nil
+	}
+	defer beginMutation(parent)()
+	viableNext := node.GetNextSibling()
+	for viableNext != nil && containsNode(nodes, viableNext) {
+		viableNext = viableNext.GetNextSibling()
+	}
+	if len(nodes) == 0 {
+		detachChild(parent, node)
+		return nil
+	}
+	newNode, restore, err := convertNodes(node.GetOwnerDocument(), nodes, "ReplaceWith")
+	if err != nil {
+		return err
+	}
+	if node.GetParentNode() == parent {
+		if err := validateReplacement(newNode, parent, node, "ReplaceWith"); err != nil {
+			restore()
+			return err
+		}
+		replaceChild(parent, newNode, node)
+		return nil
+	}
+	if _, err = insertBeforeE(parent, newNode, viableNext, "ReplaceWith"); err != nil {
+		restore()
+	}
+	return err
+}
+
+// Removes node from the children list of its parent
+func childRemove(node Node) {
+	parent := node.GetParentNode()
+	if parent == nil {
+		return
+	}
+	defer beginMutation(parent)()
+	detachChild(parent, node)
+}
+
+// Inserts nodes before the first child of parent
+func parentPrepend(parent Node, nodes []interface{}) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	defer beginMutation(parent)()
+	newNode, restore, err := convertNodes(ownerOf(parent), nodes, "Prepend")
+	if err != nil {
+		return err
+	}
+	if _, err = insertBeforeE(parent, newNode, parent.GetFirstChild(), "Prepend"); err != nil {
+		restore()
+	}
+	return err
+}
+
+// Inserts nodes after the last child of parent
+func parentAppend(parent Node, nodes []interface{}) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	defer beginMutation(parent)()
+	newNode, restore, err := convertNodes(ownerOf(parent), nodes, "Append")
+	if err != nil {
+		return err
+	}
+	if _, err = insertBeforeE(parent, newNode, nil, "Append"); err != nil {
+		restore()
+	}
+	return err
+}
+
+// Replaces all children of parent with nodes
+func parentReplaceChildren(parent Node, nodes []interface{}) error {
+	defer beginMutation(parent)()
+	var newNode Node
+	if len(nodes) > 0 {
+		var restore func()
+		var err error
+		newNode, restore, err = convertNodes(ownerOf(parent), nodes, "ReplaceChildren")
+		if err != nil {
+			return err
+		}
+		if err := validatePreInsertion(newNode, parent, nil, "ReplaceChildren"); err != nil {
+			restore()
+			return err
+		}
+	}
+	for child := parent.GetFirstChild(); child != nil; child = parent.GetFirstChild() {
+		detachChild(parent, child)
+	}
+	if newNode != nil {
+		insertBefore(parent, newNode, nil)
+	}
+	return nil
+}
+
+// ownerOf returns the document of node. For documents, this is the
+// document itself.
+func ownerOf(node Node) Document {
+	if doc, ok := node.(Document); ok {
+		return doc
+	}
+	return node.GetOwnerDocument()
+}
+
+// Inserts nodes or strings before this element.
+func (el *BasicElement) Before(nodes ...interface{}) { must(childBefore(el, nodes)) }
+
+// Inserts nodes or strings before this element. Returns an error if the
+// insertion is not valid.
+func (el *BasicElement) BeforeE(nodes ...interface{}) error { return childBefore(el, nodes) }
+
+// Inserts nodes or strings after this element.
+func (el *BasicElement) After(nodes ...interface{}) { must(childAfter(el, nodes)) }
+
+// Inserts nodes or strings after this element. Returns an error if the
+// insertion is not valid.
+func (el *BasicElement) AfterE(nodes ...interface{}) error { return childAfter(el, nodes) }
+
+// Replaces this element with nodes or strings.
+func (el *BasicElement) ReplaceWith(nodes ...interface{}) { must(childReplaceWith(el, nodes)) }
+
+// Replaces this element with nodes or strings. Returns an error if the
+// replacement is not valid.
+func (el *BasicElement) ReplaceWithE(nodes ...interface{}) error {
+	return childReplaceWith(el, nodes)
+}
+
+// Inserts nodes or strings before the first child of this element.
+func (el *BasicElement) Prepend(nodes ...interface{}) { must(parentPrepend(el, nodes)) }
+
+// Inserts nodes or strings before the first child of this element.
+// Returns an error if the insertion is not valid.
+func (el *BasicElement) PrependE(nodes ...interface{}) error { return parentPrepend(el, nodes) }
+
+// Inserts nodes or strings after the last child of this element.
+func (el *BasicElement) Append(nodes ...interface{}) { must(parentAppend(el, nodes)) }
+
+// Inserts nodes or strings after the last child of this element.
+// Returns an error if the insertion is not valid.
+func (el *BasicElement) AppendE(nodes ...interface{}) error { return parentAppend(el, nodes) }
+
+// Replaces the children of this element with nodes or strings.
+func (el *BasicElement) ReplaceChildren(nodes ...interface{}) {
+	must(parentReplaceChildren(el, nodes))
+}
+
+// Replaces the children of this element with nodes or strings. Returns
+// an error if the replacement is not valid.
+func (el *BasicElement) ReplaceChildrenE(nodes ...interface{}) error {
+	return parentReplaceChildren(el, nodes)
+}
+
+// Removes this element from the children list of its parent.
+func (el *BasicElement) Remove() { childRemove(el) }
+
+// Inserts nodes or strings before this node.
+func (cd *BasicText) Before(nodes ...interface{}) { must(childBefore(cd, nodes)) }
+
+// Inserts nodes or strings before this node. Returns an error if the
+// insertion is not valid.
+func (cd *BasicText) BeforeE(nodes ...interface{}) error { return childBefore(cd, nodes) }
+
+// Inserts nodes or strings after this node.
+func (cd *BasicText) After(nodes ...interface{}) { must(childAfter(cd, nodes)) }
+
+// Inserts nodes or strings after this node. Returns an error if the
+// insertion is not valid.
+func (cd *BasicText) AfterE(nodes ...interface{}) error { return childAfter(cd, nodes) }
+
+// Replaces this node with nodes or strings.
+func (cd *BasicText) ReplaceWith(nodes ...interface{}) { must(childReplaceWith(cd, nodes)) }
+
+// Replaces this node with nodes or strings. Returns an error if the
+// replacement is not valid.
+func (cd *BasicText) ReplaceWithE(nodes ...interface{}) error {
+	return childReplaceWith(cd, nodes)
+}
+
+// Removes this node from the children list of its parent.
+func (cd *BasicText) Remove() { childRemove(cd) }
+
+// Inserts nodes or strings before this node.
+func (cd *BasicCDATASection) Before(nodes ...interface{}) { must(childBefore(cd, nodes)) }
+
+// Inserts nodes or strings before this node. Returns an error if the
+// insertion is not valid.
+func (cd *BasicCDATASection) BeforeE(nodes ...interface{}) error { return childBefore(cd, nodes) }
+
+// Inserts nodes or strings after this node.
+func (cd *BasicCDATASection) After(nodes ...interface{}) { must(childAfter(cd, nodes)) }
+
+// Inserts nodes or strings after this node. Returns an error if the
+// insertion is not valid.
+func (cd *BasicCDATASection) AfterE(nodes ...interface{}) error { return childAfter(cd, nodes) }
+
+// Replaces this node with nodes or strings.
+func (cd *BasicCDATASection) ReplaceWith(nodes ...interface{}) { must(childReplaceWith(cd, nodes)) }
+
+// Replaces this node with nodes or strings. Returns an error if the
+// replacement is not valid.
+func (cd *BasicCDATASection) ReplaceWithE(nodes ...interface{}) error {
+	return childReplaceWith(cd, nodes)
+}
+
+// Removes this node from the children list of its parent.
+func (cd *BasicCDATASection) Remove() { childRemove(cd) }
+
+// Inserts nodes or strings before this node.
+func (cd *BasicComment) Before(nodes ...interface{}) { must(childBefore(cd, nodes)) }
+
+// Inserts nodes or strings before this node. Returns an error if the
+// insertion is not valid.
+func (cd *BasicComment) BeforeE(nodes ...interface{}) error { return childBefore(cd, nodes) }
+
+// Inserts nodes or strings after this node.
+func (cd *BasicComment) After(nodes ...interface{}) { must(childAfter(cd, nodes)) }
+
+// Inserts nodes or strings after this node. Returns an error if the
+// insertion is not valid.
+func (cd *BasicComment) AfterE(nodes ...interface{}) error { return childAfter(cd, nodes) }
+
+// Replaces this node with nodes or strings.
+func (cd *BasicComment) ReplaceWith(nodes ...interface{}) { must(childReplaceWith(cd, nodes)) }
+
+// Replaces this node with nodes or strings. Returns an error if the
+// replacement is not valid.
+func (cd *BasicComment) ReplaceWithE(nodes ...interface{}) error {
+	return childReplaceWith(cd, nodes)
+}
+
+// Removes this node from the children list of its parent.
+func (cd *BasicComment) Remove() { childRemove(cd) }
+
+// Inserts nodes or strings before this node.
+func (p *BasicProcessingInstruction) Before(nodes ...interface{}) { must(childBefore(p, nodes)) }
+
+// Inserts nodes or strings before this node. Returns an error if the
+// insertion is not valid.
+func (p *BasicProcessingInstruction) BeforeE(nodes ...interface{}) error {
+	return childBefore(p, nodes)
+}
+
+// Inserts nodes or strings after this node.
+func (p *BasicProcessingInstruction) After(nodes ...interface{}) { must(childAfter(p, nodes)) }
+
+// Inserts nodes or strings after this node. Returns an error if the
+// insertion is not valid.
+func (p *BasicProcessingInstruction) AfterE(nodes ...interface{}) error { return childAfter(p, nodes) }
+
+// Replaces this node with nodes or strings.
+func (p *BasicProcessingInstruction) ReplaceWith(nodes ...interface{}) {
+	must(childReplaceWith(p, nodes))
+}
+
+// Replaces this node with nodes or strings. Returns an error if the
+// replacement is not valid.
+func (p *BasicProcessingInstruction) ReplaceWithE(nodes ...interface{}) error {
+	return childReplaceWith(p, nodes)
+}
+
+// Removes this node from the children list of its parent.
+func (p *BasicProcessingInstruction) Remove() { childRemove(p) }
+
+// Inserts nodes or strings before the first child of the document.
+func (doc *BasicDocument) Prepend(nodes ...interface{}) { must(parentPrepend(doc, nodes)) }
+
+// Inserts nodes or strings before the first child of the document.
+// Returns an error if the insertion is not valid.
+func (doc *BasicDocument) PrependE(nodes ...interface{}) error { return parentPrepend(doc, nodes) }
+
+// Inserts nodes or strings after the last child of the document.
+func (doc *BasicDocument) Append(nodes ...interface{}) { must(parentAppend(doc, nodes)) }
+
+// Inserts nodes or strings after the last child of the document.
+// Returns an error if the insertion is not valid.
+func (doc *BasicDocument) AppendE(nodes ...interface{}) error { return parentAppend(doc, nodes) }
+
+// Replaces the children of the document with nodes or strings.
+func (doc *BasicDocument) ReplaceChildren(nodes ...interface{}) {
+	must(parentReplaceChildren(doc, nodes))
+}
+
+// Replaces the children of the document with nodes or strings. Returns
+// an error if the replacement is not valid.
+func (doc *BasicDocument) ReplaceChildrenE(nodes ...interface{}) error {
+	return parentReplaceChildren(doc, nodes)
+}
+
+// Inserts nodes or strings before the first child of the fragment.
+func (frag *BasicDocumentFragment) Prepend(nodes ...interface{}) { must(parentPrepend(frag, nodes)) }
+
+// Inserts nodes or strings before the first child of the fragment.
+// Returns an error if the insertion is not valid.
+func (frag *BasicDocumentFragment) PrependE(nodes ...interface{}) error {
+	return parentPrepend(frag, nodes)
+}
+
+// Inserts nodes or strings after the last child of the fragment.
+func (frag *BasicDocumentFragment) Append(nodes ...interface{}) { must(parentAppend(frag, nodes)) }
+
+// Inserts nodes or strings after the last child of the fragment.
+// Returns an error if the insertion is not valid.
+func (frag *BasicDocumentFragment) AppendE(nodes ...interface{}) error {
+	return parentAppend(frag, nodes)
+}
+
+// Replaces the children of the fragment with nodes or strings.
+func (frag *BasicDocumentFragment) ReplaceChildren(nodes ...interface{}) {
+	must(parentReplaceChildren(frag, nodes))
+}
+
+// Replaces the children of the fragment with nodes or strings. Returns
+// an error if the replacement is not valid.
+func (frag *BasicDocumentFragment) ReplaceChildrenE(nodes ...interface{}) error {
+	return parentReplaceChildren(frag, nodes)
+}
@@ -0,0 +1,193 @@
+package dom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func encodeString(t *testing.T, node Node) string {
+	buf := bytes.Buffer{}
+	if err := Encode(node, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestReplaceChild(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<root><a/><b/><c/></root>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	a := root.GetFirstChild()
+	b := a.GetNextSibling()
+	c := b.GetNextSibling()
+
+	x := doc.CreateElement("x")
+	if ret := root.ReplaceChild(x, b); ret != b {
+		t.Errorf("Wrong return value")
+	}
+	if b.GetParentNode() != nil {
+		t.Errorf("Replaced node still has parent")
+	}
+	if s := encodeString(t, doc); s != "<root><a></a><x></x><c></c></root>" {
+		t.Errorf("Wrong result: %s", s)
+	}
+	// Replace with a sibling
+	root.ReplaceChild(c, a)
+	if s := encodeString(t, doc); s != "<root><c></c><x></x></root>" {
+		t.Errorf("Wrong result: %s", s)
+	}
+	// Replace with fragment
+	frag := doc.CreateDocumentFragment()
+	frag.AppendChild(doc.CreateElement("y"))
+	frag.AppendChild(doc.CreateTextNode("t"))
+	root.ReplaceChild(frag, c)
+	if s := encodeString(t, doc); s != "<root><y></y>t<x></x></root>" {
+		t.Errorf("Wrong result: %s", s)
+	}
+
+	expectPanic := func(name, typ string, f func()) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("%s: expected panic", name)
+			} else if e, ok := r.(ErrDOM); !ok || e.Typ != typ {
+				t.Errorf("%s: unexpected panic %v", name, r)
+			}
+		}()
+		f()
+	}
+	expectPanic("not a child", NOT_FOUND_ERR, func() { root.ReplaceChild(doc.CreateElement("z"), a) })
+	expectPanic("ancestor", HIERARCHY_REQUEST_ERR, func() { x.ReplaceChild(root, x.GetFirstChild()) })
+	expectPanic("ancestor", HIERARCHY_REQUEST_ERR, func() { x.AppendChild(root) })
+	expectPanic("text under doc", HIERARCHY_REQUEST_ERR, func() { doc.ReplaceChild(doc.CreateTextNode("x"), root) })
+	// Replacing the document element with another element is valid
+	newRoot := doc.CreateElement("newroot")
+	doc.ReplaceChild(newRoot, root)
+	if doc.GetDocumentElement() != newRoot {
+		t.Errorf("Document element not replaced")
+	}
+	expectPanic("second root", HIERARCHY_REQUEST_ERR, func() { doc.InsertBefore(doc.CreateElement("r"), newRoot) })
+	doctype := &BasicDocumentType{name: "newroot"}
+	doctype.ownerDocument = doc.(*BasicDocument)
+	expectPanic("doctype after element", HIERARCHY_REQUEST_ERR, func() { doc.AppendChild(doctype) })
+	doc.InsertBefore(doctype, newRoot)
+	if doc.GetDocumentType() != doctype {
+		t.Errorf("Doctype not inserted")
+	}
+}
+
+func TestChildNodeMixins(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<root><a/>text<b/></root>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	a := root.GetFirstElementChild()
+	b := a.GetNextElementSibling()
+	text := a.GetNextSibling().(Text)
+
+	a.Before("1", doc.CreateElement("x"))
+	if s := encodeString(t, root); s != "<root>1<x></x><a></a>text<b></b></root>" {
+		t.Errorf("Wrong result: %s", s)
+	}
+	// b is moved after a
+	a.After(b, "2")
+	if s := encodeString(t, root); s != "<root>1<x></x><a></a><b></b>2text</root>" {
+		t.Errorf("Wrong result: %s", s)
+	}
+	text.ReplaceWith(doc.CreateElement("t"))
+	if s := encodeString(t, root); s != "<root>1<x></x><a></a><b></b>2<t></t></root>" {
+		t.Errorf("Wrong result: %s", s)
+	}
+	if text.GetParentNode() != nil {
+		t.Errorf("Text still in tree")
+	}
+	first := root.GetFirstChild().(Text)
+	first.Remove()
+	root.Prepend("p")
+	root.Append("q", doc.CreateComment("c"))
+	if s := encodeString(t, root); s != "<root>p<x></x><a></a><b></b>2<t></t>q<!--c--></root>" {
+		t.Errorf("Wrong result: %s", s)
+	}
+	a.ReplaceChildren("inner", doc.CreateElement("i"))
+	root.ReplaceChildren(a)
+	if s := encodeString(t, root); s != "<root><a>inner<i></i></a></root>" {
+		t.Errorf("Wrong result: %s", s)
+	}
+	a.ReplaceWith()
+	if root.HasChildNodes() {
+		t.Errorf("ReplaceWith without arguments should remove")
+	}
+	doc.Prepend(doc.CreateComment("start"))
+	doc.Append(doc.CreateComment("end"))
+	if s := encodeString(t, doc); s != "<!--start--><root></root><!--end-->" {
+		t.Errorf("Wrong result: %s", s)
+	}
+	end := doc.GetLastChild().(Comment)
+	end.Before(doc.CreateProcessingInstruction("pi", "x"))
+	end.Remove()
+	// Removing a detached node does nothing
+	end.Remove()
+	doc.GetLastChild().(ProcessingInstruction).ReplaceWith(doc.CreateComment("pi"))
+	if s := encodeString(t, doc); s != "<!--start--><root></root><!--pi-->" {
+		t.Errorf("Wrong result: %s", s)
+	}
+}
+
+func TestMixinErrors(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<root><a>text</a><b/></root>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	a := root.GetFirstElementChild()
+	b := a.GetNextElementSibling()
+	text := a.GetFirstChild().(Text)
+	other := NewDocument()
+
+	expectError := func(name, typ string, err error) {
+		e, ok := err.(ErrDOM)
+		if !ok || e.Typ != typ {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+	expectError("not a node", TYPE_MISMATCH_ERR, root.AppendE(b, 1))
+	expectError("wrong document", WRONG_DOCUMENT_ERR, b.BeforeE(other.CreateElement("x")))
+	expectError("ancestor", HIERARCHY_REQUEST_ERR, text.AfterE(root))
+	expectError("ancestor", HIERARCHY_REQUEST_ERR, b.ReplaceWithE(root))
+	expectError("second root", HIERARCHY_REQUEST_ERR, doc.AppendE(doc.CreateElement("x")))
+	expectError("text under document", HIERARCHY_REQUEST_ERR, doc.PrependE("x"))
+	expectError("text under document", HIERARCHY_REQUEST_ERR, doc.ReplaceChildrenE("x"))
+	if s := encodeString(t, doc); s != `<root><a>text</a><b></b></root>` {
+		t.Errorf("Document changed: %s", s)
+	}
+
+	// Multiple arguments are collected before they are inserted, and
+	// they are moved back if the insertion fails
+	expectError("ancestor", HIERARCHY_REQUEST_ERR, a.AppendE(root, "x"))
+	expectError("ancestor", HIERARCHY_REQUEST_ERR, text.BeforeE(b, root))
+	expectError("ancestor", HIERARCHY_REQUEST_ERR, text.ReplaceWithE(b, "y", root, text))
+	frag := doc.CreateDocumentFragment()
+	frag.AppendChild(doc.CreateElement("f1"))
+	frag.AppendChild(doc.CreateElement("f2"))
+	expectError("second root", HIERARCHY_REQUEST_ERR, doc.AppendE(frag, b))
+	if s := encodeString(t, doc); s != `<root><a>text</a><b></b></root>` {
+		t.Errorf("Document changed: %s", s)
+	}
+	if s := encodeString(t, frag); s != `<f1></f1><f2></f2>` {
+		t.Errorf("Fragment changed: %s", s)
+	}
+
+	if err := text.ReplaceWithE("new", doc.CreateElement("x")); err != nil {
+		t.Error(err)
+	}
+	if err := b.AfterE("after"); err != nil {
+		t.Error(err)
+	}
+	if s := encodeString(t, doc); s != `<root><a>new<x></x></a><b></b>after</root>` {
+		t.Errorf("Wrong result: %s", s)
+	}
+}
@@ -0,0 +1,356 @@
+package dom
+
+// MutationType is the type of a MutationRecord
+type MutationType string
+
+const (
+	// Children of the target are added or removed
+	MutationChildList MutationType = "childList"
+	// An attribute of the target is added, removed, or changed
+	MutationAttributes MutationType = "attributes"
+	// The value of a character data node is changed
+	MutationCharacterData MutationType = "characterData"
+)
+
+// MutationRecord describes a single change to the document
+type MutationRecord struct {
+	Type MutationType
+
+	// The node whose children changed for MutationChildList, the
+	// element for MutationAttributes, and the character data node
+	// for MutationCharacterData
+	Target Node
+
+	// The nodes added to, or removed from the target
+	AddedNodes   []Node
+	RemovedNodes []Node
+
+	// The siblings of the added or removed nodes
+	PreviousSibling Node
+	NextSibling     Node
+
+	// The local name and namespace of the changed attribute
+	AttributeName      string
+	AttributeNamespace string
+
+	// The value of the attribute or character data before the
+	// change, if requested by the observer. It is "" if the
+	// attribute did not exist.
+	OldValue string
+}
+
+// MutationObserverInit selects the changes reported to a
+// MutationObserver
+type MutationObserverInit struct {
+	// Report the addition and removal of children
+	ChildList bool
+	// Report attribute changes
+	Attributes bool
+	// Report character data changes
+	CharacterData bool
+	// Report changes to the descendants of the target as well. A
+	// node removed from the subtree stays observed until the records
+	// are next delivered to the callback, or taken with TakeRecords
+	// if there is no callback, so the changes made to it right after
+	// its removal are reported.
+	Subtree bool
+	// Record the old value of attributes. Implies Attributes.
+	AttributeOldValue bool
+	// Record the old value of character data. Implies CharacterData.
+	CharacterDataOldValue bool
+	// If not nil, only the changes to the non-namespaced attributes
+	// with these local names are reported. Implies Attributes.
+	AttributeFilter []string
+}
+
+// MutationCallback receives the records queued for a
+// MutationObserver
+type MutationCallback func(records []MutationRecord, observer MutationObserver)
+
+// MutationObserver reports the changes in the observed nodes
+type MutationObserver interface {
+	// Starts observing target. If target is already observed by this
+	// observer, its options are replaced.
+	Observe(target Node, options MutationObserverInit) error
+
+	// Stops observing all nodes and discards the queued records
+	Disconnect()
+
+	// Returns and clears the queued records
+	TakeRecords() []MutationRecord
+}
+
+type mutationRegistration struct {
+	node    Node
+	options MutationObserverInit
+	// Transient registrations observe the nodes removed from an
+	// observed subtree until the next delivery
+	transient bool
+}
+
+// BasicMutationObserver implements MutationObserver
+//
+// Records are queued when the document changes. If the observer has
+// a callback, the queued records are passed to it when the outermost
+// DOM method that changed the document returns, so the callback never
+// runs in the middle of an operation. Changes made by a callback are
+// delivered after the callback returns. Without a callback, the
+// records are collected until TakeRecords is called.
+type BasicMutationObserver struct {
+	doc           *BasicDocument
+	callback      MutationCallback
+	registrations []mutationRegistration
+	records       []MutationRecord
+}
+
+var _ MutationObserver = &BasicMutationObserver{}
+
+func newMutationObserver(doc *BasicDocument, callback MutationCallback) *BasicMutationObserver {
+	return &BasicMutationObserver{
+		doc:      doc,
+		callback: callback,
+	}
+}
+
+// Starts observing target. If target is already observed by this
+// observer, its options are replaced.
+func (o *BasicMutationObserver) Observe(target Node, options MutationObserverInit) error {
+	if options.AttributeOldValue || options.AttributeFilter != nil {
+		options.Attributes = true
+	}
+	if options.CharacterDataOldValue {
+		options.CharacterData = true
+	}
+	if !options.ChildList && !options.Attributes && !options.CharacterData {
+		return ErrDOM{
+			Typ: TYPE_MISMATCH_ERR,
+			Msg: "One of ChildList, Attributes, or CharacterData must be set",
+			Op:  "Observe",
+		}
+	}
+	if target.GetOwnerDocument() != Document(o.doc) {
+		return ErrDOM{
+			Typ: WRONG_DOCUMENT_ERR,
+			Msg: "Target does not belong to the document of the observer",
+			Op:  "Observe",
+		}
+	}
+	for i := range o.registrations {
+		if !o.registrations[i].transient && o.registrations[i].node == target {
+			o.registrations[i].options = options
+			return nil
+		}
+	}
+	if len(o.registrations) == 0 {
+		o.doc.observers = append(o.doc.observers, o)
+	}
+	o.registrations = append(o.registrations, mutationRegistration{node: target, options: options})
+	return nil
+}
+
+// Stops observing all nodes and discards the queued records
+func (o *BasicMutationObserver) Disconnect() {
+	o.registrations = nil
+	o.records = nil
+	w := 0
+	for _, x := range o.doc.observers {
+		if x != o {
+			o.doc.observers[w] = x
+			w++
+		}
+	}
+	o.doc.observers = o.doc.observers[:w]
+}
+
+// Returns and clears the queued records
+func (o *BasicMutationObserver) TakeRecords() []MutationRecord {
+	o.removeTransientRegistrations()
+	ret := o.records
+	o.records = nil
+	return ret
+}
+
+// addTransientRegistrations adds a transient registration for
+// removed for each registration observing the subtree parent was in
+func (o *BasicMutationObserver) addTransientRegistrations(parent, removed Node) {
+	for _, reg := range o.registrations {
+		if reg.options.Subtree && (reg.node == parent || reg.node.Contains(parent)) {
+			o.registrations = append(o.registrations, mutationRegistration{node: removed, options: reg.options, transient: true})
+		}
+	}
+}
+
+func (o *BasicMutationObserver) removeTransientRegistrations() {
+	w := 0
+	for _, reg := range o.registrations {
+		if !reg.transient {
+			o.registrations[w] = reg
+			w++
+		}
+	}
+	o.registrations = o.registrations[:w]
+}
+
+// queue adds rec to the records of the observer if one of the
+// registrations is interested in it
+func (o *BasicMutationObserver) queue(rec MutationRecord) {
+	interested := false
+	oldValue := false
+	for _, reg := range o.registrations {
+		if !reg.matches(rec) {
+			continue
+		}
+		interested = true
+		switch rec.Type {
+		case MutationAttributes:
+			oldValue = oldValue || reg.options.AttributeOldValue
+		case MutationCharacterData:
+			oldValue = oldValue || reg.options.CharacterDataOldValue
+		}
+	}
+	if !interested {
+		return
+	}
+	if !oldValue {
+		rec.OldValue = ""
+	}
+	o.records = append(o.records, rec)
+}
+
+// matches returns true if rec is a change that is selected by the
+// registration
+func (reg mutationRegistration) matches(rec MutationRecord) bool {
+	if rec.Target != reg.node {
+		if !reg.options.Subtree || !reg.node.Contains(rec.Target) {
+			return false
+		}
+	}
+	switch rec.Type {
+	case MutationChildList:
+		return reg.options.ChildList
+	case MutationCharacterData:
+		return reg.options.CharacterData
+	case MutationAttributes:
+		if !reg.options.Attributes {
+			return false
+		}
+		if reg.options.AttributeFilter == nil {
+			return true
+		}
+		if len(rec.AttributeNamespace) > 0 {
+			return false
+		}
+		for _, name := range reg.options.AttributeFilter {
+			if name == rec.AttributeName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// queueMutation queues rec for the interested observers of the
+// document of node. The records are delivered when the outermost
+// mutation of the document ends.
+func queueMutation(node Node, rec MutationRecord) {
+	doc, _ := node.GetOwnerDocument().(*BasicDocument)
+	if doc == nil {
+		return
+	}
+	for _, o := range doc.observers {
+		o.queue(rec)
+	}
+}
+
+// beginMutation marks the start of a DOM method that changes the
+// document of node, and returns the function that marks its end.
+// Mutation records are delivered when the outermost method ends:
+//
+//	defer beginMutation(node)()
+func beginMutation(node Node) func() {
+	doc, _ := ownerOf(node).(*BasicDocument)
+	if doc == nil {
+		return func() {}
+	}
+	doc.mutating++
+	return doc.endMutation
+}
+
+func (doc *BasicDocument) endMutation() {
+	doc.mutating--
+	if doc.mutating == 0 {
+		doc.deliverMutations()
+	}
+}
+
+// deliverMutations passes the queued records to the observer
+// callbacks until there are no more records. Records queued while a
+// callback is running are delivered by the outermost call.
+func (doc *BasicDocument) deliverMutations() {
+	if doc.delivering || len(doc.observers) == 0 {
+		return
+	}
+	doc.delivering = true
+	defer func() { doc.delivering = false }()
+	for {
+		delivered := false
+		observers := append([]*BasicMutationObserver(nil), doc.observers...)
+		for _, o := range observers {
+			if o.callback == nil {
+				continue
+			}
+			o.removeTransientRegistrations()
+			if len(o.records) == 0 {
+				continue
+			}
+			o.callback(o.TakeRecords(), o)
+			delivered = true
+		}
+		if !delivered {
+			return
+		}
+	}
+}
+
+func childListMutated(parent Node, added, removed, prev, next Node) {
+	rec := MutationRecord{
+		Type:            MutationChildList,
+		Target:          parent,
+		PreviousSibling: prev,
+		NextSibling:     next,
+	}
+	if added != nil {
+		rec.AddedNodes = []Node{added}
+	}
+	child := added
+	if removed != nil {
+		rec.RemovedNodes = []Node{removed}
+		child = removed
+	}
+	queueMutation(child, rec)
+	if removed != nil {
+		if doc, _ := removed.GetOwnerDocument().(*BasicDocument); doc != nil {
+			for _, o := range doc.observers {
+				o.addTransientRegistrations(parent, removed)
+			}
+		}
+	}
+}
+
+func attributeMutated(el *BasicElement, name Name, oldValue string) {
+	queueMutation(el, MutationRecord{
+		Type:               MutationAttributes,
+		Target:             el,
+		AttributeName:      name.Local,
+		AttributeNamespace: name.Space,
+		OldValue:           oldValue,
+	})
+}
+
+func characterDataMutated(node Node, oldValue string) {
+	queueMutation(node, MutationRecord{
+		Type:     MutationCharacterData,
+		Target:   node,
+		OldValue: oldValue,
+	})
+}
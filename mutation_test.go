@@ -0,0 +1,214 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestMutationObserver(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<a><b x="1">text</b><c/></a>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := doc.GetDocumentElement()
+	b := a.GetFirstChild().(Element)
+	c := b.GetNextSibling()
+	text := b.GetFirstChild().(Text)
+
+	records := make([]MutationRecord, 0)
+	observer := doc.CreateMutationObserver(func(r []MutationRecord, _ MutationObserver) {
+		records = append(records, r...)
+	})
+	if err := observer.Observe(a, MutationObserverInit{}); err == nil {
+		t.Errorf("Expecting error for empty options")
+	}
+	if err := observer.Observe(a, MutationObserverInit{ChildList: true, Subtree: true, AttributeOldValue: true, CharacterDataOldValue: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := doc.CreateElement("d")
+	a.InsertBefore(d, c)
+	if len(records) != 1 || records[0].Type != MutationChildList || records[0].Target != a ||
+		len(records[0].AddedNodes) != 1 || records[0].AddedNodes[0] != d ||
+		records[0].PreviousSibling != b || records[0].NextSibling != c {
+		t.Errorf("Wrong insert record: %+v", records)
+	}
+
+	records = records[:0]
+	a.RemoveChild(d)
+	if len(records) != 1 || len(records[0].RemovedNodes) != 1 || records[0].RemovedNodes[0] != d ||
+		records[0].PreviousSibling != b || records[0].NextSibling != c {
+		t.Errorf("Wrong remove record: %+v", records)
+	}
+
+	records = records[:0]
+	b.SetAttribute("x", "2")
+	b.SetAttribute("y", "3")
+	b.RemoveAttribute("x")
+	if len(records) != 3 {
+		t.Fatalf("Wrong attribute records: %+v", records)
+	}
+	for i, old := range []string{"1", "", "2"} {
+		if records[i].Type != MutationAttributes || records[i].Target != b || records[i].OldValue != old {
+			t.Errorf("Wrong attribute record %d: %+v", i, records[i])
+		}
+	}
+	if records[1].AttributeName != "y" {
+		t.Errorf("Wrong attribute name: %s", records[1].AttributeName)
+	}
+
+	records = records[:0]
+	text.SetValue("new")
+	if len(records) != 1 || records[0].Type != MutationCharacterData || records[0].Target != text || records[0].OldValue != "text" {
+		t.Errorf("Wrong character data record: %+v", records)
+	}
+
+	// Not observing the subtree
+	if err := observer.Observe(a, MutationObserverInit{ChildList: true}); err != nil {
+		t.Fatal(err)
+	}
+	records = records[:0]
+	text.SetValue("x")
+	b.AppendChild(doc.CreateElement("e"))
+	if len(records) != 0 {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+
+	observer.Disconnect()
+	a.AppendChild(doc.CreateElement("f"))
+	if len(records) != 0 {
+		t.Errorf("Unexpected records after disconnect: %+v", records)
+	}
+}
+
+func TestMutationObserverFilter(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<a x="1" y="2"/>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := doc.GetDocumentElement()
+	observer := doc.CreateMutationObserver(nil)
+	if err := observer.Observe(a, MutationObserverInit{AttributeFilter: []string{"y"}}); err != nil {
+		t.Fatal(err)
+	}
+	a.SetAttribute("x", "3")
+	a.SetAttribute("y", "4")
+	records := observer.TakeRecords()
+	if len(records) != 1 || records[0].AttributeName != "y" || records[0].OldValue != "" {
+		t.Errorf("Wrong records: %+v", records)
+	}
+	if len(observer.TakeRecords()) != 0 {
+		t.Errorf("Records are not cleared")
+	}
+}
+
+func TestMutationObserverNested(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<a/>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := doc.GetDocumentElement()
+	calls := 0
+	n := 0
+	observer := doc.CreateMutationObserver(func(r []MutationRecord, _ MutationObserver) {
+		calls++
+		n += len(r)
+		if a.GetChildNodes().GetLength() < 3 {
+			a.AppendChild(doc.CreateElement("x"))
+		}
+	})
+	if err := observer.Observe(a, MutationObserverInit{ChildList: true}); err != nil {
+		t.Fatal(err)
+	}
+	a.AppendChild(doc.CreateElement("x"))
+	if calls != 3 || n != 3 {
+		t.Errorf("Wrong delivery: %d calls, %d records", calls, n)
+	}
+}
+
+func TestMutationObserverDeferred(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<r><a/><b/></r>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := doc.GetDocumentElement()
+	a := r.GetFirstChild()
+	b := a.GetNextSibling()
+	x := doc.CreateElement("x")
+	var records []MutationRecord
+	observer := doc.CreateMutationObserver(func(recs []MutationRecord, _ MutationObserver) {
+		// Removing the next sibling while ReplaceChild is running
+		// would invalidate its reference node
+		if b.GetParentNode() == r {
+			r.RemoveChild(b)
+		}
+		records = append(records, recs...)
+	})
+	if err := observer.Observe(r, MutationObserverInit{ChildList: true}); err != nil {
+		t.Fatal(err)
+	}
+	r.ReplaceChild(x, a)
+	if s, _ := r.GetOuterXML(); s != `<r><x></x></r>` {
+		t.Errorf("Wrong result: %s", s)
+	}
+	if len(records) != 3 {
+		t.Errorf("Wrong records: %+v", records)
+	}
+}
+
+func TestMutationObserverTransient(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<r><a><b/></a><c/></r>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := doc.GetDocumentElement()
+	a := r.GetFirstChild().(Element)
+	b := a.GetFirstChild()
+	c := a.GetNextSibling().(Element)
+
+	// Without a callback, removed nodes are observed until the records
+	// are taken
+	observer := doc.CreateMutationObserver(nil)
+	if err := observer.Observe(r, MutationObserverInit{ChildList: true, Attributes: true, Subtree: true}); err != nil {
+		t.Fatal(err)
+	}
+	r.RemoveChild(a)
+	a.SetAttribute("x", "1")
+	a.RemoveChild(b)
+	records := observer.TakeRecords()
+	if len(records) != 3 || records[1].Type != MutationAttributes || records[1].Target != a ||
+		records[2].Type != MutationChildList || records[2].Target != a || records[2].RemovedNodes[0] != b {
+		t.Errorf("Wrong records: %+v", records)
+	}
+	a.SetAttribute("y", "2")
+	if records := observer.TakeRecords(); len(records) != 0 {
+		t.Errorf("Removed node still observed: %+v", records)
+	}
+	observer.Disconnect()
+
+	// With a callback, the changes made by the same operation are
+	// reported
+	records = nil
+	observer = doc.CreateMutationObserver(func(recs []MutationRecord, _ MutationObserver) {
+		records = append(records, recs...)
+	})
+	if err := observer.Observe(r, MutationObserverInit{ChildList: true, Subtree: true}); err != nil {
+		t.Fatal(err)
+	}
+	tx := doc.Begin()
+	r.RemoveChild(c)
+	c.AppendChild(doc.CreateElement("d"))
+	tx.Commit()
+	doc.Undo()
+	records = nil
+	doc.Redo()
+	if len(records) != 2 || records[0].Target != r || records[1].Target != c {
+		t.Errorf("Wrong records: %+v", records)
+	}
+	records = nil
+	c.AppendChild(doc.CreateElement("e"))
+	if len(records) != 0 {
+		t.Errorf("Removed node still observed: %+v", records)
+	}
+}
@@ -154,6 +154,11 @@ type Node interface {
 	// Returns the object's root
 	GetRootNode() Node
 
+	// Returns the position of the node in the parsed input. The
+	// position is only recorded if the document is parsed with
+	// ParseOptions.TrackPositions.
+	GetPosition() (Position, bool)
+
 	// Accepts a namespace URI as an argument and returns a boolean value
 	// with a value of true if the namespace is the default namespace on
 	// the given node or false if not.
@@ -180,9 +185,34 @@ type Node interface {
 	// remove empty).
 	Normalize()
 
-	// // Replaces one child Node of the current one with the second one
-	// // given in parameter.
-	// ReplaceChild(newChild, oldChild Node)
+	// Returns the text content of the node. For elements and document
+	// fragments, this is the concatenation of the text of all
+	// descendant text and CDATA section nodes. For attributes and
+	// character data nodes, this is the value of the node. For
+	// documents and document types, this is "".
+	GetTextContent() string
+
+	// Sets the text content of the node. For elements and document
+	// fragments, all children are replaced by a single text node, or
+	// removed if text is "". For attributes and character data nodes,
+	// the value is set. For documents and document types, this does
+	// nothing.
+	SetTextContent(text string)
+
+	// Replaces one child Node of the current one with the second one
+	// given in parameter. Returns the replaced node. If newChild is a
+	// DocumentFragment, oldChild is replaced by all the children of
+	// the fragment.
+	ReplaceChild(newChild, oldChild Node) Node
+
+	// The methods ending with E are the same as the corresponding
+	// mutation methods, but they return the ErrDOM errors instead of
+	// panicking. Nodes that cannot have children return
+	// HIERARCHY_REQUEST errors.
+	AppendChildE(Node) (Node, error)
+	InsertBeforeE(newNode, referenceNode Node) (Node, error)
+	RemoveChildE(Node) error
+	ReplaceChildE(newChild, oldChild Node) (Node, error)
 
 	treeNode() *tnode
 	cloneNode(owner Document, deep bool) Node
@@ -44,3 +44,51 @@ func TestNodeList(t *testing.T) {
 	}
 
 }
+
+func TestMutationErrors(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<root><a>text</a><b/></root>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	a := root.GetFirstChild()
+	b := root.GetLastChild()
+	other := NewDocument()
+
+	expectError := func(name, typ string, err error) {
+		e, ok := err.(ErrDOM)
+		if !ok || e.Typ != typ {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+	_, err = root.AppendChildE(other.CreateElement("x"))
+	expectError("wrong document", WRONG_DOCUMENT_ERR, err)
+	_, err = a.AppendChildE(root)
+	expectError("ancestor", HIERARCHY_REQUEST_ERR, err)
+	_, err = a.GetFirstChild().AppendChildE(doc.CreateElement("x"))
+	expectError("text", HIERARCHY_REQUEST_ERR, err)
+	_, err = root.InsertBeforeE(doc.CreateElement("x"), a.GetFirstChild())
+	expectError("reference not a child", NOT_FOUND_ERR, err)
+	expectError("not a child", NOT_FOUND_ERR, a.RemoveChildE(b))
+	_, err = doc.ReplaceChildE(doc.CreateTextNode("x"), root)
+	expectError("text under document", HIERARCHY_REQUEST_ERR, err)
+	_, err = doc.AdoptNodeE(other)
+	expectError("adopt document", NOT_SUPPORTED_ERR, err)
+	if s := encodeString(t, doc); s != `<root><a>text</a><b></b></root>` {
+		t.Errorf("Document changed: %s", s)
+	}
+
+	x := doc.CreateElement("x")
+	if ret, err := root.InsertBeforeE(x, b); err != nil || ret != x {
+		t.Errorf("InsertBeforeE: %v %v", ret, err)
+	}
+	if ret, err := root.ReplaceChildE(doc.CreateElement("y"), a); err != nil || ret != a {
+		t.Errorf("ReplaceChildE: %v %v", ret, err)
+	}
+	if err := root.RemoveChildE(b); err != nil {
+		t.Error(err)
+	}
+	if s := encodeString(t, doc); s != `<root><y></y><x></x></root>` {
+		t.Errorf("Wrong result: %s", s)
+	}
+}
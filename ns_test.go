@@ -29,3 +29,40 @@ xmlns:t="https://test.com/t">
 		t.Errorf("Wrong root qname: %v", qn)
 	}
 }
+
+func TestLookupDefaultNamespace(t *testing.T) {
+	// The default namespace declaration of a prefixed element is not
+	// the namespace of the element
+	input := `<p:r xmlns:p="urn:p" xmlns="urn:d"><p:a/><b xmlns=""/></p:r>`
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	a := root.GetFirstElementChild()
+	b := a.GetNextElementSibling()
+	if uri := root.LookupNamespaceURI(""); uri != "urn:d" {
+		t.Errorf("Wrong default namespace: %s", uri)
+	}
+	if uri := a.LookupNamespaceURI(""); uri != "urn:d" {
+		t.Errorf("Wrong inherited default namespace: %s", uri)
+	}
+	if uri := b.LookupNamespaceURI(""); uri != "" {
+		t.Errorf("Default namespace not undeclared: %s", uri)
+	}
+	if uri := a.LookupNamespaceURI("p"); uri != "urn:p" {
+		t.Errorf("Wrong namespace for p: %s", uri)
+	}
+
+	// Declarations created with SetAttribute and SetAttributeNS
+	el := doc.CreateElementNS("q", "urn:q", "el")
+	el.SetAttribute("xmlns", "urn:x")
+	if uri := el.LookupNamespaceURI(""); uri != "urn:x" {
+		t.Errorf("Wrong default namespace: %s", uri)
+	}
+	el = doc.CreateElementNS("q", "urn:q", "el")
+	el.SetAttributeNS("", xmlnsURL, "xmlns", "urn:y")
+	if uri := el.LookupNamespaceURI(""); uri != "urn:y" {
+		t.Errorf("Wrong default namespace: %s", uri)
+	}
+}
@@ -8,226 +8,515 @@ import (
 	"unicode"
 )
 
+// ParseOptions control how a document is parsed
+type ParseOptions struct {
+	// If set, CDATA sections are kept as CDATASection nodes.
+	// Otherwise they are converted to text nodes.
+	KeepCDATASections bool
+
+	// If set, the position of every parsed node is recorded, and can
+	// be retrieved using Node.GetPosition. The position of an
+	// attribute is the position of its element.
+	TrackPositions bool
+
+	// If non-nil, Configure is called with the decoder created by
+	// ParseReader, or given to ParseWithOptions, before parsing
+	// starts. It can be used to set decoder fields such as Strict,
+	// AutoClose, and Entities.
+	Configure func(*xml.Decoder)
+}
+
+// Position is a location in the parsed input
+type Position struct {
+	// Line and Column are 1-based
+	Line   int
+	Column int
+	// Byte offset from the start of the input
+	Offset int64
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// withPosition adds the position to the parse error
+func withPosition(err error, pos Position) error {
+	switch e := err.(type) {
+	case *xml.SyntaxError:
+		if e.Line == 0 {
+			e.Line = pos.Line
+		}
+	case ErrDOM:
+		e.Msg = fmt.Sprintf("%s (%s)", e.Msg, pos)
+		return e
+	}
+	return err
+}
+
 // Parses an XML document.
 //
 // If decoder.Strict is false, the parser looks at decoder.AutoClose
 // to handle auto-closing HTML tags. Otherwise it is a strict XML
 // parser.
-func Parse(decoder *xml.Decoder) (ret Document, resultErr error) {
-	defer func() {
-		if err := recover(); err != nil {
-			if e, ok := err.(error); ok {
-				resultErr = e
-			} else {
-				resultErr = fmt.Errorf("%v", err)
-			}
+func Parse(decoder *xml.Decoder) (Document, error) {
+	return parse(decoder, ParseOptions{}, nil)
+}
+
+// ParseWithOptions parses an XML document using the decoder and the
+// given options. Configure is called with the decoder before parsing
+// starts. The decoder does not give access to the raw input, so CDATA
+// sections cannot be detected: if KeepCDATASections is set, a
+// NOT_SUPPORTED ErrDOM is returned. Use ParseReader to keep CDATA
+// sections.
+func ParseWithOptions(decoder *xml.Decoder, options ParseOptions) (Document, error) {
+	if options.KeepCDATASections {
+		return nil, ErrDOM{
+			Typ: NOT_SUPPORTED_ERR,
+			Msg: "CDATA sections can only be kept using ParseReader",
+			Op:  "ParseWithOptions",
 		}
-	}()
+	}
+	if options.Configure != nil {
+		options.Configure(decoder)
+	}
+	return parse(decoder, options, nil)
+}
+
+// ParseReader parses an XML document from r using the given
+// options. The xml.Decoder does not distinguish CDATA sections from
+// text, so ParseReader keeps track of the raw input to detect them.
+func ParseReader(r io.Reader, options ParseOptions) (Document, error) {
+	raw := &rawInput{r: r}
+	decoder := xml.NewDecoder(raw)
+	if options.Configure != nil {
+		options.Configure(decoder)
+	}
+	return parse(decoder, options, raw)
+}
+
+// rawInput keeps the part of the input that is read by the decoder
+// but not yet processed by the parser
+type rawInput struct {
+	r   io.Reader
+	buf []byte
+	// Input offset of buf[0]
+	base int64
+}
+
+func (r *rawInput) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+// hasPrefixAt returns true if the input at the given offset starts
+// with prefix
+func (r *rawInput) hasPrefixAt(offset int64, prefix string) bool {
+	start := offset - r.base
+	if start < 0 || start+int64(len(prefix)) > int64(len(r.buf)) {
+		return false
+	}
+	return string(r.buf[start:start+int64(len(prefix))]) == prefix
+}
+
+// discard drops the input before offset
+func (r *rawInput) discard(offset int64) {
+	n := offset - r.base
+	if n <= 0 {
+		return
+	}
+	if n > int64(len(r.buf)) {
+		n = int64(len(r.buf))
+	}
+	r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+	r.base += n
+}
+
+func parse(decoder *xml.Decoder, options ParseOptions, raw *rawInput) (Document, error) {
+	p := newParser(decoder, options, raw)
+	if options.TrackPositions {
+		p.doc.setPosition(&Position{Line: 1, Column: 1})
+	}
+	if err := p.build(p.doc); err != nil {
+		return nil, err
+	}
+	return p.doc, nil
+}
 
-	ret = NewDocument()
+// Parses XML content in the context of an existing element. The
+// content may contain multiple top-level elements and text. Prefixes
+// that are not declared in the content are resolved using
+// context.LookupNamespaceURI. Returns a DocumentFragment owned by the
+// document of context. The context element is not changed.
+func ParseFragment(context Element, decoder *xml.Decoder) (DocumentFragment, error) {
+	p := newParser(decoder, ParseOptions{}, nil)
+	p.doc = context.GetOwnerDocument().(*BasicDocument)
+	p.context = context
+	frag := p.doc.CreateDocumentFragment()
+	if err := p.build(frag); err != nil {
+		return nil, err
+	}
+	return frag, nil
+}
 
-	interner := make(map[string]string)
-	intern := func(s string) string {
-		existing, ok := interner[s]
-		if ok {
-			return existing
+// build reads all events and adds their nodes under parent
+func (p *parser) build(parent Node) error {
+	builder := treeBuilder{parent: parent}
+	for {
+		event, err := p.next()
+		if err == io.EOF {
+			if p.context != nil && p.decoder.Strict && len(p.elementStack) > 0 {
+				return p.syntaxError("Unexpected EOF")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if p.options.TrackPositions && event.Type != EndElementEvent {
+			pos := event.Position
+			setNodePosition(event.Node, &pos)
+		}
+		if err := builder.add(event); err != nil {
+			return withPosition(err, p.pos)
 		}
-		interner[s] = s
-		return s
 	}
+}
 
-	autoClose := func(name xml.Name) bool {
-		if decoder.Strict {
-			return false
+// setNodePosition sets the position of the node, and the attributes
+// of an element
+func setNodePosition(node Node, pos *Position) {
+	if n, ok := node.(interface{ setPosition(*Position) }); ok {
+		n.setPosition(pos)
+	}
+	if el, ok := node.(*BasicElement); ok {
+		for _, attr := range el.attributes.attrs {
+			attr.setPosition(pos)
 		}
-		for _, str := range decoder.AutoClose {
-			if strings.EqualFold(str, name.Local) {
-				return true
-			}
+	}
+}
+
+// treeBuilder adds the nodes of parse events to a tree
+type treeBuilder struct {
+	parent Node
+}
+
+func (b *treeBuilder) add(event Event) error {
+	switch event.Type {
+	case StartElementEvent:
+		if _, err := b.parent.AppendChildE(event.Node); err != nil {
+			return err
+		}
+		b.parent = event.Node
+	case EndElementEvent:
+		b.parent = b.parent.GetParentNode()
+	default:
+		if _, err := b.parent.AppendChildE(event.Node); err != nil {
+			return err
 		}
-		return false
 	}
+	return nil
+}
+
+// openElement is an element whose end tag is not yet seen
+type openElement struct {
+	name xml.Name
+	el   *BasicElement
+}
 
-	elementStack := make([]xml.Name, 0, 16)
+// parser turns the tokens of an xml.Decoder into parse events. The
+// nodes of the events are owned by doc, but they are not attached
+// to a tree.
+type parser struct {
+	decoder  *xml.Decoder
+	options  ParseOptions
+	raw      *rawInput
+	doc      *BasicDocument
+	interner map[string]string
+	// If non-nil, a fragment is parsed in the context of this
+	// element
+	context Element
+
+	elementStack  []openElement
+	autoCloseSeen bool
+	pending       []Event
+	// Position of the current token
+	pos Position
+}
+
+func newParser(decoder *xml.Decoder, options ParseOptions, raw *rawInput) *parser {
+	return &parser{
+		decoder:      decoder,
+		options:      options,
+		raw:          raw,
+		doc:          NewDocument().(*BasicDocument),
+		interner:     make(map[string]string),
+		elementStack: make([]openElement, 0, 16),
+	}
+}
 
-	var parent *BasicElement
-	autoCloseSeen := false
+func (p *parser) intern(s string) string {
+	existing, ok := p.interner[s]
+	if ok {
+		return existing
+	}
+	p.interner[s] = s
+	return s
+}
 
-	closeAutoClose := func() {
-		if !autoCloseSeen {
-			return
+func (p *parser) autoClose(name xml.Name) bool {
+	if p.decoder.Strict {
+		return false
+	}
+	for _, str := range p.decoder.AutoClose {
+		if strings.EqualFold(str, name.Local) {
+			return true
 		}
-		autoCloseSeen = false
-		elementStack = elementStack[:len(elementStack)-1]
-		par := parent.GetParentNode()
-		if _, ok := par.(*BasicDocument); ok {
-			parent = nil
-		} else {
-			parent = par.(*BasicElement)
+	}
+	return false
+}
+
+// depth returns the number of open elements
+func (p *parser) depth() int {
+	return len(p.elementStack)
+}
+
+func (p *parser) emit(typ EventType, node Node) {
+	p.pending = append(p.pending, Event{Type: typ, Node: node, Depth: p.depth(), Position: p.pos})
+}
+
+// popElement closes the innermost open element
+func (p *parser) popElement() {
+	el := p.elementStack[len(p.elementStack)-1].el
+	p.elementStack = p.elementStack[:len(p.elementStack)-1]
+	p.emit(EndElementEvent, el)
+}
+
+func (p *parser) closeAutoClose() {
+	if !p.autoCloseSeen {
+		return
+	}
+	p.autoCloseSeen = false
+	p.popElement()
+}
+
+// lookupNamespaceURI resolves prefix using the open elements
+func (p *parser) lookupNamespaceURI(prefix string) string {
+	if len(p.elementStack) == 0 && p.context == nil {
+		return ""
+	}
+	switch prefix {
+	case xmlPrefix:
+		return xmlURL
+	case xmlnsPrefix:
+		return xmlnsURL
+	}
+	for i := len(p.elementStack) - 1; i >= 0; i-- {
+		if uri, ok := p.elementStack[i].el.lookupLocalNamespaceURI(prefix); ok {
+			return uri
 		}
 	}
+	if p.context != nil {
+		return p.context.LookupNamespaceURI(prefix)
+	}
+	return ""
+}
 
-	isSpaceOrEmpty := func(s string) bool {
-		for _, x := range s {
-			if !unicode.IsSpace(x) {
-				return false
-			}
+// syntaxError returns a syntax error at the current token
+func (p *parser) syntaxError(msg string) error {
+	return &xml.SyntaxError{
+		Msg:  fmt.Sprintf("%s (column %d)", msg, p.pos.Column),
+		Line: p.pos.Line,
+	}
+}
+
+func isSpaceOrEmpty(s string) bool {
+	for _, x := range s {
+		if !unicode.IsSpace(x) {
+			return false
 		}
-		return true
 	}
+	return true
+}
 
-	for {
-		tok, err := decoder.RawToken()
-		if err == io.EOF {
-			break
+// next returns the next parse event, or io.EOF at the end of input
+func (p *parser) next() (Event, error) {
+	for len(p.pending) == 0 {
+		if err := p.readToken(); err != nil {
+			return Event{}, err
 		}
-		if err != nil {
-			return nil, err
+	}
+	event := p.pending[0]
+	p.pending = p.pending[1:]
+	return event, nil
+}
+
+// readToken reads the next token and adds the events for it
+func (p *parser) readToken() error {
+	decoder := p.decoder
+	tokenStart := decoder.InputOffset()
+	line, column := decoder.InputPos()
+	p.pos = Position{Line: line, Column: column, Offset: tokenStart}
+	tok, err := decoder.RawToken()
+	if err != nil {
+		return err
+	}
+	isCDATA := false
+	if p.raw != nil {
+		if _, ok := tok.(xml.CharData); ok {
+			isCDATA = p.raw.hasPrefixAt(tokenStart, "<![CDATA[")
 		}
-		switch token := tok.(type) {
-		case xml.StartElement:
+		p.raw.discard(decoder.InputOffset())
+	}
+	ret := p.doc
+	switch token := tok.(type) {
+	case xml.StartElement:
 
-			closeAutoClose()
+		p.closeAutoClose()
 
-			elementStack = append(elementStack, token.Name)
-			newElement := ret.CreateElement(intern(token.Name.Local)).(*BasicElement) // Create an empty element for now
-			newElement.name.Prefix = intern(token.Name.Space)
-			if parent == nil {
-				ret.AppendChild(newElement) // This is the document element
-			} else {
-				parent.AppendChild(newElement)
-			}
+		newElement := ret.CreateElement(p.intern(token.Name.Local)).(*BasicElement) // Create an empty element for now
+		newElement.name.Prefix = p.intern(token.Name.Space)
 
-			// First, create all attributes without namespaces
-			for _, attr := range token.Attr {
-				newAttr := ret.CreateAttribute(intern(attr.Name.Local)).(*BasicAttr)
-				newAttr.name.Prefix = intern(attr.Name.Space)
-				newAttr.value = attr.Value
-				newAttr.parent = newElement
-				newElement.attributes.attrs = append(newElement.attributes.attrs, newAttr)
-			}
+		// First, create all attributes without namespaces
+		for _, attr := range token.Attr {
+			newAttr := ret.CreateAttribute(p.intern(attr.Name.Local)).(*BasicAttr)
+			newAttr.name.Prefix = p.intern(attr.Name.Space)
+			newAttr.value = attr.Value
+			newAttr.parent = newElement
+			newElement.attributes.attrs = append(newElement.attributes.attrs, newAttr)
+		}
 
-			// Now process all xmlns attributes
-			for _, attr := range newElement.attributes.attrs {
-				if attr.name.Prefix == xmlnsPrefix {
-					attr.name.Space = xmlnsURL
-					if newElement.name.Prefix == attr.name.Local {
-						newElement.name.Space = intern(attr.value)
-						attr.value = intern(attr.value)
-					}
-				} else if len(attr.name.Prefix) == 0 && attr.name.Local == xmlnsPrefix {
-					if len(newElement.name.Prefix) == 0 {
-						newElement.name.Space = intern(attr.value)
-						attr.value = intern(attr.value)
-					}
-				} else {
-					// If attr has prefix, then we have to find namespace
-					if len(attr.name.Prefix) > 0 {
-						// Is namespace defined here?
-						for _, a := range newElement.attributes.attrs {
-							if a.name.Prefix == xmlnsPrefix && a.name.Local == attr.name.Prefix {
-								attr.name.Space = a.value
-								break
-							}
-						}
-						if len(attr.name.Space) == 0 && parent != nil {
-							attr.name.Space = parent.LookupNamespaceURI(attr.name.Prefix)
+		// Now process all xmlns attributes
+		for _, attr := range newElement.attributes.attrs {
+			if attr.name.Prefix == xmlnsPrefix {
+				attr.name.Space = xmlnsURL
+				if newElement.name.Prefix == attr.name.Local {
+					newElement.name.Space = p.intern(attr.value)
+					attr.value = p.intern(attr.value)
+				}
+			} else if len(attr.name.Prefix) == 0 && attr.name.Local == xmlnsPrefix {
+				if len(newElement.name.Prefix) == 0 {
+					newElement.name.Space = p.intern(attr.value)
+					attr.value = p.intern(attr.value)
+				}
+			} else {
+				// If attr has prefix, then we have to find namespace
+				if len(attr.name.Prefix) > 0 {
+					// Is namespace defined here?
+					for _, a := range newElement.attributes.attrs {
+						if a.name.Prefix == xmlnsPrefix && a.name.Local == attr.name.Prefix {
+							attr.name.Space = a.value
+							break
 						}
 					}
+					if len(attr.name.Space) == 0 {
+						attr.name.Space = p.lookupNamespaceURI(attr.name.Prefix)
+					}
 				}
 			}
-			newElement.attributes.mapAttrs = make(map[xml.Name]*BasicAttr)
-			for _, a := range newElement.attributes.attrs {
-				newElement.attributes.mapAttrs[a.name.Name] = a
-			}
-			// If namespace is not yet resolved, resolve it
-			if len(newElement.name.Space) == 0 {
-				newElement.name.Space = newElement.LookupNamespaceURI(newElement.name.Prefix)
+		}
+		newElement.attributes.mapAttrs = make(map[xml.Name]*BasicAttr)
+		for _, a := range newElement.attributes.attrs {
+			newElement.attributes.mapAttrs[a.name.Name] = a
+		}
+		// If namespace is not yet resolved, resolve it
+		if len(newElement.name.Space) == 0 {
+			switch newElement.name.Prefix {
+			case xmlPrefix:
+				newElement.name.Space = xmlURL
+			case xmlnsPrefix:
+				newElement.name.Space = xmlnsURL
+			default:
+				if uri, ok := newElement.lookupLocalNamespaceURI(newElement.name.Prefix); ok {
+					newElement.name.Space = uri
+				} else {
+					newElement.name.Space = p.lookupNamespaceURI(newElement.name.Prefix)
+				}
 			}
+		}
 
-			parent = newElement
-			if autoClose(token.Name) {
-				autoCloseSeen = true
-			}
+		p.emit(StartElementEvent, newElement)
+		p.elementStack = append(p.elementStack, openElement{name: token.Name, el: newElement})
+		if p.autoClose(token.Name) {
+			p.autoCloseSeen = true
+		}
 
-		case xml.EndElement:
-			if len(elementStack) == 0 {
-				return nil, &xml.SyntaxError{
-					Msg: "Extra objects before document",
-				}
+	case xml.EndElement:
+		if len(p.elementStack) == 0 {
+			return p.syntaxError("Extra objects before document")
+		}
+		if p.autoCloseSeen {
+			if p.elementStack[len(p.elementStack)-1].name == token.Name {
+				p.autoCloseSeen = false
+				p.popElement()
+				break
 			}
-			if autoCloseSeen {
-				if elementStack[len(elementStack)-1] == token.Name {
-					autoCloseSeen = false
-					elementStack = elementStack[:len(elementStack)-1]
-					break
-				}
-				closeAutoClose()
+			p.closeAutoClose()
+		}
+
+		last := p.elementStack[len(p.elementStack)-1].name
+		if last.Space != token.Name.Space || !strings.EqualFold(last.Local, token.Name.Local) {
+			return p.syntaxError(fmt.Sprintf("Mismatched closing tag %s", token.Name.Local))
+		}
+		p.popElement()
+
+	case xml.CharData:
+		if len(p.elementStack) == 0 && p.context == nil {
+			// charData must be only spaces
+			if !isSpaceOrEmpty(string(token)) || isCDATA {
+				return p.syntaxError("Extra characters before document")
 			}
+		} else if isCDATA && p.options.KeepCDATASections {
+			p.emit(TextEvent, ret.CreateCDATASection(string(token)))
+		} else {
+			p.emit(TextEvent, ret.CreateTextNode(string(token)))
+		}
 
-			last := elementStack[len(elementStack)-1]
-			if last.Space != token.Name.Space || !strings.EqualFold(last.Local, token.Name.Local) {
-				return nil, &xml.SyntaxError{
-					Msg: fmt.Sprintf("Mismatched closing tag %s", token.Name.Local),
-				}
+	case xml.Comment:
+		p.emit(CommentEvent, ret.CreateComment(string(token)))
+
+	case xml.ProcInst:
+		if token.Target == "xml" {
+			// The XML declaration is kept in the document, and it
+			// is not a node
+			if tokenStart != 0 {
+				return p.syntaxError("XML declaration not at the start of the document")
 			}
-			elementStack = elementStack[:len(elementStack)-1]
-			par := parent.GetParentNode()
-			if _, ok := par.(*BasicDocument); ok {
-				parent = nil
-			} else {
-				parent = par.(*BasicElement)
+			if p.context == nil {
+				p.doc.xmlDecl = parseXMLDeclaration(string(token.Inst))
 			}
+			break
+		}
+		p.closeAutoClose()
+		p.emit(ProcessingInstructionEvent, ret.CreateProcessingInstruction(token.Target, string(token.Inst)))
 
-		case xml.CharData:
-			if len(elementStack) == 0 {
-				// charData must be only spaces
-				if !isSpaceOrEmpty(string(token)) {
-					return nil, &xml.SyntaxError{
-						Msg: "Extra characters before document",
-					}
-				}
-			} else {
-				newNode := ret.CreateTextNode(string(token))
-				parent.AppendChild(newNode)
+	case xml.Directive:
+		content := string(token)
+		if strings.HasPrefix(content, "CDATA[") && strings.HasSuffix(content, "]]") {
+			if len(p.elementStack) == 0 && p.context == nil {
+				return p.syntaxError("CDATA before document")
 			}
-
-		case xml.Comment:
-			if len(elementStack) == 0 {
-				ret.AppendChild(ret.CreateComment(string(token)))
+			if p.options.KeepCDATASections {
+				p.emit(TextEvent, ret.CreateCDATASection(string(content[6:len(content)-2])))
 			} else {
-				parent.AppendChild(ret.CreateComment(string(token)))
+				p.emit(TextEvent, ret.CreateTextNode(string(content[6:len(content)-2])))
 			}
-
-		case xml.ProcInst:
-			closeAutoClose()
-			newNode := ret.CreateProcessingInstruction(token.Target, string(token.Inst))
-			if parent == nil {
-				ret.AppendChild(newNode)
-			} else {
-				parent.AppendChild(newNode)
+		} else {
+			documentType, ok, err := ParseDocumentType([]byte(token))
+			if err != nil {
+				return withPosition(err, p.pos)
 			}
-
-		case xml.Directive:
-			content := string(token)
-			if strings.HasPrefix(content, "CDATA[") && strings.HasSuffix(content, "]]") {
-				if len(elementStack) == 0 {
-					return nil, &xml.SyntaxError{
-						Msg: "CDATA before document",
-					}
-				}
-				newNode := ret.CreateTextNode(string(content[6 : len(content)-2]))
-				parent.AppendChild(newNode)
-			} else {
-				documentType, ok, err := ParseDocumentType([]byte(token))
-				if err != nil {
-					return nil, err
+			if ok {
+				if len(p.elementStack) > 0 {
+					return p.syntaxError("DOCTYPE inside document element")
 				}
-				if ok {
-					parent.AppendChild(documentType)
+				if p.context != nil {
+					return p.syntaxError("DOCTYPE in fragment")
 				}
+				documentType.(*BasicDocumentType).setOwner(ret)
+				p.emit(DocumentTypeEvent, documentType)
 			}
 		}
 	}
-	return ret, nil
+	return nil
 }
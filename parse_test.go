@@ -1,6 +1,7 @@
 package dom
 
 import (
+	"bytes"
 	"encoding/xml"
 	"strings"
 	"testing"
@@ -192,3 +193,171 @@ func TestAutoClose(t *testing.T) {
 		return
 	}
 }
+
+func TestKeepCDATASections(t *testing.T) {
+	input := `<rss><item><description><![CDATA[<b>bold</b> & more]]></description><title>a<![CDATA[b]]>c</title></item></rss>`
+	doc, err := ParseReader(strings.NewReader(input), ParseOptions{KeepCDATASections: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := doc.GetDocumentElement().GetFirstElementChild().GetFirstElementChild()
+	cdata, ok := desc.GetFirstChild().(CDATASection)
+	if !ok {
+		t.Fatalf("Not a CDATA section: %T", desc.GetFirstChild())
+	}
+	if cdata.GetNodeType() != CDATA_SECTION_NODE || cdata.GetValue() != "<b>bold</b> & more" {
+		t.Errorf("Wrong CDATA: %s", cdata.GetValue())
+	}
+	title := desc.GetNextElementSibling()
+	if title.GetChildNodes().GetLength() != 3 || title.GetFirstChild().GetNextSibling().GetNodeType() != CDATA_SECTION_NODE {
+		t.Errorf("Wrong title children")
+	}
+	buf := bytes.Buffer{}
+	if err := Encode(doc, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != input {
+		t.Errorf("Round trip failed: %s", buf.String())
+	}
+
+	// Without the option, CDATA sections are text nodes
+	doc, err = ParseReader(strings.NewReader(input), ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc = doc.GetDocumentElement().GetFirstElementChild().GetFirstElementChild()
+	if desc.GetFirstChild().GetNodeType() != TEXT_NODE {
+		t.Errorf("Expected text node")
+	}
+
+	// CDATA sections containing the terminator are split
+	doc = NewDocument()
+	root := doc.CreateElement("root")
+	doc.AppendChild(root)
+	root.AppendChild(doc.CreateCDATASection("a]]>b"))
+	buf.Reset()
+	if err := Encode(doc, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "<root><![CDATA[a]]]]><![CDATA[>b]]></root>" {
+		t.Errorf("Wrong encoding: %s", buf.String())
+	}
+	doc, err = Parse(xml.NewDecoder(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Normalize()
+	if doc.GetDocumentElement().GetFirstChild().(Text).GetValue() != "a]]>b" {
+		t.Errorf("Wrong value")
+	}
+}
+
+func TestTrackPositions(t *testing.T) {
+	input := "<?xml version=\"1.0\"?>\n<root a=\"1\">\n  <child>text</child><!--c-->\n</root>"
+	doc, err := ParseReader(strings.NewReader(input), ParseOptions{TrackPositions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.GetDocumentElement()
+	child := root.GetFirstElementChild()
+	check := func(node Node, line, column int, offset int64) {
+		pos, ok := node.GetPosition()
+		if !ok {
+			t.Errorf("No position for %s", node.GetNodeName())
+			return
+		}
+		if pos.Line != line || pos.Column != column || pos.Offset != offset {
+			t.Errorf("Wrong position for %s: %+v", node.GetNodeName(), pos)
+		}
+	}
+	check(root, 2, 1, 22)
+	check(root.GetAttributeNode("a"), 2, 1, 22)
+	check(child, 3, 3, 37)
+	check(child.GetFirstChild(), 3, 10, 44)
+	check(child.GetNextSibling(), 3, 22, 56)
+
+	// Positions are not tracked by default
+	doc, _ = ParseReader(strings.NewReader(input), ParseOptions{})
+	if _, ok := doc.GetDocumentElement().GetPosition(); ok {
+		t.Errorf("Position should not be tracked")
+	}
+}
+
+func TestParseWithOptions(t *testing.T) {
+	input := "<root>\n  <a><![CDATA[x]]></a></root>"
+	doc, err := ParseWithOptions(xml.NewDecoder(strings.NewReader(input)), ParseOptions{
+		TrackPositions: true,
+		Configure:      func(d *xml.Decoder) { d.Strict = false },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := doc.GetDocumentElement().GetFirstElementChild()
+	if pos, ok := a.GetPosition(); !ok || pos.Line != 2 || pos.Column != 3 {
+		t.Errorf("Wrong position: %+v", pos)
+	}
+	if a.GetFirstChild().GetNodeType() != TEXT_NODE {
+		t.Errorf("CDATA section not converted to text")
+	}
+
+	// The decoder cannot detect CDATA sections
+	_, err = ParseWithOptions(xml.NewDecoder(strings.NewReader(input)), ParseOptions{KeepCDATASections: true})
+	if e, ok := err.(ErrDOM); !ok || e.Typ != NOT_SUPPORTED_ERR {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	_, err := Parse(xml.NewDecoder(strings.NewReader("<root>\n<a>\n</b></root>")))
+	serr, ok := err.(*xml.SyntaxError)
+	if !ok || serr.Line != 3 || !strings.Contains(serr.Msg, "column 1") {
+		t.Errorf("Wrong error: %v", err)
+	}
+	_, err = Parse(xml.NewDecoder(strings.NewReader("<root/>\n\n  <root/>")))
+	derr, ok := err.(ErrDOM)
+	if !ok || !strings.Contains(derr.Msg, "line 3, column 3") {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func TestParseFragment(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<root xmlns="urn:d" xmlns:p="urn:p"><ctx/></root>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := doc.GetDocumentElement().GetFirstChild().(Element)
+	frag, err := ParseFragment(context, xml.NewDecoder(strings.NewReader(`text<p:a p:x="1" xml:lang="en"/><b xmlns:p="urn:q"><p:c/></b><!--c-->`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frag.GetOwnerDocument() != doc || context.HasChildNodes() {
+		t.Errorf("Wrong owner or context changed")
+	}
+	children := frag.GetChildNodes()
+	if children.GetLength() != 4 {
+		t.Fatalf("Expected 4 nodes, got %d", children.GetLength())
+	}
+	if children.Item(0).(Text).GetValue() != "text" {
+		t.Errorf("Wrong text")
+	}
+	a := children.Item(1).(Element)
+	if a.GetQName().Space != "urn:p" {
+		t.Errorf("Wrong namespace: %v", a.GetQName())
+	}
+	if _, ok := a.GetAttributeNS("urn:p", "x"); !ok {
+		t.Errorf("Attribute namespace not resolved")
+	}
+	if _, ok := a.GetAttributeNS(xmlURL, "lang"); !ok {
+		t.Errorf("xml prefix not resolved")
+	}
+	b := children.Item(2).(Element)
+	if b.GetQName().Space != "urn:d" || b.GetFirstChild().(Element).GetQName().Space != "urn:q" {
+		t.Errorf("Wrong namespaces: %v", b.GetQName())
+	}
+
+	for _, input := range []string{`<a>`, `<a></b>`, `<!DOCTYPE x>`} {
+		if _, err := ParseFragment(context, xml.NewDecoder(strings.NewReader(input))); err == nil {
+			t.Errorf("Expected error for %s", input)
+		}
+	}
+}
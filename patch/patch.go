@@ -0,0 +1,491 @@
+// Package patch applies XML Patch operations (RFC 5261) to DOM
+// documents.
+//
+// A patch is an element whose children are add, replace, and remove
+// operations. The sel attribute of an operation is an XPath
+// expression that must select exactly one node of the document. The
+// prefixes in selectors are resolved using the namespace
+// declarations in scope for the operation in the patch document, and
+// unprefixed element names are in the default namespace of the patch
+// document.
+//
+// Patches are applied atomically: if an operation fails, the changes
+// of the previous operations are rolled back.
+//
+// Replay applies the edit script computed by the diff package to the
+// old tree of the comparison, also atomically.
+package patch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bserdar/go-dom"
+	"github.com/bserdar/go-dom/xpath"
+)
+
+const (
+	xmlURL   = "http://www.w3.org/XML/1998/namespace"
+	xmlnsURL = "http://www.w3.org/2000/xmlns"
+)
+
+// Apply applies the operations of patch to doc. patch is either the
+// patch document, or the element that contains the operations. The
+// errors are dom.ErrDOM values. If an operation fails, doc is left
+// unchanged. The patch is applied using doc.Atomically, so it is not
+// added to the undo stack.
+func Apply(doc dom.Document, patch dom.Node) error {
+	root, ok := patch.(dom.Element)
+	if pdoc, isDoc := patch.(dom.Document); isDoc {
+		root, ok = pdoc.GetDocumentElement(), true
+	}
+	if !ok || root == nil {
+		return patchError(dom.SYNTAX_ERR, "", "Patch is not an element or a document")
+	}
+	return atomically(doc, func() error {
+		for op := root.GetFirstElementChild(); op != nil; op = op.GetNextElementSibling() {
+			if err := applyOperation(doc, op); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// atomically runs f using doc.Atomically. If f fails, the changes are
+// rolled back. The ErrDOM panics of f are returned as errors.
+func atomically(doc dom.Document, f func() error) error {
+	return doc.Atomically(func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				domErr, ok := r.(dom.ErrDOM)
+				if !ok {
+					panic(r)
+				}
+				err = domErr
+			}
+		}()
+		return f()
+	})
+}
+
+func patchError(typ, op, msg string, args ...interface{}) error {
+	if len(op) == 0 {
+		op = "Apply"
+	}
+	return dom.ErrDOM{
+		Typ: typ,
+		Msg: fmt.Sprintf(msg, args...),
+		Op:  op,
+	}
+}
+
+func applyOperation(doc dom.Document, op dom.Element) error {
+	name := op.GetLocalName()
+	switch name {
+	case "add", "replace", "remove":
+	default:
+		return patchError(dom.SYNTAX_ERR, name, "Unknown patch operation")
+	}
+	sel, ok := op.GetAttribute("sel")
+	if !ok {
+		return patchError(dom.SYNTAX_ERR, name, "Missing sel attribute")
+	}
+	if elementSel, prefix, ok := namespaceSelector(sel); ok && name != "add" {
+		return namespaceOperation(doc, op, elementSel, prefix)
+	}
+	target, err := selectTarget(doc, op, sel)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "add":
+		return add(doc, op, target)
+	case "replace":
+		return replace(doc, op, target)
+	}
+	return remove(op, target)
+}
+
+// inScopeNamespaces returns the prefixes and the default namespace
+// declared for node in the patch document
+func inScopeNamespaces(node dom.Element) (map[string]string, string) {
+	ret := make(map[string]string)
+	defaultNS := ""
+	defaultSet := false
+	for el := node; el != nil; el = el.GetParentElement() {
+		attrs := el.GetAttributes()
+		for i := 0; i < attrs.GetLength(); i++ {
+			prefix, uri, ok := namespaceDecl(attrs.Item(i))
+			if !ok {
+				continue
+			}
+			if len(prefix) == 0 {
+				if !defaultSet {
+					defaultNS, defaultSet = uri, true
+				}
+				continue
+			}
+			if _, exists := ret[prefix]; !exists {
+				ret[prefix] = uri
+			}
+		}
+	}
+	return ret, defaultNS
+}
+
+// namespaceDecl returns the prefix and URI declared by attr if it is
+// a namespace declaration
+func namespaceDecl(attr dom.Attr) (string, string, bool) {
+	name := attr.GetQName()
+	if name.Prefix == "xmlns" || (name.Space == xmlnsURL && name.Local != "xmlns") {
+		return name.Local, attr.GetValue(), true
+	}
+	if len(name.Prefix) == 0 && name.Local == "xmlns" {
+		return "", attr.GetValue(), true
+	}
+	return "", "", false
+}
+
+func selectTarget(doc dom.Document, op dom.Element, sel string) (dom.Node, error) {
+	namespaces, defaultNS := inScopeNamespaces(op)
+	nodes, err := xpath.Select(sel, doc, &xpath.Context{
+		Namespaces:       namespaces,
+		DefaultNamespace: defaultNS,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 {
+		return nil, patchError(dom.NOT_FOUND_ERR, op.GetLocalName(), "%s selects %d nodes, expecting exactly one", sel, len(nodes))
+	}
+	return nodes[0], nil
+}
+
+// namespaceSelector splits a selector of the form
+// expr/namespace::prefix into the element selector and the prefix
+func namespaceSelector(sel string) (string, string, bool) {
+	const axis = "/namespace::"
+	i := strings.LastIndex(sel, axis)
+	if i == -1 {
+		return "", "", false
+	}
+	prefix := strings.TrimSpace(sel[i+len(axis):])
+	if len(prefix) == 0 || strings.ContainsAny(prefix, "/[]():*@ \t\n") {
+		return "", "", false
+	}
+	return sel[:i], prefix, true
+}
+
+// namespaceOperation replaces or removes the declaration of prefix
+// on the element selected by sel. The declaration must be on the
+// element itself, not on one of its ancestors.
+func namespaceOperation(doc dom.Document, op dom.Element, sel, prefix string) error {
+	name := op.GetLocalName()
+	target, err := selectTarget(doc, op, sel)
+	if err != nil {
+		return err
+	}
+	el, ok := target.(dom.Element)
+	if !ok {
+		return patchError(dom.HIERARCHY_REQUEST_ERR, name, "Namespace declarations can only be selected on elements")
+	}
+	var decl dom.Attr
+	attrs := el.GetAttributes()
+	for i := 0; i < attrs.GetLength(); i++ {
+		if p, _, ok := namespaceDecl(attrs.Item(i)); ok && p == prefix {
+			decl = attrs.Item(i)
+			break
+		}
+	}
+	if decl == nil {
+		return patchError(dom.NOT_FOUND_ERR, name, "Namespace prefix %s is not declared on %s", prefix, el.GetTagName())
+	}
+	users := prefixUsers(el, prefix, decl.GetValue())
+	if name == "remove" {
+		if len(users) > 0 {
+			return patchError(dom.NAMESPACE_ERR, name, "Namespace prefix %s is in use", prefix)
+		}
+		el.RemoveAttributeNode(decl)
+		return nil
+	}
+	uri := op.GetTextContent()
+	if len(uri) == 0 {
+		return patchError(dom.NAMESPACE_ERR, name, "Namespace prefix %s cannot be bound to an empty URI", prefix)
+	}
+	// The elements and attributes that use the prefix move to the
+	// new namespace
+	for _, node := range users {
+		var qname dom.Name
+		switch n := node.(type) {
+		case dom.Element:
+			qname = n.GetQName()
+		case dom.Attr:
+			qname = n.GetQName()
+		}
+		if _, err := doc.RenameNode(node, prefix, uri, qname.Local); err != nil {
+			return err
+		}
+	}
+	decl.SetValue(uri)
+	return nil
+}
+
+// prefixUsers returns the elements and attributes in the subtree of
+// el whose names use prefix bound to uri by the declaration on el
+func prefixUsers(el dom.Element, prefix, uri string) []dom.Node {
+	ret := make([]dom.Node, 0)
+	var walk func(dom.Element, bool)
+	walk = func(e dom.Element, root bool) {
+		attrs := e.GetAttributes()
+		if !root {
+			for i := 0; i < attrs.GetLength(); i++ {
+				if p, _, ok := namespaceDecl(attrs.Item(i)); ok && p == prefix {
+					// Redeclared
+					return
+				}
+			}
+		}
+		if name := e.GetQName(); name.Prefix == prefix && name.Space == uri {
+			ret = append(ret, e)
+		}
+		for i := 0; i < attrs.GetLength(); i++ {
+			attr := attrs.Item(i)
+			if _, _, ok := namespaceDecl(attr); ok {
+				continue
+			}
+			if name := attr.GetQName(); name.Prefix == prefix && name.Space == uri {
+				ret = append(ret, attr)
+			}
+		}
+		for child := e.GetFirstElementChild(); child != nil; child = child.GetNextElementSibling() {
+			walk(child, false)
+		}
+	}
+	walk(el, true)
+	return ret
+}
+
+// importContent returns copies of the children of op that belong to
+// doc
+func importContent(doc dom.Document, op dom.Element) []dom.Node {
+	ret := make([]dom.Node, 0)
+	for child := op.GetFirstChild(); child != nil; child = child.GetNextSibling() {
+		ret = append(ret, doc.AdoptNode(child.CloneNode(true)))
+	}
+	return ret
+}
+
+func isWhitespace(node dom.Node) bool {
+	return node.GetNodeType() == dom.TEXT_NODE && len(strings.TrimSpace(node.GetTextContent())) == 0
+}
+
+func add(doc dom.Document, op dom.Element, target dom.Node) error {
+	if typ, ok := op.GetAttribute("type"); ok {
+		el, ok := target.(dom.Element)
+		if !ok {
+			return patchError(dom.HIERARCHY_REQUEST_ERR, "add", "Attributes can only be added to elements")
+		}
+		value := op.GetTextContent()
+		if strings.HasPrefix(typ, "@") {
+			return addAttribute(el, op, typ[1:], value)
+		}
+		if strings.HasPrefix(typ, "namespace::") {
+			prefix := strings.TrimPrefix(typ, "namespace::")
+			attrs := el.GetAttributes()
+			for i := 0; i < attrs.GetLength(); i++ {
+				if p, _, ok := namespaceDecl(attrs.Item(i)); ok && p == prefix {
+					return patchError(dom.NAMESPACE_ERR, "add", "Namespace prefix %s is already declared", prefix)
+				}
+			}
+			el.SetAttributeNS("xmlns", xmlnsURL, prefix, value)
+			return nil
+		}
+		return patchError(dom.SYNTAX_ERR, "add", "Invalid type: %s", typ)
+	}
+
+	parent := target
+	var before dom.Node
+	pos, _ := op.GetAttribute("pos")
+	switch pos {
+	case "":
+	case "prepend":
+		before = target.GetFirstChild()
+	case "before":
+		parent, before = target.GetParentNode(), target
+	case "after":
+		parent, before = target.GetParentNode(), target.GetNextSibling()
+	default:
+		return patchError(dom.SYNTAX_ERR, "add", "Invalid pos: %s", pos)
+	}
+	if parent == nil {
+		return patchError(dom.HIERARCHY_REQUEST_ERR, "add", "Target has no parent")
+	}
+	switch parent.GetNodeType() {
+	case dom.ELEMENT_NODE, dom.DOCUMENT_NODE:
+	default:
+		return patchError(dom.HIERARCHY_REQUEST_ERR, "add", "Cannot add children to %s", parent.GetNodeName())
+	}
+	for _, node := range importContent(doc, op) {
+		if parent.GetNodeType() == dom.DOCUMENT_NODE && isWhitespace(node) {
+			continue
+		}
+		if _, err := parent.InsertBeforeE(node, before); err != nil {
+			return err
+		}
+		if el, ok := node.(dom.Element); ok {
+			declareNamespaces(el)
+		}
+	}
+	return nil
+}
+
+func addAttribute(el dom.Element, op dom.Element, name, value string) error {
+	prefix, local := "", name
+	if i := strings.IndexByte(name, ':'); i != -1 {
+		prefix, local = name[:i], name[i+1:]
+	}
+	uri := ""
+	if len(prefix) > 0 {
+		namespaces, _ := inScopeNamespaces(op)
+		var ok bool
+		if uri, ok = namespaces[prefix]; !ok && prefix != "xml" {
+			return patchError(dom.NAMESPACE_ERR, "add", "Undefined namespace prefix %s", prefix)
+		}
+		if prefix == "xml" {
+			uri = xmlURL
+		}
+	}
+	if el.GetAttributeNodeNS(uri, local) != nil {
+		return patchError(dom.INUSE_ATTRIBUTE_ERR, "add", "Attribute %s already exists", name)
+	}
+	el.SetAttributeNS(prefix, uri, local, value)
+	if len(prefix) > 0 && uri != xmlURL {
+		declare(el, prefix, uri)
+	}
+	return nil
+}
+
+func replace(doc dom.Document, op dom.Element, target dom.Node) error {
+	switch target.GetNodeType() {
+	case dom.ATTRIBUTE_NODE, dom.TEXT_NODE, dom.CDATA_SECTION_NODE:
+		target.SetTextContent(op.GetTextContent())
+		return nil
+	case dom.DOCUMENT_NODE:
+		return patchError(dom.HIERARCHY_REQUEST_ERR, "replace", "Cannot replace the document")
+	}
+	var replacement dom.Node
+	for _, node := range importContent(doc, op) {
+		if isWhitespace(node) {
+			continue
+		}
+		if replacement != nil {
+			return patchError(dom.HIERARCHY_REQUEST_ERR, "replace", "Replacement must be a single node")
+		}
+		replacement = node
+	}
+	if replacement == nil || replacement.GetNodeType() != target.GetNodeType() {
+		return patchError(dom.HIERARCHY_REQUEST_ERR, "replace", "Replacement must be a %s", target.GetNodeName())
+	}
+	if _, err := target.GetParentNode().ReplaceChildE(replacement, target); err != nil {
+		return err
+	}
+	if el, ok := replacement.(dom.Element); ok {
+		declareNamespaces(el)
+	}
+	return nil
+}
+
+func remove(op dom.Element, target dom.Node) error {
+	if attr, ok := target.(dom.Attr); ok {
+		attr.GetOwnerElement().RemoveAttributeNode(attr)
+		return nil
+	}
+	parent := target.GetParentNode()
+	if parent == nil || target.GetNodeType() == dom.DOCUMENT_NODE {
+		return patchError(dom.HIERARCHY_REQUEST_ERR, "remove", "Cannot remove the document")
+	}
+	if _, ok := parent.(dom.Document); ok && target.GetNodeType() == dom.ELEMENT_NODE {
+		return patchError(dom.HIERARCHY_REQUEST_ERR, "remove", "Cannot remove the document element")
+	}
+	var removeBefore, removeAfter bool
+	ws, _ := op.GetAttribute("ws")
+	switch ws {
+	case "":
+	case "before":
+		removeBefore = true
+	case "after":
+		removeAfter = true
+	case "both":
+		removeBefore, removeAfter = true, true
+	default:
+		return patchError(dom.SYNTAX_ERR, "remove", "Invalid ws: %s", ws)
+	}
+	prev, next := target.GetPreviousSibling(), target.GetNextSibling()
+	if removeBefore && (prev == nil || !isWhitespace(prev)) {
+		return patchError(dom.NOT_FOUND_ERR, "remove", "No whitespace before the target")
+	}
+	if removeAfter && (next == nil || !isWhitespace(next)) {
+		return patchError(dom.NOT_FOUND_ERR, "remove", "No whitespace after the target")
+	}
+	if removeBefore {
+		if err := parent.RemoveChildE(prev); err != nil {
+			return err
+		}
+	}
+	if removeAfter {
+		if err := parent.RemoveChildE(next); err != nil {
+			return err
+		}
+	}
+	return parent.RemoveChildE(target)
+}
+
+// declareNamespaces adds the namespace declarations that are needed
+// for the names in the subtree of el, which are not already declared
+// by its ancestors
+func declareNamespaces(el dom.Element) {
+	name := el.GetQName()
+	declare(el, name.Prefix, name.Space)
+	attrs := el.GetAttributes()
+	for i := 0; i < attrs.GetLength(); i++ {
+		attr := attrs.Item(i)
+		if _, _, ok := namespaceDecl(attr); ok {
+			continue
+		}
+		if name := attr.GetQName(); len(name.Space) > 0 && name.Space != xmlURL {
+			declare(el, name.Prefix, name.Space)
+		}
+	}
+	for child := el.GetFirstElementChild(); child != nil; child = child.GetNextElementSibling() {
+		declareNamespaces(child)
+	}
+}
+
+// declare adds a declaration of prefix to el unless the prefix is
+// already bound to uri
+func declare(el dom.Element, prefix, uri string) {
+	if inScope(el, prefix) == uri {
+		return
+	}
+	if len(prefix) == 0 {
+		el.SetAttribute("xmlns", uri)
+		return
+	}
+	el.SetAttributeNS("xmlns", xmlnsURL, prefix, uri)
+}
+
+// inScope returns the namespace URI of prefix for el, using the
+// declarations of el and its ancestors
+func inScope(el dom.Element, prefix string) string {
+	attrs := el.GetAttributes()
+	for i := 0; i < attrs.GetLength(); i++ {
+		if p, uri, ok := namespaceDecl(attrs.Item(i)); ok && p == prefix {
+			return uri
+		}
+	}
+	if parent := el.GetParentElement(); parent != nil {
+		return parent.LookupNamespaceURI(prefix)
+	}
+	return ""
+}
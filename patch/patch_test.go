@@ -0,0 +1,187 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bserdar/go-dom"
+)
+
+func parse(t *testing.T, input string) dom.Document {
+	doc, err := dom.Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func encode(t *testing.T, node dom.Node) string {
+	buf := bytes.Buffer{}
+	if err := dom.Encode(node, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{
+			`<doc><note>This is a sample document</note></doc>`,
+			`<diff><add sel="doc"><foo id="ert4773">This is a new child</foo></add></diff>`,
+			`<doc><note>This is a sample document</note><foo id="ert4773">This is a new child</foo></doc>`,
+		},
+		{
+			`<doc><foo a="1"/></doc>`,
+			`<diff><add sel="doc/foo[@a='1']" type="@b">new value</add></diff>`,
+			`<doc><foo a="1" b="new value"></foo></doc>`,
+		},
+		{
+			`<doc><foo a="1"/><note/></doc>`,
+			`<diff><add sel="doc/note" pos="before"><!-- comment --></add><add sel="doc/foo" pos="prepend"><x/></add></diff>`,
+			`<doc><foo a="1"><x></x></foo><!-- comment --><note></note></doc>`,
+		},
+		{
+			`<doc><foo a="1">text</foo></doc>`,
+			`<diff><replace sel="doc/foo/@a">2</replace><replace sel="doc/foo/text()">new</replace></diff>`,
+			`<doc><foo a="2">new</foo></doc>`,
+		},
+		{
+			`<doc><foo a="1"/><note/></doc>`,
+			`<diff><replace sel="doc/foo"><bar/></replace><remove sel="doc/note"/></diff>`,
+			`<doc><bar></bar></doc>`,
+		},
+		{
+			`<doc>
+  <foo/>
+  <note/>
+</doc>`,
+			`<diff><remove sel="doc/foo" ws="after"/></diff>`,
+			`<doc>
+  <note></note>
+</doc>`,
+		},
+		{
+			`<doc xmlns="urn:d" xmlns:y="urn:y"><y:foo/></doc>`,
+			`<p:diff xmlns:p="urn:ietf:params:xml:ns:pidf-diff" xmlns="urn:d" xmlns:z="urn:y" xmlns:q="urn:q">
+  <p:add sel="doc/z:foo"><q:new/></p:add>
+  <p:add sel="doc/z:foo" type="@q:attr">v</p:add>
+</p:diff>`,
+			`<doc xmlns="urn:d" xmlns:y="urn:y"><y:foo q:attr="v" xmlns:q="urn:q"><q:new xmlns:q="urn:q"></q:new></y:foo></doc>`,
+		},
+	}
+	for _, test := range tests {
+		doc := parse(t, test.doc)
+		if err := Apply(doc, parse(t, test.patch)); err != nil {
+			t.Errorf("%s: %v", test.patch, err)
+			continue
+		}
+		if s := encode(t, doc); s != test.expected {
+			t.Errorf("%s: got %s", test.patch, s)
+		}
+		if doc.CanUndo() {
+			t.Errorf("%s: patch pushed to the undo stack", test.patch)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		patch string
+		typ   string
+	}{
+		{`<diff><add sel="doc/missing"><x/></add></diff>`, dom.NOT_FOUND_ERR},
+		{`<diff><remove sel="doc/foo"/></diff>`, dom.NOT_FOUND_ERR},
+		{`<diff><remove sel="doc"/></diff>`, dom.HIERARCHY_REQUEST_ERR},
+		{`<diff><add sel="doc/foo[1]/text()"><x/></add></diff>`, dom.HIERARCHY_REQUEST_ERR},
+		{`<diff><add sel="doc" pos="after"><x/></add></diff>`, dom.HIERARCHY_REQUEST_ERR},
+		{`<diff><replace sel="doc/foo[1]"><!--c--></replace></diff>`, dom.HIERARCHY_REQUEST_ERR},
+		{`<diff><add sel="doc/foo[1]" type="@a">x</add></diff>`, dom.INUSE_ATTRIBUTE_ERR},
+	}
+	for _, test := range tests {
+		doc := parse(t, `<doc><foo a="1">t</foo><foo/></doc>`)
+		// The first operation succeeds, and is rolled back
+		patch := parse(t, test.patch)
+		first := patch.CreateElement("add")
+		first.SetAttribute("sel", "doc")
+		first.AppendChild(patch.CreateElement("added"))
+		patch.GetDocumentElement().InsertBefore(first, patch.GetDocumentElement().GetFirstChild())
+		err := Apply(doc, patch)
+		var domErr dom.ErrDOM
+		if !errors.As(err, &domErr) || domErr.Typ != test.typ {
+			t.Errorf("%s: wrong error %v", test.patch, err)
+		}
+		if s := encode(t, doc); s != `<doc><foo a="1">t</foo><foo></foo></doc>` {
+			t.Errorf("%s: not rolled back: %s", test.patch, s)
+		}
+	}
+}
+
+func TestAtomically(t *testing.T) {
+	doc := parse(t, `<doc/>`)
+	root := doc.GetDocumentElement()
+	// ErrDOM panics are returned as errors
+	err := atomically(doc, func() error {
+		root.SetAttribute("a", "1")
+		root.AppendChild(root)
+		return nil
+	})
+	var domErr dom.ErrDOM
+	if !errors.As(err, &domErr) || domErr.Typ != dom.HIERARCHY_REQUEST_ERR {
+		t.Errorf("Wrong error %v", err)
+	}
+	// Other panics continue after rollback
+	func() {
+		defer func() {
+			if r := recover(); r != "fail" {
+				t.Errorf("Wrong panic %v", r)
+			}
+		}()
+		atomically(doc, func() error {
+			root.SetAttribute("b", "2")
+			panic("fail")
+		})
+	}()
+	if s := encode(t, doc); s != `<doc></doc>` {
+		t.Errorf("Not rolled back: %s", s)
+	}
+}
+
+func TestApplyNamespaceDeclarations(t *testing.T) {
+	input := `<r xmlns:p="u" xmlns:q="w"><c p:a="1"><p:d/></c></r>`
+	doc := parse(t, input)
+	if err := Apply(doc, parse(t, `<diff><remove sel="r/namespace::q"/><replace sel="r/namespace::p">v</replace></diff>`)); err != nil {
+		t.Fatal(err)
+	}
+	if s := encode(t, doc); s != `<r xmlns:p="v"><c p:a="1"><p:d></p:d></c></r>` {
+		t.Errorf("Wrong result: %s", s)
+	}
+	c := doc.GetDocumentElement().GetFirstElementChild()
+	if c.GetAttributeNodeNS("v", "a") == nil || c.GetFirstElementChild().GetQName().Space != "v" {
+		t.Errorf("Names using the prefix are not moved to the new namespace")
+	}
+
+	for _, patch := range []string{
+		// Declared on an ancestor of the selected element
+		`<diff><remove sel="r/c/namespace::p"/></diff>`,
+		`<diff><replace sel="r/c/namespace::p">v</replace></diff>`,
+		// The prefix is used
+		`<diff><remove sel="r/namespace::p"/></diff>`,
+		// Empty namespace URI
+		`<diff><replace sel="r/namespace::p"></replace></diff>`,
+	} {
+		doc := parse(t, input)
+		if err := Apply(doc, parse(t, patch)); err == nil {
+			t.Errorf("%s: expecting error", patch)
+		} else if _, ok := err.(dom.ErrDOM); !ok {
+			t.Errorf("%s: wrong error %v", patch, err)
+		}
+		if s := encode(t, doc); s != `<r xmlns:p="u" xmlns:q="w"><c p:a="1"><p:d></p:d></c></r>` {
+			t.Errorf("%s: document changed: %s", patch, s)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package patch
+
+import (
+	"github.com/bserdar/go-dom"
+	"github.com/bserdar/go-dom/diff"
+)
+
+// Replay applies the edits of script, computed by diff.Compare, to
+// the old tree of the comparison. doc is the document of the old
+// tree. The inserted nodes are copied from the new tree. If an edit
+// fails, doc is left unchanged. The errors are dom.ErrDOM values.
+// Like Apply, Replay is not added to the undo stack.
+func Replay(doc dom.Document, script diff.Script) error {
+	r := replayer{
+		doc:    doc,
+		placed: make(map[dom.Node]dom.Node),
+	}
+	return atomically(doc, func() error {
+		// Deleted nodes are not the positions of other edits. They
+		// are removed first, so the document element can be replaced.
+		for i := range script {
+			if script[i].Type == diff.Delete {
+				if err := r.replay(script[i]); err != nil {
+					return err
+				}
+			}
+		}
+		for i := range script {
+			if script[i].Type != diff.Delete {
+				if err := r.replay(script[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+type replayer struct {
+	doc dom.Document
+	// The nodes of the new tree that are inserted or moved, and the
+	// corresponding nodes of doc
+	placed map[dom.Node]dom.Node
+}
+
+func replayError(typ string, edit diff.Edit, msg string) error {
+	path := edit.OldPath
+	if len(path) == 0 {
+		path = edit.NewPath
+	}
+	return patchError(typ, "Replay", "%s %s: %s", edit.Type, path, msg)
+}
+
+// checkNode returns an error if node does not belong to the document
+func (r *replayer) checkNode(edit diff.Edit, node dom.Node) error {
+	if node == nil {
+		return replayError(dom.NOT_FOUND_ERR, edit, "Missing node")
+	}
+	if node != r.doc && node.GetOwnerDocument() != r.doc {
+		return replayError(dom.WRONG_DOCUMENT_ERR, edit, "Node does not belong to the document")
+	}
+	return nil
+}
+
+func (r *replayer) replay(edit diff.Edit) error {
+	switch edit.Type {
+	case diff.Insert, diff.Move:
+		return r.place(edit)
+	}
+	if err := r.checkNode(edit, edit.Old); err != nil {
+		return err
+	}
+	switch edit.Type {
+	case diff.Delete:
+		parent := edit.Old.GetParentNode()
+		if parent == nil {
+			return replayError(dom.HIERARCHY_REQUEST_ERR, edit, "Node has no parent")
+		}
+		return parent.RemoveChildE(edit.Old)
+	case diff.ChangeText:
+		switch edit.Old.GetNodeType() {
+		case dom.TEXT_NODE, dom.CDATA_SECTION_NODE, dom.COMMENT_NODE, dom.PROCESSING_INSTRUCTION_NODE:
+			edit.Old.SetTextContent(edit.NewValue)
+			return nil
+		}
+		return replayError(dom.NOT_SUPPORTED_ERR, edit, "Cannot change the value of "+edit.Old.GetNodeName())
+	}
+	el, ok := edit.Old.(dom.Element)
+	if !ok {
+		return replayError(dom.HIERARCHY_REQUEST_ERR, edit, "Attribute edit of a non-element")
+	}
+	switch edit.Type {
+	case diff.AddAttribute, diff.ChangeAttribute:
+		newAttr := findAttribute(edit.New, edit.Attribute)
+		if newAttr == nil {
+			return replayError(dom.NOT_FOUND_ERR, edit, "No attribute "+edit.Attribute+" in the new tree")
+		}
+		name := newAttr.GetQName()
+		el.SetAttributeNS(name.Prefix, name.Space, name.Local, edit.NewValue)
+		if len(name.Prefix) > 0 && name.Prefix != "xmlns" && name.Space != xmlURL {
+			declare(el, name.Prefix, name.Space)
+		}
+		if edit.Type == diff.AddAttribute {
+			// Added attributes are not in the ReorderAttributes
+			// edits, so they are moved to their position in the new
+			// tree here
+			orderAttributes(el, edit.New.(dom.Element))
+		}
+	case diff.RemoveAttribute:
+		attr := findAttribute(el, edit.Attribute)
+		if attr == nil {
+			return replayError(dom.NOT_FOUND_ERR, edit, "No attribute "+edit.Attribute)
+		}
+		el.RemoveAttributeNode(attr)
+	case diff.ReorderAttributes:
+		newEl, ok := edit.New.(dom.Element)
+		if !ok {
+			return replayError(dom.NOT_FOUND_ERR, edit, "No element in the new tree")
+		}
+		orderAttributes(el, newEl)
+	}
+	return nil
+}
+
+// orderAttributes appends the attributes of el again in the order of
+// the attributes of newEl. The attributes that newEl does not have
+// are left at the start.
+func orderAttributes(el, newEl dom.Element) {
+	attrs := newEl.GetAttributes()
+	for i := 0; i < attrs.GetLength(); i++ {
+		name := attrs.Item(i).GetQName()
+		if attr := el.GetAttributeNodeNS(name.Space, name.Local); attr != nil {
+			el.RemoveAttributeNode(attr)
+			el.SetAttributeNode(attr)
+		}
+	}
+}
+
+// findAttribute returns the attribute of node with the given
+// qualified name
+func findAttribute(node dom.Node, name string) dom.Attr {
+	el, ok := node.(dom.Element)
+	if !ok {
+		return nil
+	}
+	attrs := el.GetAttributes()
+	for i := 0; i < attrs.GetLength(); i++ {
+		if attrs.Item(i).GetName() == name {
+			return attrs.Item(i)
+		}
+	}
+	return nil
+}
+
+// place inserts a copy of the new node, or moves the old node to the
+// position of the new node
+func (r *replayer) place(edit diff.Edit) error {
+	if edit.Parent == nil {
+		return replayError(dom.NOT_SUPPORTED_ERR, edit, "Cannot replace the root of the comparison")
+	}
+	if err := r.checkNode(edit, edit.Parent); err != nil {
+		return err
+	}
+	after := edit.After
+	if p, ok := r.placed[after]; ok {
+		after = p
+	}
+	var reference dom.Node
+	if after == nil {
+		reference = edit.Parent.GetFirstChild()
+	} else {
+		if after.GetParentNode() != edit.Parent {
+			return replayError(dom.NOT_FOUND_ERR, edit, "Position not found")
+		}
+		reference = after.GetNextSibling()
+	}
+	node := edit.Old
+	if edit.Type == diff.Insert {
+		var err error
+		if node, err = r.doc.AdoptNodeE(edit.New.CloneNode(true)); err != nil {
+			return err
+		}
+	} else if err := r.checkNode(edit, node); err != nil {
+		return err
+	}
+	if node == reference {
+		reference = node.GetNextSibling()
+	}
+	if _, err := edit.Parent.InsertBeforeE(node, reference); err != nil {
+		return err
+	}
+	if el, ok := node.(dom.Element); ok {
+		declareNamespaces(el)
+	}
+	r.placed[edit.New] = node
+	return nil
+}
@@ -0,0 +1,125 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/bserdar/go-dom"
+	"github.com/bserdar/go-dom/diff"
+)
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		old, new string
+	}{
+		{
+			`<config>
+  <server port="80" debug="true">a</server>
+  <name>x</name>
+  <!-- c -->
+  <old/>
+  <group><item id="1"/></group>
+</config>`,
+			`<config>
+  <server port="8080" tls="on">b</server>
+  <name>x</name>
+  <!-- c -->
+  <group/>
+  <item id="1"/>
+  <new/>
+</config>`,
+		},
+		{
+			`<a x="1" y="2" z="3"><b/><c/><d/></a>`,
+			`<a z="3" y="2" x="0"><d/><b/><e/></a>`,
+		},
+		{
+			`<a xmlns:p="u"><p:b p:x="1"/></a>`,
+			`<a xmlns:p="u" xmlns:q="v"><q:c q:y="2"/><p:b/></a>`,
+		},
+		{
+			`<?pi one?><a><!--x--><b>t</b></a>`,
+			`<?pi two?><a><b>t<c/></b><!--y--></a>`,
+		},
+	}
+	for _, test := range tests {
+		old := parse(t, test.old)
+		new := parse(t, test.new)
+		if err := Replay(old, diff.Compare(old, new, diff.Options{})); err != nil {
+			t.Errorf("%s: %v", test.new, err)
+			continue
+		}
+		if script := diff.Compare(old, new, diff.Options{}); len(script) != 0 {
+			t.Errorf("%s: differences after replay:\n%s", test.new, script)
+		}
+		if s, expected := encode(t, old), encode(t, new); s != expected {
+			t.Errorf("Wrong result: %s, expecting %s", s, expected)
+		}
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	old := parse(t, `<a><b/></a>`)
+	new := parse(t, `<a><c/></a>`)
+	script := diff.Compare(old, new, diff.Options{})
+	// Replaying to another document fails, and is rolled back
+	other := parse(t, `<a><b/></a>`)
+	script = append(diff.Script{{Type: diff.ChangeText, Old: other.GetDocumentElement().GetFirstChild()}}, script...)
+	err := Replay(old, script)
+	var domErr dom.ErrDOM
+	if !errors.As(err, &domErr) || domErr.Typ != dom.WRONG_DOCUMENT_ERR {
+		t.Errorf("Wrong error: %v", err)
+	}
+	if s := encode(t, old); s != `<a><b></b></a>` {
+		t.Errorf("Not rolled back: %s", s)
+	}
+}
+
+// randomTree returns a random document using a few names, so random
+// pairs of trees have common parts
+func randomTree(rnd *rand.Rand) string {
+	var sb strings.Builder
+	var element func(depth int)
+	element = func(depth int) {
+		name := string(rune('a' + rnd.Intn(3)))
+		sb.WriteString("<" + name)
+		for _, i := range rnd.Perm(3)[:rnd.Intn(4)] {
+			fmt.Fprintf(&sb, ` %c="%d"`, 'x'+i, rnd.Intn(2))
+		}
+		sb.WriteString(">")
+		if depth < 3 {
+			for i := rnd.Intn(4); i > 0; i-- {
+				switch rnd.Intn(4) {
+				case 0:
+					fmt.Fprintf(&sb, "t%d", rnd.Intn(2))
+				case 1:
+					fmt.Fprintf(&sb, "<!--%d-->", rnd.Intn(2))
+				default:
+					element(depth + 1)
+				}
+			}
+		}
+		sb.WriteString("</" + name + ">")
+	}
+	element(0)
+	return sb.String()
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		oldInput, newInput := randomTree(rnd), randomTree(rnd)
+		old := parse(t, oldInput)
+		new := parse(t, newInput)
+		if err := Replay(old, diff.Compare(old, new, diff.Options{})); err != nil {
+			t.Errorf("%s -> %s: %v", oldInput, newInput, err)
+			continue
+		}
+		if script := diff.Compare(old, new, diff.Options{}); len(script) != 0 {
+			t.Errorf("%s -> %s: differences after replay:\n%s", oldInput, newInput, script)
+		}
+	}
+}
@@ -0,0 +1,777 @@
+package dom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// selectorList is a comma separated list of complex selectors. It
+// matches if any of the selectors match.
+type selectorList []*complexSelector
+
+// complexSelector is a sequence of compound selectors separated by
+// combinators. combinators[i] is the combinator between compounds[i]
+// and compounds[i+1]. It is one of ' ', '>', '+', '~'.
+type complexSelector struct {
+	compounds   []*compoundSelector
+	combinators []rune
+}
+
+type compoundSelector struct {
+	// Type selector. If nil, matches all elements.
+	typ        *typeSelector
+	conditions []selectorCondition
+}
+
+// typeSelector matches the element name. If anyNS is set, elements
+// in any namespace match. Otherwise, the element namespace must be
+// ns. If local is "*", any name matches.
+type typeSelector struct {
+	anyNS bool
+	ns    string
+	local string
+}
+
+type selectorCondition interface {
+	match(el Element) bool
+}
+
+type idCondition string
+
+type classCondition string
+
+type attrCondition struct {
+	anyNS bool
+	ns    string
+	local string
+	// One of "", "=", "~=", "|=", "^=", "$=", "*="
+	op              string
+	value           string
+	caseInsensitive bool
+}
+
+// nthCondition matches elements whose 1-based index among the
+// relevant siblings is a*n+b for some n>=0
+type nthCondition struct {
+	a, b   int
+	last   bool
+	ofType bool
+}
+
+type notCondition struct {
+	selectors selectorList
+}
+
+type isCondition struct {
+	selectors selectorList
+}
+
+type rootCondition struct{}
+
+type emptyCondition struct{}
+
+func (c idCondition) match(el Element) bool {
+	v, ok := el.GetAttribute("id")
+	return ok && v == string(c)
+}
+
+func (c classCondition) match(el Element) bool {
+	v, ok := el.GetAttribute("class")
+	if !ok {
+		return false
+	}
+	for _, cls := range strings.Fields(v) {
+		if cls == string(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *attrCondition) match(el Element) bool {
+	attrs := el.GetAttributes()
+	for i := 0; i < attrs.GetLength(); i++ {
+		attr := attrs.Item(i)
+		name := attr.GetQName()
+		if name.Local != c.local {
+			continue
+		}
+		if !c.anyNS && name.Space != c.ns {
+			continue
+		}
+		if c.matchValue(attr.GetValue()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *attrCondition) matchValue(value string) bool {
+	want := c.value
+	if c.caseInsensitive {
+		value = strings.ToLower(value)
+		want = strings.ToLower(want)
+	}
+	switch c.op {
+	case "":
+		return true
+	case "=":
+		return value == want
+	case "~=":
+		for _, s := range strings.Fields(value) {
+			if s == want {
+				return true
+			}
+		}
+		return false
+	case "|=":
+		return value == want || strings.HasPrefix(value, want+"-")
+	case "^=":
+		return len(want) > 0 && strings.HasPrefix(value, want)
+	case "$=":
+		return len(want) > 0 && strings.HasSuffix(value, want)
+	case "*=":
+		return len(want) > 0 && strings.Contains(value, want)
+	}
+	return false
+}
+
+func (c *nthCondition) match(el Element) bool {
+	if el.GetParentNode() == nil {
+		return false
+	}
+	sameType := func(e Element) bool {
+		if !c.ofType {
+			return true
+		}
+		n1, n2 := e.GetQName(), el.GetQName()
+		return n1.Space == n2.Space && n1.Local == n2.Local
+	}
+	index := 1
+	if c.last {
+		for s := el.GetNextElementSibling(); s != nil; s = s.GetNextElementSibling() {
+			if sameType(s) {
+				index++
+			}
+		}
+	} else {
+		for s := el.GetPreviousElementSibling(); s != nil; s = s.GetPreviousElementSibling() {
+			if sameType(s) {
+				index++
+			}
+		}
+	}
+	if c.a == 0 {
+		return index == c.b
+	}
+	n := index - c.b
+	return n%c.a == 0 && n/c.a >= 0
+}
+
+func (c *notCondition) match(el Element) bool {
+	return !c.selectors.match(el)
+}
+
+func (c *isCondition) match(el Element) bool {
+	return c.selectors.match(el)
+}
+
+func (rootCondition) match(el Element) bool {
+	_, ok := el.GetParentNode().(Document)
+	return ok
+}
+
+func (emptyCondition) match(el Element) bool {
+	for ch := el.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+		switch ch.GetNodeType() {
+		case ELEMENT_NODE:
+			return false
+		case TEXT_NODE, CDATA_SECTION_NODE:
+			if len(ch.(CharacterData).GetValue()) > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (t *typeSelector) match(el Element) bool {
+	name := el.GetQName()
+	if !t.anyNS && name.Space != t.ns {
+		return false
+	}
+	return t.local == "*" || t.local == name.Local
+}
+
+func (c *compoundSelector) match(el Element) bool {
+	if c.typ != nil && !c.typ.match(el) {
+		return false
+	}
+	for _, cond := range c.conditions {
+		if !cond.match(el) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *complexSelector) match(el Element) bool {
+	return s.matchAt(el, len(s.compounds)-1)
+}
+
+// matchAt matches el against compounds[i], and then the preceding
+// compounds against the elements selected by the combinators
+func (s *complexSelector) matchAt(el Element, i int) bool {
+	if !s.compounds[i].match(el) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch s.combinators[i-1] {
+	case '>':
+		parent, ok := el.GetParentNode().(Element)
+		return ok && s.matchAt(parent, i-1)
+	case ' ':
+		for trc := el.GetParentNode(); trc != nil; trc = trc.GetParentNode() {
+			parent, ok := trc.(Element)
+			if !ok {
+				return false
+			}
+			if s.matchAt(parent, i-1) {
+				return true
+			}
+		}
+	case '+':
+		prev := el.GetPreviousElementSibling()
+		return prev != nil && s.matchAt(prev, i-1)
+	case '~':
+		for prev := el.GetPreviousElementSibling(); prev != nil; prev = prev.GetPreviousElementSibling() {
+			if s.matchAt(prev, i-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l selectorList) match(el Element) bool {
+	for _, s := range l {
+		if s.match(el) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorParser parses CSS selectors. Namespace prefixes are
+// resolved using the resolver node.
+type selectorParser struct {
+	input    string
+	pos      int
+	resolver Node
+	op       string
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return ErrDOM{
+		Typ: SYNTAX_ERR,
+		Msg: fmt.Sprintf("Invalid selector '%s' at %d: %s", p.input, p.pos, fmt.Sprintf(format, args...)),
+		Op:  p.op,
+	}
+}
+
+func (p *selectorParser) peek() rune {
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+	return r
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.input) && strings.ContainsRune(" \t\r\n\f", p.peek()) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '\\' || unicode.IsLetter(r) || r >= 0x80
+}
+
+func isIdentChar(r rune) bool {
+	return isIdentStart(r) || r == '-' || (r >= '0' && r <= '9')
+}
+
+// ident parses a CSS identifier, processing escapes
+func (p *selectorParser) ident() (string, error) {
+	var sb strings.Builder
+	start := p.pos
+	if p.peek() == '-' {
+		sb.WriteRune('-')
+		p.pos++
+	}
+	if !isIdentStart(p.peek()) && !(p.peek() == '-' && sb.Len() > 0) {
+		p.pos = start
+		return "", p.errorf("Identifier expected")
+	}
+	for p.pos < len(p.input) {
+		r, w := utf8.DecodeRuneInString(p.input[p.pos:])
+		if !isIdentChar(r) {
+			break
+		}
+		p.pos += w
+		if r != '\\' {
+			sb.WriteRune(r)
+			continue
+		}
+		if p.pos >= len(p.input) {
+			return "", p.errorf("Invalid escape")
+		}
+		// Hex escape
+		hexEnd := p.pos
+		for hexEnd < len(p.input) && hexEnd-p.pos < 6 && strings.ContainsRune("0123456789abcdefABCDEF", rune(p.input[hexEnd])) {
+			hexEnd++
+		}
+		if hexEnd > p.pos {
+			v, _ := strconv.ParseUint(p.input[p.pos:hexEnd], 16, 32)
+			sb.WriteRune(rune(v))
+			p.pos = hexEnd
+			if p.pos < len(p.input) && p.input[p.pos] == ' ' {
+				p.pos++
+			}
+			continue
+		}
+		r, w = utf8.DecodeRuneInString(p.input[p.pos:])
+		sb.WriteRune(r)
+		p.pos += w
+	}
+	return sb.String(), nil
+}
+
+func (p *selectorParser) str() (string, error) {
+	quote := p.peek()
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.input) {
+		r, w := utf8.DecodeRuneInString(p.input[p.pos:])
+		p.pos += w
+		switch r {
+		case quote:
+			return sb.String(), nil
+		case '\\':
+			if p.pos < len(p.input) {
+				r, w = utf8.DecodeRuneInString(p.input[p.pos:])
+				p.pos += w
+				sb.WriteRune(r)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return "", p.errorf("Unterminated string")
+}
+
+func parseSelectorList(input string, resolver Node, op string) (selectorList, error) {
+	p := &selectorParser{input: input, resolver: resolver, op: op}
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) {
+		return nil, p.errorf("Unexpected '%c'", p.peek())
+	}
+	return list, nil
+}
+
+func (p *selectorParser) parseList() (selectorList, error) {
+	ret := selectorList{}
+	for {
+		p.skipSpace()
+		sel, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, sel)
+		p.skipSpace()
+		if p.peek() != ',' {
+			return ret, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseComplex() (*complexSelector, error) {
+	ret := &complexSelector{}
+	for {
+		compound, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		ret.compounds = append(ret.compounds, compound)
+		space := p.skipSpace()
+		comb := p.peek()
+		switch comb {
+		case '>', '+', '~':
+			p.pos++
+			p.skipSpace()
+		case ',', ')', 0:
+			return ret, nil
+		default:
+			if !space {
+				return nil, p.errorf("Unexpected '%c'", comb)
+			}
+			comb = ' '
+		}
+		ret.combinators = append(ret.combinators, comb)
+	}
+}
+
+// resolvePrefix returns the namespace for a prefix. If the prefix is
+// not defined in the context of the resolver node, it is an error
+func (p *selectorParser) resolvePrefix(prefix string) (string, error) {
+	if prefix == xmlPrefix {
+		return xmlURL, nil
+	}
+	if p.resolver != nil {
+		if ns := p.resolver.LookupNamespaceURI(prefix); len(ns) > 0 {
+			return ns, nil
+		}
+	}
+	return "", ErrDOM{
+		Typ: NAMESPACE_ERR,
+		Msg: fmt.Sprintf("Undefined namespace prefix %s", prefix),
+		Op:  p.op,
+	}
+}
+
+// parseQName parses [ns|]name, where ns and name can be
+// '*'. allowWildcard is false for attribute names.
+func (p *selectorParser) parseQName(allowWildcard bool) (anyNS bool, ns, local string, err error) {
+	name := func() (string, error) {
+		if p.peek() == '*' && allowWildcard {
+			p.pos++
+			return "*", nil
+		}
+		return p.ident()
+	}
+	anyNS = true
+	if p.peek() == '|' {
+		// |name: no namespace
+		p.pos++
+		anyNS = false
+		local, err = name()
+		return
+	}
+	first := ""
+	if p.peek() == '*' {
+		p.pos++
+		first = "*"
+	} else {
+		first, err = p.ident()
+		if err != nil {
+			return
+		}
+	}
+	if p.peek() != '|' || strings.HasPrefix(p.input[p.pos:], "|=") {
+		if first == "*" && !allowWildcard {
+			err = p.errorf("Identifier expected")
+		}
+		local = first
+		return
+	}
+	p.pos++
+	if first != "*" {
+		anyNS = false
+		if ns, err = p.resolvePrefix(first); err != nil {
+			return
+		}
+	}
+	local, err = name()
+	return
+}
+
+func (p *selectorParser) parseCompound() (*compoundSelector, error) {
+	ret := &compoundSelector{}
+	if r := p.peek(); r == '*' || r == '|' || isIdentStart(r) || r == '-' {
+		anyNS, ns, local, err := p.parseQName(true)
+		if err != nil {
+			return nil, err
+		}
+		ret.typ = &typeSelector{anyNS: anyNS, ns: ns, local: local}
+	}
+	for {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			id, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			ret.conditions = append(ret.conditions, idCondition(id))
+		case '.':
+			p.pos++
+			cls, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			ret.conditions = append(ret.conditions, classCondition(cls))
+		case '[':
+			p.pos++
+			cond, err := p.parseAttr()
+			if err != nil {
+				return nil, err
+			}
+			ret.conditions = append(ret.conditions, cond)
+		case ':':
+			p.pos++
+			cond, err := p.parsePseudo()
+			if err != nil {
+				return nil, err
+			}
+			ret.conditions = append(ret.conditions, cond)
+		default:
+			if ret.typ == nil && len(ret.conditions) == 0 {
+				return nil, p.errorf("Selector expected")
+			}
+			return ret, nil
+		}
+	}
+}
+
+func (p *selectorParser) parseAttr() (selectorCondition, error) {
+	p.skipSpace()
+	start := p.pos
+	anyNS, ns, local, err := p.parseQName(false)
+	if err != nil {
+		return nil, err
+	}
+	// An attribute name without namespace prefix is in no namespace
+	if !strings.ContainsRune(p.input[start:p.pos], '|') {
+		anyNS = false
+	}
+	ret := &attrCondition{anyNS: anyNS, ns: ns, local: local}
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+		return ret, nil
+	}
+	for _, op := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			ret.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if len(ret.op) == 0 {
+		return nil, p.errorf("Attribute operator expected")
+	}
+	p.skipSpace()
+	if r := p.peek(); r == '"' || r == '\'' {
+		ret.value, err = p.str()
+	} else {
+		ret.value, err = p.ident()
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if r := p.peek(); r == 'i' || r == 'I' {
+		p.pos++
+		ret.caseInsensitive = true
+		p.skipSpace()
+	} else if r == 's' || r == 'S' {
+		p.pos++
+		p.skipSpace()
+	}
+	if p.peek() != ']' {
+		return nil, p.errorf("']' expected")
+	}
+	p.pos++
+	return ret, nil
+}
+
+func (p *selectorParser) parsePseudo() (selectorCondition, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(name)
+	switch name {
+	case "root":
+		return rootCondition{}, nil
+	case "empty":
+		return emptyCondition{}, nil
+	case "first-child":
+		return &nthCondition{b: 1}, nil
+	case "last-child":
+		return &nthCondition{b: 1, last: true}, nil
+	case "first-of-type":
+		return &nthCondition{b: 1, ofType: true}, nil
+	case "last-of-type":
+		return &nthCondition{b: 1, last: true, ofType: true}, nil
+	case "only-child":
+		return &isCondition{selectors: selectorList{{compounds: []*compoundSelector{{
+			conditions: []selectorCondition{&nthCondition{b: 1}, &nthCondition{b: 1, last: true}},
+		}}}}}, nil
+	case "only-of-type":
+		return &isCondition{selectors: selectorList{{compounds: []*compoundSelector{{
+			conditions: []selectorCondition{&nthCondition{b: 1, ofType: true}, &nthCondition{b: 1, last: true, ofType: true}},
+		}}}}}, nil
+	}
+	if p.peek() != '(' {
+		return nil, p.errorf("Unknown pseudo-class %s", name)
+	}
+	p.pos++
+	p.skipSpace()
+	var ret selectorCondition
+	switch name {
+	case "not", "is", "where", "matches":
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		if name == "not" {
+			ret = &notCondition{selectors: list}
+		} else {
+			ret = &isCondition{selectors: list}
+		}
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		end := strings.IndexRune(p.input[p.pos:], ')')
+		if end == -1 {
+			return nil, p.errorf("')' expected")
+		}
+		a, b, err := parseNth(p.input[p.pos : p.pos+end])
+		if err != nil {
+			return nil, p.errorf("%s", err.Error())
+		}
+		p.pos += end
+		ret = &nthCondition{a: a, b: b,
+			last:   strings.Contains(name, "last"),
+			ofType: strings.HasSuffix(name, "of-type"),
+		}
+	default:
+		return nil, p.errorf("Unknown pseudo-class %s", name)
+	}
+	p.skipSpace()
+	if p.peek() != ')' {
+		return nil, p.errorf("')' expected")
+	}
+	p.pos++
+	return ret, nil
+}
+
+// parseNth parses the An+B microsyntax
+func parseNth(s string) (int, int, error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+	parseInt := func(str string) (int, error) {
+		str = strings.TrimPrefix(str, "+")
+		if len(str) == 0 || str[0] == '+' {
+			return 0, fmt.Errorf("Invalid nth expression %s", s)
+		}
+		return strconv.Atoi(str)
+	}
+	ix := strings.IndexRune(s, 'n')
+	if ix == -1 {
+		b, err := parseInt(s)
+		return 0, b, err
+	}
+	var a, b int
+	switch coef := s[:ix]; coef {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = parseInt(coef); err != nil {
+			return 0, 0, err
+		}
+	}
+	if rest := s[ix+1:]; len(rest) > 0 {
+		if rest[0] != '+' && rest[0] != '-' {
+			return 0, 0, fmt.Errorf("Invalid nth expression %s", s)
+		}
+		var err error
+		if b, err = parseInt(rest); err != nil {
+			return 0, 0, err
+		}
+	}
+	return a, b, nil
+}
+
+// querySelectorAll returns the descendants of root matching the
+// selector in document order. If first is set, returns after the
+// first match.
+func querySelectorAll(root Node, selector string, first bool, op string) ([]Element, error) {
+	list, err := parseSelectorList(selector, root, op)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Element, 0)
+	var walk func(Node) bool
+	walk = func(node Node) bool {
+		for ch := node.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			el, ok := ch.(Element)
+			if !ok {
+				continue
+			}
+			if list.match(el) {
+				ret = append(ret, el)
+				if first {
+					return true
+				}
+			}
+			if walk(el) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(root)
+	return ret, nil
+}
+
+// staticNodeList is a NodeList that does not change when the DOM
+// changes
+type staticNodeList []Node
+
+func (list staticNodeList) GetLength() int { return len(list) }
+
+func (list staticNodeList) Item(i int) Node {
+	if i < 0 || i >= len(list) {
+		return nil
+	}
+	return list[i]
+}
+
+func querySelector(root Node, selector string) (Element, error) {
+	result, err := querySelectorAll(root, selector, true, "QuerySelector")
+	if err != nil || len(result) == 0 {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+func querySelectorList(root Node, selector string) (NodeList, error) {
+	result, err := querySelectorAll(root, selector, false, "QuerySelectorAll")
+	if err != nil {
+		return nil, err
+	}
+	ret := make(staticNodeList, len(result))
+	for i := range result {
+		ret[i] = result[i]
+	}
+	return ret, nil
+}
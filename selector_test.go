@@ -0,0 +1,106 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestQuerySelector(t *testing.T) {
+	input := `<html xmlns:svg="http://www.w3.org/2000/svg">
+<body>
+<div id="main" class="content wide">
+  <p class="intro" lang="en-US">One</p>
+  <p>Two</p>
+  <span data-x="abc def">Three</span>
+  <p class="outro">Four</p>
+</div>
+<ul><li>1</li><li>2</li><li>3</li><li>4</li><li>5</li></ul>
+<svg:svg><svg:rect width="10"/></svg:svg>
+<empty/>
+</body>
+</html>`
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	describe := func(list NodeList) string {
+		s := make([]string, 0)
+		for i := 0; i < list.GetLength(); i++ {
+			el := list.Item(i).(Element)
+			text := ""
+			if ch, ok := el.GetFirstChild().(Text); ok && len(strings.TrimSpace(ch.GetValue())) > 0 {
+				text = "(" + ch.GetValue() + ")"
+			}
+			s = append(s, el.GetTagName()+text)
+		}
+		return strings.Join(s, ",")
+	}
+	tests := map[string]string{
+		"p":                             "p(One),p(Two),p(Four)",
+		"#main > p.intro":               "p(One)",
+		"div .outro":                    "p(Four)",
+		".content.wide":                 "div",
+		"p + p":                         "p(Two)",
+		"p.intro ~ p":                   "p(Two),p(Four)",
+		"p:not(.intro, .outro)":         "p(Two)",
+		"[lang|=en]":                    "p(One)",
+		"[data-x~=def]":                 "span(Three)",
+		"[data-x^='ab']":                "span(Three)",
+		"[data-x$=\"ef\"]":              "span(Three)",
+		"[data-x*=c]":                   "span(Three)",
+		"[class=INTRO i]":               "p(One)",
+		"li:nth-child(2n+1)":            "li(1),li(3),li(5)",
+		"li:nth-child(even)":            "li(2),li(4)",
+		"li:nth-last-child(-n+2)":       "li(4),li(5)",
+		"li:first-child, li:last-child": "li(1),li(5)",
+		"div > :nth-of-type(2)":         "p(Two)",
+		"div > span:only-of-type":       "span(Three)",
+		"div > p:last-of-type":          "p(Four)",
+		"svg|rect":                      "svg:rect",
+		"*|rect":                        "svg:rect",
+		"|rect":                         "",
+		"rect[width='10']":              "svg:rect",
+		"svg|*":                         "svg:svg,svg:rect",
+		":root":                         "html",
+		"body > :empty":                 "empty",
+		"ul li:is(:first-child)":        "li(1)",
+	}
+	for sel, expected := range tests {
+		list, err := doc.QuerySelectorAll(sel)
+		if err != nil {
+			t.Errorf("%s: %v", sel, err)
+			continue
+		}
+		if got := describe(list); got != expected {
+			t.Errorf("%s: expected %s, got %s", sel, expected, got)
+		}
+	}
+
+	div, err := doc.QuerySelector("div")
+	if err != nil || div == nil {
+		t.Fatalf("div not found: %v", err)
+	}
+	p, err := div.QuerySelector("p:nth-child(2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GetFirstChild().(Text).GetValue() != "Two" {
+		t.Errorf("Wrong element")
+	}
+	// Ancestors outside the scope element take part in matching
+	list, _ := div.QuerySelectorAll("body p")
+	if list.GetLength() != 3 {
+		t.Errorf("Wrong length: %d", list.GetLength())
+	}
+	if el, _ := div.QuerySelector("li"); el != nil {
+		t.Errorf("Found element outside scope")
+	}
+
+	for _, sel := range []string{"", "p >", "p[", "p:unknown", "[a=]", "x|p", "li:nth-child(n+)", "p,,p"} {
+		_, err := doc.QuerySelectorAll(sel)
+		if err == nil {
+			t.Errorf("%s: expected error", sel)
+		}
+	}
+}
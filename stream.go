@@ -0,0 +1,135 @@
+package dom
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// EventType is the type of a parse event
+type EventType int
+
+const (
+	// Start tag of an element. The event node is the element with
+	// its attributes, but without its children.
+	StartElementEvent EventType = iota
+	// End tag of an element. The event node is the same element as
+	// the StartElementEvent.
+	EndElementEvent
+	// Text or CDATA section
+	TextEvent
+	CommentEvent
+	ProcessingInstructionEvent
+	DocumentTypeEvent
+)
+
+// Event is a parse event. The event nodes are not attached to a
+// tree, so an element only knows the namespaces it declares itself,
+// but the names of the elements and attributes are resolved using
+// the namespaces in scope.
+type Event struct {
+	Type EventType
+	Node Node
+	// Depth is the number of elements enclosing the node. The
+	// document element has depth 0.
+	Depth int
+	// Position of the token in the input
+	Position Position
+}
+
+// StreamReader reads an XML document as a sequence of parse events
+// without keeping the document in memory. Selected elements can be
+// read as a whole using Expand.
+type StreamReader struct {
+	parser    *parser
+	last      Event
+	rootSeen  bool
+	rootEnded bool
+}
+
+// NewStreamReader returns a StreamReader that reads the XML document
+// using the decoder.
+func NewStreamReader(decoder *xml.Decoder) *StreamReader {
+	return &StreamReader{parser: newParser(decoder, ParseOptions{}, nil)}
+}
+
+// NewStreamReaderOptions returns a StreamReader that reads the XML
+// document from r using the given options.
+func NewStreamReaderOptions(r io.Reader, options ParseOptions) *StreamReader {
+	raw := &rawInput{r: r}
+	decoder := xml.NewDecoder(raw)
+	if options.Configure != nil {
+		options.Configure(decoder)
+	}
+	return &StreamReader{parser: newParser(decoder, options, raw)}
+}
+
+// GetOwnerDocument returns the document that owns the nodes returned
+// by the reader. The nodes are not added to the document.
+func (s *StreamReader) GetOwnerDocument() Document {
+	return s.parser.doc
+}
+
+// Next returns the next parse event. It returns io.EOF at the end of
+// the input.
+func (s *StreamReader) Next() (Event, error) {
+	event, err := s.parser.next()
+	if err != nil {
+		s.last = Event{}
+		return event, err
+	}
+	if event.Type == StartElementEvent && event.Depth == 0 {
+		if s.rootSeen {
+			s.last = Event{}
+			return Event{}, s.parser.syntaxError("Multiple document elements")
+		}
+		s.rootSeen = true
+	}
+	s.last = event
+	return event, nil
+}
+
+// readSubtree reads the events until the end of the element of the
+// last StartElementEvent, and passes them to fn
+func (s *StreamReader) readSubtree(op string, fn func(Event) error) error {
+	if s.last.Type != StartElementEvent || s.last.Node == nil {
+		return ErrDOM{
+			Typ: INVALID_STATE_ERR,
+			Msg: "Not at the start of an element",
+			Op:  op,
+		}
+	}
+	start := s.last
+	for {
+		event, err := s.Next()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if event.Type == EndElementEvent && event.Node == start.Node {
+			return nil
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// Expand reads the element of the last StartElementEvent with all
+// its descendants, and returns it. The element is not attached to a
+// tree. The EndElementEvent of the element is consumed.
+func (s *StreamReader) Expand() (Element, error) {
+	el, _ := s.last.Node.(Element)
+	builder := treeBuilder{parent: el}
+	if err := s.readSubtree("Expand", builder.add); err != nil {
+		return nil, err
+	}
+	return el, nil
+}
+
+// Skip skips the contents of the element of the last
+// StartElementEvent. The EndElementEvent of the element is consumed.
+func (s *StreamReader) Skip() error {
+	return s.readSubtree("Skip", func(Event) error { return nil })
+}
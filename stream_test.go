@@ -0,0 +1,112 @@
+package dom
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamExpand(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<feed xmlns="urn:feed" xmlns:x="urn:x">
+<record id="1"><name x:lang="en">One</name><!--c--></record>
+<skip><record id="nested"/></skip>
+<record id="2"><name>Two</name></record>
+</feed>`
+	reader := NewStreamReader(xml.NewDecoder(strings.NewReader(input)))
+	records := make([]Element, 0)
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != StartElementEvent {
+			continue
+		}
+		el := event.Node.(Element)
+		switch el.GetLocalName() {
+		case "record":
+			if event.Depth != 1 {
+				t.Errorf("Wrong depth: %d", event.Depth)
+			}
+			rec, err := reader.Expand()
+			if err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, rec)
+		case "skip":
+			if err := reader.Skip(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	rec := records[0]
+	if rec.GetParentNode() != nil || rec.GetQName().Space != "urn:feed" {
+		t.Errorf("Wrong record: %v %s", rec.GetParentNode(), rec.GetQName().Space)
+	}
+	name := rec.GetFirstElementChild()
+	if name.GetQName().Space != "urn:feed" || name.GetAttributeNodeNS("urn:x", "lang") == nil {
+		t.Errorf("Namespaces not resolved")
+	}
+	if s := encodeString(t, records[1]); s != `<record id="2"><name>Two</name></record>` {
+		t.Errorf("Wrong record: %s", s)
+	}
+	if rec.GetOwnerDocument() != reader.GetOwnerDocument() || reader.GetOwnerDocument().GetDocumentElement() != nil {
+		t.Errorf("Wrong owner document")
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	input := `<!DOCTYPE a><?pi x?><a>text<![CDATA[cdata]]><b/></a>`
+	reader := NewStreamReaderOptions(strings.NewReader(input), ParseOptions{KeepCDATASections: true})
+	types := make([]EventType, 0)
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, event.Type)
+		if event.Type == TextEvent && event.Node.GetNodeType() == CDATA_SECTION_NODE && event.Node.(CDATASection).GetValue() != "cdata" {
+			t.Errorf("Wrong CDATA")
+		}
+	}
+	expected := []EventType{DocumentTypeEvent, ProcessingInstructionEvent, StartElementEvent, TextEvent, TextEvent, StartElementEvent, EndElementEvent, EndElementEvent}
+	if len(types) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, types)
+	}
+	for i := range types {
+		if types[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, types)
+		}
+	}
+	if _, err := reader.Expand(); err == nil {
+		t.Errorf("Expand should fail at the end")
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	for _, input := range []string{`<a></a><b></b>`, `<a><b></a>`, `<a><b>`} {
+		reader := NewStreamReader(xml.NewDecoder(strings.NewReader(input)))
+		var err error
+		for err == nil {
+			var event Event
+			event, err = reader.Next()
+			if err == nil && event.Type == StartElementEvent && event.Depth == 1 {
+				_, err = reader.Expand()
+			}
+		}
+		if err == io.EOF {
+			t.Errorf("%s: Error expected", input)
+		}
+	}
+}
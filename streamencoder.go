@@ -0,0 +1,350 @@
+package dom
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// EncoderAttr is an attribute of an element written by an Encoder
+type EncoderAttr struct {
+	Name  Name
+	Value string
+}
+
+// nsScope keeps the namespace declarations of an open element
+type nsScope struct {
+	parent        *nsScope
+	name          Name
+	definedPrefix map[string]string
+	defaultNS     string
+	hasDefault    bool
+}
+
+func (s *nsScope) lookupPrefix(prefix string) (string, bool) {
+	for trc := s; trc != nil; trc = trc.parent {
+		if ns, ok := trc.definedPrefix[prefix]; ok {
+			return ns, true
+		}
+	}
+	return "", false
+}
+
+func (s *nsScope) lookupDefault() string {
+	for trc := s; trc != nil; trc = trc.parent {
+		if trc.hasDefault {
+			return trc.defaultNS
+		}
+	}
+	return ""
+}
+
+// findPrefix returns a prefix bound to the namespace
+func (s *nsScope) findPrefix(ns string) (string, bool) {
+	for trc := s; trc != nil; trc = trc.parent {
+		for prefix, uri := range trc.definedPrefix {
+			if uri != ns {
+				continue
+			}
+			// The prefix may be redefined by a descendant
+			if found, _ := s.lookupPrefix(prefix); found == ns {
+				return prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (s *nsScope) define(prefix, ns string) {
+	if s.definedPrefix == nil {
+		s.definedPrefix = make(map[string]string)
+	}
+	s.definedPrefix[prefix] = ns
+}
+
+// Encoder writes an XML document incrementally. Namespace
+// declarations are added to the start tags as necessary, so the
+// output is namespace well-formed.
+type Encoder struct {
+	enc      encoder
+	doc      *BasicDocument
+	top      *nsScope
+	depth    int
+	rootSeen bool
+	uniqueNS int
+	// If set, prefixes without a namespace are written as they are,
+	// like Encode does, instead of failing
+	lenient bool
+}
+
+// NewEncoder returns a new encoder writing to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		enc: encoder{out: bufio.NewWriter(w), options: EncodeOptions{Newline: "\n"}},
+		doc: NewDocument().(*BasicDocument),
+	}
+}
+
+func encoderError(typ, op, msg string) error {
+	return ErrDOM{Typ: typ, Msg: msg, Op: op}
+}
+
+// uniquePrefix returns a prefix that is not defined in scope
+func (e *Encoder) uniquePrefix(scope *nsScope) string {
+	for i := e.uniqueNS; ; i++ {
+		prefix := fmt.Sprintf("ns%d", i)
+		if _, exists := scope.lookupPrefix(prefix); !exists {
+			e.uniqueNS = i + 1
+			return prefix
+		}
+	}
+}
+
+// isNamespaceDeclaration returns true if the name is an xmlns or
+// xmlns:prefix attribute name
+func isNamespaceDeclaration(name Name) bool {
+	return name.Prefix == xmlnsPrefix || (len(name.Prefix) == 0 && name.Local == xmlnsPrefix)
+}
+
+// StartElement writes the start tag of an element. If name has a
+// namespace but no prefix, the element is written in the default
+// namespace. Attributes with a namespace but no prefix are written
+// using a prefix bound to the namespace, or a new prefix.
+func (e *Encoder) StartElement(name Name, attrs ...EncoderAttr) error {
+	const op = "StartElement"
+	if e.depth == 0 && e.rootSeen {
+		return encoderError(HIERARCHY_REQUEST_ERR, op, "Multiple document elements")
+	}
+	scope := &nsScope{parent: e.top}
+	decls := make([]EncoderAttr, 0)
+	declare := func(prefix, ns string) error {
+		if len(prefix) == 0 {
+			if scope.hasDefault {
+				return encoderError(NAMESPACE_ERR, op, "Inconsistent default namespace")
+			}
+			scope.defaultNS, scope.hasDefault = ns, true
+			decls = append(decls, EncoderAttr{Name: Name{Name: xml.Name{Local: xmlnsPrefix}}, Value: ns})
+			return nil
+		}
+		if _, exists := scope.definedPrefix[prefix]; exists {
+			return encoderError(NAMESPACE_ERR, op, fmt.Sprintf("Inconsistent prefix %s", prefix))
+		}
+		scope.define(prefix, ns)
+		decls = append(decls, EncoderAttr{Name: Name{Name: xml.Name{Space: xmlnsURL, Local: prefix}, Prefix: xmlnsPrefix}, Value: ns})
+		return nil
+	}
+	// Explicit namespace declarations
+	others := make([]EncoderAttr, 0, len(attrs))
+	for _, attr := range attrs {
+		if !isNamespaceDeclaration(attr.Name) {
+			others = append(others, attr)
+			continue
+		}
+		prefix := attr.Name.Local
+		if attr.Name.Prefix != xmlnsPrefix {
+			prefix = ""
+		}
+		if err := declare(prefix, attr.Value); err != nil {
+			return err
+		}
+	}
+	// resolve returns the name to write, adding declarations if
+	// necessary
+	resolve := func(n Name, isAttr bool) (Name, error) {
+		switch {
+		case n.Prefix == xmlPrefix || (len(n.Prefix) == 0 && n.Space == xmlURL):
+			n.Prefix = xmlPrefix
+		case len(n.Space) > 0 && len(n.Prefix) > 0:
+			if ns, exists := scope.lookupPrefix(n.Prefix); !exists || ns != n.Space {
+				if err := declare(n.Prefix, n.Space); err != nil {
+					return n, err
+				}
+			}
+		case len(n.Space) > 0 && isAttr:
+			if prefix, ok := scope.findPrefix(n.Space); ok {
+				n.Prefix = prefix
+			} else {
+				n.Prefix = e.uniquePrefix(scope)
+				if err := declare(n.Prefix, n.Space); err != nil {
+					return n, err
+				}
+			}
+		case len(n.Space) > 0:
+			if scope.lookupDefault() != n.Space {
+				if err := declare("", n.Space); err != nil {
+					return n, err
+				}
+			}
+		case len(n.Prefix) > 0:
+			ns, exists := scope.lookupPrefix(n.Prefix)
+			if !exists && e.lenient {
+				return n, nil
+			}
+			if !exists {
+				return n, encoderError(NAMESPACE_ERR, op, fmt.Sprintf("No namespace for prefix %s", n.Prefix))
+			}
+			n.Space = ns
+		case !isAttr:
+			if len(scope.lookupDefault()) > 0 {
+				if err := declare("", ""); err != nil {
+					return n, err
+				}
+			}
+		}
+		return n, nil
+	}
+	elName, err := resolve(name, false)
+	if err != nil {
+		return err
+	}
+	for i := range others {
+		if others[i].Name, err = resolve(others[i].Name, true); err != nil {
+			return err
+		}
+	}
+	scope.name = elName
+
+	out := e.enc.out
+	if _, err := out.WriteRune('<'); err != nil {
+		return err
+	}
+	if err := e.enc.writeName(elName); err != nil {
+		return err
+	}
+	for _, attr := range append(decls, others...) {
+		if _, err := out.WriteRune(' '); err != nil {
+			return err
+		}
+		if err := e.enc.writeName(attr.Name); err != nil {
+			return err
+		}
+		if _, err := out.WriteRune('='); err != nil {
+			return err
+		}
+		if err := e.enc.writeAttrValue(attr.Value); err != nil {
+			return err
+		}
+	}
+	if _, err := out.WriteRune('>'); err != nil {
+		return err
+	}
+	e.top = scope
+	e.depth++
+	e.rootSeen = true
+	return nil
+}
+
+// EndElement writes the end tag of the innermost open element
+func (e *Encoder) EndElement() error {
+	if e.top == nil {
+		return encoderError(INVALID_STATE_ERR, "EndElement", "No open element")
+	}
+	out := e.enc.out
+	if _, err := out.WriteString("</"); err != nil {
+		return err
+	}
+	if err := e.enc.writeName(e.top.name); err != nil {
+		return err
+	}
+	if _, err := out.WriteRune('>'); err != nil {
+		return err
+	}
+	e.top = e.top.parent
+	e.depth--
+	return nil
+}
+
+// Text writes escaped character data
+func (e *Encoder) Text(text string) error {
+	if e.depth == 0 {
+		if !isWhitespace(text) {
+			return encoderError(HIERARCHY_REQUEST_ERR, "Text", "Text outside the document element")
+		}
+	}
+	return e.enc.writeCharData(text)
+}
+
+// CDATA writes a CDATA section
+func (e *Encoder) CDATA(text string) error {
+	if e.depth == 0 {
+		return encoderError(HIERARCHY_REQUEST_ERR, "CDATA", "CDATA section outside the document element")
+	}
+	return e.enc.encode(e.doc.CreateCDATASection(text), e.depth, false, false)
+}
+
+// Comment writes a comment
+func (e *Encoder) Comment(text string) error {
+	return e.enc.encode(e.doc.CreateComment(text), e.depth, false, false)
+}
+
+// PI writes a processing instruction
+func (e *Encoder) PI(target, data string) error {
+	return e.enc.encode(e.doc.CreateProcessingInstruction(target, data), e.depth, false, false)
+}
+
+// WriteNode writes the node and its descendants at the current
+// position. Namespace declarations are added if the names in the
+// subtree use namespaces that are not declared in the output.
+func (e *Encoder) WriteNode(node Node) error {
+	switch node.GetNodeType() {
+	case DOCUMENT_NODE, DOCUMENT_FRAGMENT_NODE:
+		if doc, ok := node.(*BasicDocument); ok && !e.rootSeen {
+			if err := e.enc.writeXMLDeclaration(doc); err != nil {
+				return err
+			}
+		}
+		for ch := node.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			if err := e.WriteNode(ch); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ELEMENT_NODE:
+		el := node.(Element)
+		attrs := el.GetAttributes()
+		list := make([]EncoderAttr, 0, attrs.GetLength())
+		for i := 0; i < attrs.GetLength(); i++ {
+			list = append(list, EncoderAttr{Name: attrs.Item(i).GetQName(), Value: attrs.Item(i).GetValue()})
+		}
+		if err := e.StartElement(el.GetQName(), list...); err != nil {
+			return err
+		}
+		for ch := el.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			if err := e.WriteNode(ch); err != nil {
+				return err
+			}
+		}
+		return e.EndElement()
+
+	case TEXT_NODE:
+		return e.Text(node.(Text).GetValue())
+
+	case CDATA_SECTION_NODE:
+		return e.CDATA(node.(CDATASection).GetValue())
+
+	case DOCUMENT_TYPE_NODE:
+		if e.rootSeen {
+			return encoderError(HIERARCHY_REQUEST_ERR, "WriteNode", "Document type after the document element")
+		}
+	}
+	return e.enc.encode(node, e.depth, false, false)
+}
+
+// Flush writes any buffered data to the underlying writer
+func (e *Encoder) Flush() error {
+	return e.enc.out.Flush()
+}
+
+// Close checks that all elements are closed, and flushes the
+// output
+func (e *Encoder) Close() error {
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	if e.top != nil {
+		return encoderError(INVALID_STATE_ERR, "Close", fmt.Sprintf("Unclosed element %s", e.top.name.QName()))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package dom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<p:item xmlns:p="urn:p"><p:sub q="1">x</p:sub></p:item>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := doc.GetDocumentElement().GetFirstElementChild()
+
+	buf := bytes.Buffer{}
+	enc := NewEncoder(&buf)
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(enc.PI("xml", `version="1.0"`))
+	must(enc.StartElement(Name{Name: xml.Name{Space: "urn:a", Local: "root"}}))
+	must(enc.StartElement(Name{Name: xml.Name{Space: "urn:b", Local: "x"}, Prefix: "b"},
+		EncoderAttr{Name: Name{Name: xml.Name{Space: "urn:c", Local: "at"}}, Value: `"v"`},
+		EncoderAttr{Name: Name{Name: xml.Name{Space: "urn:b", Local: "at"}}, Value: "w"}))
+	must(enc.Text("a < b"))
+	must(enc.EndElement())
+	must(enc.StartElement(Name{Name: xml.Name{Local: "plain"}}))
+	must(enc.StartElement(Name{Name: xml.Name{Local: "inner"}}))
+	must(enc.EndElement())
+	must(enc.EndElement())
+	must(enc.WriteNode(sub))
+	must(enc.Comment("c"))
+	must(enc.CDATA("d"))
+	must(enc.EndElement())
+	must(enc.Close())
+
+	expected := `<?xml version="1.0"?><root xmlns="urn:a"><b:x xmlns:b="urn:b" xmlns:ns0="urn:c" ns0:at="&#34;v&#34;" b:at="w">a &lt; b</b:x><plain xmlns=""><inner></inner></plain><p:sub xmlns:p="urn:p" q="1">x</p:sub><!--c--><![CDATA[d]]></root>`
+	if buf.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, buf.String())
+	}
+	if _, err := Parse(xml.NewDecoder(strings.NewReader(buf.String()))); err != nil {
+		t.Errorf("Output cannot be parsed: %v", err)
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	enc := NewEncoder(&bytes.Buffer{})
+	if err := enc.EndElement(); err == nil {
+		t.Errorf("EndElement without StartElement should fail")
+	}
+	if err := enc.StartElement(Name{Name: xml.Name{Local: "x"}, Prefix: "p"}); err == nil {
+		t.Errorf("Undeclared prefix should fail")
+	}
+	if err := enc.StartElement(Name{Name: xml.Name{Space: "urn:b", Local: "x"}, Prefix: "p"},
+		EncoderAttr{Name: Name{Name: xml.Name{Space: xmlnsURL, Local: "p"}, Prefix: xmlnsPrefix}, Value: "urn:a"}); err == nil {
+		t.Errorf("Inconsistent prefix should fail")
+	}
+	if err := enc.Text("text"); err == nil {
+		t.Errorf("Text outside document element should fail")
+	}
+	if err := enc.StartElement(Name{Name: xml.Name{Local: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err == nil {
+		t.Errorf("Close with open element should fail")
+	}
+	enc.EndElement()
+	if err := enc.StartElement(Name{Name: xml.Name{Local: "b"}}); err == nil {
+		t.Errorf("Second document element should fail")
+	}
+}
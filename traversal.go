@@ -0,0 +1,464 @@
+package dom
+
+// WhatToShow bits select the node types visited by NodeIterators
+// and TreeWalkers. The bit of a node type is 1 << (type-1).
+const (
+	SHOW_ALL                    uint32 = 0xFFFFFFFF
+	SHOW_ELEMENT                uint32 = 0x1
+	SHOW_ATTRIBUTE              uint32 = 0x2
+	SHOW_TEXT                   uint32 = 0x4
+	SHOW_CDATA_SECTION          uint32 = 0x8
+	SHOW_PROCESSING_INSTRUCTION uint32 = 0x40
+	SHOW_COMMENT                uint32 = 0x80
+	SHOW_DOCUMENT               uint32 = 0x100
+	SHOW_DOCUMENT_TYPE          uint32 = 0x200
+	SHOW_DOCUMENT_FRAGMENT      uint32 = 0x400
+)
+
+// FilterResult is the result of a NodeFilter
+type FilterResult int
+
+const (
+	// The node is visited
+	FILTER_ACCEPT FilterResult = 1
+	// The node and its descendants are not visited by a TreeWalker.
+	// For a NodeIterator, this is the same as FILTER_SKIP.
+	FILTER_REJECT FilterResult = 2
+	// The node is not visited, but its descendants are
+	FILTER_SKIP FilterResult = 3
+)
+
+// NodeFilter decides whether a node is visited by a NodeIterator or
+// TreeWalker. It is only called for the nodes selected by
+// whatToShow.
+type NodeFilter func(Node) FilterResult
+
+// NodeIterator iterates over the nodes of a subtree in document
+// order
+type NodeIterator interface {
+	// Returns the root of the iterated subtree
+	GetRoot() Node
+
+	// Returns the node the iterator is anchored to
+	GetReferenceNode() Node
+
+	// Returns true if the iterator is anchored before the reference
+	// node, and false if it is anchored after it
+	GetPointerBeforeReferenceNode() bool
+
+	// Returns the node types visited by the iterator
+	GetWhatToShow() uint32
+
+	// Returns the next node in document order, or nil if there is
+	// none
+	NextNode() Node
+
+	// Returns the previous node in document order, or nil if there is
+	// none
+	PreviousNode() Node
+
+	// Stops tracking the removal of nodes from the document. The
+	// iterator should not be used after Detach. Iterators are
+	// referenced weakly by the document when built with Go 1.24 or
+	// later. With older versions, an iterator that is not detached
+	// is kept, and updated on every removal, until the document is
+	// freed.
+	Detach()
+}
+
+// TreeWalker navigates the nodes of a subtree
+type TreeWalker interface {
+	// Returns the root of the subtree
+	GetRoot() Node
+
+	// Returns the node types visited by the walker
+	GetWhatToShow() uint32
+
+	// Returns the node the walker is positioned at
+	GetCurrentNode() Node
+
+	// Sets the node the walker is positioned at
+	SetCurrentNode(Node)
+
+	// Moves to the closest visible ancestor of the current node, and
+	// returns it. Returns nil if there is none.
+	ParentNode() Node
+
+	// Moves to the first visible child of the current node, and
+	// returns it. Returns nil if there is none.
+	FirstChild() Node
+
+	// Moves to the last visible child of the current node, and
+	// returns it. Returns nil if there is none.
+	LastChild() Node
+
+	// Moves to the previous visible sibling of the current node, and
+	// returns it. Returns nil if there is none.
+	PreviousSibling() Node
+
+	// Moves to the next visible sibling of the current node, and
+	// returns it. Returns nil if there is none.
+	NextSibling() Node
+
+	// Moves to the previous visible node in document order, and
+	// returns it. Returns nil if there is none.
+	PreviousNode() Node
+
+	// Moves to the next visible node in document order, and returns
+	// it. Returns nil if there is none.
+	NextNode() Node
+}
+
+// traversal implements the filtering common to NodeIterator and
+// TreeWalker
+type traversal struct {
+	root       Node
+	whatToShow uint32
+	filter     NodeFilter
+	active     bool
+}
+
+func (t *traversal) GetRoot() Node { return t.root }
+
+func (t *traversal) GetWhatToShow() uint32 { return t.whatToShow }
+
+// filterNode returns the filter result for node
+func (t *traversal) filterNode(node Node) FilterResult {
+	if t.active {
+		panic(ErrDOM{
+			Typ: INVALID_STATE_ERR,
+			Msg: "Recursive traversal from a node filter",
+			Op:  "Filter",
+		})
+	}
+	if t.whatToShow&(1<<(node.GetNodeType()-1)) == 0 {
+		return FILTER_SKIP
+	}
+	if t.filter == nil {
+		return FILTER_ACCEPT
+	}
+	t.active = true
+	defer func() { t.active = false }()
+	return t.filter(node)
+}
+
+// lastDescendant returns the inclusive descendant of node that is
+// last in document order
+func lastDescendant(node Node) Node {
+	for child := node.GetLastChild(); child != nil; child = node.GetLastChild() {
+		node = child
+	}
+	return node
+}
+
+// prevInSubtree returns the node preceding node in document order
+// that is under root, or nil
+func prevInSubtree(root, node Node) Node {
+	if node == root {
+		return nil
+	}
+	if prev := node.GetPreviousSibling(); prev != nil {
+		return lastDescendant(prev)
+	}
+	return node.GetParentNode()
+}
+
+// BasicNodeIterator implements NodeIterator
+type BasicNodeIterator struct {
+	traversal
+	reference              Node
+	pointerBeforeReference bool
+	doc                    *BasicDocument
+}
+
+var _ NodeIterator = &BasicNodeIterator{}
+
+func newNodeIterator(root Node, whatToShow uint32, filter NodeFilter) *BasicNodeIterator {
+	it := &BasicNodeIterator{
+		traversal:              traversal{root: root, whatToShow: whatToShow, filter: filter},
+		reference:              root,
+		pointerBeforeReference: true,
+	}
+	if doc, _ := root.GetOwnerDocument().(*BasicDocument); doc != nil {
+		it.doc = doc
+		doc.iterators.add(it)
+	}
+	return it
+}
+
+func (it *BasicNodeIterator) GetReferenceNode() Node { return it.reference }
+
+func (it *BasicNodeIterator) GetPointerBeforeReferenceNode() bool {
+	return it.pointerBeforeReference
+}
+
+func (it *BasicNodeIterator) traverse(next bool) Node {
+	node := it.reference
+	beforeNode := it.pointerBeforeReference
+	for {
+		if next {
+			if !beforeNode {
+				node = nextInSubtree(it.root, node)
+				if node == nil {
+					return nil
+				}
+			} else {
+				beforeNode = false
+			}
+		} else {
+			if beforeNode {
+				node = prevInSubtree(it.root, node)
+				if node == nil {
+					return nil
+				}
+			} else {
+				beforeNode = true
+			}
+		}
+		if it.filterNode(node) == FILTER_ACCEPT {
+			break
+		}
+	}
+	it.reference = node
+	it.pointerBeforeReference = beforeNode
+	return node
+}
+
+// Returns the next node in document order, or nil if there is none
+func (it *BasicNodeIterator) NextNode() Node { return it.traverse(true) }
+
+// Returns the previous node in document order, or nil if there is
+// none
+func (it *BasicNodeIterator) PreviousNode() Node { return it.traverse(false) }
+
+// Stops tracking the removal of nodes from the document
+func (it *BasicNodeIterator) Detach() {
+	if it.doc != nil {
+		it.doc.iterators.remove(it)
+		it.doc = nil
+	}
+}
+
+// removing adjusts the iterator before node is removed from the
+// tree
+func (it *BasicNodeIterator) removing(node Node) {
+	if node == it.root || node.Contains(it.root) || !node.Contains(it.reference) {
+		return
+	}
+	if it.pointerBeforeReference {
+		// Move to the node following the removed subtree
+		for trc := node; trc != nil && trc != it.root; trc = trc.GetParentNode() {
+			if next := trc.GetNextSibling(); next != nil {
+				it.reference = next
+				return
+			}
+		}
+		it.pointerBeforeReference = false
+	}
+	if prev := node.GetPreviousSibling(); prev != nil {
+		it.reference = lastDescendant(prev)
+	} else {
+		it.reference = node.GetParentNode()
+	}
+}
+
+// iteratorsRemoving adjusts the node iterators of the document of
+// node before node is removed from the tree
+func iteratorsRemoving(node Node) {
+	doc, _ := node.GetOwnerDocument().(*BasicDocument)
+	if doc == nil {
+		return
+	}
+	doc.iterators.each(func(it *BasicNodeIterator) {
+		it.removing(node)
+	})
+}
+
+// BasicTreeWalker implements TreeWalker
+type BasicTreeWalker struct {
+	traversal
+	current Node
+}
+
+var _ TreeWalker = &BasicTreeWalker{}
+
+func newTreeWalker(root Node, whatToShow uint32, filter NodeFilter) *BasicTreeWalker {
+	return &BasicTreeWalker{
+		traversal: traversal{root: root, whatToShow: whatToShow, filter: filter},
+		current:   root,
+	}
+}
+
+func (w *BasicTreeWalker) GetCurrentNode() Node { return w.current }
+
+func (w *BasicTreeWalker) SetCurrentNode(node Node) { w.current = node }
+
+// Moves to the closest visible ancestor of the current node
+func (w *BasicTreeWalker) ParentNode() Node {
+	for node := w.current; node != nil && node != w.root; {
+		node = node.GetParentNode()
+		if node != nil && w.filterNode(node) == FILTER_ACCEPT {
+			w.current = node
+			return node
+		}
+	}
+	return nil
+}
+
+func (w *BasicTreeWalker) traverseChildren(first bool) Node {
+	firstOf := func(node Node) Node {
+		if first {
+			return node.GetFirstChild()
+		}
+		return node.GetLastChild()
+	}
+	siblingOf := func(node Node) Node {
+		if first {
+			return node.GetNextSibling()
+		}
+		return node.GetPreviousSibling()
+	}
+	node := firstOf(w.current)
+	for node != nil {
+		switch w.filterNode(node) {
+		case FILTER_ACCEPT:
+			w.current = node
+			return node
+		case FILTER_SKIP:
+			if child := firstOf(node); child != nil {
+				node = child
+				continue
+			}
+		}
+		for node != nil {
+			if sibling := siblingOf(node); sibling != nil {
+				node = sibling
+				break
+			}
+			parent := node.GetParentNode()
+			if parent == nil || parent == w.root || parent == w.current {
+				return nil
+			}
+			node = parent
+		}
+	}
+	return nil
+}
+
+// Moves to the first visible child of the current node
+func (w *BasicTreeWalker) FirstChild() Node { return w.traverseChildren(true) }
+
+// Moves to the last visible child of the current node
+func (w *BasicTreeWalker) LastChild() Node { return w.traverseChildren(false) }
+
+func (w *BasicTreeWalker) traverseSiblings(next bool) Node {
+	siblingOf := func(node Node) Node {
+		if next {
+			return node.GetNextSibling()
+		}
+		return node.GetPreviousSibling()
+	}
+	firstOf := func(node Node) Node {
+		if next {
+			return node.GetFirstChild()
+		}
+		return node.GetLastChild()
+	}
+	node := w.current
+	if node == w.root {
+		return nil
+	}
+	for {
+		sibling := siblingOf(node)
+		for sibling != nil {
+			node = sibling
+			result := w.filterNode(node)
+			if result == FILTER_ACCEPT {
+				w.current = node
+				return node
+			}
+			sibling = firstOf(node)
+			if result == FILTER_REJECT || sibling == nil {
+				sibling = siblingOf(node)
+			}
+		}
+		node = node.GetParentNode()
+		if node == nil || node == w.root {
+			return nil
+		}
+		if w.filterNode(node) == FILTER_ACCEPT {
+			return nil
+		}
+	}
+}
+
+// Moves to the next visible sibling of the current node
+func (w *BasicTreeWalker) NextSibling() Node { return w.traverseSiblings(true) }
+
+// Moves to the previous visible sibling of the current node
+func (w *BasicTreeWalker) PreviousSibling() Node { return w.traverseSiblings(false) }
+
+// Moves to the previous visible node in document order
+func (w *BasicTreeWalker) PreviousNode() Node {
+	node := w.current
+	for node != w.root {
+		sibling := node.GetPreviousSibling()
+		for sibling != nil {
+			node = sibling
+			result := w.filterNode(node)
+			for result != FILTER_REJECT && node.HasChildNodes() {
+				node = node.GetLastChild()
+				result = w.filterNode(node)
+			}
+			if result == FILTER_ACCEPT {
+				w.current = node
+				return node
+			}
+			sibling = node.GetPreviousSibling()
+		}
+		parent := node.GetParentNode()
+		if node == w.root || parent == nil {
+			return nil
+		}
+		node = parent
+		if w.filterNode(node) == FILTER_ACCEPT {
+			w.current = node
+			return node
+		}
+	}
+	return nil
+}
+
+// Moves to the next visible node in document order
+func (w *BasicTreeWalker) NextNode() Node {
+	node := w.current
+	result := FILTER_ACCEPT
+	for {
+		for result != FILTER_REJECT && node.HasChildNodes() {
+			node = node.GetFirstChild()
+			result = w.filterNode(node)
+			if result == FILTER_ACCEPT {
+				w.current = node
+				return node
+			}
+		}
+		var sibling Node
+		for temporary := node; temporary != nil; temporary = temporary.GetParentNode() {
+			if temporary == w.root {
+				return nil
+			}
+			if sibling = temporary.GetNextSibling(); sibling != nil {
+				break
+			}
+		}
+		if sibling == nil {
+			return nil
+		}
+		node = sibling
+		result = w.filterNode(node)
+		if result == FILTER_ACCEPT {
+			w.current = node
+			return node
+		}
+	}
+}
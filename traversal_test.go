@@ -0,0 +1,137 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func traversalTestDoc(t *testing.T) Document {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<a><b><c/>t1<d/></b><e>t2<f/></e><g/></a>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func nodeNames(nodes []Node) string {
+	s := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if n == nil {
+			s = append(s, "nil")
+			continue
+		}
+		if t, ok := n.(Text); ok {
+			s = append(s, t.GetValue())
+			continue
+		}
+		s = append(s, n.GetNodeName())
+	}
+	return strings.Join(s, ",")
+}
+
+func TestNodeIterator(t *testing.T) {
+	doc := traversalTestDoc(t)
+	skipE := func(node Node) FilterResult {
+		if node.GetNodeName() == "e" {
+			return FILTER_REJECT
+		}
+		return FILTER_ACCEPT
+	}
+	it := doc.CreateNodeIterator(doc.GetDocumentElement(), SHOW_ELEMENT, skipE)
+	nodes := make([]Node, 0)
+	for n := it.NextNode(); n != nil; n = it.NextNode() {
+		nodes = append(nodes, n)
+	}
+	if s := nodeNames(nodes); s != "a,b,c,d,f,g" {
+		t.Errorf("Wrong nodes: %s", s)
+	}
+	nodes = nodes[:0]
+	for n := it.PreviousNode(); n != nil; n = it.PreviousNode() {
+		nodes = append(nodes, n)
+	}
+	if s := nodeNames(nodes); s != "g,f,d,c,b,a" {
+		t.Errorf("Wrong nodes: %s", s)
+	}
+	it.Detach()
+
+	// Removal of nodes during iteration
+	it = doc.CreateNodeIterator(doc, SHOW_ELEMENT|SHOW_TEXT, nil)
+	for n := it.NextNode(); n != nil && n.GetNodeName() != "c"; n = it.NextNode() {
+	}
+	b := doc.GetDocumentElement().GetFirstChild()
+	doc.GetDocumentElement().RemoveChild(b)
+	if it.GetReferenceNode() != doc.GetDocumentElement() || it.GetPointerBeforeReferenceNode() {
+		t.Errorf("Wrong reference after remove: %s", it.GetReferenceNode().GetNodeName())
+	}
+	if n := it.NextNode(); n == nil || n.GetNodeName() != "e" {
+		t.Errorf("Wrong next node: %v", n)
+	}
+	it.PreviousNode()
+	if !it.GetPointerBeforeReferenceNode() {
+		t.Errorf("Expected pointer before reference")
+	}
+	e := it.GetReferenceNode()
+	doc.GetDocumentElement().RemoveChild(e)
+	if n := it.GetReferenceNode(); n.GetNodeName() != "g" || !it.GetPointerBeforeReferenceNode() {
+		t.Errorf("Wrong reference after remove: %s", n.GetNodeName())
+	}
+}
+
+func TestTreeWalker(t *testing.T) {
+	doc := traversalTestDoc(t)
+	root := doc.GetDocumentElement()
+	// Skip b, so its children are visible as children of a
+	skipB := func(node Node) FilterResult {
+		if node.GetNodeName() == "b" {
+			return FILTER_SKIP
+		}
+		if node.GetNodeName() == "e" {
+			return FILTER_REJECT
+		}
+		return FILTER_ACCEPT
+	}
+	w := doc.CreateTreeWalker(root, SHOW_ELEMENT, skipB)
+	nodes := []Node{w.FirstChild(), w.NextSibling(), w.NextSibling(), w.NextSibling()}
+	if s := nodeNames(nodes); s != "c,d,g,nil" {
+		t.Errorf("Wrong siblings: %s", s)
+	}
+	if n := w.ParentNode(); n != root {
+		t.Errorf("Wrong parent: %v", n)
+	}
+	if n := w.LastChild(); n == nil || n.GetNodeName() != "g" {
+		t.Errorf("Wrong last child: %v", n)
+	}
+	nodes = []Node{w.PreviousSibling(), w.PreviousSibling(), w.PreviousSibling()}
+	if s := nodeNames(nodes); s != "d,c,nil" {
+		t.Errorf("Wrong siblings: %s", s)
+	}
+
+	w = doc.CreateTreeWalker(root, SHOW_ALL, skipB)
+	nodes = nodes[:0]
+	for n := w.NextNode(); n != nil; n = w.NextNode() {
+		nodes = append(nodes, n)
+	}
+	if s := nodeNames(nodes); s != "c,t1,d,g" {
+		t.Errorf("Wrong nodes: %s", s)
+	}
+	nodes = nodes[:0]
+	for n := w.PreviousNode(); n != nil; n = w.PreviousNode() {
+		nodes = append(nodes, n)
+	}
+	if s := nodeNames(nodes); s != "d,t1,c,a" {
+		t.Errorf("Wrong nodes: %s", s)
+	}
+
+	recursive := doc.CreateTreeWalker(root, SHOW_ALL, nil)
+	recursive = doc.CreateTreeWalker(root, SHOW_ALL, func(node Node) FilterResult {
+		recursive.NextNode()
+		return FILTER_ACCEPT
+	})
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic")
+		}
+	}()
+	recursive.NextNode()
+}
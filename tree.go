@@ -7,6 +7,23 @@ type tnode struct {
 	child  Node
 	// ver is used by nodelists to keep track of child list changes
 	ver int
+	// subtreeVer is used by collections to keep track of changes in
+	// the subtree rooted at this node
+	subtreeVer int
+}
+
+// childListChanged records a change in the children of parent
+func childListChanged(parent Node) {
+	parent.treeNode().ver++
+	subtreeChanged(parent)
+}
+
+// subtreeChanged records a change in the subtree of node, and the
+// subtrees of all its ancestors
+func subtreeChanged(node Node) {
+	for trc := node; trc != nil; trc = trc.GetParentNode() {
+		trc.treeNode().subtreeVer++
+	}
 }
 
 func (node *tnode) firstChild() Node {
@@ -40,12 +57,122 @@ func (node *tnode) nextSibling() Node {
 	return node.next
 }
 
+// nodeInserted is called after node is inserted into the tree
+func nodeInserted(node Node) {
+	idIndexInserted(node)
+	parent := node.GetParentNode()
+	next := node.GetNextSibling()
+	childListMutated(parent, node, nil, node.GetPreviousSibling(), next)
+	recordChange(node, &insertChange{parent: parent, node: node, next: next})
+}
+
+// nodeDetaching is called before node is detached from its parent
+func nodeDetaching(node Node) {
+	idIndexDetaching(node)
+	iteratorsRemoving(node)
+}
+
+// nodeDetached is called after node is detached from parent. prev
+// and next are the siblings of node before it was detached.
+func nodeDetached(parent, node, prev, next Node) {
+	childListMutated(parent, nil, node, prev, next)
+	recordChange(parent, &removeChange{parent: parent, node: node, next: next})
+}
+
+// attributeSet is called after attr is added to el at index. If attr
+// replaces another attribute with the same name, replaced is the old
+// attribute.
+func attributeSet(el *BasicElement, attr, replaced *BasicAttr, index int) {
+	if replaced != nil {
+		idIndexAttrChanged(el, attr, replaced.value, true, attr.value, true)
+		attributeMutated(el, attr.name, replaced.value)
+	} else {
+		idIndexAttrChanged(el, attr, "", false, attr.value, true)
+		attributeMutated(el, attr.name, "")
+	}
+	recordChange(el, &attrSetChange{el: el, attr: attr, replaced: replaced, index: index})
+}
+
+// attributeRemoved is called after attr is removed from index of el
+func attributeRemoved(el *BasicElement, attr *BasicAttr, index int) {
+	idIndexAttrChanged(el, attr, attr.value, true, "", false)
+	attributeMutated(el, attr.name, attr.value)
+	recordChange(el, &attrRemoveChange{el: el, attr: attr, index: index})
+}
+
+// attributeValueChanged is called after the value of attr of el
+// changes
+func attributeValueChanged(el *BasicElement, attr *BasicAttr, oldValue string) {
+	idIndexAttrChanged(el, attr, oldValue, true, attr.value, true)
+	attributeMutated(el, attr.name, oldValue)
+	recordChange(el, &attrValueChange{attr: attr, oldValue: oldValue, newValue: attr.value})
+}
+
+// characterDataChanged is called after the value of a character data
+// node changes
+func characterDataChanged(node Node, oldValue string) {
+	characterDataMutated(node, oldValue)
+	recordChange(node, &textChange{node: node, oldValue: oldValue, newValue: node.GetTextContent()})
+}
+
+// targetChanged is called after the target of a processing
+// instruction changes
+func targetChanged(pi *BasicProcessingInstruction, oldTarget string) {
+	recordChange(pi, &targetChange{pi: pi, oldTarget: oldTarget, newTarget: pi.target})
+}
+
+// elementRenamed is called after the name, prefix, or namespace of el
+// changes
+func elementRenamed(el *BasicElement, oldName Name) {
+	subtreeChanged(el)
+	if getIDIndex(el) != nil {
+		// ID attributes declared in the DTD depend on element names
+		el.ownerDocument.ids = nil
+	}
+	recordChange(el, &renameChange{el: el, oldName: oldName, newName: el.name})
+}
+
+// attributeRenamed is called after the name, prefix, or namespace of
+// attr of el changes
+func attributeRenamed(el *BasicElement, attr *BasicAttr, oldName Name) {
+	subtreeChanged(el)
+	if getIDIndex(el) != nil {
+		// ID attributes are selected by name
+		el.ownerDocument.ids = nil
+	}
+	attributeMutated(el, oldName, attr.value)
+	attributeMutated(el, attr.name, "")
+	recordChange(el, &attrRenameChange{attr: attr, oldName: oldName, newName: attr.name})
+}
+
 // Insert child after given node. If after is nil, insert as first node
 func insertChildAfter(parent, newChild, after Node) {
+	linkChildAfter(parent, newChild, after)
+	nodeInserted(newChild)
+}
+
+// Insert child before given node. If before is nil, insert as last node
+func insertChildBefore(parent, newChild, before Node) {
+	linkChildBefore(parent, newChild, before)
+	nodeInserted(newChild)
+}
+
+func detachChild(parent, child Node) {
+	if parent == nil {
+		unlinkChild(parent, child)
+		return
+	}
+	nodeDetaching(child)
+	prev, next := child.GetPreviousSibling(), child.GetNextSibling()
+	unlinkChild(parent, child)
+	nodeDetached(parent, child, prev, next)
+}
+
+func linkChildAfter(parent, newChild, after Node) {
 	newChildtn := newChild.treeNode()
 	newChildtn.parent = parent
 	parenttn := parent.treeNode()
-	parenttn.ver++
+	childListChanged(parent)
 	if after == nil {
 		first := parenttn.firstChild()
 		// newChild is the new first node
@@ -69,12 +196,11 @@ func insertChildAfter(parent, newChild, after Node) {
 	atn.next = newChild
 }
 
-// Insert child before given node. If before is nil, insert as last node
-func insertChildBefore(parent, newChild, before Node) {
+func linkChildBefore(parent, newChild, before Node) {
 	newChildtn := newChild.treeNode()
 	newChildtn.parent = parent
 	parenttn := parent.treeNode()
-	parenttn.ver++
+	childListChanged(parent)
 	if before == nil {
 		last := parenttn.lastChild()
 		// newChild is the last node
@@ -101,11 +227,11 @@ func insertChildBefore(parent, newChild, before Node) {
 	btn.prev = newChild
 }
 
-func detachChild(parent, child Node) {
+func unlinkChild(parent, child Node) {
 	childtn := child.treeNode()
 	if parent != nil {
 		parenttn := parent.treeNode()
-		parenttn.ver++
+		childListChanged(parent)
 		if parenttn.child == child {
 			parenttn.child = childtn.next
 			if parenttn.child == child {
@@ -121,4 +247,5 @@ func detachChild(parent, child Node) {
 	}
 	childtn.next = nil
 	childtn.prev = nil
+	childtn.parent = nil
 }
@@ -0,0 +1,212 @@
+package dom
+
+// Tx is a transaction that records the changes made to a document.
+// Transactions can be nested. The changes of a committed nested
+// transaction become part of the enclosing transaction.
+type Tx interface {
+	// Ends the transaction. If this is the outermost transaction, its
+	// changes are pushed to the undo stack of the document.
+	Commit()
+
+	// Undoes all the changes made in the transaction, and ends it
+	Rollback()
+}
+
+// change is a recorded change to the document
+type change interface {
+	undo()
+	redo()
+}
+
+// BasicTx implements Tx
+type BasicTx struct {
+	doc     *BasicDocument
+	parent  *BasicTx
+	changes []change
+	done    bool
+	// The changes of a private transaction are not pushed to the
+	// undo stack
+	private bool
+}
+
+var _ Tx = &BasicTx{}
+
+func (tx *BasicTx) end(op string) {
+	if tx.done || tx.doc.tx != tx {
+		panic(ErrDOM{
+			Typ: INVALID_STATE_ERR,
+			Msg: "Transaction is not the active transaction",
+			Op:  op,
+		})
+	}
+	tx.done = true
+	tx.doc.tx = tx.parent
+}
+
+// Ends the transaction. If this is the outermost transaction, its
+// changes are pushed to the undo stack of the document.
+func (tx *BasicTx) Commit() {
+	tx.end("Commit")
+	if len(tx.changes) == 0 {
+		return
+	}
+	if tx.parent != nil {
+		tx.parent.changes = append(tx.parent.changes, tx.changes...)
+		return
+	}
+	if tx.private {
+		tx.doc.undoStack = nil
+	} else {
+		tx.doc.undoStack = append(tx.doc.undoStack, tx.changes)
+	}
+	tx.doc.redoStack = nil
+}
+
+// Undoes all the changes made in the transaction, and ends it
+func (tx *BasicTx) Rollback() {
+	tx.end("Rollback")
+	tx.doc.replay(func() { undoChanges(tx.changes) })
+}
+
+func undoChanges(changes []change) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		changes[i].undo()
+	}
+}
+
+func redoChanges(changes []change) {
+	for _, c := range changes {
+		c.redo()
+	}
+}
+
+// replay runs f without recording the changes it makes
+func (doc *BasicDocument) replay(f func()) {
+	defer beginMutation(doc)()
+	doc.replaying = true
+	defer func() { doc.replaying = false }()
+	f()
+}
+
+func (doc *BasicDocument) checkNoTx(op string) {
+	if doc.tx != nil {
+		panic(ErrDOM{
+			Typ: INVALID_STATE_ERR,
+			Msg: "There is an active transaction",
+			Op:  op,
+		})
+	}
+}
+
+// recordChange records c in the active transaction of the document
+// of node. A change to a node of the document outside a transaction
+// clears the undo and redo stacks, because they may no longer be
+// applied.
+func recordChange(node Node, c change) {
+	doc, _ := node.GetOwnerDocument().(*BasicDocument)
+	if doc == nil || doc.replaying {
+		return
+	}
+	if doc.tx != nil {
+		doc.tx.changes = append(doc.tx.changes, c)
+		return
+	}
+	if (len(doc.undoStack) > 0 || len(doc.redoStack) > 0) && getRootNode(node) == doc {
+		doc.undoStack = nil
+		doc.redoStack = nil
+	}
+}
+
+type insertChange struct {
+	parent, node, next Node
+}
+
+func (c *insertChange) undo() { detachChild(c.parent, c.node) }
+func (c *insertChange) redo() { insertChildBefore(c.parent, c.node, c.next) }
+
+type removeChange struct {
+	parent, node, next Node
+}
+
+func (c *removeChange) undo() { insertChildBefore(c.parent, c.node, c.next) }
+func (c *removeChange) redo() { detachChild(c.parent, c.node) }
+
+type attrSetChange struct {
+	el       *BasicElement
+	attr     *BasicAttr
+	replaced *BasicAttr
+	index    int
+}
+
+func (c *attrSetChange) undo() {
+	if c.replaced != nil {
+		c.el.attributes.setNamedItemNS(c.el, c.replaced)
+		return
+	}
+	c.el.attributes.removeAttr(c.attr)
+}
+
+func (c *attrSetChange) redo() {
+	if c.replaced != nil {
+		c.el.attributes.setNamedItemNS(c.el, c.attr)
+		return
+	}
+	c.el.attributes.insertAttr(c.el, c.attr, c.index)
+}
+
+type attrRemoveChange struct {
+	el    *BasicElement
+	attr  *BasicAttr
+	index int
+}
+
+func (c *attrRemoveChange) undo() { c.el.attributes.insertAttr(c.el, c.attr, c.index) }
+func (c *attrRemoveChange) redo() { c.el.attributes.removeAttr(c.attr) }
+
+type attrValueChange struct {
+	attr               *BasicAttr
+	oldValue, newValue string
+}
+
+func (c *attrValueChange) undo() { c.attr.SetValue(c.oldValue) }
+func (c *attrValueChange) redo() { c.attr.SetValue(c.newValue) }
+
+type textChange struct {
+	node               Node
+	oldValue, newValue string
+}
+
+func (c *textChange) undo() { c.node.SetTextContent(c.oldValue) }
+func (c *textChange) redo() { c.node.SetTextContent(c.newValue) }
+
+type targetChange struct {
+	pi                   *BasicProcessingInstruction
+	oldTarget, newTarget string
+}
+
+func (c *targetChange) undo() { c.pi.SetTarget(c.oldTarget) }
+func (c *targetChange) redo() { c.pi.SetTarget(c.newTarget) }
+
+type renameChange struct {
+	el               *BasicElement
+	oldName, newName Name
+}
+
+func (c *renameChange) undo() { c.el.setName(c.oldName) }
+func (c *renameChange) redo() { c.el.setName(c.newName) }
+
+type attrRenameChange struct {
+	attr             *BasicAttr
+	oldName, newName Name
+}
+
+func (c *attrRenameChange) undo() { c.attr.setName(c.oldName) }
+func (c *attrRenameChange) redo() { c.attr.setName(c.newName) }
+
+type xmlDeclChange struct {
+	doc              *BasicDocument
+	oldDecl, newDecl xmlDeclaration
+}
+
+func (c *xmlDeclChange) undo() { c.doc.xmlDecl = c.oldDecl }
+func (c *xmlDeclChange) redo() { c.doc.xmlDecl = c.newDecl }
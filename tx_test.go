@@ -0,0 +1,191 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	input := `<?x a?><r xmlns:h="https://test.com/h"><a id="1" x="2">text</a><b/><h:c/></r>`
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := encodeString(t, doc)
+	if doc.GetElementById("1") == nil {
+		t.Fatal("No element with ID 1")
+	}
+	r := doc.GetDocumentElement()
+	a := r.GetFirstElementChild()
+	b := a.GetNextElementSibling()
+
+	edit := func() {
+		a.SetAttribute("x", "3")
+		a.SetAttribute("y", "4")
+		a.RemoveAttribute("id")
+		b.SetAttribute("id", "1")
+		idAttr := doc.CreateAttribute("x")
+		idAttr.SetValue("5")
+		a.SetAttributeNode(idAttr)
+		a.GetFirstChild().(Text).SetValue("changed")
+		a.AppendChild(doc.CreateTextNode(" more"))
+		a.Normalize()
+		r.InsertBefore(b, a)
+		r.AppendChild(doc.CreateElementNS("", "https://test.com/t", "new"))
+		if err := doc.NormalizeNamespaces(); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.SetInnerXML("<d/>e"); err != nil {
+			t.Fatal(err)
+		}
+		doc.GetFirstChild().(ProcessingInstruction).SetTarget("y")
+		r.RemoveChild(r.GetLastChild().GetPreviousSibling())
+	}
+
+	tx := doc.Begin()
+	edit()
+	modified := encodeString(t, doc)
+	if modified == original {
+		t.Fatal("Document not modified")
+	}
+	tx.Rollback()
+	if s := encodeString(t, doc); s != original {
+		t.Errorf("Wrong rollback: %s", s)
+	}
+	if doc.GetElementById("1") != a {
+		t.Errorf("Wrong ID index after rollback")
+	}
+	if doc.CanUndo() {
+		t.Errorf("Rolled back transaction can be undone")
+	}
+
+	tx = doc.Begin()
+	edit()
+	tx.Commit()
+	if s := encodeString(t, doc); s != modified {
+		t.Errorf("Wrong commit: %s", s)
+	}
+	if doc.GetElementById("1") != b {
+		t.Errorf("Wrong ID index after commit")
+	}
+	if !doc.Undo() {
+		t.Fatal("Undo failed")
+	}
+	if s := encodeString(t, doc); s != original {
+		t.Errorf("Wrong undo: %s", s)
+	}
+	if doc.Undo() {
+		t.Errorf("Undo with empty stack")
+	}
+	if !doc.Redo() {
+		t.Fatal("Redo failed")
+	}
+	if s := encodeString(t, doc); s != modified {
+		t.Errorf("Wrong redo: %s", s)
+	}
+	if doc.GetElementById("1") != b {
+		t.Errorf("Wrong ID index after redo")
+	}
+
+	// Changes outside a transaction clear the history
+	r.AppendChild(doc.CreateElement("z"))
+	if doc.CanUndo() || doc.CanRedo() {
+		t.Errorf("History not cleared")
+	}
+}
+
+func TestNestedTransaction(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<r/>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := doc.GetDocumentElement()
+	outer := doc.Begin()
+	r.SetAttribute("a", "1")
+	inner := doc.Begin()
+	r.SetAttribute("b", "2")
+	inner.Rollback()
+	inner = doc.Begin()
+	r.SetAttribute("c", "3")
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expecting panic for committing the outer transaction")
+			}
+		}()
+		outer.Commit()
+	}()
+	inner.Commit()
+	outer.Commit()
+	if s := encodeString(t, doc); s != `<r a="1" c="3"></r>` {
+		t.Errorf("Wrong result: %s", s)
+	}
+	doc.Undo()
+	if s := encodeString(t, doc); s != `<r></r>` {
+		t.Errorf("Wrong undo: %s", s)
+	}
+}
+
+func TestAtomically(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<r/>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := doc.GetDocumentElement()
+	tx := doc.Begin()
+	r.SetAttribute("a", "1")
+	tx.Commit()
+
+	// Failed changes are rolled back, and the history is kept
+	fail := ErrDOM{Typ: NOT_FOUND_ERR, Msg: "fail", Op: "Test"}
+	if err := doc.Atomically(func() error {
+		r.SetAttribute("b", "2")
+		return fail
+	}); err != fail {
+		t.Errorf("Wrong error: %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() != fail {
+				t.Errorf("Expecting panic")
+			}
+		}()
+		doc.Atomically(func() error {
+			r.AppendChild(doc.CreateElement("x"))
+			// Left open by the panic
+			doc.Begin()
+			r.SetAttribute("c", "3")
+			panic(fail)
+		})
+	}()
+	if s := encodeString(t, doc); s != `<r a="1"></r>` {
+		t.Errorf("Wrong rollback: %s", s)
+	}
+	if !doc.CanUndo() {
+		t.Errorf("History cleared by rolled back changes")
+	}
+
+	// Successful changes are not pushed to the undo stack
+	if err := doc.Atomically(func() error {
+		r.SetAttribute("d", "4")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if doc.CanUndo() || doc.CanRedo() {
+		t.Errorf("History not cleared")
+	}
+
+	// In a transaction, the changes become part of the transaction
+	tx = doc.Begin()
+	doc.Atomically(func() error {
+		r.SetAttribute("e", "5")
+		return nil
+	})
+	tx.Commit()
+	doc.Undo()
+	if s := encodeString(t, doc); s != `<r a="1" d="4"></r>` {
+		t.Errorf("Wrong undo: %s", s)
+	}
+}
@@ -62,6 +62,7 @@ func TestClone(t *testing.T) {
 
 func TestProcessingInstruction(t *testing.T) {
 	input := `<?xml version = "1.0" ?>
+<?app version = "1.0" ?>
 <note>
 <to attr="val">  </to>  <!--comment-->
 </note>`
@@ -71,8 +72,12 @@ func TestProcessingInstruction(t *testing.T) {
 		t.Errorf(err.Error())
 		return
 	}
+	// The XML declaration is not a node
+	if doc.GetXMLVersion() != "1.0" {
+		t.Errorf("Wrong version: %s", doc.GetXMLVersion())
+	}
 	pi := doc.GetFirstChild().(ProcessingInstruction)
-	if pi.GetTarget() != "xml" {
+	if pi.GetTarget() != "app" {
 		t.Errorf("Wrong target")
 	}
 	if pi.GetValue() != `version = "1.0" ` {
@@ -202,3 +207,95 @@ func TestNormalizeNamespaces(t *testing.T) {
 	}
 
 }
+
+func TestEncodeDocumentType(t *testing.T) {
+	inputs := []string{
+		`<?xml version="1.0" encoding="UTF-8" standalone="no"?><!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd"><html></html>`,
+		`<!DOCTYPE note SYSTEM "note.dtd"><note></note>`,
+		`<!DOCTYPE note SYSTEM 'say "hi".dtd' [
+<!ELEMENT note (#PCDATA)>
+<!ENTITY x "y">
+]><note></note>`,
+		`<!DOCTYPE note><!--c--><note></note>`,
+	}
+	for _, input := range inputs {
+		doc, err := Parse(xml.NewDecoder(strings.NewReader(input)))
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if doc.GetDocumentType() == nil {
+			t.Errorf("%s: no doctype", input)
+			continue
+		}
+		buf := bytes.Buffer{}
+		if err := Encode(doc, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != input {
+			t.Errorf("Expected %s, got %s", input, buf.String())
+		}
+	}
+
+	doc, _ := Parse(xml.NewDecoder(strings.NewReader(inputs[0])))
+	dt := doc.GetDocumentType()
+	if dt.GetName() != "html" || dt.GetPublicID() != "-//W3C//DTD XHTML 1.0 Strict//EN" || dt.GetSystemID() != "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd" {
+		t.Errorf("Wrong doctype: %v", dt)
+	}
+	if !dt.IsEqualNode(doc.CloneNode(true).(Document).GetDocumentType()) {
+		t.Errorf("Cloned doctype not equal")
+	}
+}
+
+func TestXMLDeclaration(t *testing.T) {
+	doc, err := Parse(xml.NewDecoder(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?><root></root>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.GetXMLVersion() != "1.0" || doc.GetXMLEncoding() != "UTF-8" || doc.GetXMLStandalone() != "" {
+		t.Errorf("Wrong declaration: %s %s %s", doc.GetXMLVersion(), doc.GetXMLEncoding(), doc.GetXMLStandalone())
+	}
+	doc.SetXMLStandalone("yes")
+	buf := bytes.Buffer{}
+	Encode(doc, &buf)
+	if buf.String() != `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><root></root>` {
+		t.Errorf("Wrong output: %s", buf.String())
+	}
+	doc.SetXMLVersion("")
+	buf.Reset()
+	Encode(doc, &buf)
+	if buf.String() != `<root></root>` {
+		t.Errorf("Wrong output: %s", buf.String())
+	}
+
+	doc = NewDocument()
+	doc.AppendChild(doc.CreateDocumentType("root", "", "root.dtd"))
+	doc.AppendChild(doc.CreateElement("root"))
+	doc.SetXMLEncoding("UTF-8")
+	buf.Reset()
+	EncodeWithOptions(doc, &buf, EncodeOptions{Indent: " "})
+	if buf.String() != "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!DOCTYPE root SYSTEM \"root.dtd\">\n<root></root>" {
+		t.Errorf("Wrong output: %s", buf.String())
+	}
+
+	// The declaration is not a child of the document
+	doc, err = Parse(xml.NewDecoder(strings.NewReader(`<?xml version="1.0" standalone="yes"?><root></root>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.GetFirstChild() != doc.GetDocumentElement() || doc.GetChildNodes().GetLength() != 1 {
+		t.Errorf("Declaration is a node")
+	}
+	if clone := doc.CloneNode(false).(Document); clone.GetXMLStandalone() != "yes" {
+		t.Errorf("Declaration not cloned")
+	}
+	tx := doc.Begin()
+	doc.SetXMLEncoding("UTF-8")
+	tx.Rollback()
+	if doc.GetXMLEncoding() != "" {
+		t.Errorf("Declaration change not rolled back")
+	}
+	if _, err := Parse(xml.NewDecoder(strings.NewReader(`<root></root><?xml version="1.0"?>`))); err == nil {
+		t.Errorf("Expecting error for a misplaced declaration")
+	}
+}
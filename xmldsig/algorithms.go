@@ -0,0 +1,170 @@
+package xmldsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"math/big"
+
+	"github.com/bserdar/go-dom"
+)
+
+var digestMethods = map[string]crypto.Hash{
+	SHA1:   crypto.SHA1,
+	SHA256: crypto.SHA256,
+	SHA384: crypto.SHA384,
+	SHA512: crypto.SHA512,
+}
+
+type keyKind int
+
+const (
+	rsaKey keyKind = iota
+	ecdsaKey
+	hmacKey
+)
+
+type signatureMethod struct {
+	kind keyKind
+	hash crypto.Hash
+}
+
+var signatureMethods = map[string]signatureMethod{
+	RSASHA1:     {rsaKey, crypto.SHA1},
+	RSASHA256:   {rsaKey, crypto.SHA256},
+	RSASHA384:   {rsaKey, crypto.SHA384},
+	RSASHA512:   {rsaKey, crypto.SHA512},
+	ECDSASHA1:   {ecdsaKey, crypto.SHA1},
+	ECDSASHA256: {ecdsaKey, crypto.SHA256},
+	ECDSASHA384: {ecdsaKey, crypto.SHA384},
+	ECDSASHA512: {ecdsaKey, crypto.SHA512},
+	HMACSHA1:    {hmacKey, crypto.SHA1},
+	HMACSHA256:  {hmacKey, crypto.SHA256},
+	HMACSHA384:  {hmacKey, crypto.SHA384},
+	HMACSHA512:  {hmacKey, crypto.SHA512},
+}
+
+// digest computes the digest of data using the digest method
+func digest(method string, data []byte, op string) ([]byte, error) {
+	h, ok := digestMethods[method]
+	if !ok {
+		return nil, errorf(dom.NOT_SUPPORTED_ERR, op, "Unsupported digest method %s", method)
+	}
+	hash := h.New()
+	hash.Write(data)
+	return hash.Sum(nil), nil
+}
+
+func getSignatureMethod(method string, op string) (signatureMethod, error) {
+	m, ok := signatureMethods[method]
+	if !ok {
+		return m, errorf(dom.NOT_SUPPORTED_ERR, op, "Unsupported signature method %s", method)
+	}
+	return m, nil
+}
+
+// defaultSignatureMethod returns the SHA-256 signature method for
+// the key
+func defaultSignatureMethod(key interface{}) string {
+	switch key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return RSASHA256
+	case *ecdsa.PrivateKey, *ecdsa.PublicKey:
+		return ECDSASHA256
+	}
+	return HMACSHA256
+}
+
+// sign computes the signature value of data
+func sign(method string, key interface{}, data []byte) ([]byte, error) {
+	const op = "Sign"
+	m, err := getSignatureMethod(method, op)
+	if err != nil {
+		return nil, err
+	}
+	if m.kind == hmacKey {
+		k, ok := key.([]byte)
+		if !ok {
+			return nil, errorf(dom.INVALID_ACCESS_ERR, op, "%s requires a []byte key", method)
+		}
+		mac := hmac.New(m.hash.New, k)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	}
+	hash := m.hash.New()
+	hash.Write(data)
+	sum := hash.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if m.kind == rsaKey {
+			return rsa.SignPKCS1v15(rand.Reader, k, m.hash, sum)
+		}
+	case *ecdsa.PrivateKey:
+		if m.kind == ecdsaKey {
+			r, s, err := ecdsa.Sign(rand.Reader, k, sum)
+			if err != nil {
+				return nil, err
+			}
+			// The signature value is r and s, each padded to the key
+			// size
+			size := (k.Curve.Params().BitSize + 7) / 8
+			ret := make([]byte, 2*size)
+			r.FillBytes(ret[:size])
+			s.FillBytes(ret[size:])
+			return ret, nil
+		}
+	}
+	return nil, errorf(dom.INVALID_ACCESS_ERR, op, "Key %T cannot be used with %s", key, method)
+}
+
+// verify checks the signature value of data
+func verify(method string, key interface{}, data, signature []byte) error {
+	const op = "Verify"
+	m, err := getSignatureMethod(method, op)
+	if err != nil {
+		return err
+	}
+	if m.kind == hmacKey {
+		k, ok := key.([]byte)
+		if !ok {
+			return errorf(dom.INVALID_ACCESS_ERR, op, "%s requires a []byte key", method)
+		}
+		mac := hmac.New(m.hash.New, k)
+		mac.Write(data)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errorf(dom.VALIDATION_ERR, op, "Signature value mismatch")
+		}
+		return nil
+	}
+	hash := m.hash.New()
+	hash.Write(data)
+	sum := hash.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if m.kind == rsaKey {
+			if rsa.VerifyPKCS1v15(k, m.hash, sum, signature) != nil {
+				return errorf(dom.VALIDATION_ERR, op, "Signature value mismatch")
+			}
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if m.kind == ecdsaKey {
+			size := (k.Curve.Params().BitSize + 7) / 8
+			if len(signature) != 2*size {
+				return errorf(dom.VALIDATION_ERR, op, "Invalid ECDSA signature length")
+			}
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if !ecdsa.Verify(k, sum, r, s) {
+				return errorf(dom.VALIDATION_ERR, op, "Signature value mismatch")
+			}
+			return nil
+		}
+	}
+	return errorf(dom.INVALID_ACCESS_ERR, op, "Key %T cannot be used with %s", key, method)
+}
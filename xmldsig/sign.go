@@ -0,0 +1,241 @@
+package xmldsig
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/bserdar/go-dom"
+	"github.com/bserdar/go-dom/c14n"
+)
+
+const xmlnsURL = "http://www.w3.org/2000/xmlns"
+
+// Signer creates XML signatures
+type Signer struct {
+	// Key is a *rsa.PrivateKey, *ecdsa.PrivateKey, or a []byte HMAC
+	// key
+	Key interface{}
+
+	// SignatureMethod is the signature algorithm. If empty, the
+	// SHA-256 algorithm for the key is used.
+	SignatureMethod string
+
+	// DigestMethod is the default digest algorithm of references. If
+	// empty, SHA256 is used.
+	DigestMethod string
+
+	// Canonicalization is the canonicalization method of SignedInfo,
+	// and the canonicalization transform of same-document
+	// references. If empty, exclusive canonicalization is used.
+	Canonicalization string
+
+	// Prefix of the signature elements. If empty, "ds" is used.
+	Prefix string
+
+	// If non-empty, a KeyInfo with this KeyName is included in the
+	// signature
+	KeyName string
+
+	// Resolver is used to get the external resources of detached
+	// signatures
+	Resolver Resolver
+}
+
+func (s *Signer) signatureMethod() string {
+	if len(s.SignatureMethod) == 0 {
+		return defaultSignatureMethod(s.Key)
+	}
+	return s.SignatureMethod
+}
+
+func (s *Signer) digestMethod() string {
+	if len(s.DigestMethod) == 0 {
+		return SHA256
+	}
+	return s.DigestMethod
+}
+
+func (s *Signer) canonicalization() string {
+	if len(s.Canonicalization) == 0 {
+		return c14n.ExclusiveC14N
+	}
+	return s.Canonicalization
+}
+
+func (s *Signer) prefix() string {
+	if len(s.Prefix) == 0 {
+		return "ds"
+	}
+	return s.Prefix
+}
+
+// getID returns the ID of the element
+func getID(el dom.Element) (string, bool) {
+	for _, name := range []string{"Id", "ID", "id"} {
+		if id, ok := el.GetAttribute(name); ok {
+			return id, true
+		}
+	}
+	return el.GetAttributeNS("http://www.w3.org/XML/1998/namespace", "id")
+}
+
+// SignEnveloped signs el, and appends the signature to el. If el is
+// not the document element, el must have an ID attribute.
+func (s *Signer) SignEnveloped(el dom.Element) (dom.Element, error) {
+	uri := ""
+	if doc := el.GetOwnerDocument(); doc == nil || doc.GetDocumentElement() != el {
+		id, ok := getID(el)
+		if !ok {
+			return nil, errorf(dom.NOT_FOUND_ERR, "Sign", "Element %s does not have an ID", el.GetTagName())
+		}
+		uri = "#" + id
+	}
+	return s.Sign(el, Reference{
+		URI:        uri,
+		Transforms: []Transform{{Algorithm: EnvelopedSignature}, {Algorithm: s.canonicalization()}},
+	})
+}
+
+// SignEnveloping creates a signature containing an Object with the
+// given ID, moves content into the Object, and appends the signature
+// to parent. If signing fails, content is moved back to where it was.
+func (s *Signer) SignEnveloping(parent dom.Node, id string, content ...dom.Node) (dom.Element, error) {
+	return s.sign(parent, []Reference{{
+		URI:        "#" + id,
+		Transforms: []Transform{{Algorithm: s.canonicalization()}},
+	}}, id, content)
+}
+
+// SignDetached signs the resources with the given URIs, and appends
+// the signature to parent. The URIs are either "#id" references to
+// elements in the same document, or external resources that are
+// resolved using the Resolver.
+func (s *Signer) SignDetached(parent dom.Node, uris ...string) (dom.Element, error) {
+	refs := make([]Reference, 0, len(uris))
+	for _, uri := range uris {
+		ref := Reference{URI: uri}
+		if len(uri) > 0 && uri[0] == '#' {
+			ref.Transforms = []Transform{{Algorithm: s.canonicalization()}}
+		}
+		refs = append(refs, ref)
+	}
+	return s.Sign(parent, refs...)
+}
+
+// Sign creates a signature for the references, and appends it to
+// parent. If signing fails, the document is not changed. Outside a
+// transaction, signing is not added to the undo stack of the
+// document, and it clears the undo and redo stacks.
+func (s *Signer) Sign(parent dom.Node, references ...Reference) (dom.Element, error) {
+	return s.sign(parent, references, "", nil)
+}
+
+func (s *Signer) sign(parent dom.Node, references []Reference, objectID string, content []dom.Node) (dom.Element, error) {
+	doc, ok := parent.(dom.Document)
+	if !ok {
+		doc = parent.GetOwnerDocument()
+	}
+	// The changes are rolled back if signing fails, so the content
+	// moved into the Object goes back to its original place. Signing
+	// is not added to the undo stack of the document.
+	var signature dom.Element
+	err := doc.Atomically(func() (err error) {
+		signature, err = s.buildSignature(doc, parent, references, objectID, content)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+func (s *Signer) buildSignature(doc dom.Document, parent dom.Node, references []Reference, objectID string, content []dom.Node) (dom.Element, error) {
+	// Do not modify the caller's references
+	references = append([]Reference(nil), references...)
+	prefix := s.prefix()
+	create := func(parent dom.Element, local string) dom.Element {
+		el := doc.CreateElementNS(prefix, Namespace, local)
+		parent.AppendChild(el)
+		return el
+	}
+	createText := func(parent dom.Element, local, text string) dom.Element {
+		el := create(parent, local)
+		el.AppendChild(doc.CreateTextNode(text))
+		return el
+	}
+
+	signature := doc.CreateElementNS(prefix, Namespace, "Signature")
+	signature.SetAttributeNS("xmlns", xmlnsURL, prefix, Namespace)
+	signedInfo := create(signature, "SignedInfo")
+	create(signedInfo, "CanonicalizationMethod").SetAttribute("Algorithm", s.canonicalization())
+	create(signedInfo, "SignatureMethod").SetAttribute("Algorithm", s.signatureMethod())
+	digestValues := make([]dom.Element, 0, len(references))
+	for i := range references {
+		if len(references[i].DigestMethod) == 0 {
+			references[i].DigestMethod = s.digestMethod()
+		}
+		ref := references[i]
+		refEl := create(signedInfo, "Reference")
+		refEl.SetAttribute("URI", ref.URI)
+		if len(ref.Transforms) > 0 {
+			transforms := create(refEl, "Transforms")
+			for _, t := range ref.Transforms {
+				tEl := create(transforms, "Transform")
+				tEl.SetAttribute("Algorithm", t.Algorithm)
+				if len(t.InclusivePrefixes) > 0 {
+					incl := doc.CreateElementNS("ec", c14n.ExclusiveC14N, "InclusiveNamespaces")
+					incl.SetAttributeNS("xmlns", xmlnsURL, "ec", c14n.ExclusiveC14N)
+					incl.SetAttribute("PrefixList", strings.Join(t.InclusivePrefixes, " "))
+					tEl.AppendChild(incl)
+				}
+				if t.Algorithm == XPathFilter {
+					xp := createText(tEl, "XPath", t.XPath)
+					for p, uri := range t.Namespaces {
+						xp.SetAttributeNS("xmlns", xmlnsURL, p, uri)
+					}
+				}
+			}
+		}
+		create(refEl, "DigestMethod").SetAttribute("Algorithm", ref.DigestMethod)
+		digestValues = append(digestValues, create(refEl, "DigestValue"))
+	}
+	signatureValue := create(signature, "SignatureValue")
+	if len(s.KeyName) > 0 {
+		createText(create(signature, "KeyInfo"), "KeyName", s.KeyName)
+	}
+	if len(objectID) > 0 {
+		object := create(signature, "Object")
+		object.SetAttribute("Id", objectID)
+		for _, n := range content {
+			if _, err := object.AppendChildE(n); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := parent.AppendChildE(signature); err != nil {
+		return nil, err
+	}
+
+	p := processor{signature: signature, resolver: s.Resolver, op: "Sign"}
+	for i, ref := range references {
+		_, value, err := p.digestReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		digestValues[i].AppendChild(doc.CreateTextNode(base64.StdEncoding.EncodeToString(value)))
+	}
+	options, err := c14n.OptionsForAlgorithm(s.canonicalization())
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := c14n.Canonicalize(signedInfo, options)
+	if err != nil {
+		return nil, err
+	}
+	value, err := sign(s.signatureMethod(), s.Key, canonical)
+	if err != nil {
+		return nil, err
+	}
+	signatureValue.AppendChild(doc.CreateTextNode(base64.StdEncoding.EncodeToString(value)))
+	return signature, nil
+}
@@ -0,0 +1,216 @@
+package xmldsig
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/bserdar/go-dom"
+	"github.com/bserdar/go-dom/c14n"
+	"github.com/bserdar/go-dom/xpath"
+)
+
+// transformData is the input or output of a transform. It is a
+// node-set if root is non-nil, and octets otherwise. The node-set
+// contains the nodes under root selected by filter.
+type transformData struct {
+	root   dom.Node
+	filter func(dom.Node) bool
+	octets []byte
+}
+
+// and returns a filter that selects the nodes selected by both
+// filters
+func and(f1, f2 func(dom.Node) bool) func(dom.Node) bool {
+	if f1 == nil {
+		return f2
+	}
+	return func(node dom.Node) bool {
+		return f1(node) && f2(node)
+	}
+}
+
+func excludeComments(node dom.Node) bool {
+	return node.GetNodeType() != dom.COMMENT_NODE
+}
+
+// isUnder returns true if node is ancestor or a descendant of
+// ancestor. Attributes are under their owner elements.
+func isUnder(node, ancestor dom.Node) bool {
+	if attr, ok := node.(dom.Attr); ok {
+		node = attr.GetOwnerElement()
+	}
+	for trc := node; trc != nil; trc = trc.GetParentNode() {
+		if trc == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// processor computes the digests of references of a signature
+type processor struct {
+	signature dom.Element
+	resolver  Resolver
+	op        string
+}
+
+// top returns the root of the tree containing the signature
+func (p *processor) top() dom.Node {
+	var node dom.Node = p.signature
+	for node.GetParentNode() != nil {
+		node = node.GetParentNode()
+	}
+	return node
+}
+
+// findByID returns the unique element with the given ID. Id, ID,
+// id, and xml:id attributes are IDs.
+func (p *processor) findByID(id string) (dom.Element, error) {
+	var found []dom.Element
+	var walk func(dom.Node)
+	walk = func(n dom.Node) {
+		if el, ok := n.(dom.Element); ok {
+			attrs := el.GetAttributes()
+			for i := 0; i < attrs.GetLength(); i++ {
+				name := attrs.Item(i).GetQName()
+				isID := (len(name.Prefix) == 0 && (name.Local == "Id" || name.Local == "ID" || name.Local == "id")) ||
+					(name.Prefix == "xml" && name.Local == "id")
+				if isID && attrs.Item(i).GetValue() == id {
+					found = append(found, el)
+					break
+				}
+			}
+		}
+		for ch := n.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			walk(ch)
+		}
+	}
+	walk(p.top())
+	switch len(found) {
+	case 0:
+		return nil, errorf(dom.NOT_FOUND_ERR, p.op, "Element with ID %s not found", id)
+	case 1:
+		return found[0], nil
+	}
+	return nil, errorf(dom.VALIDATION_ERR, p.op, "Multiple elements with ID %s", id)
+}
+
+// dereference returns the data referenced by the URI
+func (p *processor) dereference(uri string) (transformData, error) {
+	if len(uri) == 0 {
+		return transformData{root: p.top(), filter: excludeComments}, nil
+	}
+	if !strings.HasPrefix(uri, "#") {
+		if p.resolver == nil {
+			return transformData{}, errorf(dom.NOT_SUPPORTED_ERR, p.op, "Cannot resolve %s", uri)
+		}
+		data, err := p.resolver(uri)
+		if err != nil {
+			return transformData{}, err
+		}
+		return transformData{octets: data}, nil
+	}
+	fragment := uri[1:]
+	if fragment == "xpointer(/)" {
+		return transformData{root: p.top()}, nil
+	}
+	if strings.HasPrefix(fragment, "xpointer(id(") && strings.HasSuffix(fragment, "))") {
+		id := fragment[len("xpointer(id(") : len(fragment)-2]
+		if len(id) < 2 || (id[0] != '\'' && id[0] != '"') || id[len(id)-1] != id[0] {
+			return transformData{}, errorf(dom.SYNTAX_ERR, p.op, "Invalid XPointer %s", uri)
+		}
+		el, err := p.findByID(id[1 : len(id)-1])
+		if err != nil {
+			return transformData{}, err
+		}
+		return transformData{root: el}, nil
+	}
+	el, err := p.findByID(fragment)
+	if err != nil {
+		return transformData{}, err
+	}
+	return transformData{root: el, filter: excludeComments}, nil
+}
+
+// toNodeSet parses octets into a node-set
+func (p *processor) toNodeSet(data transformData) (transformData, error) {
+	if data.root != nil {
+		return data, nil
+	}
+	doc, err := dom.ParseReader(bytes.NewReader(data.octets), dom.ParseOptions{})
+	if err != nil {
+		return transformData{}, err
+	}
+	return transformData{root: doc}, nil
+}
+
+// transform applies the transform to data
+func (p *processor) transform(data transformData, t Transform) (transformData, error) {
+	switch t.Algorithm {
+	case EnvelopedSignature:
+		if data.root == nil {
+			return transformData{}, errorf(dom.INVALID_STATE_ERR, p.op, "Enveloped signature transform requires a node-set")
+		}
+		sig := p.signature
+		data.filter = and(data.filter, func(node dom.Node) bool { return !isUnder(node, sig) })
+		return data, nil
+
+	case XPathFilter:
+		data, err := p.toNodeSet(data)
+		if err != nil {
+			return data, err
+		}
+		expr, err := xpath.Compile(t.XPath)
+		if err != nil {
+			return data, err
+		}
+		ctx := &xpath.Context{Namespaces: t.Namespaces}
+		data.filter = and(data.filter, func(node dom.Node) bool {
+			result, err := expr.Evaluate(node, ctx)
+			return err == nil && result.Boolean()
+		})
+		return data, nil
+	}
+	options, err := c14n.OptionsForAlgorithm(t.Algorithm)
+	if err != nil {
+		return transformData{}, errorf(dom.NOT_SUPPORTED_ERR, p.op, "Unsupported transform %s", t.Algorithm)
+	}
+	options.InclusivePrefixes = t.InclusivePrefixes
+	data, err = p.toNodeSet(data)
+	if err != nil {
+		return data, err
+	}
+	options.Filter = data.filter
+	out, err := c14n.Canonicalize(data.root, options)
+	if err != nil {
+		return transformData{}, err
+	}
+	return transformData{octets: out}, nil
+}
+
+// digestReference dereferences and transforms the reference, and
+// returns the dereferenced node and the digest. The node is nil if
+// the reference is an external resource.
+func (p *processor) digestReference(ref Reference) (dom.Node, []byte, error) {
+	data, err := p.dereference(ref.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	node := data.root
+	for _, t := range ref.Transforms {
+		if data, err = p.transform(data, t); err != nil {
+			return nil, nil, err
+		}
+	}
+	if data.root != nil {
+		data, err = p.transform(data, Transform{Algorithm: c14n.C14N10})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	value, err := digest(ref.DigestMethod, data.octets, p.op)
+	if err != nil {
+		return nil, nil, err
+	}
+	return node, value, nil
+}
@@ -0,0 +1,215 @@
+package xmldsig
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/bserdar/go-dom"
+	"github.com/bserdar/go-dom/c14n"
+)
+
+// Verifier verifies XML signatures
+type Verifier struct {
+	// Key is a *rsa.PublicKey, *ecdsa.PublicKey, or a []byte HMAC key
+	Key interface{}
+
+	// Resolver is used to get the external resources referenced by
+	// the signature
+	Resolver Resolver
+}
+
+// decodeBase64 decodes base64 text ignoring whitespace
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// algorithm returns the Algorithm attribute of el
+func algorithm(el dom.Element, op string) (string, error) {
+	alg, ok := el.GetAttribute("Algorithm")
+	if !ok {
+		return "", errorf(dom.SYNTAX_ERR, op, "%s does not have an Algorithm", el.GetTagName())
+	}
+	return alg, nil
+}
+
+// inScopeNamespaces returns the namespace declarations in scope for
+// el
+func inScopeNamespaces(el dom.Element) map[string]string {
+	ret := map[string]string{}
+	for trc := dom.Node(el); trc != nil; trc = trc.GetParentNode() {
+		e, ok := trc.(dom.Element)
+		if !ok {
+			break
+		}
+		attrs := e.GetAttributes()
+		for i := 0; i < attrs.GetLength(); i++ {
+			name := attrs.Item(i).GetQName()
+			if name.Prefix != "xmlns" {
+				continue
+			}
+			if _, ok := ret[name.Local]; !ok {
+				ret[name.Local] = attrs.Item(i).GetValue()
+			}
+		}
+	}
+	return ret
+}
+
+// parseTransform returns the transform described by a Transform
+// element
+func parseTransform(el dom.Element, op string) (Transform, error) {
+	alg, err := algorithm(el, op)
+	if err != nil {
+		return Transform{}, err
+	}
+	t := Transform{Algorithm: alg}
+	for ch := el.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+		child, ok := ch.(dom.Element)
+		if !ok {
+			continue
+		}
+		name := child.GetQName()
+		switch {
+		case name.Space == c14n.ExclusiveC14N && name.Local == "InclusiveNamespaces":
+			prefixes, _ := child.GetAttribute("PrefixList")
+			t.InclusivePrefixes = strings.Fields(prefixes)
+		case name.Space == Namespace && name.Local == "XPath":
+			t.XPath = textContent(child)
+			t.Namespaces = inScopeNamespaces(child)
+		}
+	}
+	if alg == XPathFilter && len(t.XPath) == 0 {
+		return t, errorf(dom.SYNTAX_ERR, op, "XPath transform without an expression")
+	}
+	return t, nil
+}
+
+// parseReference returns the reference described by a Reference
+// element
+func parseReference(el dom.Element, op string) (Reference, error) {
+	ref := Reference{}
+	uri, ok := el.GetAttribute("URI")
+	if !ok {
+		return ref, errorf(dom.NOT_SUPPORTED_ERR, op, "Reference without URI")
+	}
+	ref.URI = uri
+	for _, transforms := range childElements(el, "Transforms") {
+		for _, t := range childElements(transforms, "Transform") {
+			transform, err := parseTransform(t, op)
+			if err != nil {
+				return ref, err
+			}
+			ref.Transforms = append(ref.Transforms, transform)
+		}
+	}
+	digestMethod, err := childElement(el, "DigestMethod", op)
+	if err != nil {
+		return ref, err
+	}
+	if ref.DigestMethod, err = algorithm(digestMethod, op); err != nil {
+		return ref, err
+	}
+	return ref, nil
+}
+
+// VerifiedReference is a reference whose digest is verified
+type VerifiedReference struct {
+	Reference
+
+	// Node is the node the URI of the reference resolved to: the
+	// document for an empty URI, or the element with the ID. It is nil
+	// for external resources. Applications should process only the
+	// signed nodes, so content moved or duplicated in the document
+	// cannot be passed as signed.
+	Node dom.Node
+}
+
+// Verify verifies the signature value of a Signature element, and
+// the digests of all its references. It returns the verified
+// references with the nodes that were digested.
+func (v *Verifier) Verify(signature dom.Element) ([]VerifiedReference, error) {
+	const op = "Verify"
+	if !isDSElement(signature, "Signature") {
+		return nil, errorf(dom.INVALID_NODE_TYPE_ERR, op, "Not a Signature element: %s", signature.GetTagName())
+	}
+	signedInfo, err := childElement(signature, "SignedInfo", op)
+	if err != nil {
+		return nil, err
+	}
+	c14nMethod, err := childElement(signedInfo, "CanonicalizationMethod", op)
+	if err != nil {
+		return nil, err
+	}
+	c14nTransform, err := parseTransform(c14nMethod, op)
+	if err != nil {
+		return nil, err
+	}
+	options, err := c14n.OptionsForAlgorithm(c14nTransform.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	options.InclusivePrefixes = c14nTransform.InclusivePrefixes
+	signatureMethod, err := childElement(signedInfo, "SignatureMethod", op)
+	if err != nil {
+		return nil, err
+	}
+	method, err := algorithm(signatureMethod, op)
+	if err != nil {
+		return nil, err
+	}
+	if len(childElements(signatureMethod, "HMACOutputLength")) > 0 {
+		return nil, errorf(dom.NOT_SUPPORTED_ERR, op, "HMACOutputLength is not supported")
+	}
+	signatureValue, err := childElement(signature, "SignatureValue", op)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodeBase64(textContent(signatureValue))
+	if err != nil {
+		return nil, errorf(dom.SYNTAX_ERR, op, "Invalid SignatureValue: %v", err)
+	}
+	canonical, err := c14n.Canonicalize(signedInfo, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(method, v.Key, canonical, value); err != nil {
+		return nil, err
+	}
+
+	refElements := childElements(signedInfo, "Reference")
+	if len(refElements) == 0 {
+		return nil, errorf(dom.SYNTAX_ERR, op, "SignedInfo does not have a Reference")
+	}
+	p := processor{signature: signature, resolver: v.Resolver, op: op}
+	refs := make([]VerifiedReference, 0, len(refElements))
+	for _, refEl := range refElements {
+		ref, err := parseReference(refEl, op)
+		if err != nil {
+			return nil, err
+		}
+		digestValue, err := childElement(refEl, "DigestValue", op)
+		if err != nil {
+			return nil, err
+		}
+		expected, err := decodeBase64(textContent(digestValue))
+		if err != nil {
+			return nil, errorf(dom.SYNTAX_ERR, op, "Invalid DigestValue: %v", err)
+		}
+		node, computed, err := p.digestReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		if subtle.ConstantTimeCompare(computed, expected) != 1 {
+			return nil, errorf(dom.VALIDATION_ERR, op, "Digest mismatch for reference %s", ref.URI)
+		}
+		refs = append(refs, VerifiedReference{Reference: ref, Node: node})
+	}
+	return refs, nil
+}
@@ -0,0 +1,158 @@
+// Package xmldsig creates and verifies XML Digital Signatures
+// (XMLDSig) over dom.Document trees.
+//
+// Enveloped, enveloping, and detached signatures can be created
+// using RSA, ECDSA, or HMAC keys. Canonicalization is done using the
+// c14n package.
+package xmldsig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bserdar/go-dom"
+)
+
+// Namespace is the XML Signature namespace
+const Namespace = "http://www.w3.org/2000/09/xmldsig#"
+
+// Digest methods
+const (
+	SHA1   = "http://www.w3.org/2000/09/xmldsig#sha1"
+	SHA256 = "http://www.w3.org/2001/04/xmlenc#sha256"
+	SHA384 = "http://www.w3.org/2001/04/xmldsig-more#sha384"
+	SHA512 = "http://www.w3.org/2001/04/xmlenc#sha512"
+)
+
+// Signature methods
+const (
+	RSASHA1     = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	RSASHA256   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	RSASHA384   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha384"
+	RSASHA512   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	ECDSASHA1   = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha1"
+	ECDSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	ECDSASHA384 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384"
+	ECDSASHA512 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512"
+	HMACSHA1    = "http://www.w3.org/2000/09/xmldsig#hmac-sha1"
+	HMACSHA256  = "http://www.w3.org/2001/04/xmldsig-more#hmac-sha256"
+	HMACSHA384  = "http://www.w3.org/2001/04/xmldsig-more#hmac-sha384"
+	HMACSHA512  = "http://www.w3.org/2001/04/xmldsig-more#hmac-sha512"
+)
+
+// Transforms other than canonicalization
+const (
+	EnvelopedSignature = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	XPathFilter        = "http://www.w3.org/TR/1999/REC-xpath-19991116"
+)
+
+// Resolver returns the octets of an external resource referenced
+// by a signature
+type Resolver func(uri string) ([]byte, error)
+
+// Transform describes a Reference transform
+type Transform struct {
+	// Algorithm identifier of the transform
+	Algorithm string
+
+	// InclusivePrefixes is the InclusiveNamespaces PrefixList of an
+	// exclusive canonicalization transform
+	InclusivePrefixes []string
+
+	// XPath is the expression of an XPath filter transform
+	XPath string
+
+	// Namespaces used by the XPath expression
+	Namespaces map[string]string
+}
+
+// Reference describes a data object to be signed
+type Reference struct {
+	// URI of the data object. An empty URI refers to the document
+	// containing the signature, and "#id" refers to the element with
+	// the given ID. Other URIs are resolved using the Resolver.
+	URI string
+
+	// Transforms applied to the data object before digesting
+	Transforms []Transform
+
+	// If empty, the DigestMethod of the Signer is used
+	DigestMethod string
+}
+
+func errorf(typ, op, format string, args ...interface{}) error {
+	return dom.ErrDOM{
+		Typ: typ,
+		Msg: fmt.Sprintf(format, args...),
+		Op:  op,
+	}
+}
+
+// isDSElement returns true if node is an element in the signature
+// namespace with the given local name
+func isDSElement(node dom.Node, local string) bool {
+	el, ok := node.(dom.Element)
+	if !ok {
+		return false
+	}
+	name := el.GetQName()
+	return name.Space == Namespace && name.Local == local
+}
+
+// childElements returns the child elements of el in the signature
+// namespace with the given local name
+func childElements(el dom.Element, local string) []dom.Element {
+	ret := make([]dom.Element, 0)
+	for ch := el.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+		if isDSElement(ch, local) {
+			ret = append(ret, ch.(dom.Element))
+		}
+	}
+	return ret
+}
+
+// childElement returns the only child element of el in the
+// signature namespace with the given local name
+func childElement(el dom.Element, local string, op string) (dom.Element, error) {
+	children := childElements(el, local)
+	if len(children) != 1 {
+		return nil, errorf(dom.SYNTAX_ERR, op, "Expecting one %s in %s", local, el.GetTagName())
+	}
+	return children[0], nil
+}
+
+// textContent returns the concatenation of the text descendants of
+// the node
+func textContent(node dom.Node) string {
+	var sb strings.Builder
+	var walk func(dom.Node)
+	walk = func(n dom.Node) {
+		for ch := n.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			switch ch.GetNodeType() {
+			case dom.TEXT_NODE, dom.CDATA_SECTION_NODE:
+				sb.WriteString(ch.(dom.CharacterData).GetValue())
+			case dom.ELEMENT_NODE:
+				walk(ch)
+			}
+		}
+	}
+	walk(node)
+	return sb.String()
+}
+
+// FindSignatures returns the Signature elements under node in
+// document order
+func FindSignatures(node dom.Node) []dom.Element {
+	ret := make([]dom.Element, 0)
+	var walk func(dom.Node)
+	walk = func(n dom.Node) {
+		if isDSElement(n, "Signature") {
+			ret = append(ret, n.(dom.Element))
+		}
+		for ch := n.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			walk(ch)
+		}
+	}
+	walk(node)
+	return ret
+}
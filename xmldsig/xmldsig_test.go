@@ -0,0 +1,288 @@
+package xmldsig
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/bserdar/go-dom"
+	"github.com/bserdar/go-dom/c14n"
+)
+
+func parse(t *testing.T, input string) dom.Document {
+	doc, err := dom.ParseReader(strings.NewReader(input), dom.ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// reparse encodes and parses the document, so verification does not
+// depend on the in-memory signed document
+func reparse(t *testing.T, doc dom.Document) dom.Document {
+	buf := bytes.Buffer{}
+	if err := dom.Encode(doc, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return parse(t, buf.String())
+}
+
+func onlySignature(t *testing.T, doc dom.Document) dom.Element {
+	sigs := FindSignatures(doc)
+	if len(sigs) != 1 {
+		t.Fatalf("Expected 1 signature, got %d", len(sigs))
+	}
+	return sigs[0]
+}
+
+const testDoc = `<po:order xmlns:po="urn:po" xmlns:x="urn:unused"><!--c--><po:item id="i1" qty="2">Widget</po:item><po:item id="i2">Gadget</po:item></po:order>`
+
+func TestEnvelopedRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := parse(t, testDoc)
+	signer := Signer{Key: key, KeyName: "test"}
+	if _, err := signer.SignEnveloped(doc.GetDocumentElement()); err != nil {
+		t.Fatal(err)
+	}
+	doc = reparse(t, doc)
+	refs, err := (&Verifier{Key: &key.PublicKey}).Verify(onlySignature(t, doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0].URI != "" || refs[0].Transforms[0].Algorithm != EnvelopedSignature || refs[0].Node != doc {
+		t.Errorf("Wrong references: %v", refs)
+	}
+
+	// Comments are not signed
+	doc.GetDocumentElement().RemoveChild(doc.GetDocumentElement().GetFirstChild())
+	if _, err := (&Verifier{Key: &key.PublicKey}).Verify(onlySignature(t, doc)); err != nil {
+		t.Error(err)
+	}
+
+	// Modify content
+	doc.GetDocumentElement().GetFirstElementChild().SetAttribute("qty", "20")
+	if _, err := (&Verifier{Key: &key.PublicKey}).Verify(onlySignature(t, doc)); err == nil {
+		t.Errorf("Expecting digest mismatch")
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	doc = reparse(t, doc)
+	if _, err := (&Verifier{Key: &other.PublicKey}).Verify(onlySignature(t, doc)); err == nil {
+		t.Errorf("Expecting signature mismatch")
+	}
+}
+
+func TestEnvelopedElementECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := parse(t, testDoc)
+	item := doc.GetDocumentElement().GetFirstElementChild()
+	signer := Signer{Key: key, Canonicalization: c14n.C14N11, DigestMethod: SHA512}
+	if _, err := signer.SignEnveloped(item); err != nil {
+		t.Fatal(err)
+	}
+	doc = reparse(t, doc)
+	refs, err := (&Verifier{Key: &key.PublicKey}).Verify(onlySignature(t, doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs[0].URI != "#i1" {
+		t.Errorf("Wrong URI: %s", refs[0].URI)
+	}
+	// Changes outside the signed element do not matter
+	doc.GetDocumentElement().GetLastChild().(dom.Element).SetAttribute("qty", "1")
+	if _, err := (&Verifier{Key: &key.PublicKey}).Verify(onlySignature(t, doc)); err != nil {
+		t.Error(err)
+	}
+	// Inherited namespaces are signed by inclusive canonicalization
+	doc.GetDocumentElement().SetAttributeNS("xmlns", "http://www.w3.org/2000/xmlns", "y", "urn:y")
+	if _, err := (&Verifier{Key: &key.PublicKey}).Verify(onlySignature(t, doc)); err == nil {
+		t.Errorf("Expecting digest mismatch")
+	}
+
+	// Element without an ID
+	doc = parse(t, `<a><b/></a>`)
+	if _, err := signer.SignEnveloped(doc.GetDocumentElement().GetFirstElementChild()); err == nil {
+		t.Errorf("Expecting error")
+	}
+}
+
+func TestEnveloping(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := dom.NewDocument()
+	content := doc.CreateElementNS("", "urn:data", "data")
+	content.SetAttribute("xmlns", "urn:data")
+	content.AppendChild(doc.CreateTextNode("signed content"))
+	signer := Signer{Key: key, SignatureMethod: ECDSASHA384}
+	if _, err := signer.SignEnveloping(doc, "obj", content); err != nil {
+		t.Fatal(err)
+	}
+	doc = reparse(t, doc)
+	sig := doc.GetDocumentElement()
+	if _, err := (&Verifier{Key: &key.PublicKey}).Verify(sig); err != nil {
+		t.Fatal(err)
+	}
+	object := childElements(sig, "Object")[0]
+	object.GetFirstChild().GetFirstChild().(dom.Text).SetValue("changed")
+	if _, err := (&Verifier{Key: &key.PublicKey}).Verify(sig); err == nil {
+		t.Errorf("Expecting digest mismatch")
+	}
+}
+
+func TestDetachedHMAC(t *testing.T) {
+	resources := map[string][]byte{
+		"http://example.com/a.txt": []byte("resource a"),
+	}
+	resolver := func(uri string) ([]byte, error) {
+		data, ok := resources[uri]
+		if !ok {
+			return nil, dom.ErrDOM{Typ: dom.NOT_FOUND_ERR, Msg: uri}
+		}
+		return data, nil
+	}
+	doc := parse(t, testDoc)
+	signer := Signer{Key: []byte("secret"), Resolver: resolver, SignatureMethod: HMACSHA512}
+	if _, err := signer.SignDetached(doc.GetDocumentElement(), "http://example.com/a.txt", "#i2"); err != nil {
+		t.Fatal(err)
+	}
+	doc = reparse(t, doc)
+	refs, err := (&Verifier{Key: []byte("secret"), Resolver: resolver}).Verify(onlySignature(t, doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 {
+		t.Errorf("Wrong references: %v", refs)
+	}
+	if _, err := (&Verifier{Key: []byte("wrong"), Resolver: resolver}).Verify(onlySignature(t, doc)); err == nil {
+		t.Errorf("Expecting signature mismatch")
+	}
+	resources["http://example.com/a.txt"] = []byte("changed")
+	if _, err := (&Verifier{Key: []byte("secret"), Resolver: resolver}).Verify(onlySignature(t, doc)); err == nil {
+		t.Errorf("Expecting digest mismatch")
+	}
+	if _, err := (&Verifier{Key: []byte("secret")}).Verify(onlySignature(t, doc)); err == nil {
+		t.Errorf("Expecting unresolved reference")
+	}
+	if _, err := (&Signer{Key: []byte("secret"), SignatureMethod: RSASHA256}).SignDetached(doc.GetDocumentElement(), "#i1"); err == nil {
+		t.Errorf("Expecting wrong key error")
+	}
+}
+
+func TestXPathTransform(t *testing.T) {
+	doc := parse(t, testDoc)
+	signer := Signer{Key: []byte("secret")}
+	_, err := signer.Sign(doc.GetDocumentElement(), Reference{
+		URI: "",
+		Transforms: []Transform{
+			{Algorithm: XPathFilter, XPath: "not(ancestor-or-self::p:item[@id='i2'])", Namespaces: map[string]string{"p": "urn:po"}},
+			{Algorithm: EnvelopedSignature},
+			{Algorithm: c14n.ExclusiveC14N, InclusivePrefixes: []string{"x"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc = reparse(t, doc)
+	verifier := Verifier{Key: []byte("secret")}
+	refs, err := verifier.Verify(onlySignature(t, doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs[0].Transforms[2].InclusivePrefixes[0] != "x" || refs[0].Transforms[0].Namespaces["p"] != "urn:po" {
+		t.Errorf("Wrong transforms: %v", refs[0].Transforms)
+	}
+	// i2 is not signed
+	doc.GetDocumentElement().GetLastChild().(dom.Element).SetAttribute("qty", "5")
+	if _, err := verifier.Verify(onlySignature(t, doc)); err != nil {
+		t.Error(err)
+	}
+	doc.GetDocumentElement().GetFirstElementChild().SetAttribute("qty", "5")
+	if _, err := verifier.Verify(onlySignature(t, doc)); err == nil {
+		t.Errorf("Expecting digest mismatch")
+	}
+}
+
+func TestDuplicateID(t *testing.T) {
+	doc := parse(t, `<a><b Id="x">1</b><c Id="x">2</c></a>`)
+	signer := Signer{Key: []byte("secret")}
+	if _, err := signer.SignDetached(doc.GetDocumentElement(), "#x"); err == nil {
+		t.Errorf("Expecting duplicate ID error")
+	}
+	if len(FindSignatures(doc)) != 0 {
+		t.Errorf("Signature not removed")
+	}
+}
+
+func TestVerifiedNodes(t *testing.T) {
+	doc := parse(t, testDoc)
+	signer := Signer{Key: []byte("secret")}
+	_, err := signer.Sign(doc.GetDocumentElement(),
+		Reference{URI: "", Transforms: []Transform{{Algorithm: EnvelopedSignature}}},
+		Reference{URI: "#i2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc = reparse(t, doc)
+	refs, err := (&Verifier{Key: []byte("secret")}).Verify(onlySignature(t, doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Node != doc || refs[1].Node != doc.GetDocumentElement().GetFirstElementChild().GetNextElementSibling() {
+		t.Errorf("Wrong signed nodes: %v", refs)
+	}
+}
+
+func TestSignErrors(t *testing.T) {
+	doc := parse(t, testDoc)
+	tx := doc.Begin()
+	doc.GetDocumentElement().SetAttribute("a", "1")
+	tx.Commit()
+	signer := Signer{Key: []byte("secret")}
+	refs := []Reference{{URI: "#i1"}}
+	// The document already has a document element
+	if _, err := signer.Sign(doc, refs...); err == nil {
+		t.Errorf("Expecting error")
+	}
+	if refs[0].DigestMethod != "" {
+		t.Errorf("References modified: %v", refs)
+	}
+	if len(FindSignatures(doc)) != 0 {
+		t.Errorf("Signature not removed")
+	}
+
+	// The content is moved back if signing fails
+	item := doc.GetDocumentElement().GetFirstElementChild()
+	next := item.GetNextSibling()
+	if _, err := (&Signer{Key: []byte("secret"), SignatureMethod: RSASHA256}).SignEnveloping(doc.GetDocumentElement(), "obj", item); err == nil {
+		t.Errorf("Expecting wrong key error")
+	}
+	if item.GetParentNode() != doc.GetDocumentElement() || item.GetNextSibling() != next {
+		t.Errorf("Content not restored")
+	}
+	if len(FindSignatures(doc)) != 0 {
+		t.Errorf("Signature not removed")
+	}
+	if !doc.CanUndo() {
+		t.Errorf("Failed signing cleared the history")
+	}
+
+	// Signing cannot be undone
+	if _, err := signer.SignEnveloped(doc.GetDocumentElement()); err != nil {
+		t.Fatal(err)
+	}
+	if doc.CanUndo() {
+		t.Errorf("Signing pushed to the undo stack")
+	}
+}
@@ -0,0 +1,470 @@
+package xpath
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/bserdar/go-dom"
+)
+
+// evalContext is the XPath evaluation context
+type evalContext struct {
+	node     dom.Node
+	position int
+	size     int
+	env      *environment
+}
+
+// environment contains the parts of the context that do not change
+// during evaluation
+type environment struct {
+	namespaces map[string]string
+	// defaultNamespace is used for unprefixed element names
+	defaultNamespace string
+	variables        map[string]interface{}
+	// resolver is used to resolve prefixes if namespaces is nil
+	resolver dom.Node
+}
+
+func (env *environment) lookupNamespace(prefix string) (string, error) {
+	if prefix == "xml" {
+		return xmlURL, nil
+	}
+	if env.namespaces != nil {
+		if ns, ok := env.namespaces[prefix]; ok {
+			return ns, nil
+		}
+	} else if env.resolver != nil {
+		if ns := env.resolver.LookupNamespaceURI(prefix); len(ns) > 0 {
+			return ns, nil
+		}
+	}
+	return "", dom.ErrDOM{
+		Typ: dom.NAMESPACE_ERR,
+		Msg: fmt.Sprintf("Undefined namespace prefix %s", prefix),
+		Op:  "Evaluate",
+	}
+}
+
+func typeError(msg string, args ...interface{}) error {
+	return dom.ErrDOM{
+		Typ: dom.TYPE_MISMATCH_ERR,
+		Msg: fmt.Sprintf(msg, args...),
+		Op:  "Evaluate",
+	}
+}
+
+// Value conversions. Values are one of []dom.Node, string, float64,
+// or bool
+
+func toBoolean(v interface{}) bool {
+	switch t := v.(type) {
+	case []dom.Node:
+		return len(t) > 0
+	case string:
+		return len(t) > 0
+	case float64:
+		return t != 0 && !math.IsNaN(t)
+	case bool:
+		return t
+	}
+	return false
+}
+
+func toNumber(v interface{}) float64 {
+	switch t := v.(type) {
+	case []dom.Node:
+		return parseNumber(toString(t))
+	case string:
+		return parseNumber(t)
+	case float64:
+		return t
+	case bool:
+		if t {
+			return 1
+		}
+		return 0
+	}
+	return math.NaN()
+}
+
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case []dom.Node:
+		if len(t) == 0 {
+			return ""
+		}
+		return stringValue(t[0])
+	case string:
+		return t
+	case float64:
+		return formatNumber(t)
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	}
+	return ""
+}
+
+// formatNumber converts a number to string using the XPath rules
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	case f == math.Trunc(f) && math.Abs(f) < 1e15:
+		return strconv.FormatFloat(f, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (ctx *evalContext) with(node dom.Node, position, size int) *evalContext {
+	return &evalContext{node: node, position: position, size: size, env: ctx.env}
+}
+
+func (ctx *evalContext) eval(e expr) (interface{}, error) {
+	switch t := e.(type) {
+	case *literalExpr:
+		return t.value, nil
+	case *numberExpr:
+		return t.value, nil
+	case *variableExpr:
+		v, ok := ctx.env.variables[t.name]
+		if !ok {
+			return nil, dom.ErrDOM{
+				Typ: dom.NOT_FOUND_ERR,
+				Msg: fmt.Sprintf("Undefined variable %s", t.name),
+				Op:  "Evaluate",
+			}
+		}
+		return v, nil
+	case *negateExpr:
+		v, err := ctx.eval(t.operand)
+		if err != nil {
+			return nil, err
+		}
+		return -toNumber(v), nil
+	case *binaryExpr:
+		return ctx.evalBinary(t)
+	case *unionExpr:
+		left, err := ctx.evalNodeSet(t.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := ctx.evalNodeSet(t.right)
+		if err != nil {
+			return nil, err
+		}
+		all := make([]dom.Node, 0, len(left)+len(right))
+		all = append(all, left...)
+		all = append(all, right...)
+		return sortDocumentOrder(all), nil
+	case *functionCall:
+		return t.fn.call(ctx, t.args)
+	case *filterExpr:
+		nodes, err := ctx.evalNodeSet(t.primary)
+		if err != nil {
+			return nil, err
+		}
+		for _, pred := range t.predicates {
+			nodes, err = ctx.filter(nodes, pred)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nodes, nil
+	case *pathExpr:
+		return ctx.evalPath(t)
+	}
+	return nil, fmt.Errorf("Unknown expression %T", e)
+}
+
+func (ctx *evalContext) evalNodeSet(e expr) ([]dom.Node, error) {
+	v, err := ctx.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.([]dom.Node)
+	if !ok {
+		return nil, typeError("Expression does not evaluate to a node-set")
+	}
+	return nodes, nil
+}
+
+func (ctx *evalContext) evalBinary(e *binaryExpr) (interface{}, error) {
+	left, err := ctx.eval(e.left)
+	if err != nil {
+		return nil, err
+	}
+	// Short circuit boolean operators
+	switch e.op {
+	case "or":
+		if toBoolean(left) {
+			return true, nil
+		}
+		right, err := ctx.eval(e.right)
+		if err != nil {
+			return nil, err
+		}
+		return toBoolean(right), nil
+	case "and":
+		if !toBoolean(left) {
+			return false, nil
+		}
+		right, err := ctx.eval(e.right)
+		if err != nil {
+			return nil, err
+		}
+		return toBoolean(right), nil
+	}
+	right, err := ctx.eval(e.right)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compareValues(e.op, left, right), nil
+	case "+":
+		return toNumber(left) + toNumber(right), nil
+	case "-":
+		return toNumber(left) - toNumber(right), nil
+	case "*":
+		return toNumber(left) * toNumber(right), nil
+	case "div":
+		return toNumber(left) / toNumber(right), nil
+	case "mod":
+		return math.Mod(toNumber(left), toNumber(right)), nil
+	}
+	return nil, fmt.Errorf("Unknown operator %s", e.op)
+}
+
+func compareAtoms(op string, left, right interface{}) bool {
+	if op == "=" || op == "!=" {
+		var eq bool
+		switch {
+		case isBool(left) || isBool(right):
+			eq = toBoolean(left) == toBoolean(right)
+		case isNumber(left) || isNumber(right):
+			eq = toNumber(left) == toNumber(right)
+		default:
+			eq = toString(left) == toString(right)
+		}
+		if op == "=" {
+			return eq
+		}
+		return !eq
+	}
+	l, r := toNumber(left), toNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+	return false
+}
+
+func isBool(v interface{}) bool {
+	_, ok := v.(bool)
+	return ok
+}
+
+func isNumber(v interface{}) bool {
+	_, ok := v.(float64)
+	return ok
+}
+
+// compareValues compares two values. If one of the values is a
+// node-set, the comparison is true if it is true for any of the
+// nodes in the node-set.
+func compareValues(op string, left, right interface{}) bool {
+	lnodes, lok := left.([]dom.Node)
+	rnodes, rok := right.([]dom.Node)
+	switch {
+	case lok && rok:
+		rstrs := make([]string, len(rnodes))
+		for i, n := range rnodes {
+			rstrs[i] = stringValue(n)
+		}
+		for _, l := range lnodes {
+			ls := stringValue(l)
+			for _, rs := range rstrs {
+				if compareAtoms(op, ls, rs) {
+					return true
+				}
+			}
+		}
+		return false
+	case lok:
+		if isBool(right) {
+			return compareAtoms(op, len(lnodes) > 0, right)
+		}
+		for _, l := range lnodes {
+			if compareAtoms(op, atomize(l, right), right) {
+				return true
+			}
+		}
+		return false
+	case rok:
+		if isBool(left) {
+			return compareAtoms(op, left, len(rnodes) > 0)
+		}
+		for _, r := range rnodes {
+			if compareAtoms(op, left, atomize(r, left)) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(op, left, right)
+}
+
+// atomize converts a node to a number or a string depending on the
+// type of the value it is compared with
+func atomize(node dom.Node, other interface{}) interface{} {
+	if isNumber(other) {
+		return parseNumber(stringValue(node))
+	}
+	return stringValue(node)
+}
+
+// filter applies a predicate to nodes. The nodes are in the order
+// positions are assigned.
+func (ctx *evalContext) filter(nodes []dom.Node, pred expr) ([]dom.Node, error) {
+	ret := make([]dom.Node, 0, len(nodes))
+	for i, node := range nodes {
+		v, err := ctx.with(node, i+1, len(nodes)).eval(pred)
+		if err != nil {
+			return nil, err
+		}
+		var keep bool
+		if num, ok := v.(float64); ok {
+			keep = num == float64(i+1)
+		} else {
+			keep = toBoolean(v)
+		}
+		if keep {
+			ret = append(ret, node)
+		}
+	}
+	return ret, nil
+}
+
+func (ctx *evalContext) evalPath(p *pathExpr) (interface{}, error) {
+	var nodes []dom.Node
+	switch {
+	case p.filter != nil:
+		var err error
+		nodes, err = ctx.evalNodeSet(p.filter)
+		if err != nil {
+			return nil, err
+		}
+	case p.absolute:
+		root := ctx.node
+		if parent := parentOf(root); parent != nil {
+			root = parent.GetRootNode()
+		}
+		nodes = []dom.Node{root}
+	default:
+		nodes = []dom.Node{ctx.node}
+	}
+	for _, s := range p.steps {
+		result := make([]dom.Node, 0)
+		for _, node := range nodes {
+			selected, err := ctx.evalStep(s, node)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, selected...)
+		}
+		if len(nodes) > 1 || s.axis.isReverse() {
+			result = sortDocumentOrder(result)
+		}
+		nodes = result
+	}
+	return nodes, nil
+}
+
+func (ctx *evalContext) evalStep(s *step, node dom.Node) ([]dom.Node, error) {
+	candidates := axisNodes(s.axis, node)
+	selected := make([]dom.Node, 0, len(candidates))
+	for _, c := range candidates {
+		ok, err := ctx.matches(s, c)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selected = append(selected, c)
+		}
+	}
+	var err error
+	for _, pred := range s.predicates {
+		selected, err = ctx.filter(selected, pred)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return selected, nil
+}
+
+// matches evaluates the node test of the step for the node
+func (ctx *evalContext) matches(s *step, node dom.Node) (bool, error) {
+	typ := node.GetNodeType()
+	switch s.test.kind {
+	case testNode:
+		return true, nil
+	case testText:
+		return typ == dom.TEXT_NODE || typ == dom.CDATA_SECTION_NODE, nil
+	case testComment:
+		return typ == dom.COMMENT_NODE, nil
+	case testPI:
+		if typ != dom.PROCESSING_INSTRUCTION_NODE {
+			return false, nil
+		}
+		return len(s.test.target) == 0 || node.(dom.ProcessingInstruction).GetTarget() == s.test.target, nil
+	}
+	// Name test. Only nodes of the principal node type match
+	switch s.axis {
+	case axisAttribute, axisNamespace:
+		if typ != dom.ATTRIBUTE_NODE {
+			return false, nil
+		}
+	default:
+		if typ != dom.ELEMENT_NODE {
+			return false, nil
+		}
+	}
+	if len(s.test.local) == 0 {
+		return true, nil
+	}
+	ns, local := expandedName(node)
+	if s.axis == axisNamespace {
+		return len(s.test.prefix) == 0 && s.test.local == local, nil
+	}
+	testNS := ""
+	if len(s.test.prefix) > 0 {
+		var err error
+		testNS, err = ctx.env.lookupNamespace(s.test.prefix)
+		if err != nil {
+			return false, err
+		}
+	} else if s.axis != axisAttribute {
+		testNS = ctx.env.defaultNamespace
+	}
+	if ns != testNS {
+		return false, nil
+	}
+	return s.test.local == "*" || s.test.local == local, nil
+}
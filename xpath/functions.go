@@ -0,0 +1,350 @@
+package xpath
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bserdar/go-dom"
+)
+
+type function struct {
+	minArgs int
+	// maxArgs is -1 for functions with variable number of arguments
+	maxArgs int
+	call    func(ctx *evalContext, args []expr) (interface{}, error)
+}
+
+// evalArgs evaluates all arguments
+func evalArgs(ctx *evalContext, args []expr) ([]interface{}, error) {
+	ret := make([]interface{}, len(args))
+	for i, a := range args {
+		v, err := ctx.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+// stringFunc builds a function whose arguments are converted to
+// strings
+func stringFunc(min, max int, fn func(ctx *evalContext, args []string) interface{}) *function {
+	return &function{
+		minArgs: min,
+		maxArgs: max,
+		call: func(ctx *evalContext, args []expr) (interface{}, error) {
+			values, err := evalArgs(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+			strs := make([]string, len(values))
+			for i, v := range values {
+				strs[i] = toString(v)
+			}
+			return fn(ctx, strs), nil
+		},
+	}
+}
+
+// stringOrContext returns the argument as a string, or the
+// string-value of the context node if there are no arguments
+func stringOrContext(ctx *evalContext, args []string) string {
+	if len(args) == 0 {
+		return stringValue(ctx.node)
+	}
+	return args[0]
+}
+
+func numberFunc(fn func(float64) float64) *function {
+	return &function{
+		minArgs: 1,
+		maxArgs: 1,
+		call: func(ctx *evalContext, args []expr) (interface{}, error) {
+			v, err := ctx.eval(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return fn(toNumber(v)), nil
+		},
+	}
+}
+
+// nodeFunc builds a function that takes an optional node-set
+// argument, and operates on the first node of it, or on the context
+// node if there are no arguments. If the node-set is empty, the
+// function returns the empty string
+func nodeFunc(fn func(dom.Node) string) *function {
+	return &function{
+		minArgs: 0,
+		maxArgs: 1,
+		call: func(ctx *evalContext, args []expr) (interface{}, error) {
+			if len(args) == 0 {
+				return fn(ctx.node), nil
+			}
+			nodes, err := ctx.evalNodeSet(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if len(nodes) == 0 {
+				return "", nil
+			}
+			return fn(nodes[0]), nil
+		},
+	}
+}
+
+var coreFunctions map[string]*function
+
+func init() {
+	coreFunctions = map[string]*function{
+		// Node set functions
+		"last": {call: func(ctx *evalContext, _ []expr) (interface{}, error) {
+			return float64(ctx.size), nil
+		}},
+		"position": {call: func(ctx *evalContext, _ []expr) (interface{}, error) {
+			return float64(ctx.position), nil
+		}},
+		"count": {minArgs: 1, maxArgs: 1, call: func(ctx *evalContext, args []expr) (interface{}, error) {
+			nodes, err := ctx.evalNodeSet(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return float64(len(nodes)), nil
+		}},
+		"id": {minArgs: 1, maxArgs: 1, call: fnID},
+		"local-name": nodeFunc(func(node dom.Node) string {
+			_, local := expandedName(node)
+			return local
+		}),
+		"namespace-uri": nodeFunc(func(node dom.Node) string {
+			ns, _ := expandedName(node)
+			return ns
+		}),
+		"name": nodeFunc(qualifiedName),
+
+		// String functions
+		"string": {minArgs: 0, maxArgs: 1, call: func(ctx *evalContext, args []expr) (interface{}, error) {
+			if len(args) == 0 {
+				return stringValue(ctx.node), nil
+			}
+			v, err := ctx.eval(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return toString(v), nil
+		}},
+		"concat": stringFunc(2, -1, func(_ *evalContext, args []string) interface{} {
+			return strings.Join(args, "")
+		}),
+		"starts-with": stringFunc(2, 2, func(_ *evalContext, args []string) interface{} {
+			return strings.HasPrefix(args[0], args[1])
+		}),
+		"contains": stringFunc(2, 2, func(_ *evalContext, args []string) interface{} {
+			return strings.Contains(args[0], args[1])
+		}),
+		"substring-before": stringFunc(2, 2, func(_ *evalContext, args []string) interface{} {
+			ix := strings.Index(args[0], args[1])
+			if ix == -1 {
+				return ""
+			}
+			return args[0][:ix]
+		}),
+		"substring-after": stringFunc(2, 2, func(_ *evalContext, args []string) interface{} {
+			ix := strings.Index(args[0], args[1])
+			if ix == -1 {
+				return ""
+			}
+			return args[0][ix+len(args[1]):]
+		}),
+		"substring": {minArgs: 2, maxArgs: 3, call: fnSubstring},
+		"string-length": stringFunc(0, 1, func(ctx *evalContext, args []string) interface{} {
+			return float64(utf8.RuneCountInString(stringOrContext(ctx, args)))
+		}),
+		"normalize-space": stringFunc(0, 1, func(ctx *evalContext, args []string) interface{} {
+			return strings.Join(strings.FieldsFunc(stringOrContext(ctx, args), isXMLSpace), " ")
+		}),
+		"translate": stringFunc(3, 3, func(_ *evalContext, args []string) interface{} {
+			from := []rune(args[1])
+			to := []rune(args[2])
+			mapping := make(map[rune]int, len(from))
+			for i, r := range from {
+				if _, ok := mapping[r]; !ok {
+					mapping[r] = i
+				}
+			}
+			var sb strings.Builder
+			for _, r := range args[0] {
+				ix, ok := mapping[r]
+				if !ok {
+					sb.WriteRune(r)
+				} else if ix < len(to) {
+					sb.WriteRune(to[ix])
+				}
+			}
+			return sb.String()
+		}),
+
+		// Boolean functions
+		"boolean": {minArgs: 1, maxArgs: 1, call: func(ctx *evalContext, args []expr) (interface{}, error) {
+			v, err := ctx.eval(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return toBoolean(v), nil
+		}},
+		"not": {minArgs: 1, maxArgs: 1, call: func(ctx *evalContext, args []expr) (interface{}, error) {
+			v, err := ctx.eval(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return !toBoolean(v), nil
+		}},
+		"true": {call: func(*evalContext, []expr) (interface{}, error) {
+			return true, nil
+		}},
+		"false": {call: func(*evalContext, []expr) (interface{}, error) {
+			return false, nil
+		}},
+		"lang": stringFunc(1, 1, func(ctx *evalContext, args []string) interface{} {
+			for trc := ctx.node; trc != nil; trc = parentOf(trc) {
+				el, ok := trc.(dom.Element)
+				if !ok {
+					continue
+				}
+				attrs := el.GetAttributes()
+				for i := 0; i < attrs.GetLength(); i++ {
+					attr := attrs.Item(i)
+					ns, local := expandedName(attr)
+					if ns == xmlURL && local == "lang" {
+						lang := strings.ToLower(attr.GetValue())
+						want := strings.ToLower(args[0])
+						return lang == want || strings.HasPrefix(lang, want+"-")
+					}
+				}
+			}
+			return false
+		}),
+
+		// Number functions
+		"number": {minArgs: 0, maxArgs: 1, call: func(ctx *evalContext, args []expr) (interface{}, error) {
+			if len(args) == 0 {
+				return parseNumber(stringValue(ctx.node)), nil
+			}
+			v, err := ctx.eval(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return toNumber(v), nil
+		}},
+		"sum": {minArgs: 1, maxArgs: 1, call: func(ctx *evalContext, args []expr) (interface{}, error) {
+			nodes, err := ctx.evalNodeSet(args[0])
+			if err != nil {
+				return nil, err
+			}
+			sum := 0.0
+			for _, n := range nodes {
+				sum += parseNumber(stringValue(n))
+			}
+			return sum, nil
+		}},
+		"floor":   numberFunc(math.Floor),
+		"ceiling": numberFunc(math.Ceil),
+		"round":   numberFunc(round),
+	}
+}
+
+func isXMLSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+}
+
+// round returns the integer closest to f. If there are two such
+// numbers, the one closest to positive infinity is returned.
+func round(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f == 0 {
+		return f
+	}
+	if f < 0 && f >= -0.5 {
+		return math.Copysign(0, -1)
+	}
+	return math.Floor(f + 0.5)
+}
+
+func fnSubstring(ctx *evalContext, args []expr) (interface{}, error) {
+	values, err := evalArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	str := []rune(toString(values[0]))
+	start := round(toNumber(values[1]))
+	end := math.Inf(1)
+	if len(values) == 3 {
+		end = start + round(toNumber(values[2]))
+	}
+	var sb strings.Builder
+	for i, r := range str {
+		pos := float64(i + 1)
+		if pos >= start && pos < end {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String(), nil
+}
+
+// fnID implements the id() function. In documents, elements are
+// identified using the ID attributes of the document. Otherwise,
+// xml:id or id attributes are used.
+func fnID(ctx *evalContext, args []expr) (interface{}, error) {
+	v, err := ctx.eval(args[0])
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]struct{})
+	if nodes, ok := v.([]dom.Node); ok {
+		for _, n := range nodes {
+			for _, id := range strings.FieldsFunc(stringValue(n), isXMLSpace) {
+				ids[id] = struct{}{}
+			}
+		}
+	} else {
+		for _, id := range strings.FieldsFunc(toString(v), isXMLSpace) {
+			ids[id] = struct{}{}
+		}
+	}
+	ret := make([]dom.Node, 0)
+	if len(ids) == 0 {
+		return ret, nil
+	}
+	root := ctx.node
+	if parent := parentOf(root); parent != nil {
+		root = parent.GetRootNode()
+	}
+	if doc, ok := root.(dom.Document); ok {
+		for id := range ids {
+			if el := doc.GetElementById(id); el != nil {
+				ret = append(ret, el)
+			}
+		}
+		return sortDocumentOrder(ret), nil
+	}
+	walkDescendants(root, func(node dom.Node) {
+		el, ok := node.(dom.Element)
+		if !ok {
+			return
+		}
+		attrs := el.GetAttributes()
+		for i := 0; i < attrs.GetLength(); i++ {
+			attr := attrs.Item(i)
+			ns, local := expandedName(attr)
+			if local != "id" || (len(ns) > 0 && ns != xmlURL) {
+				continue
+			}
+			if _, ok := ids[attr.GetValue()]; ok {
+				ret = append(ret, el)
+				return
+			}
+		}
+	})
+	return ret, nil
+}
@@ -0,0 +1,320 @@
+package xpath
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bserdar/go-dom"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokNumber
+	tokLiteral
+	tokVariable
+	// tokName is a NameTest (NCName, QName, prefix:* or *)
+	tokName
+	// tokFunction is a function name followed by '('
+	tokFunction
+	// tokNodeType is one of comment, text, processing-instruction, node followed by '('
+	tokNodeType
+	// tokAxis is an axis name followed by '::'
+	tokAxis
+	// tokOperator is an operator name (and, or, mod, div) or the multiply operator
+	tokOperator
+	tokSlash
+	tokDoubleSlash
+	tokPipe
+	tokPlus
+	tokMinus
+	tokEq
+	tokNeq
+	tokLt
+	tokLte
+	tokGt
+	tokGte
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokDot
+	tokDoubleDot
+	tokAt
+	tokComma
+	tokDoubleColon
+)
+
+type token struct {
+	typ tokenType
+	// Textual value of the token
+	val string
+	num float64
+	pos int
+}
+
+func syntaxError(pos int, msg string, args ...interface{}) error {
+	return dom.ErrDOM{
+		Typ: dom.SYNTAX_ERR,
+		Msg: fmt.Sprintf("%d: %s", pos, fmt.Sprintf(msg, args...)),
+		Op:  "Compile",
+	}
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || r == '-' || r == '.' || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r)
+}
+
+type lexer struct {
+	input  string
+	pos    int
+	tokens []token
+}
+
+func (l *lexer) peekRune(offset int) rune {
+	p := l.pos
+	for i := 0; i < offset; i++ {
+		if p >= len(l.input) {
+			return 0
+		}
+		_, w := utf8.DecodeRuneInString(l.input[p:])
+		p += w
+	}
+	if p >= len(l.input) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[p:])
+	return r
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.input) {
+		r, w := utf8.DecodeRuneInString(l.input[l.pos:])
+		if r != ' ' && r != '\t' && r != '\n' && r != '\r' {
+			return
+		}
+		l.pos += w
+	}
+}
+
+func (l *lexer) scanNCName() string {
+	start := l.pos
+	for l.pos < len(l.input) {
+		r, w := utf8.DecodeRuneInString(l.input[l.pos:])
+		if l.pos == start && !isNameStart(r) {
+			break
+		}
+		if !isNameChar(r) {
+			break
+		}
+		l.pos += w
+	}
+	return l.input[start:l.pos]
+}
+
+// nextNonSpace returns the next non-space rune after pos without
+// consuming it, and its position
+func (l *lexer) nextNonSpace(pos int) (rune, int) {
+	for pos < len(l.input) {
+		r, w := utf8.DecodeRuneInString(l.input[pos:])
+		if r != ' ' && r != '\t' && r != '\n' && r != '\r' {
+			return r, pos
+		}
+		pos += w
+	}
+	return 0, pos
+}
+
+// precedingAllowsOperator implements the disambiguation rule of
+// XPath 1.0, section 3.7: if there is a preceding token and it is
+// not one of @, ::, (, [, , or an Operator, then a * must be
+// recognized as a MultiplyOperator and an NCName must be recognized
+// as an OperatorName.
+func (l *lexer) precedingAllowsOperator() bool {
+	if len(l.tokens) == 0 {
+		return false
+	}
+	switch l.tokens[len(l.tokens)-1].typ {
+	case tokAt, tokDoubleColon, tokLParen, tokLBracket, tokComma, tokOperator,
+		tokSlash, tokDoubleSlash, tokPipe, tokPlus, tokMinus, tokEq, tokNeq,
+		tokLt, tokLte, tokGt, tokGte:
+		return false
+	}
+	return true
+}
+
+var nodeTypeNames = map[string]struct{}{
+	"comment":                {},
+	"text":                   {},
+	"processing-instruction": {},
+	"node":                   {},
+}
+
+func tokenize(input string) ([]token, error) {
+	l := lexer{input: input}
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.input) {
+			l.tokens = append(l.tokens, token{typ: tokEOF, pos: l.pos})
+			return l.tokens, nil
+		}
+		start := l.pos
+		r := l.peekRune(0)
+		emit := func(typ tokenType, n int) {
+			l.tokens = append(l.tokens, token{typ: typ, val: l.input[start : start+n], pos: start})
+			l.pos = start + n
+		}
+		switch {
+		case r == '(':
+			emit(tokLParen, 1)
+		case r == ')':
+			emit(tokRParen, 1)
+		case r == '[':
+			emit(tokLBracket, 1)
+		case r == ']':
+			emit(tokRBracket, 1)
+		case r == '@':
+			emit(tokAt, 1)
+		case r == ',':
+			emit(tokComma, 1)
+		case r == '|':
+			emit(tokPipe, 1)
+		case r == '+':
+			emit(tokPlus, 1)
+		case r == '-':
+			emit(tokMinus, 1)
+		case r == '=':
+			emit(tokEq, 1)
+		case r == '!':
+			if l.peekRune(1) != '=' {
+				return nil, syntaxError(start, "Unexpected '!'")
+			}
+			emit(tokNeq, 2)
+		case r == '<':
+			if l.peekRune(1) == '=' {
+				emit(tokLte, 2)
+			} else {
+				emit(tokLt, 1)
+			}
+		case r == '>':
+			if l.peekRune(1) == '=' {
+				emit(tokGte, 2)
+			} else {
+				emit(tokGt, 1)
+			}
+		case r == '/':
+			if l.peekRune(1) == '/' {
+				emit(tokDoubleSlash, 2)
+			} else {
+				emit(tokSlash, 1)
+			}
+		case r == ':':
+			if l.peekRune(1) != ':' {
+				return nil, syntaxError(start, "Unexpected ':'")
+			}
+			emit(tokDoubleColon, 2)
+		case r == '.' && l.peekRune(1) == '.':
+			emit(tokDoubleDot, 2)
+		case r == '.' && !isDigit(l.peekRune(1)):
+			emit(tokDot, 1)
+		case r == '.' || isDigit(r):
+			for isDigit(l.peekRune(0)) {
+				l.pos++
+			}
+			if l.peekRune(0) == '.' {
+				l.pos++
+				for isDigit(l.peekRune(0)) {
+					l.pos++
+				}
+			}
+			str := l.input[start:l.pos]
+			l.tokens = append(l.tokens, token{typ: tokNumber, val: str, num: parseNumber(str), pos: start})
+		case r == '"' || r == '\'':
+			end := strings.IndexRune(l.input[start+1:], r)
+			if end == -1 {
+				return nil, syntaxError(start, "Unterminated literal")
+			}
+			l.tokens = append(l.tokens, token{typ: tokLiteral, val: l.input[start+1 : start+1+end], pos: start})
+			l.pos = start + end + 2
+		case r == '$':
+			l.pos++
+			name := l.scanQName()
+			if len(name) == 0 {
+				return nil, syntaxError(start, "Invalid variable reference")
+			}
+			l.tokens = append(l.tokens, token{typ: tokVariable, val: name, pos: start})
+		case r == '*':
+			if l.precedingAllowsOperator() {
+				emit(tokOperator, 1)
+			} else {
+				emit(tokName, 1)
+			}
+		case isNameStart(r):
+			if l.precedingAllowsOperator() {
+				name := l.scanNCName()
+				switch name {
+				case "and", "or", "mod", "div":
+					l.tokens = append(l.tokens, token{typ: tokOperator, val: name, pos: start})
+					continue
+				}
+				return nil, syntaxError(start, "Expecting operator, got %s", name)
+			}
+			name := l.scanNCName()
+			// Axis name?
+			next, nextPos := l.nextNonSpace(l.pos)
+			if next == ':' && strings.HasPrefix(l.input[nextPos:], "::") {
+				l.tokens = append(l.tokens, token{typ: tokAxis, val: name, pos: start})
+				continue
+			}
+			// QName or prefix:*
+			if l.peekRune(0) == ':' && l.peekRune(1) != ':' {
+				l.pos++
+				if l.peekRune(0) == '*' {
+					l.pos++
+					l.tokens = append(l.tokens, token{typ: tokName, val: l.input[start:l.pos], pos: start})
+					continue
+				}
+				local := l.scanNCName()
+				if len(local) == 0 {
+					return nil, syntaxError(start, "Invalid qualified name")
+				}
+				name = l.input[start:l.pos]
+			}
+			next, _ = l.nextNonSpace(l.pos)
+			if next == '(' {
+				if _, ok := nodeTypeNames[name]; ok {
+					l.tokens = append(l.tokens, token{typ: tokNodeType, val: name, pos: start})
+				} else {
+					l.tokens = append(l.tokens, token{typ: tokFunction, val: name, pos: start})
+				}
+				continue
+			}
+			l.tokens = append(l.tokens, token{typ: tokName, val: name, pos: start})
+		default:
+			return nil, syntaxError(start, "Unexpected character '%c'", r)
+		}
+	}
+}
+
+func (l *lexer) scanQName() string {
+	start := l.pos
+	if len(l.scanNCName()) == 0 {
+		return ""
+	}
+	if l.peekRune(0) == ':' && isNameStart(l.peekRune(1)) {
+		l.pos++
+		l.scanNCName()
+	}
+	return l.input[start:l.pos]
+}
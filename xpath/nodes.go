@@ -0,0 +1,354 @@
+package xpath
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bserdar/go-dom"
+)
+
+const (
+	xmlURL   = "http://www.w3.org/XML/1998/namespace"
+	xmlnsURL = "http://www.w3.org/2000/xmlns"
+)
+
+// isNamespaceDecl returns true if attr is an xmlns or xmlns:prefix
+// attribute. These are not attributes in the XPath data model, they
+// are namespace nodes.
+func isNamespaceDecl(attr dom.Attr) bool {
+	name := attr.GetQName()
+	if name.Prefix == "xmlns" || name.Space == xmlnsURL {
+		return true
+	}
+	return len(name.Prefix) == 0 && name.Local == "xmlns"
+}
+
+// namespacePrefix returns the prefix declared by a namespace
+// declaration attribute
+func namespacePrefix(attr dom.Attr) string {
+	name := attr.GetQName()
+	if len(name.Prefix) == 0 && name.Local == "xmlns" {
+		return ""
+	}
+	return name.Local
+}
+
+// stringValue returns the string-value of a node
+func stringValue(node dom.Node) string {
+	switch n := node.(type) {
+	case dom.Attr:
+		return n.GetValue()
+	case dom.CharacterData:
+		return n.GetValue()
+	}
+	var sb strings.Builder
+	var collect func(dom.Node)
+	collect = func(nd dom.Node) {
+		for ch := nd.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+			switch ch.GetNodeType() {
+			case dom.TEXT_NODE, dom.CDATA_SECTION_NODE:
+				sb.WriteString(ch.(dom.CharacterData).GetValue())
+			case dom.ELEMENT_NODE:
+				collect(ch)
+			}
+		}
+	}
+	collect(node)
+	return sb.String()
+}
+
+// expandedName returns the namespace URI and the local name of a
+// node. Nodes that do not have a name return empty strings.
+func expandedName(node dom.Node) (string, string) {
+	switch n := node.(type) {
+	case dom.Element:
+		q := n.GetQName()
+		return q.Space, q.Local
+	case dom.Attr:
+		if isNamespaceDecl(n) {
+			return "", namespacePrefix(n)
+		}
+		q := n.GetQName()
+		if len(q.Space) == 0 && q.Prefix == "xml" {
+			return xmlURL, q.Local
+		}
+		return q.Space, q.Local
+	case dom.ProcessingInstruction:
+		return "", n.GetTarget()
+	}
+	return "", ""
+}
+
+// qualifiedName returns the QName of the node as it appears in the
+// document
+func qualifiedName(node dom.Node) string {
+	switch n := node.(type) {
+	case dom.Element:
+		q := n.GetQName()
+		return q.QName()
+	case dom.Attr:
+		if isNamespaceDecl(n) {
+			return namespacePrefix(n)
+		}
+		q := n.GetQName()
+		return q.QName()
+	case dom.ProcessingInstruction:
+		return n.GetTarget()
+	}
+	return ""
+}
+
+func attributes(node dom.Node) []dom.Node {
+	el, ok := node.(dom.Element)
+	if !ok {
+		return nil
+	}
+	attrs := el.GetAttributes()
+	ret := make([]dom.Node, 0, attrs.GetLength())
+	for i := 0; i < attrs.GetLength(); i++ {
+		attr := attrs.Item(i)
+		if !isNamespaceDecl(attr) {
+			ret = append(ret, attr)
+		}
+	}
+	return ret
+}
+
+// namespaceNodes returns the namespace declarations in scope for
+// node. The DOM does not have namespace nodes, so these are the
+// closest xmlns attributes declaring each prefix. Undeclarations
+// (xmlns="") are not included.
+func namespaceNodes(node dom.Node) []dom.Node {
+	if _, ok := node.(dom.Element); !ok {
+		return nil
+	}
+	seen := make(map[string]struct{})
+	ret := make([]dom.Node, 0)
+	for trc := node; trc != nil; trc = trc.GetParentNode() {
+		el, ok := trc.(dom.Element)
+		if !ok {
+			break
+		}
+		attrs := el.GetAttributes()
+		for i := 0; i < attrs.GetLength(); i++ {
+			attr := attrs.Item(i)
+			if !isNamespaceDecl(attr) {
+				continue
+			}
+			prefix := namespacePrefix(attr)
+			if _, ok := seen[prefix]; ok {
+				continue
+			}
+			seen[prefix] = struct{}{}
+			if len(attr.GetValue()) > 0 {
+				ret = append(ret, attr)
+			}
+		}
+	}
+	return ret
+}
+
+// parentOf returns the parent of a node in the XPath data model. The
+// parent of an attribute is its owner element.
+func parentOf(node dom.Node) dom.Node {
+	if attr, ok := node.(dom.Attr); ok {
+		if el := attr.GetOwnerElement(); el != nil {
+			return el
+		}
+		return nil
+	}
+	return node.GetParentNode()
+}
+
+// walkDescendants calls fn for all descendants of node in document
+// order
+func walkDescendants(node dom.Node, fn func(dom.Node)) {
+	for ch := node.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+		fn(ch)
+		walkDescendants(ch, fn)
+	}
+}
+
+// walkDescendantsReverse calls fn for all descendants of node in
+// reverse document order
+func walkDescendantsReverse(node dom.Node, fn func(dom.Node)) {
+	for ch := node.GetLastChild(); ch != nil; ch = ch.GetPreviousSibling() {
+		walkDescendantsReverse(ch, fn)
+		fn(ch)
+	}
+}
+
+// axisNodes returns the nodes on the axis from node, in axis order
+func axisNodes(axis axisType, node dom.Node) []dom.Node {
+	ret := make([]dom.Node, 0)
+	add := func(n dom.Node) { ret = append(ret, n) }
+	_, isAttr := node.(dom.Attr)
+	switch axis {
+	case axisSelf:
+		add(node)
+	case axisChild:
+		if !isAttr {
+			for ch := node.GetFirstChild(); ch != nil; ch = ch.GetNextSibling() {
+				add(ch)
+			}
+		}
+	case axisDescendant:
+		if !isAttr {
+			walkDescendants(node, add)
+		}
+	case axisDescendantOrSelf:
+		add(node)
+		if !isAttr {
+			walkDescendants(node, add)
+		}
+	case axisParent:
+		if p := parentOf(node); p != nil {
+			add(p)
+		}
+	case axisAncestor:
+		for p := parentOf(node); p != nil; p = p.GetParentNode() {
+			add(p)
+		}
+	case axisAncestorOrSelf:
+		add(node)
+		for p := parentOf(node); p != nil; p = p.GetParentNode() {
+			add(p)
+		}
+	case axisFollowingSibling:
+		if !isAttr {
+			for s := node.GetNextSibling(); s != nil; s = s.GetNextSibling() {
+				add(s)
+			}
+		}
+	case axisPrecedingSibling:
+		if !isAttr {
+			for s := node.GetPreviousSibling(); s != nil; s = s.GetPreviousSibling() {
+				add(s)
+			}
+		}
+	case axisFollowing:
+		start := node
+		if isAttr {
+			start = parentOf(node)
+			if start == nil {
+				break
+			}
+			walkDescendants(start, add)
+		}
+		for trc := start; trc != nil; trc = trc.GetParentNode() {
+			for s := trc.GetNextSibling(); s != nil; s = s.GetNextSibling() {
+				add(s)
+				walkDescendants(s, add)
+			}
+		}
+	case axisPreceding:
+		start := node
+		if isAttr {
+			start = parentOf(node)
+		}
+		for trc := start; trc != nil; trc = trc.GetParentNode() {
+			for s := trc.GetPreviousSibling(); s != nil; s = s.GetPreviousSibling() {
+				walkDescendantsReverse(s, add)
+				add(s)
+			}
+		}
+	case axisAttribute:
+		return attributes(node)
+	case axisNamespace:
+		return namespaceNodes(node)
+	}
+	return ret
+}
+
+// orderKey is the position of a node in document order. It is the
+// list of child indexes from the root. Attributes are placed after
+// their owner element and before its children.
+type orderKey struct {
+	root dom.Node
+	path []int
+}
+
+func nodeOrderKey(node dom.Node) orderKey {
+	rev := make([]int, 0, 16)
+	trc := node
+	if attr, ok := node.(dom.Attr); ok {
+		el := attr.GetOwnerElement()
+		if el == nil {
+			return orderKey{root: node}
+		}
+		attrs := el.GetAttributes()
+		ix := 0
+		for i := 0; i < attrs.GetLength(); i++ {
+			if attrs.Item(i) == attr {
+				ix = i
+				break
+			}
+		}
+		// Attributes sort before children, which have index >= 0
+		rev = append(rev, -attrs.GetLength()-1+ix)
+		trc = el
+	}
+	for {
+		parent := trc.GetParentNode()
+		if parent == nil {
+			break
+		}
+		ix := 0
+		for s := trc.GetPreviousSibling(); s != nil; s = s.GetPreviousSibling() {
+			ix++
+		}
+		rev = append(rev, ix)
+		trc = parent
+	}
+	path := make([]int, len(rev))
+	for i := range rev {
+		path[i] = rev[len(rev)-1-i]
+	}
+	return orderKey{root: trc, path: path}
+}
+
+func compareKeys(k1, k2 orderKey, roots map[dom.Node]int) int {
+	if k1.root != k2.root {
+		return roots[k1.root] - roots[k2.root]
+	}
+	for i := 0; i < len(k1.path) && i < len(k2.path); i++ {
+		if k1.path[i] != k2.path[i] {
+			return k1.path[i] - k2.path[i]
+		}
+	}
+	return len(k1.path) - len(k2.path)
+}
+
+// sortDocumentOrder sorts nodes in document order and removes
+// duplicates. Nodes in different trees are grouped by tree.
+func sortDocumentOrder(nodes []dom.Node) []dom.Node {
+	if len(nodes) < 2 {
+		return nodes
+	}
+	type keyed struct {
+		node dom.Node
+		key  orderKey
+	}
+	seen := make(map[dom.Node]struct{}, len(nodes))
+	roots := make(map[dom.Node]int)
+	items := make([]keyed, 0, len(nodes))
+	for _, n := range nodes {
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		k := nodeOrderKey(n)
+		if _, ok := roots[k.root]; !ok {
+			roots[k.root] = len(roots)
+		}
+		items = append(items, keyed{node: n, key: k})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return compareKeys(items[i].key, items[j].key, roots) < 0
+	})
+	ret := make([]dom.Node, len(items))
+	for i := range items {
+		ret[i] = items[i].node
+	}
+	return ret
+}
@@ -0,0 +1,581 @@
+package xpath
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+type axisType int
+
+const (
+	axisChild axisType = iota
+	axisDescendant
+	axisParent
+	axisAncestor
+	axisFollowingSibling
+	axisPrecedingSibling
+	axisFollowing
+	axisPreceding
+	axisAttribute
+	axisNamespace
+	axisSelf
+	axisDescendantOrSelf
+	axisAncestorOrSelf
+)
+
+var axisNames = map[string]axisType{
+	"child":              axisChild,
+	"descendant":         axisDescendant,
+	"parent":             axisParent,
+	"ancestor":           axisAncestor,
+	"following-sibling":  axisFollowingSibling,
+	"preceding-sibling":  axisPrecedingSibling,
+	"following":          axisFollowing,
+	"preceding":          axisPreceding,
+	"attribute":          axisAttribute,
+	"namespace":          axisNamespace,
+	"self":               axisSelf,
+	"descendant-or-self": axisDescendantOrSelf,
+	"ancestor-or-self":   axisAncestorOrSelf,
+}
+
+// isReverse returns true for axes that return nodes in reverse
+// document order
+func (a axisType) isReverse() bool {
+	switch a {
+	case axisParent, axisAncestor, axisPrecedingSibling, axisPreceding, axisAncestorOrSelf:
+		return true
+	}
+	return false
+}
+
+type nodeTestKind int
+
+const (
+	// Matches the principal node type of the axis with a name
+	testName nodeTestKind = iota
+	// node()
+	testNode
+	// text()
+	testText
+	// comment()
+	testComment
+	// processing-instruction(literal?)
+	testPI
+)
+
+type nodeTest struct {
+	kind nodeTestKind
+	// prefix and local name for name tests. If local is "*", any
+	// name in the namespace matches. If both are empty, the test is
+	// "*"
+	prefix string
+	local  string
+	// Target for processing-instruction tests
+	target string
+}
+
+type step struct {
+	axis       axisType
+	test       nodeTest
+	predicates []expr
+}
+
+// expr is a node of the expression tree
+type expr interface{}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+type negateExpr struct {
+	operand expr
+}
+
+type unionExpr struct {
+	left, right expr
+}
+
+type literalExpr struct {
+	value string
+}
+
+type numberExpr struct {
+	value float64
+}
+
+type variableExpr struct {
+	name string
+}
+
+type functionCall struct {
+	name string
+	fn   *function
+	args []expr
+}
+
+type filterExpr struct {
+	primary    expr
+	predicates []expr
+}
+
+// pathExpr is a location path, optionally starting from a filter
+// expression
+type pathExpr struct {
+	// If filter is nil and absolute is true, the path starts at
+	// the root node. If filter is nil and absolute is false, the path
+	// starts at the context node
+	filter   expr
+	absolute bool
+	steps    []*step
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(typ tokenType, what string) (token, error) {
+	t := p.next()
+	if t.typ != typ {
+		return t, syntaxError(t.pos, "Expecting %s", what)
+	}
+	return t, nil
+}
+
+func (p *parser) isOperator(name string) bool {
+	t := p.peek()
+	return t.typ == tokOperator && t.val == name
+}
+
+func parse(input string) (expr, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, syntaxError(t.pos, "Unexpected '%s'", t.val)
+	}
+	return e, nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("and") {
+		p.next()
+		right, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseEquality() (expr, error) {
+	left, err := p.parseRelational()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.typ != tokEq && t.typ != tokNeq {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseRelational()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.val, left: left, right: right}
+	}
+}
+
+func (p *parser) parseRelational() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.typ != tokLt && t.typ != tokLte && t.typ != tokGt && t.typ != tokGte {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.val, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.typ != tokPlus && t.typ != tokMinus {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.val, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*") || p.isOperator("div") || p.isOperator("mod") {
+		t := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.val, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.peek().typ == tokMinus {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateExpr{operand: operand}, nil
+	}
+	return p.parseUnion()
+}
+
+func (p *parser) parseUnion() (expr, error) {
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokPipe {
+		p.next()
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		left = &unionExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+// descendantOrSelfStep is the step that '//' abbreviates
+func descendantOrSelfStep() *step {
+	return &step{axis: axisDescendantOrSelf, test: nodeTest{kind: testNode}}
+}
+
+func (p *parser) parsePath() (expr, error) {
+	t := p.peek()
+	switch t.typ {
+	case tokVariable, tokLParen, tokLiteral, tokNumber, tokFunction:
+		primary, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		var preds []expr
+		for p.peek().typ == tokLBracket {
+			pred, err := p.parsePredicate()
+			if err != nil {
+				return nil, err
+			}
+			preds = append(preds, pred)
+		}
+		var filter expr = primary
+		if len(preds) > 0 {
+			filter = &filterExpr{primary: primary, predicates: preds}
+		}
+		next := p.peek().typ
+		if next != tokSlash && next != tokDoubleSlash {
+			return filter, nil
+		}
+		path := &pathExpr{filter: filter}
+		if err := p.parseRelativePath(path); err != nil {
+			return nil, err
+		}
+		return path, nil
+
+	case tokSlash:
+		p.next()
+		path := &pathExpr{absolute: true}
+		if !p.startsStep() {
+			return path, nil
+		}
+		s, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, s)
+		if err := p.parseRelativePath(path); err != nil {
+			return nil, err
+		}
+		return path, nil
+
+	case tokDoubleSlash:
+		path := &pathExpr{absolute: true}
+		if err := p.parseRelativePath(path); err != nil {
+			return nil, err
+		}
+		return path, nil
+	}
+	if !p.startsStep() {
+		return nil, syntaxError(t.pos, "Unexpected '%s'", t.val)
+	}
+	path := &pathExpr{}
+	s, err := p.parseStep()
+	if err != nil {
+		return nil, err
+	}
+	path.steps = append(path.steps, s)
+	if err := p.parseRelativePath(path); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+func (p *parser) startsStep() bool {
+	switch p.peek().typ {
+	case tokName, tokNodeType, tokAxis, tokAt, tokDot, tokDoubleDot:
+		return true
+	}
+	return false
+}
+
+// parseRelativePath parses ('/' Step | '//' Step)* and appends the
+// steps to path
+func (p *parser) parseRelativePath(path *pathExpr) error {
+	for {
+		switch p.peek().typ {
+		case tokSlash:
+			p.next()
+		case tokDoubleSlash:
+			p.next()
+			path.steps = append(path.steps, descendantOrSelfStep())
+		default:
+			return nil
+		}
+		s, err := p.parseStep()
+		if err != nil {
+			return err
+		}
+		path.steps = append(path.steps, s)
+	}
+}
+
+func (p *parser) parseStep() (*step, error) {
+	t := p.next()
+	switch t.typ {
+	case tokDot:
+		return &step{axis: axisSelf, test: nodeTest{kind: testNode}}, nil
+	case tokDoubleDot:
+		return &step{axis: axisParent, test: nodeTest{kind: testNode}}, nil
+	}
+	ret := &step{axis: axisChild}
+	switch t.typ {
+	case tokAt:
+		ret.axis = axisAttribute
+		t = p.next()
+	case tokAxis:
+		axis, ok := axisNames[t.val]
+		if !ok {
+			return nil, syntaxError(t.pos, "Unknown axis %s", t.val)
+		}
+		ret.axis = axis
+		if _, err := p.expect(tokDoubleColon, "'::'"); err != nil {
+			return nil, err
+		}
+		t = p.next()
+	}
+	switch t.typ {
+	case tokName:
+		ret.test = nodeTest{kind: testName}
+		if t.val != "*" {
+			if ix := strings.IndexRune(t.val, ':'); ix != -1 {
+				ret.test.prefix = t.val[:ix]
+				ret.test.local = t.val[ix+1:]
+			} else {
+				ret.test.local = t.val
+			}
+		}
+	case tokNodeType:
+		if _, err := p.expect(tokLParen, "'('"); err != nil {
+			return nil, err
+		}
+		switch t.val {
+		case "node":
+			ret.test.kind = testNode
+		case "text":
+			ret.test.kind = testText
+		case "comment":
+			ret.test.kind = testComment
+		case "processing-instruction":
+			ret.test.kind = testPI
+			if p.peek().typ == tokLiteral {
+				ret.test.target = p.next().val
+			}
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, syntaxError(t.pos, "Expecting node test")
+	}
+	for p.peek().typ == tokLBracket {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return nil, err
+		}
+		ret.predicates = append(ret.predicates, pred)
+	}
+	return ret, nil
+}
+
+func (p *parser) parsePredicate() (expr, error) {
+	if _, err := p.expect(tokLBracket, "'['"); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokRBracket, "']'"); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.typ {
+	case tokVariable:
+		return &variableExpr{name: t.val}, nil
+	case tokLiteral:
+		return &literalExpr{value: t.val}, nil
+	case tokNumber:
+		return &numberExpr{value: t.num}, nil
+	case tokLParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case tokFunction:
+		fn, ok := coreFunctions[t.val]
+		if !ok {
+			return nil, syntaxError(t.pos, "Unknown function %s", t.val)
+		}
+		call := &functionCall{name: t.val, fn: fn}
+		if _, err := p.expect(tokLParen, "'('"); err != nil {
+			return nil, err
+		}
+		if p.peek().typ != tokRParen {
+			for {
+				arg, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+				if p.peek().typ != tokComma {
+					break
+				}
+				p.next()
+			}
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		if len(call.args) < fn.minArgs || (fn.maxArgs >= 0 && len(call.args) > fn.maxArgs) {
+			return nil, syntaxError(t.pos, "Wrong number of arguments to %s", t.val)
+		}
+		return call, nil
+	}
+	return nil, syntaxError(t.pos, "Unexpected '%s'", t.val)
+}
+
+// parseNumber converts a string to a number using the XPath rules:
+// optional whitespace, an optional minus sign, a Number, and
+// optional whitespace. Anything else is NaN.
+func parseNumber(s string) float64 {
+	s = strings.Trim(s, " \t\r\n")
+	body := s
+	if strings.HasPrefix(body, "-") {
+		body = body[1:]
+	}
+	if len(body) == 0 {
+		return math.NaN()
+	}
+	digits := 0
+	dot := false
+	for _, r := range body {
+		switch {
+		case isDigit(r):
+			digits++
+		case r == '.' && !dot:
+			dot = true
+		default:
+			return math.NaN()
+		}
+	}
+	if digits == 0 {
+		return math.NaN()
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
@@ -0,0 +1,193 @@
+// Package xpath implements XPath 1.0 expressions over dom.Node trees.
+//
+// The DOM does not have namespace nodes, so the namespace axis
+// returns the xmlns attributes that declare the namespaces in scope
+// for an element.
+package xpath
+
+import (
+	"github.com/bserdar/go-dom"
+)
+
+// ResultType is the type of the value an expression evaluates to
+type ResultType int
+
+const (
+	NodeSetResult ResultType = iota
+	StringResult
+	NumberResult
+	BooleanResult
+)
+
+// Context contains the namespace and variable bindings used during
+// evaluation
+type Context struct {
+	// Namespaces maps prefixes to namespace URIs. If nil, prefixes
+	// are resolved using the LookupNamespaceURI method of the
+	// context node.
+	Namespaces map[string]string
+
+	// DefaultNamespace is the namespace of the unprefixed element
+	// names in name tests. XPath 1.0 expressions use "", but some
+	// languages that embed XPath, such as XML Patch, use the default
+	// namespace in scope.
+	DefaultNamespace string
+
+	// Variables contains the values of variables. A variable value
+	// can be a string, a number (any Go integer or float type), a
+	// bool, a []dom.Node, or a dom.Node.
+	Variables map[string]interface{}
+}
+
+// Expr is a compiled XPath expression. An Expr can be evaluated
+// concurrently.
+type Expr struct {
+	source string
+	root   expr
+}
+
+// Compile parses an XPath expression
+func Compile(expression string) (*Expr, error) {
+	root, err := parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{source: expression, root: root}, nil
+}
+
+// MustCompile parses an XPath expression, and panics if there is an
+// error
+func MustCompile(expression string) *Expr {
+	ret, err := Compile(expression)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string { return e.source }
+
+// Evaluate evaluates the expression using node as the context
+// node. ctx can be nil.
+func (e *Expr) Evaluate(node dom.Node, ctx *Context) (Result, error) {
+	env := &environment{resolver: node}
+	if ctx != nil {
+		env.namespaces = ctx.Namespaces
+		env.defaultNamespace = ctx.DefaultNamespace
+		if len(ctx.Variables) > 0 {
+			env.variables = make(map[string]interface{}, len(ctx.Variables))
+			for k, v := range ctx.Variables {
+				env.variables[k] = convertVariable(v)
+			}
+		}
+	}
+	ec := &evalContext{node: node, position: 1, size: 1, env: env}
+	v, err := ec.eval(e.root)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{value: v}, nil
+}
+
+// Select evaluates the expression and returns the resulting
+// node-set. If the expression does not evaluate to a node-set, it
+// returns an error.
+func (e *Expr) Select(node dom.Node, ctx *Context) ([]dom.Node, error) {
+	result, err := e.Evaluate(node, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.Type() != NodeSetResult {
+		return nil, typeError("Expression does not evaluate to a node-set")
+	}
+	return result.Nodes(), nil
+}
+
+// Evaluate compiles and evaluates an expression
+func Evaluate(expression string, node dom.Node, ctx *Context) (Result, error) {
+	e, err := Compile(expression)
+	if err != nil {
+		return Result{}, err
+	}
+	return e.Evaluate(node, ctx)
+}
+
+// Select compiles and evaluates an expression that returns a
+// node-set
+func Select(expression string, node dom.Node, ctx *Context) ([]dom.Node, error) {
+	e, err := Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return e.Select(node, ctx)
+}
+
+func convertVariable(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string, float64, bool, []dom.Node:
+		return t
+	case dom.Node:
+		return []dom.Node{t}
+	case int:
+		return float64(t)
+	case int8:
+		return float64(t)
+	case int16:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint:
+		return float64(t)
+	case uint8:
+		return float64(t)
+	case uint16:
+		return float64(t)
+	case uint32:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	}
+	return v
+}
+
+// Result is the value of an evaluated expression
+type Result struct {
+	value interface{}
+}
+
+// Type returns the type of the result
+func (r Result) Type() ResultType {
+	switch r.value.(type) {
+	case string:
+		return StringResult
+	case float64:
+		return NumberResult
+	case bool:
+		return BooleanResult
+	}
+	return NodeSetResult
+}
+
+// Nodes returns the nodes of a node-set result in document order. If
+// the result is not a node-set, returns nil.
+func (r Result) Nodes() []dom.Node {
+	nodes, _ := r.value.([]dom.Node)
+	return nodes
+}
+
+// String converts the result to a string using the XPath string()
+// function
+func (r Result) String() string { return toString(r.value) }
+
+// Number converts the result to a number using the XPath number()
+// function
+func (r Result) Number() float64 { return toNumber(r.value) }
+
+// Boolean converts the result to a boolean using the XPath boolean()
+// function
+func (r Result) Boolean() bool { return toBoolean(r.value) }
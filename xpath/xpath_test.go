@@ -0,0 +1,201 @@
+package xpath
+
+import (
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bserdar/go-dom"
+)
+
+const testDoc = `<library xmlns:b="http://example.org/book">
+<b:book id="b1" year="1999" xml:lang="en-US">
+  <title>First</title>
+  <price>10</price>
+</b:book>
+<b:book id="b2" year="2005">
+  <title>Second</title>
+  <price>25.5</price>
+  <!--note-->
+</b:book>
+<magazine id="m1"><title>Third</title><price>4</price></magazine>
+<?proc data?>
+</library>`
+
+func parseDoc(t *testing.T, input string) dom.Document {
+	doc, err := dom.Parse(xml.NewDecoder(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func names(nodes []dom.Node) string {
+	s := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if el, ok := n.(dom.Element); ok {
+			if id, ok := el.GetAttribute("id"); ok {
+				s = append(s, n.GetNodeName()+"#"+id)
+				continue
+			}
+		}
+		s = append(s, n.GetNodeName())
+	}
+	return strings.Join(s, ",")
+}
+
+func TestSelect(t *testing.T) {
+	doc := parseDoc(t, testDoc)
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"/library/b:book", "b:book#b1,b:book#b2"},
+		{"//title", "title,title,title"},
+		{"//b:book[@year > 2000]", "b:book#b2"},
+		{"//*[price < 20]", "b:book#b1,magazine#m1"},
+		{"/library/*[last()]", "magazine#m1"},
+		{"/library/*[position() = 2]", "b:book#b2"},
+		{"//title[. = 'Second']/..", "b:book#b2"},
+		{"//magazine/preceding-sibling::*", "b:book#b1,b:book#b2"},
+		{"//magazine/preceding-sibling::*[1]", "b:book#b2"},
+		{"//price/ancestor::*", "library,b:book#b1,b:book#b2,magazine#m1"},
+		{"//b:book[1]/following::title", "title,title"},
+		{"//b:book[2]/preceding::price", "price"},
+		{"//comment()", "#comment"},
+		{"//processing-instruction('proc')", "proc"},
+		{"//b:*[@id='b1'] | //magazine", "b:book#b1,magazine#m1"},
+		{"//@id", "id,id,id"},
+		{"id('m1 b2')", "b:book#b2,magazine#m1"},
+		{"//*[lang('en')]", "b:book#b1,title,price"},
+		{"/library/node()[self::magazine]", "magazine#m1"},
+		{"//title[starts-with(., 'T')]/ancestor-or-self::*[2]", "magazine#m1"},
+		{"(//title)[2]", "title"},
+	}
+	for _, test := range tests {
+		nodes, err := Select(test.expr, doc, nil)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if got := names(nodes); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.expr, test.expected, got)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	doc := parseDoc(t, testDoc)
+	strs := map[string]string{
+		"string(//b:book[2]/title)":                "Second",
+		"concat('a', 'b', 1)":                      "ab1",
+		"substring('12345', 1.5, 2.6)":             "234",
+		"substring('12345', 0, 3)":                 "12",
+		"substring-before('1999/04/01', '/')":      "1999",
+		"substring-after('1999/04/01', '/')":       "04/01",
+		"normalize-space('  a   b  ')":             "a b",
+		"translate('--aaa--', 'abc-', 'ABC')":      "AAA",
+		"local-name(//b:book)":                     "book",
+		"namespace-uri(//b:book)":                  "http://example.org/book",
+		"name(/library/*[2])":                      "b:book",
+		"string(sum(//price))":                     "39.5",
+		"string(1 div 0)":                          "Infinity",
+		"string(0 div 0)":                          "NaN",
+		"string(-3 mod 2)":                         "-1",
+		"string(round(2.5))":                       "3",
+		"string(round(-2.5))":                      "-2",
+		"string(count(//*))":                       "10",
+		"string(floor(-1.5) + ceiling(1.2))":       "0",
+		"string(//b:book[1]/@year)":                "1999",
+		"string(count(//title[1]/namespace::*))":   "1",
+		"string(string-length('äbc'))":             "3",
+		"string(boolean(//nothing) or not(false))": "true",
+	}
+	for expr, expected := range strs {
+		result, err := Evaluate(expr, doc, nil)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if result.String() != expected {
+			t.Errorf("%s: expected %s, got %s", expr, expected, result.String())
+		}
+	}
+
+	result, err := Evaluate("//price > 20 and //price = 4", doc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Type() != BooleanResult || !result.Boolean() {
+		t.Errorf("Wrong result: %v", result)
+	}
+	result, _ = Evaluate("number('abc')", doc, nil)
+	if !math.IsNaN(result.Number()) {
+		t.Errorf("Expected NaN")
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	doc := parseDoc(t, testDoc)
+	nodes, err := Select("//x:book", doc, &Context{Namespaces: map[string]string{"x": "http://example.org/book"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Errorf("Wrong result: %s", names(nodes))
+	}
+	// Prefix resolved using the context node
+	nodes, err = Select("b:book", doc.GetDocumentElement(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Errorf("Wrong result: %s", names(nodes))
+	}
+	if _, err := Select("//y:book", doc, nil); err == nil {
+		t.Errorf("Expected error")
+	}
+	// Unprefixed names in the default namespace
+	nodes, err = Select("//book", doc, &Context{DefaultNamespace: "http://example.org/book"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Errorf("Wrong result: %s", names(nodes))
+	}
+}
+
+func TestVariables(t *testing.T) {
+	doc := parseDoc(t, testDoc)
+	nodes, err := Select("//*[@id = $id]", doc, &Context{Variables: map[string]interface{}{"id": "b2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names(nodes) != "b:book#b2" {
+		t.Errorf("Wrong result: %s", names(nodes))
+	}
+	nodes, err = Select("$n/title", doc, &Context{Variables: map[string]interface{}{"n": nodes[0]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 {
+		t.Errorf("Wrong result: %s", names(nodes))
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	for _, expr := range []string{"", "//", "a[", "foo(", "1 +", "a b", "unknown()", "@", "'abc", "child::", "bad-axis::a", "count()"} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("%s: expected error", expr)
+		} else if e, ok := err.(dom.ErrDOM); !ok || e.Typ != dom.SYNTAX_ERR {
+			t.Errorf("%s: unexpected error %v", expr, err)
+		}
+	}
+	// Operator disambiguation
+	for _, expr := range []string{"div div div", "* * *", "a/*", "@*", "-2 mod -3", "a|b", "$x*2"} {
+		if _, err := Compile(expr); err != nil {
+			t.Errorf("%s: %v", expr, err)
+		}
+	}
+}